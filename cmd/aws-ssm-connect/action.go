@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+// Actions performed once an instance has been resolved.
+const (
+	actionShell   = "shell"
+	actionPrint   = "print"
+	actionForward = "forward"
+	actionRun     = "run"
+	actionSocks   = "socks"
+	actionSession = "print-session"
+	actionURL     = "url"
+	actionMenu    = "menu"
+)
+
+// resolveAction picks the action from --action, falling back to the configured
+// default and then to an interactive shell, and validates its companion flags.
+func resolveAction(defaultAction string) (string, error) {
+	action := actionFlag
+	if action == "" && socksPort != 0 {
+		action = actionSocks
+	}
+	if action == "" && printSession {
+		action = actionSession
+	}
+	if action == "" && openURL != "" {
+		action = actionURL
+	}
+	if action == "" && selectOnly {
+		action = actionPrint
+	}
+	if action == "" && menuFlag {
+		action = actionMenu
+	}
+	if action == "" {
+		action = defaultAction
+	}
+	if action == "" {
+		action = actionShell
+	}
+
+	switch action {
+	case actionShell, actionPrint, actionSession, actionMenu:
+	case actionURL:
+		if openURL == "" {
+			openURL = ssm.ConsoleEC2
+		}
+		if err := ssm.ValidateConsolePage(openURL); err != nil {
+			return "", fmt.Errorf("--open-url: %w", err)
+		}
+	case actionForward:
+		if portFlag == "" {
+			return "", fmt.Errorf("action %q requires --port (port or local:remote)", action)
+		}
+		if _, _, err := ssm.ParsePortSpec(portFlag); err != nil {
+			return "", err
+		}
+	case actionRun:
+		if commandFlag == "" {
+			return "", fmt.Errorf("action %q requires --command", action)
+		}
+	case actionSocks:
+		if socksPort < 1 || socksPort > 65535 {
+			return "", fmt.Errorf("action %q requires --socks <port> (1-65535)", action)
+		}
+	default:
+		return "", fmt.Errorf("invalid action %q (expected shell, print, forward, run, socks, print-session, url or menu)", action)
+	}
+	if execFlag != "" && action != actionShell {
+		return "", fmt.Errorf("--exec only applies to the shell action, not %q", action)
+	}
+	if shellFlag != "" && action != actionShell {
+		return "", fmt.Errorf("--shell only applies to the shell action, not %q", action)
+	}
+	if stdioFlag && action != actionShell {
+		return "", fmt.Errorf("--stdio only applies to the shell action, not %q", action)
+	}
+	if stdioFlag && (execFlag != "" || shellFlag != "" || viaFlag != "") {
+		return "", fmt.Errorf("--stdio cannot be combined with --exec, --shell or --via")
+	}
+	if selectOnly && action != actionPrint {
+		return "", fmt.Errorf("--select-only cannot be combined with action %q", action)
+	}
+	if withName && action != actionPrint {
+		return "", fmt.Errorf("--with-name only applies to --select-only or the print action")
+	}
+	if viaFlag != "" && action != actionShell {
+		return "", fmt.Errorf("--via only applies to the shell action, not %q", action)
+	}
+	if viaFlag != "" && (execFlag != "" || shellFlag != "") {
+		return "", fmt.Errorf("--exec and --shell cannot be combined with --via")
+	}
+	return action, nil
+}
+
+// runAction performs the resolved action against the selected instance,
+// using the client's profile for any session it starts.
+func runAction(ctx context.Context, client *ssm.Client, action, instanceID, instanceName string) error {
+	targetInstance = instanceID
+	if action == actionMenu {
+		// Each menu entry runs its own action, with its own checks
+		return runMenu(ctx, client, instanceID, instanceName)
+	}
+	if action != actionPrint && action != actionURL && action != actionRun {
+		if err := checkReason(client.Profile()); err != nil {
+			return err
+		}
+	}
+	if action != actionPrint && action != actionURL {
+		if err := confirmProtected(ctx, client, instanceID); err != nil {
+			return err
+		}
+	}
+
+	switch action {
+	case actionPrint:
+		if withName && instanceName != "" {
+			fmt.Printf("%s\t%s\n", instanceID, instanceName)
+		} else {
+			fmt.Println(instanceID)
+		}
+		return nil
+	case actionURL:
+		return printConsoleURL(client, instanceID, openURL, true)
+	case actionForward:
+		local, remote, err := ssm.ParsePortSpec(portFlag)
+		if err != nil {
+			return err
+		}
+		return client.StartPortForward(ctx, instanceID, instanceName, client.Profile(), local, remote)
+	case actionRun:
+		if err := confirmRun(client, instanceID, instanceName, commandFlag); err != nil {
+			return err
+		}
+		return client.RunCommand(ctx, instanceID, commandFlag)
+	case actionSocks:
+		return client.StartSOCKSProxy(ctx, instanceID, instanceName, client.Profile(), sshUser, socksPort)
+	case actionSession:
+		sess, err := client.CreateSession(ctx, instanceID, client.Profile())
+		if err != nil {
+			return err
+		}
+		out := newOutput()
+		out.Warning("The session token is short-lived and grants shell access; do not log or share it")
+		return out.JSON("session", sess)
+	default:
+		if viaFlag != "" {
+			return connectVia(ctx, client, instanceID)
+		}
+		return client.StartSession(ctx, instanceID, instanceName, client.Profile())
+	}
+}
+
+// connectVia opens an SSH session to the target through the --via bastion.
+// Both ends are resolved up front so a typo fails before any session starts.
+func connectVia(ctx context.Context, client *ssm.Client, targetID string) error {
+	bastion, err := client.FindInstance(ctx, viaFlag)
+	if err != nil {
+		return fmt.Errorf("bastion: %w", err)
+	}
+	target, err := client.FindInstance(ctx, targetID)
+	if err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+	if target.PrivateIP == "" {
+		return fmt.Errorf("target %s has no private IP to reach through the bastion", target.ID)
+	}
+	if target.ID == bastion.ID {
+		return fmt.Errorf("target and bastion are the same instance (%s)", target.ID)
+	}
+	return client.StartSSHVia(ctx, bastion.ID, bastion.Name, target.PrivateIP, target.Name, client.Profile(), sshUser)
+}
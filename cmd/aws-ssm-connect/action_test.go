@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+// resetActionFlags clears the flags resolveAction reads, now and after the test.
+func resetActionFlags(t *testing.T) {
+	reset := func() {
+		actionFlag, portFlag, commandFlag, openURL = "", "", "", ""
+		execFlag, shellFlag, viaFlag = "", "", ""
+		socksPort = 0
+		printSession, selectOnly, menuFlag, stdioFlag, withName = false, false, false, false, false
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestResolveAction(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultAction string
+		set           func()
+		want          string
+		wantErr       string
+	}{
+		{"shell by default", "", func() {}, actionShell, ""},
+		{"configured default", actionPrint, func() {}, actionPrint, ""},
+		{"flag over default", actionPrint, func() { actionFlag = actionShell }, actionShell, ""},
+		{"forward", "", func() { actionFlag, portFlag = actionForward, "8080:80" }, actionForward, ""},
+		{"default forward needs --port", actionForward, func() {}, "", "requires --port"},
+		{"forward bad port", "", func() { actionFlag, portFlag = actionForward, "99999" }, "", "invalid port"},
+		{"run", "", func() { actionFlag, commandFlag = actionRun, "uptime" }, actionRun, ""},
+		{"run needs --command", "", func() { actionFlag = actionRun }, "", "requires --command"},
+		{"unknown", "", func() { actionFlag = "reboot" }, "", `invalid action "reboot"`},
+		{"--socks implies socks", "", func() { socksPort = 1080 }, actionSocks, ""},
+		{"--select-only implies print", actionForward, func() { selectOnly = true }, actionPrint, ""},
+		{"--select-only with another action", "", func() { selectOnly, actionFlag, commandFlag = true, actionRun, "ls" }, "", "--select-only cannot be combined"},
+		{"--with-name needs print", "", func() { withName = true }, "", "--with-name only applies"},
+		{"--with-name with --select-only", "", func() { withName, selectOnly = true, true }, actionPrint, ""},
+		{"--exec needs shell", "", func() { actionFlag, execFlag = actionPrint, "ls" }, "", "--exec only applies"},
+		{"--shell", "", func() { shellFlag = "zsh" }, actionShell, ""},
+		{"--shell needs shell", "", func() { actionFlag, shellFlag = actionPrint, "zsh" }, "", "--shell only applies"},
+		{"--shell with --via", "", func() { shellFlag, viaFlag = "zsh", "bastion" }, "", "cannot be combined with --via"},
+		{"--stdio with --via", "", func() { stdioFlag, viaFlag = true, "bastion" }, "", "--stdio cannot be combined"},
+	}
+	for _, tt := range tests {
+		resetActionFlags(t)
+		tt.set()
+		got, err := resolveAction(tt.defaultAction)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: resolveAction() error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: resolveAction() = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestRunActionPrint(t *testing.T) {
+	resetActionFlags(t)
+	client := fakeSSMClient(t, ssm.Options{}, func(string, map[string]any) any { return map[string]any{} })
+	tests := []struct {
+		withName bool
+		want     string
+	}{
+		{false, "i-123\n"},
+		{true, "i-123\tweb-1\n"},
+	}
+	for _, tt := range tests {
+		withName = tt.withName
+		var err error
+		stdout, _ := captureOutput(t, func() {
+			err = runAction(context.Background(), client, actionPrint, "i-123", "web-1")
+		})
+		if err != nil || stdout != tt.want {
+			t.Errorf("print with name %t: got %q, %v; want %q", tt.withName, stdout, err, tt.want)
+		}
+	}
+}
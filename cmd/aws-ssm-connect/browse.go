@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+	"github.com/e/aws-ssm-connect/internal/selector"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+// handleInteractiveCopy browses a remote directory in the finder and
+// downloads the picked file: --interactive-copy instance[:dir] [local].
+// Picking a directory (or "..") lists it instead.
+func handleInteractiveCopy(ctx context.Context, client *ssm.Client, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf("usage: aws-ssm-connect --interactive-copy <instance>[:/dir] [local path]")
+	}
+	instance, dir := parseRemotePath(args[0], anyInstance)
+	if instance == "" {
+		instance = args[0]
+	}
+	local := "."
+	if len(args) == 2 {
+		local = args[1]
+	}
+
+	instanceID, dir, err := resolveRemote(ctx, client, instance, dir)
+	if err != nil {
+		return err
+	}
+
+	out := newOutput()
+	for {
+		abs, entries, truncated, err := client.ListDir(ctx, instanceID, dir)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return fmt.Errorf("%s is empty", abs)
+		}
+		if truncated {
+			out.Warning("%s has more than %d entries; only the first %d are shown", abs, ssm.MaxDirEntries, ssm.MaxDirEntries)
+		}
+
+		picked, err := pickEntry(client, abs, entries)
+		if err != nil {
+			return err
+		}
+		if !picked.Dir {
+			return handleCopy(ctx, client, []string{instanceID + ":" + path.Join(abs, picked.Name), local})
+		}
+		dir = path.Join(abs, picked.Name)
+	}
+}
+
+// pickEntry shows the entries of directory dir in the finder, each name
+// with its mode and, for files, its size.
+func pickEntry(client *ssm.Client, dir string, entries []ssm.DirEntry) (ssm.DirEntry, error) {
+	i, err := selector.Select(entryItems(entries), selector.ListOptions{
+		Profile: client.Profile(),
+		Region:  client.Region(),
+		Label:   dir,
+		Inline:  inlineRows,
+	})
+	if err != nil {
+		return ssm.DirEntry{}, err
+	}
+	return entries[i], nil
+}
+
+// entryItems lists directory entries for the finder, directories with a
+// trailing slash.
+func entryItems(entries []ssm.DirEntry) []selector.Item {
+	items := make([]selector.Item, len(entries))
+	for i, e := range entries {
+		items[i] = selector.Item{Key: e.Name, Label: e.Mode + "  " + output.FormatBytes(e.Size)}
+		if e.Dir {
+			items[i] = selector.Item{Key: e.Name + "/", Label: e.Mode}
+		}
+	}
+	return items
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/e/aws-ssm-connect/internal/daemon"
+	"github.com/e/aws-ssm-connect/internal/output"
+	"github.com/e/aws-ssm-connect/internal/update"
+)
+
+var (
+	clearInstances bool
+	clearVersion   bool
+	clearAll       bool
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Show or clear cached state (instance discovery, update check)",
+}
+
+// cacheEntry describes one cache for 'cache info'.
+type cacheEntry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Bytes is the file size of file caches; Entries the number of profile
+	// and region entries the daemon holds.
+	Bytes   int64     `json:"bytes,omitempty"`
+	Entries int       `json:"entries,omitempty"`
+	Updated time.Time `json:"updated_at,omitzero"`
+	Present bool      `json:"present"`
+}
+
+var cacheInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show what is cached, with sizes and ages",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		instances, err := instanceCacheInfo(cmd)
+		if err != nil {
+			return err
+		}
+		version, err := versionCacheInfo()
+		if err != nil {
+			return err
+		}
+		entries := []cacheEntry{instances, version}
+
+		if jsonFlag {
+			return newOutput().JSON("cache", entries)
+		}
+		for _, e := range entries {
+			switch {
+			case !e.Present && e.Name == "instances":
+				fmt.Printf("%-10s not cached (no 'serve' daemon running)\n", e.Name)
+			case !e.Present:
+				fmt.Printf("%-10s not cached\n", e.Name)
+			case e.Name == "instances":
+				fmt.Printf("%-10s %d entries in the daemon on %s%s\n", e.Name, e.Entries, e.Path, cacheAge("oldest fetched", e.Updated))
+			default:
+				fmt.Printf("%-10s %s in %s%s\n", e.Name, output.FormatBytes(e.Bytes), e.Path, cacheAge("written", e.Updated))
+			}
+		}
+		return nil
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Clear cached instance discovery (--instances), the update check (--version) or both (--all)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !clearInstances && !clearVersion && !clearAll {
+			return fmt.Errorf("choose what to clear: --instances, --version or --all")
+		}
+
+		out := newOutput()
+		if clearInstances || clearAll {
+			socket, err := daemon.SocketPath()
+			if err != nil {
+				return err
+			}
+			n, err := daemon.NewClient(socket).Flush(cmd.Context())
+			switch {
+			case errors.Is(err, daemon.ErrNotRunning):
+				out.Info("No 'serve' daemon running; no instances are cached")
+			case err != nil:
+				return err
+			default:
+				out.Success("Cleared %d cached instance lists", n)
+			}
+		}
+		if clearVersion || clearAll {
+			path, err := update.CachePath()
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			out.Success("Cleared the update check cache")
+		}
+		return nil
+	},
+}
+
+// instanceCacheInfo asks a running daemon how much discovery it caches.
+func instanceCacheInfo(cmd *cobra.Command) (cacheEntry, error) {
+	socket, err := daemon.SocketPath()
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	e := cacheEntry{Name: "instances", Path: socket}
+	n, oldest, err := daemon.NewClient(socket).Stats(cmd.Context())
+	if errors.Is(err, daemon.ErrNotRunning) {
+		return e, nil
+	}
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	e.Entries, e.Updated, e.Present = n, oldest, true
+	return e, nil
+}
+
+// versionCacheInfo describes the update check cache file.
+func versionCacheInfo() (cacheEntry, error) {
+	path, err := update.CachePath()
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	e := cacheEntry{Name: "version", Path: path}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	e.Bytes, e.Updated, e.Present = info.Size(), info.ModTime(), true
+	return e, nil
+}
+
+// cacheAge formats how long ago a cache was filled, if known.
+func cacheAge(what string, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf(", %s %s ago", what, time.Since(t).Round(time.Second))
+}
+
+func init() {
+	cacheClearCmd.Flags().BoolVar(&clearInstances, "instances", false, "Drop the instance lists cached by 'serve'")
+	cacheClearCmd.Flags().BoolVar(&clearVersion, "version", false, "Forget the cached latest release")
+	cacheClearCmd.Flags().BoolVar(&clearAll, "all", false, "Clear every cache")
+	cacheCmd.AddCommand(cacheInfoCmd, cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/daemon"
+	"github.com/e/aws-ssm-connect/internal/paths"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+	"github.com/e/aws-ssm-connect/internal/update"
+)
+
+// startDaemon runs a daemon on the default socket that caches one entry
+// per lookup, and returns a client for it.
+func startDaemon(t *testing.T) *daemon.Client {
+	t.Helper()
+	socket, err := daemon.SocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := daemon.Listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	fetch := func(_ context.Context, profile, region string) ([]ssm.Instance, error) {
+		return []ssm.Instance{{ID: "i-1"}}, nil
+	}
+	go func() { done <- daemon.NewServer(fetch, time.Hour, t.Logf).Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return daemon.NewClient(socket)
+}
+
+func TestCacheClear(t *testing.T) {
+	defer func(i, v, a bool) { clearInstances, clearVersion, clearAll = i, v, a }(clearInstances, clearVersion, clearAll)
+
+	tests := []struct {
+		name                string
+		instances, version  bool
+		all                 bool
+		daemon              bool
+		wantInstancesKept   bool
+		wantVersionKept     bool
+		wantErr, wantOutput string
+	}{
+		{"instances only", true, false, false, true, false, true, "", "Cleared 2 cached instance lists"},
+		{"version only", false, true, false, true, true, false, "", "Cleared the update check cache"},
+		{"all", false, false, true, true, false, false, "", "Cleared 2 cached instance lists"},
+		{"nothing chosen", false, false, false, true, true, true, "choose what to clear", ""},
+		// Without a daemon there is nothing to flush, which is fine
+		{"no daemon", true, false, false, false, false, true, "", "No 'serve' daemon running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(paths.HomeEnv, t.TempDir())
+			clearInstances, clearVersion, clearAll = tt.instances, tt.version, tt.all
+
+			versionFile, err := update.CachePath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(versionFile, []byte(`{"latest":"v1.2.3"}`), 0600); err != nil {
+				t.Fatal(err)
+			}
+			var client *daemon.Client
+			if tt.daemon {
+				client = startDaemon(t)
+				for _, region := range []string{"us-east-1", "eu-west-1"} {
+					if _, err := client.Instances(context.Background(), "prod", region); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			cacheClearCmd.SetContext(context.Background())
+			stdout, _ := captureOutput(t, func() { err = cacheClearCmd.RunE(cacheClearCmd, nil) })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("cache clear error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(stdout, tt.wantOutput) {
+				t.Errorf("output = %q, want %q", stdout, tt.wantOutput)
+			}
+
+			if _, err := os.Stat(versionFile); errors.Is(err, os.ErrNotExist) == tt.wantVersionKept {
+				t.Errorf("update check cache kept = %t, want %t", err == nil, tt.wantVersionKept)
+			}
+			if client != nil {
+				entries, _, err := client.Stats(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if kept := entries > 0; kept != tt.wantInstancesKept {
+					t.Errorf("daemon has %d entries, want kept %t", entries, tt.wantInstancesKept)
+				}
+			}
+		})
+	}
+}
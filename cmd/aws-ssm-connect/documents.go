@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+var (
+	documentType  string
+	documentCheck string
+)
+
+var documentsCmd = &cobra.Command{
+	Use:   "documents <name|id>...",
+	Short: "List SSM documents that support an instance's platform, or check one",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+
+		inst, err := client.FindInstance(ctx, withQueryNames(args)...)
+		if err != nil {
+			return err
+		}
+
+		if documentCheck != "" {
+			if err := client.CheckDocument(ctx, inst.ID, documentCheck); err != nil {
+				return err
+			}
+			newOutput().Success("%s supports %s", documentCheck, inst.ID)
+			return nil
+		}
+
+		docs, err := client.DocumentsFor(ctx, inst.ID, documentType)
+		if err != nil {
+			return err
+		}
+		if jsonFlag {
+			if docs == nil {
+				docs = []ssm.Document{}
+			}
+			return newOutput().JSON("documents", docs)
+		}
+		for _, d := range docs {
+			fmt.Printf("%s\t%s\t%s\n", d.Name, d.Owner, strings.Join(d.Platforms, ","))
+		}
+		return nil
+	},
+}
+
+func init() {
+	documentsCmd.Flags().StringVar(&documentType, "type", "Command", "Document type to list (Command, Session, Automation, ...; empty for all)")
+	documentsCmd.Flags().StringVar(&documentCheck, "check", "", "Check that this document supports the instance's platform instead of listing")
+	rootCmd.AddCommand(documentsCmd)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/e/aws-ssm-connect/internal/selector"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+var (
+	ecsCluster   string
+	ecsService   string
+	ecsContainer string
+	ecsCommand   string
+)
+
+var ecsCmd = &cobra.Command{
+	Use:   "ecs [filter...]",
+	Short: "Open an ECS Exec session in a running ECS task",
+	Long: `Open an interactive ECS Exec session in a running ECS task.
+
+The cluster, task and container are picked with the fuzzy finder when
+there is more than one candidate. Filter words narrow down the tasks
+by task ID or service name. The task must have ECS Exec enabled.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		cluster := ecsCluster
+		if cluster == "" {
+			clusters, err := client.ListECSClusters(ctx)
+			if err != nil {
+				return err
+			}
+			items := make([]selector.Item, len(clusters))
+			for i, name := range clusters {
+				items[i] = selector.Item{Key: name}
+			}
+			i, err := pick(client, items, "ECS clusters")
+			if err != nil {
+				return err
+			}
+			cluster = clusters[i]
+		}
+
+		tasks, err := client.ListECSTasks(ctx, cluster, ecsService)
+		if err != nil {
+			return err
+		}
+		byID := make(map[string]ssm.ECSTask, len(tasks))
+		items := make([]selector.Item, 0, len(tasks))
+		for _, t := range tasks {
+			byID[t.TaskID] = t
+			name := t.Service()
+			if name == "" {
+				name = t.Group
+			}
+			items = append(items, selector.Item{Key: t.TaskID, Label: name})
+		}
+		if len(args) > 0 {
+			items = selector.FilterItems(items, strings.Join(args, " "))
+		}
+		i, err := pick(client, items, "running tasks in "+cluster)
+		if err != nil {
+			return err
+		}
+		task := byID[items[i].Key]
+
+		container, err := pickContainer(client, task)
+		if err != nil {
+			return err
+		}
+
+		return client.ExecECS(ctx, task, container, ecsCommand, client.Profile())
+	},
+}
+
+// pick returns the index of the only item, or lets the user choose when
+// there are several. what names the items in errors and the finder header.
+func pick(client *ssm.Client, items []selector.Item, what string) (int, error) {
+	switch len(items) {
+	case 0:
+		return 0, fmt.Errorf("no %s found", what)
+	case 1:
+		return 0, nil
+	}
+	return selector.Select(items, selector.ListOptions{
+		Profile: client.Profile(),
+		Region:  client.Region(),
+		Label:   what,
+		Inline:  inlineRows,
+	})
+}
+
+// pickContainer chooses the container to exec into, honoring --container.
+func pickContainer(client *ssm.Client, task ssm.ECSTask) (ssm.ECSContainer, error) {
+	var containers []ssm.ECSContainer
+	var items []selector.Item
+	for _, c := range task.Containers {
+		if ecsContainer != "" && c.Name != ecsContainer {
+			continue
+		}
+		containers = append(containers, c)
+		items = append(items, selector.Item{Key: c.Name})
+	}
+
+	i, err := pick(client, items, "matching containers in task "+task.TaskID)
+	if err != nil {
+		return ssm.ECSContainer{}, err
+	}
+	return containers[i], nil
+}
+
+func init() {
+	ecsCmd.Flags().StringVar(&ecsCluster, "cluster", "", "ECS cluster (default: pick interactively)")
+	ecsCmd.Flags().StringVar(&ecsService, "service", "", "Only list tasks of this service")
+	ecsCmd.Flags().StringVar(&ecsContainer, "container", "", "Container name (default: pick when the task has several)")
+	ecsCmd.Flags().StringVar(&ecsCommand, "command", "/bin/sh", "Command to run in the container")
+	rootCmd.AddCommand(ecsCmd)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+func TestPickContainer(t *testing.T) {
+	defer func(v string) { ecsContainer = v }(ecsContainer)
+
+	task := ssm.ECSTask{TaskID: "t1", Containers: []ssm.ECSContainer{
+		{Name: "app", RuntimeID: "rt-app"},
+		{Name: "sidecar", RuntimeID: "rt-sidecar"},
+	}}
+	single := ssm.ECSTask{TaskID: "t2", Containers: []ssm.ECSContainer{{Name: "app", RuntimeID: "rt-2"}}}
+
+	tests := []struct {
+		name      string
+		task      ssm.ECSTask
+		container string
+		want      string
+		wantErr   string
+	}{
+		{"only container", single, "", "rt-2", ""},
+		{"--container picks one of several", task, "sidecar", "rt-sidecar", ""},
+		{"--container matches nothing", task, "db", "", "no matching containers in task t1"},
+	}
+	for _, tt := range tests {
+		ecsContainer = tt.container
+		got, err := pickContainer(nil, tt.task)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got.RuntimeID != tt.want {
+			t.Errorf("%s: pickContainer() = %+v, %v; want %s", tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/e/aws-ssm-connect/internal/history"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recently connected instances for the current profile",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hist, err := loadHistory()
+		if err != nil {
+			return err
+		}
+
+		if jsonFlag {
+			entries := hist.Recent
+			if entries == nil {
+				entries = []history.Entry{}
+			}
+			return newOutput().JSON("history", entries)
+		}
+
+		if len(hist.Recent) == 0 {
+			fmt.Println("No recent connections")
+			return nil
+		}
+		for _, e := range hist.Recent {
+			fmt.Printf("%s\t%s\t%s\n", e.InstanceID, e.Name, e.LastUsed.Local().Format("2006-01-02 15:04"))
+		}
+		return nil
+	},
+}
+
+// loadHistory reads the connection history of the active profile, trimmed
+// to the configured history_limit and ordered by history_order.
+func loadHistory() (*history.History, error) {
+	return history.Connections.WithLimit(settings.HistoryLimit).WithOrder(settings.HistoryOrder).Load(activeProfile())
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
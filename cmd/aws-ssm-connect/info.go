@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/e/aws-ssm-connect/internal/notes"
+	"github.com/e/aws-ssm-connect/internal/selector"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+var infoTagsOnly bool
+
+var infoCmd = &cobra.Command{
+	Use:   "info <name|id>...",
+	Short: "Show details of an instance",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		inst, err := client.FindInstance(cmd.Context(), withQueryNames(args)...)
+		if err != nil {
+			return err
+		}
+
+		if infoTagsOnly {
+			for _, line := range ssm.TagExports(inst.Tags) {
+				fmt.Println(line)
+			}
+			return nil
+		}
+
+		return printInfo(inst)
+	},
+}
+
+// printInfo prints an instance's details and note, as JSON with --json.
+func printInfo(inst selector.Instance) error {
+	n, _ := notes.Load()
+	note := n.Get(inst.ID)
+
+	out := newOutput()
+	if jsonFlag {
+		return out.JSON("instance", struct {
+			selector.Instance
+			Note string `json:"note,omitempty"`
+		}{inst, note})
+	}
+
+	out.Header(inst.ID)
+	out.Print("Name:       %s", inst.Name)
+	out.Print("Private IP: %s", inst.PrivateIP)
+	if !inst.LastPing.IsZero() {
+		ping := inst.LastPing.Local().Format("2006-01-02 15:04")
+		if inst.Stale {
+			ping += " (stale: the agent may have stopped reporting)"
+		}
+		out.Print("Last ping:  %s", ping)
+	}
+	if note != "" {
+		out.Print("Note:       %s", note)
+	}
+	if len(inst.Tags) > 0 {
+		keys := make([]string, 0, len(inst.Tags))
+		for k := range inst.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		out.Print("Tags:")
+		for _, k := range keys {
+			out.Print("  %s=%s", k, inst.Tags[k])
+		}
+	}
+	fmt.Println()
+	return nil
+}
+
+func init() {
+	infoCmd.Flags().BoolVar(&infoTagsOnly, "tags-only", false, "Print only the tags, as shell export TAG_KEY='value' lines")
+	rootCmd.AddCommand(infoCmd)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+	"github.com/e/aws-ssm-connect/internal/selector"
+)
+
+// decodeEnvelope parses a JSON envelope printed on stdout.
+func decodeEnvelope(t *testing.T, stdout string) (kind string, data any) {
+	t.Helper()
+	var env struct {
+		SchemaVersion int    `json:"schema_version"`
+		Kind          string `json:"kind"`
+		Data          any    `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &env); err != nil {
+		t.Fatalf("stdout %q is not JSON: %v", stdout, err)
+	}
+	if env.SchemaVersion != 1 {
+		t.Errorf("schema_version = %d, want 1", env.SchemaVersion)
+	}
+	return env.Kind, env.Data
+}
+
+func TestPrintInfoJSON(t *testing.T) {
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	defer func(orig bool) { jsonFlag = orig }(jsonFlag)
+	jsonFlag = true
+
+	inst := selector.Instance{
+		ID: "i-1", Name: "web-1", PrivateIP: "10.0.0.1", AZ: "us-east-1a",
+		Tags: map[string]string{"env": "prod"}, LastPing: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	var err error
+	stdout, _ := captureOutput(t, func() { err = printInfo(inst) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	kind, data := decodeEnvelope(t, stdout)
+	if kind != "instance" {
+		t.Errorf("kind = %q, want instance", kind)
+	}
+	fields, _ := data.(map[string]any)
+	want := map[string]any{
+		"id": "i-1", "name": "web-1", "private_ip": "10.0.0.1", "az": "us-east-1a",
+		"last_ping": "2026-01-02T03:04:05Z",
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("data.%s = %v, want %v", k, fields[k], v)
+		}
+	}
+	if tags, _ := fields["tags"].(map[string]any); tags["env"] != "prod" {
+		t.Errorf("data.tags = %v", fields["tags"])
+	}
+	if _, ok := fields["note"]; ok {
+		t.Error("empty note not omitted")
+	}
+}
+
+func TestHistoryJSON(t *testing.T) {
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	defer func(orig bool) { jsonFlag = orig }(jsonFlag)
+	jsonFlag = true
+	withSettings(t)
+
+	var err error
+	stdout, _ := captureOutput(t, func() { err = historyCmd.RunE(historyCmd, nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	kind, data := decodeEnvelope(t, stdout)
+	if entries, ok := data.([]any); kind != "history" || !ok || len(entries) != 0 {
+		t.Errorf("got kind %q, data %v; want an empty history list", kind, data)
+	}
+}
@@ -2,18 +2,27 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
 	"github.com/e/aws-ssm-connect/internal/config"
+	"github.com/e/aws-ssm-connect/internal/daemon"
+	"github.com/e/aws-ssm-connect/internal/history"
 	"github.com/e/aws-ssm-connect/internal/output"
 	"github.com/e/aws-ssm-connect/internal/selector"
 	"github.com/e/aws-ssm-connect/internal/ssm"
+	"github.com/e/aws-ssm-connect/internal/update"
 )
 
 var (
@@ -23,25 +32,122 @@ var (
 )
 
 var (
+	// Output
 	debug       bool
-	profile     string
-	region      string
 	showVersion bool
-	listFlag    bool
+	checkUpdate bool
+	offline     bool
+	glyphsFlag  string
+	glyphs      = output.UnicodeGlyphs
+	jsonFlag    bool
+	jsonLines   bool
+	jsonOnError bool
+	csvFlag     bool
+	quietFlag   bool
+	idsOnly     bool
+	evalFD      int
+	assumeYes   bool
+
+	// AWS access
+	profile      string
+	profiles     []string
+	fromAccount  string
+	failFast     bool
+	region       string
+	regionPrompt bool
+	findRegion   bool
+	retryModeArg string
+	// sdkRetryMode is --retry-mode or the configured retry_mode, parsed in preRun.
+	sdkRetryMode aws.RetryMode
+	noDaemon     bool
+
+	// Discovery and filtering
+	tags         []string
+	excludeTags  []string
+	azs          []string
+	resourceGrp  string
+	globFlag     bool
+	strictFlag   bool
+	noEC2        bool
+	onlineOnly   bool
+	maxInstances int
+	maxPingAge   time.Duration
+	queryName    string
+	queryFile    string
+	dumpDisc     bool
+
+	// Selection and the finder
+	selectFirst bool
+	preferTags  []string
+	selectOnly  bool
+	withName    bool
+	retrySelect bool
+	recentOnly  bool
+	showGone    bool
+	maxRecent   int
+	menuFlag    bool
+	inlineFlag  bool
+	inlineSize  int
+	// inlineRows is the finder height from --inline and --inline-height,
+	// 0 for the full screen; set in preRun.
+	inlineRows  int
+	columnsFlag string
+	labelWidth  string
+	nameWidth   int
+
+	// Listing
+	listFlag bool
+	countBy  string
+	groupBy  string
+	limit    int
+
+	// Sessions
+	actionFlag   string
+	portFlag     string
+	commandFlag  string
+	socksPort    int
+	sshUser      string
+	viaFlag      string
+	shellFlag    string
+	stdioFlag    bool
+	execFlag     string
+	sessionDoc   string
+	idleTimeout  time.Duration
+	killOnIdle   time.Duration
+	launchTries  int
+	reason       string
+	printSession bool
+	openURL      string
+
+	// Run Command
+	runFlag   bool
+	runAll    bool
+	noWait    bool
+	tailFlag  bool
+	tailLines int
+	sudoFlag  bool
+	workdir   string
+	envVars   []string
+
+	// File transfer
 	copyFlag    bool
-	runFlag     bool
+	browseCopy  bool
+	maxUpload   string
+	encryptSpec string
+	viaS3       bool
+	s3Bucket    string
+	noVerify    bool
+)
+
+// settings and columns are read once in preRun: config.json, and the
+// --columns list (nil when not given).
+var (
+	settings *config.Settings
+	columns  []selector.Column
 )
 
 func main() {
-	// Allow single-dash long flags
-	for i, arg := range os.Args {
-		switch arg {
-		case "-copy":
-			os.Args[i] = "--copy"
-		case "-run":
-			os.Args[i] = "--run"
-		}
-	}
+	os.Args = longFlags(os.Args)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -54,43 +160,115 @@ func main() {
 	}()
 
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		os.Exit(handleError(err))
+	}
+}
+
+// longFlags allows single-dash long flags, and -run-all for -run --all.
+func longFlags(args []string) []string {
+	rewritten := make([]string, 0, len(args)+1)
+	for _, arg := range args {
+		switch arg {
+		case "-copy":
+			arg = "--copy"
+		case "-run":
+			arg = "--run"
+		case "-run-all", "--run-all":
+			rewritten = append(rewritten, "--run", "--all")
+			continue
+		}
+		rewritten = append(rewritten, arg)
+	}
+	return rewritten
+}
+
+// handleError reports err and returns the exit code: that of the remote
+// command for an *ssm.ExitError, whose output was already printed, and 1
+// otherwise.
+func handleError(err error) int {
+	code := 1
+	var exitErr *ssm.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.Code
+	} else {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		if hint := config.ClockSkewHint(err); hint != "" {
+			fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
+		}
+	}
+	if jsonOnError {
+		printErrorJSON(err, code)
 	}
+	return code
+}
+
+// targetInstance is the instance the command last resolved, reported with
+// --output-json-on-error.
+var targetInstance string
+
+// printErrorJSON prints err as an "error" JSON envelope on stdout, with the
+// exit code and the instance involved, if any.
+func printErrorJSON(err error, code int) {
+	_ = newOutput().JSON("error", struct {
+		Error    string `json:"error"`
+		Code     int    `json:"code"`
+		Instance string `json:"instance,omitempty"`
+	}{err.Error(), code, targetInstance})
 }
 
 var rootCmd = &cobra.Command{
-	Use:   "aws-ssm-connect [name]",
+	Use:   "aws-ssm-connect [name...]",
 	Short: "Connect to AWS EC2 instances via SSM Session Manager",
 	Long: `aws-ssm-connect is a CLI tool for connecting to AWS EC2 instances
 using AWS Systems Manager Session Manager.
 
 Run without arguments for interactive fuzzy selection, or provide
-an instance name/ID to filter and connect directly.
+an instance name/ID to filter and connect directly. Several names
+select from instances matching any of them.
 
 Use -l to list instances: -l [filter words...]
-Use -copy to copy files: -copy src dst (use instance:/path for remote)
-Use -run to run a command: -run instance "command"`,
-	Args:          cobra.ArbitraryArgs,
-	SilenceUsage:  true,
-	SilenceErrors: true,
+Use -copy to copy files: -copy src dst (use instance:/path for remote, - for stdin)
+Use -run to run a command: -run instance "command"
+Use --action to choose what happens after selection: shell (default),
+print, forward (with --port), run (with --command) or socks (with --socks)`,
+	Args:              cobra.ArbitraryArgs,
+	SilenceUsage:      true,
+	SilenceErrors:     true,
+	PersistentPreRunE: preRun,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if showVersion {
+		if showVersion || checkUpdate {
 			fmt.Printf("aws-ssm-connect %s\n", version)
 			fmt.Printf("  commit: %s\n", commit)
 			fmt.Printf("  built:  %s\n", date)
+			if checkUpdate {
+				return printUpdateCheck(cmd.Context())
+			}
 			return nil
 		}
 
 		ctx := cmd.Context()
-		out := output.New(debug)
+		if len(profiles) > 0 {
+			return runProfiles(ctx, args)
+		}
 
-		cfg, err := config.Load(profile, region)
+		client, err := newClient()
 		if err != nil {
-			return fmt.Errorf("failed to load AWS config: %w", err)
+			return err
+		}
+
+		if dumpDisc {
+			return dumpDiscovery(ctx, client)
+		}
+
+		if findRegion {
+			if client, err = locateInstance(ctx, client, targetArg(args)); err != nil {
+				return err
+			}
 		}
 
-		client := ssm.NewClient(cfg, out)
+		if browseCopy {
+			return handleInteractiveCopy(ctx, client, args)
+		}
 
 		// Handle -c flag for file upload
 		if copyFlag {
@@ -107,37 +285,635 @@ Use -run to run a command: -run instance "command"`,
 			return handleRun(ctx, client, args)
 		}
 
-		var instanceID, instanceName string
-		if len(args) > 1 {
-			return fmt.Errorf("too many arguments; use -l for listing with filters")
+		// Name words of a saved query only narrow down the selection
+		args = withQueryNames(args)
+
+		action, err := resolveAction(settings.DefaultAction)
+		if err != nil {
+			return err
 		}
-		if len(args) > 0 {
-			// Name/ID provided - filter and select
-			instanceID, instanceName, err = client.SelectByName(ctx, args[0])
-			if err != nil {
-				return err
+		if err := checkStrict(args); err != nil {
+			return err
+		}
+
+		pickInstance := func() (string, string, error) {
+			if fastPath(args) {
+				// A full ID needs no discovery, only a check that SSM knows it
+				inst, err := client.ManagedInstance(ctx, args[0])
+				return inst.ID, inst.Name, err
+			}
+			if len(args) > 0 {
+				// Names/IDs provided - match any of them and select
+				return client.SelectByName(ctx, args...)
 			}
-		} else {
 			// No args - interactive fuzzy selection
-			instanceID, instanceName, err = client.SelectInstance(ctx)
+			return client.SelectInstance(ctx)
+		}
+		connect := func(instanceID, instanceName string) error {
+			return runAction(ctx, client, action, instanceID, instanceName)
+		}
+
+		out := newOutput()
+		return connectLoop(pickInstance, func() (string, string, error) {
+			return client.SelectInstance(ctx)
+		}, connect, func(err error) bool {
 			if err != nil {
-				return err
+				if !retrySelect {
+					return false
+				}
+				out.Error("Connection failed: %v", err)
+				return true
 			}
+			return client.ReopenRequested()
+		})
+	},
+}
+
+// checkStrict rejects --strict and --select-first when selection would
+// open the finder anyway.
+func checkStrict(args []string) error {
+	switch {
+	case strictFlag && selectFirst:
+		return fmt.Errorf("--strict and --select-first are mutually exclusive")
+	case !strictFlag && !selectFirst:
+		return nil
+	case len(args) == 0:
+		return fmt.Errorf("%s needs an instance name or ID", nonInteractiveFlag())
+	case retrySelect:
+		return fmt.Errorf("%s cannot be combined with --retry-select", nonInteractiveFlag())
+	}
+	return nil
+}
+
+// nonInteractiveFlag names the flag that replaces the finder.
+func nonInteractiveFlag() string {
+	if selectFirst {
+		return "--select-first"
+	}
+	return "--strict"
+}
+
+// fastPath reports whether args name a single complete instance ID that can
+// be connected to without discovery. Tag and AZ filters need EC2 details,
+// so they keep the full discovery.
+func fastPath(args []string) bool {
+	return len(args) == 1 && ssm.IsFullInstanceID(args[0]) &&
+		len(tags) == 0 && len(excludeTags) == 0 && len(azs) == 0 && resourceGrp == ""
+}
+
+// connectLoop connects to the first selected instance, then keeps reopening
+// the finder (which keeps its previous query) for as long as again says so:
+// after a failed connect with --retry-select, or after a pick made with the
+// continue key. It stops when again declines or selection fails or is cancelled.
+func connectLoop(
+	pickInstance, reselect func() (string, string, error),
+	connect func(instanceID, instanceName string) error,
+	again func(connectErr error) bool,
+) error {
+	instanceID, instanceName, err := pickInstance()
+	for {
+		if err != nil {
+			return err
+		}
+		err = connect(instanceID, instanceName)
+		if !again(err) {
+			return err
 		}
+		instanceID, instanceName, err = reselect()
+	}
+}
 
-		return client.StartSession(ctx, instanceID, instanceName, profile)
-	},
+// printUpdateCheck reports whether a newer release than this build exists.
+func printUpdateCheck(ctx context.Context) error {
+	latest, err := update.Latest(ctx, offline)
+	if err != nil {
+		return err
+	}
+	if update.Newer(version, latest) {
+		fmt.Printf("Update available: %s (https://github.com/eugenetaranov/aws-ssm-connect/releases/latest)\n", latest)
+	} else {
+		fmt.Printf("Up to date (latest release: %s)\n", latest)
+	}
+	return nil
+}
+
+// preRun runs before every command: it resolves the message glyphs and
+// applies any saved query.
+func preRun(cmd *cobra.Command, args []string) error {
+	var err error
+	if settings, err = config.LoadSettings(); err != nil {
+		return err
+	}
+	if columnsFlag != "" {
+		if columns, err = selector.ParseColumns(columnsFlag); err != nil {
+			return err
+		}
+	}
+	name := glyphsFlag
+	if name == "" {
+		name = settings.Glyphs
+	}
+	g, err := output.ParseGlyphs(name)
+	if err != nil {
+		return err
+	}
+	glyphs = g
+	width := labelWidth
+	if width == "" {
+		width = settings.LabelWidth
+	}
+	if width != "" {
+		if nameWidth, err = selector.ParseNameWidth(width); err != nil {
+			return err
+		}
+	}
+	regionPrompt = regionPrompt || settings.RegionFromProfile
+	mode := retryModeArg
+	if mode == "" {
+		mode = settings.RetryMode
+	}
+	if sdkRetryMode, err = config.ParseRetryMode(mode); err != nil {
+		return err
+	}
+	if f := cmd.Flags().Lookup("max-age-warning"); f != nil && !f.Changed && settings.MaxPingAge != "" {
+		if maxPingAge, err = time.ParseDuration(settings.MaxPingAge); err != nil {
+			return fmt.Errorf("config max_ping_age: %w", err)
+		}
+	}
+	if maxPingAge < 0 {
+		return fmt.Errorf("--max-age-warning must not be negative")
+	}
+	if f := cmd.Flags().Lookup("launch-retries"); f != nil && !f.Changed && settings.LaunchRetries != nil {
+		launchTries = *settings.LaunchRetries
+	}
+	if launchTries < 0 {
+		return fmt.Errorf("--launch-retries must not be negative")
+	}
+	if f := cmd.Flags().Lookup("session-document"); f != nil && f.Changed && strings.TrimSpace(sessionDoc) == "" {
+		return fmt.Errorf("--session-document must not be empty")
+	}
+	if err := validateFlags(); err != nil {
+		return err
+	}
+	if f := cmd.Flags().Lookup("inline-height"); inlineFlag || (f != nil && f.Changed) {
+		inlineRows = inlineSize
+	}
+	if err := applyProfileFromAccount(); err != nil {
+		return err
+	}
+	return applyQuery(cmd, args)
+}
+
+// applyProfileFromAccount sets --profile to the local profile that uses the
+// account given with --profile-from-account.
+func applyProfileFromAccount() error {
+	if fromAccount == "" {
+		return nil
+	}
+	if profile != "" || len(profiles) > 0 {
+		return fmt.Errorf("--profile-from-account cannot be combined with --profile or --profiles")
+	}
+	account, err := config.AccountID(fromAccount)
+	if err != nil {
+		return err
+	}
+	path, err := config.SharedConfigPath()
+	if err != nil {
+		return err
+	}
+	if profile, err = config.ProfileForAccount(path, account); err != nil {
+		return err
+	}
+	newOutput().Debug("Using profile %s for account %s", profile, account)
+	return nil
+}
+
+// newOutput returns console output honoring --debug and the glyph setting.
+func newOutput() *output.Output {
+	return output.New(debug, glyphs)
+}
+
+// targetArg returns the instance argument that --find-region applies to:
+// the first argument of -run, or the only name given for a connection.
+func targetArg(args []string) string {
+	switch {
+	case copyFlag || listFlag:
+		return ""
+	case browseCopy && len(args) > 0:
+		instance, _ := parseRemotePath(args[0], anyInstance)
+		if instance == "" {
+			return args[0]
+		}
+		return instance
+	case runFlag && len(args) > 0:
+		return args[0]
+	case len(args) == 1:
+		return args[0]
+	}
+	return ""
+}
+
+// locateInstance returns a client for the region holding instanceID. If SSM
+// does not manage the ID in the current region, other enabled regions are
+// searched and the region it is found in is reported. Discovery filters do
+// not apply: an instance they would hide is still found here.
+func locateInstance(ctx context.Context, client *ssm.Client, instanceID string) (*ssm.Client, error) {
+	if !ssm.IsInstanceID(instanceID) {
+		return client, nil
+	}
+	if _, err := client.ManagedInstance(ctx, instanceID); !errors.Is(err, ssm.ErrNotManaged) {
+		// Here, or failing for another reason the action will report
+		return client, nil
+	}
+
+	found, err := client.FindRegion(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	newOutput().Info("Found %s in %s", instanceID, found)
+	return newClientIn(profile, found)
+}
+
+// newClient loads the AWS config and builds an SSM client from command-line flags.
+func newClient() (*ssm.Client, error) {
+	return newClientFor(profile)
+}
+
+// newClientFor builds a client like newClient, but for the given profile.
+func newClientFor(profileName string) (*ssm.Client, error) {
+	return newClientIn(profileName, region)
+}
+
+// newClientIn builds a client for the given profile and region.
+func newClientIn(profileName, regionName string) (*ssm.Client, error) {
+	cfg, err := config.Load(profileName, regionName, sdkRetryMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if cfg.Region == "" && regionPrompt {
+		if cfg.Region, err = pickRegion(cfg, profileName); err != nil {
+			return nil, err
+		}
+	}
+	if sdkRetryMode != "" {
+		newOutput().Debug("Retry mode %s", config.RetryModeNote(sdkRetryMode))
+	}
+
+	opts, err := clientOptions(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssm.NewClient(cfg, newOutput(), opts), nil
+}
+
+// regionMu serializes pickRegion, since --profiles builds clients concurrently.
+var regionMu sync.Mutex
+
+// pickRegion asks for a region in the finder when neither --region nor the
+// profile sets one. The choice is kept in --region, so every later client of
+// this run uses it without asking again. Without a terminal it fails fast.
+func pickRegion(cfg aws.Config, profileName string) (string, error) {
+	regionMu.Lock()
+	defer regionMu.Unlock()
+	if region != "" {
+		return region, nil
+	}
+	if !stdinIsTerminal() {
+		return "", fmt.Errorf("no region configured: use --region or set region in the profile")
+	}
+
+	names, err := ssm.EnabledRegions(context.Background(), cfg)
+	if err != nil {
+		return "", err
+	}
+	picked, err := chooseRegion(names, profileName)
+	if err != nil {
+		return "", err
+	}
+	region = picked
+	newOutput().Debug("Using region %s for this run", region)
+	return region, nil
+}
+
+// stdinIsTerminal reports whether stdin is a terminal. Tests replace it.
+var stdinIsTerminal = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+
+// chooseRegion lets the user pick one of names in the finder. Tests replace it.
+var chooseRegion = func(names []string, profileName string) (string, error) {
+	items := make([]selector.Item, len(names))
+	for i, name := range names {
+		items[i] = selector.Item{Key: name}
+	}
+	i, err := selector.Select(items, selector.ListOptions{
+		Profile: profileName,
+		Label:   "regions",
+		Inline:  inlineRows,
+	})
+	if err != nil {
+		return "", err
+	}
+	return names[i], nil
+}
+
+// clientOptions builds discovery options from command-line flags.
+// An empty profileName falls back to AWS_PROFILE.
+func clientOptions(profileName string) (ssm.Options, error) {
+	include, err := selector.ParseTagFilters(tags)
+	if err != nil {
+		return ssm.Options{}, err
+	}
+	exclude, err := selector.ParseTagFilters(excludeTags)
+	if err != nil {
+		return ssm.Options{}, err
+	}
+	zones, err := selector.ParseAZs(azs)
+	if err != nil {
+		return ssm.Options{}, err
+	}
+	prefer, err := parsePrefer(preferTags)
+	if err != nil {
+		return ssm.Options{}, err
+	}
+	document := settings.SessionDocument
+	if sessionDoc != "" {
+		document = sessionDoc
+	}
+	if err := history.ValidateOrder(settings.HistoryOrder); err != nil {
+		return ssm.Options{}, fmt.Errorf("config history_order: %w", err)
+	}
+	if settings.HistoryLimit < 0 {
+		return ssm.Options{}, fmt.Errorf("config history_limit must not be negative")
+	}
+	pinned := maxRecent
+	if pinned == 0 {
+		pinned = settings.MaxRecent
+	}
+	continueKey := selector.DefaultContinueKey
+	if settings.ContinueKey != "" {
+		if continueKey, err = selector.ParseKey(settings.ContinueKey); err != nil {
+			return ssm.Options{}, fmt.Errorf("config continue_key: %w", err)
+		}
+	}
+	encrypt, err := ssm.ParseEncryption(encryptSpec)
+	if err != nil {
+		return ssm.Options{}, fmt.Errorf("--encrypt-uploads: %w", err)
+	}
+	var uploadSize int64
+	if maxUpload != "" {
+		if uploadSize, err = output.ParseBytes(maxUpload); err != nil {
+			return ssm.Options{}, fmt.Errorf("--max-upload-size: %w", err)
+		}
+		if uploadSize == 0 {
+			return ssm.Options{}, fmt.Errorf("--max-upload-size must be positive")
+		}
+	}
+	var staging string
+	if viaS3 {
+		if staging = s3Bucket; staging == "" {
+			staging = settings.StagingBucket
+		}
+		if staging == "" {
+			return ssm.Options{}, fmt.Errorf("--via-s3 needs a bucket: set --s3-bucket or staging_bucket in config")
+		}
+	}
+	var cache ssm.InstanceCache
+	if !noDaemon {
+		if socket, err := daemon.SocketPath(); err == nil {
+			cache = daemon.NewClient(socket)
+		}
+	}
+	return ssm.Options{
+		Cache:           cache,
+		Tags:            include,
+		ExcludeTags:     exclude,
+		AZs:             zones,
+		ResourceGroup:   resourceGrp,
+		Glob:            globFlag,
+		Strict:          strictFlag,
+		SelectFirst:     selectFirst,
+		Prefer:          prefer,
+		Profile:         profileOrEnv(profileName),
+		Columns:         columns,
+		Exec:            execFlag,
+		Shell:           shellFlag,
+		Stdio:           stdioFlag,
+		LaunchRetries:   launchTries,
+		SessionDocument: document,
+		IdleTimeout:     idleTimeout,
+		Reason:          strings.TrimSpace(reason),
+		NameWidth:       nameWidth,
+		MaxRecent:       pinned,
+		Tail:            tailFlag,
+		TailLines:       tailLines,
+		KillOnIdle:      killOnIdle,
+		NoEC2:           noEC2,
+		MaxInstances:    maxInstances,
+		JSON:            jsonFlag,
+		Quiet:           quietFlag,
+		Inline:          inlineRows,
+		ExcludeOffline:  onlineOnly,
+		MaxPingAge:      maxPingAge,
+		HistoryLimit:    settings.HistoryLimit,
+		HistoryOrder:    settings.HistoryOrder,
+		Transfer: ssm.TransferCommands{
+			Decode: settings.RemoteDecode,
+			Encode: settings.RemoteEncode,
+		},
+		Encrypt:       encrypt,
+		MaxUploadSize: int(uploadSize),
+		NoVerify:      noVerify,
+		StagingBucket: staging,
+		Finder:        selector.Options{ContinueKey: continueKey},
+		Command: ssm.CommandOptions{
+			Sudo:    sudoFlag,
+			Workdir: workdir,
+			Env:     envVars,
+		},
+	}, nil
+}
+
+// validateFlags rejects out-of-range and conflicting flag values before
+// any command runs.
+func validateFlags() error {
+	if noEC2 && (len(tags) > 0 || len(excludeTags) > 0 || len(azs) > 0 || len(preferTags) > 0) {
+		return fmt.Errorf("--no-ec2 cannot be combined with tag or AZ filters or --prefer (they need EC2 details)")
+	}
+	if maxRecent < 0 {
+		return fmt.Errorf("--max-recent must not be negative")
+	}
+	if maxInstances < 0 {
+		return fmt.Errorf("--max-instances must not be negative")
+	}
+	if killOnIdle < 0 {
+		return fmt.Errorf("--kill-on-idle must not be negative")
+	}
+	if shellFlag != "" {
+		if err := ssm.ValidateShell(shellFlag); err != nil {
+			return err
+		}
+	}
+	if inlineSize <= 0 {
+		return fmt.Errorf("--inline-height must be positive")
+	}
+	if idleTimeout != 0 {
+		if err := ssm.ValidateIdleTimeout(idleTimeout); err != nil {
+			return fmt.Errorf("--session-idle-timeout: %w", err)
+		}
+	}
+	if tailLines < 0 {
+		return fmt.Errorf("--tail-lines must not be negative")
+	}
+	if tailLines > 0 && tailFlag {
+		return fmt.Errorf("--tail-lines cannot be combined with --tail")
+	}
+	return validateListFlags()
+}
+
+// validateListFlags rejects conflicting -l output flags, for every path that
+// prints a list (including --profiles).
+func validateListFlags() error {
+	if limit < 0 {
+		return fmt.Errorf("--limit must not be negative")
+	}
+	if showGone && !recentOnly {
+		return fmt.Errorf("--show-gone requires --recent")
+	}
+	if countBy != "" && (idsOnly || columnsFlag != "" || limit > 0) {
+		return fmt.Errorf("--count-by cannot be combined with --ids-only, --columns or --limit")
+	}
+	if csvFlag && (jsonFlag || jsonLines || idsOnly || countBy != "") {
+		return fmt.Errorf("--csv cannot be combined with --json, --jsonl, --ids-only or --count-by")
+	}
+	if groupBy != "" && (idsOnly || csvFlag || countBy != "") {
+		return fmt.Errorf("--group-by cannot be combined with --ids-only, --csv or --count-by")
+	}
+	if jsonLines && (jsonFlag || idsOnly || columnsFlag != "" || countBy != "" || groupBy != "" || recentOnly) {
+		return fmt.Errorf("--jsonl cannot be combined with --json, --ids-only, --columns, --count-by, --group-by or --recent")
+	}
+	return nil
+}
+
+// parsePrefer parses --prefer values: tag:key=value, or key=value.
+func parsePrefer(values []string) ([]selector.TagFilter, error) {
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimPrefix(v, "tag:")
+	}
+	prefer, err := selector.ParseTagFilters(trimmed)
+	if err != nil {
+		return nil, fmt.Errorf("--prefer: %w", err)
+	}
+	return prefer, nil
+}
+
+// activeProfile returns the AWS profile in effect: --profile, else AWS_PROFILE.
+func activeProfile() string {
+	return profileOrEnv(profile)
+}
+
+func profileOrEnv(name string) string {
+	if name != "" {
+		return name
+	}
+	return os.Getenv("AWS_PROFILE")
 }
 
 // handleList handles the -l flag for listing instances.
 func handleList(ctx context.Context, client *ssm.Client, filters []string) error {
+	if jsonLines {
+		return streamList(ctx, client, filters)
+	}
+
 	instances, err := client.GetRunningInstances(ctx)
 	if err != nil {
 		return err
 	}
 
-	if len(instances) == 0 {
-		fmt.Println("No running SSM-managed instances found")
+	if recentOnly {
+		hist, err := loadHistory()
+		if err != nil {
+			return err
+		}
+		instances = recentInstances(instances, hist.Recent, showGone)
+	}
+
+	return printList(instances, filters, columns)
+}
+
+// dumpDiscovery prints every discovered instance with all its SSM and EC2
+// fields, before any filtering, for bug reports about missing instances.
+func dumpDiscovery(ctx context.Context, client *ssm.Client) error {
+	instances, err := client.DiscoverInstances(ctx)
+	if err != nil {
+		return err
+	}
+	if instances == nil {
+		instances = []ssm.Instance{}
+	}
+	return newOutput().JSON("discovery", struct {
+		Profile   string         `json:"profile,omitempty"`
+		Region    string         `json:"region"`
+		Count     int            `json:"count"`
+		Instances []ssm.Instance `json:"instances"`
+	}{client.Profile(), client.Region(), len(instances), instances})
+}
+
+// errListLimit stops streaming once --limit instances have been printed.
+var errListLimit = errors.New("list limit reached")
+
+// streamList prints matching instances as JSON Lines while discovery is
+// still paging, one self-contained object per line.
+func streamList(ctx context.Context, client *ssm.Client, filters []string) error {
+	enc := json.NewEncoder(os.Stdout)
+	printed := 0
+	err := client.StreamRunningInstances(ctx, func(inst selector.Instance) error {
+		if len(filters) > 0 && !matchesAllFilters(inst, filters) {
+			return nil
+		}
+		if err := enc.Encode(inst); err != nil {
+			return err
+		}
+		printed++
+		if limit > 0 && printed >= limit {
+			return errListLimit
+		}
+		return nil
+	})
+	if errors.Is(err, errListLimit) {
+		return nil
+	}
+	return err
+}
+
+// printCounts prints how many instances share each value of field.
+func printCounts(instances []selector.Instance, field string) error {
+	counts, err := selector.CountBy(instances, field)
+	if err != nil {
+		return err
+	}
+	if jsonFlag {
+		return newOutput().JSON("counts", struct {
+			Field  string           `json:"field"`
+			Total  int              `json:"total"`
+			Counts []selector.Count `json:"counts"`
+		}{field, len(instances), counts})
+	}
+	for _, c := range counts {
+		fmt.Printf("%s\t%d\n", c.Value, c.Count)
+	}
+	return nil
+}
+
+// printList filters and prints instances in the format selected by flags.
+// cols picks tab-separated columns; none keeps the classic format.
+func printList(instances []selector.Instance, filters []string, cols []selector.Column) error {
+	if len(instances) == 0 && !jsonFlag && !idsOnly && !csvFlag {
+		if recentOnly {
+			fmt.Println("No recently used instances found")
+		} else {
+			fmt.Println("No running SSM-managed instances found")
+		}
 		return nil
 	}
 
@@ -152,21 +928,143 @@ func handleList(ctx context.Context, client *ssm.Client, filters []string) error
 		instances = filtered
 	}
 
+	if countBy != "" {
+		return printCounts(instances, countBy)
+	}
+
+	if limit > 0 && len(instances) > limit {
+		instances = instances[:limit]
+	}
+
+	// Bare IDs for scripting: no headers, no messages when empty
+	if idsOnly {
+		for _, inst := range instances {
+			fmt.Println(inst.ID)
+		}
+		return nil
+	}
+
+	if groupBy != "" {
+		return printGroups(instances, groupBy, cols)
+	}
+
+	if jsonFlag {
+		if instances == nil {
+			instances = []selector.Instance{}
+		}
+		return newOutput().JSON("instances", instances)
+	}
+
+	if csvFlag {
+		return selector.WriteCSV(os.Stdout, instances, cols)
+	}
+
 	if len(instances) == 0 {
 		fmt.Println("No instances match the filters")
 		return nil
 	}
+	return printRows(instances, cols)
+}
+
+// printGroups prints instances under a header per value of field, or as
+// a "groups" JSON document with --json.
+func printGroups(instances []selector.Instance, field string, cols []selector.Column) error {
+	groups, err := selector.GroupBy(instances, field)
+	if err != nil {
+		return err
+	}
+	out := newOutput()
+	if jsonFlag {
+		if groups == nil {
+			groups = []selector.Group{}
+		}
+		return out.JSON("groups", struct {
+			Field  string           `json:"field"`
+			Total  int              `json:"total"`
+			Groups []selector.Group `json:"groups"`
+		}{field, len(instances), groups})
+	}
+	if len(groups) == 0 {
+		fmt.Println("No instances match the filters")
+		return nil
+	}
+	for _, g := range groups {
+		out.Header(fmt.Sprintf("%s (%d%s)", g.Value, len(g.Instances), zoneBreakdown(g.Zones)))
+		if err := printRows(g.Instances, cols); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zoneBreakdown formats per-AZ counts for a group header, e.g.
+// ": us-east-1a 2, us-east-1b 1".
+func zoneBreakdown(zones []selector.Count) string {
+	if len(zones) == 0 {
+		return ""
+	}
+	parts := make([]string, len(zones))
+	for i, z := range zones {
+		parts[i] = fmt.Sprintf("%s %d", z.Value, z.Count)
+	}
+	return ": " + strings.Join(parts, ", ")
+}
+
+// printRows prints one line per instance: the --columns, or the classic
+// ID, name and IP format when cols is empty.
+func printRows(instances []selector.Instance, cols []selector.Column) error {
+	if len(cols) > 0 {
+		for _, inst := range instances {
+			fmt.Println(selector.FormatRow(inst, cols))
+		}
+		return nil
+	}
 
+	color := term.IsTerminal(int(os.Stdout.Fd()))
 	for _, inst := range instances {
+		if inst.State == stateGone {
+			line := fmt.Sprintf("%s\t%s\t(gone)", inst.ID, inst.Name)
+			if color {
+				line = output.Gray + line + output.Reset
+			}
+			fmt.Println(line)
+			continue
+		}
+		line := inst.ID + "\t" + inst.PrivateIP
 		if inst.Name != "" {
-			fmt.Printf("%s\t%s\t%s\n", inst.ID, inst.Name, inst.PrivateIP)
-		} else {
-			fmt.Printf("%s\t%s\n", inst.ID, inst.PrivateIP)
+			line = inst.ID + "\t" + inst.Name + "\t" + inst.PrivateIP
+		}
+		if inst.Stale {
+			line += "\t(stale ping " + selector.PingAge(inst, time.Now()) + ")"
 		}
+		fmt.Println(line)
 	}
 	return nil
 }
 
+// stateGone marks a recently used instance that is no longer running.
+const stateGone = "gone"
+
+// recentInstances keeps the running instances found in history, most recent
+// first. With showGone, history entries that are no longer running are
+// included too, with State set to stateGone.
+func recentInstances(running []selector.Instance, recent []history.Entry, showGone bool) []selector.Instance {
+	byID := make(map[string]selector.Instance, len(running))
+	for _, inst := range running {
+		byID[inst.ID] = inst
+	}
+
+	var result []selector.Instance
+	for _, e := range recent {
+		if inst, ok := byID[e.InstanceID]; ok {
+			result = append(result, inst)
+		} else if showGone {
+			result = append(result, selector.Instance{ID: e.InstanceID, Name: e.Name, State: stateGone})
+		}
+	}
+	return result
+}
+
 // matchesAllFilters checks if instance matches all filter words (case-insensitive).
 func matchesAllFilters(inst selector.Instance, filters []string) bool {
 	searchText := strings.ToLower(inst.ID + " " + inst.Name + " " + inst.PrivateIP)
@@ -181,10 +1079,20 @@ func matchesAllFilters(inst selector.Instance, filters []string) bool {
 // handleRun handles the -run flag for running a command on an instance.
 // Format: -run instance "command"
 func handleRun(ctx context.Context, client *ssm.Client, args []string) error {
+	if len(args) >= 2 && args[0] == "-" {
+		return handleRunStdin(ctx, client, strings.Join(args[1:], " "))
+	}
+	if pattern, command, ok := fanOutTarget(args); ok {
+		return handleRunMulti(ctx, client, pattern, command)
+	}
 	if len(args) < 2 {
 		return fmt.Errorf("usage: aws-ssm-connect -run <instance> <command>")
 	}
 
+	if noWait && (tailFlag || killOnIdle > 0) {
+		return fmt.Errorf("--no-wait cannot be combined with --tail or --kill-on-idle")
+	}
+
 	instance := args[0]
 	command := strings.Join(args[1:], " ")
 
@@ -194,11 +1102,40 @@ func handleRun(ctx context.Context, client *ssm.Client, args []string) error {
 		return err
 	}
 
-	return client.RunCommand(ctx, instanceID, command)
+	if err := confirmProtected(ctx, client, instanceID); err != nil {
+		return err
+	}
+	if err := confirmRun(client, instanceID, instance, command); err != nil {
+		return err
+	}
+	if noWait {
+		return dispatchRun(ctx, client, instanceID, command)
+	}
+	if !jsonFlag {
+		return client.RunCommand(ctx, instanceID, command)
+	}
+
+	result, err := client.Run(ctx, instanceID, command)
+	if err != nil {
+		return err
+	}
+	if err := newOutput().JSON("command", struct {
+		InstanceID string `json:"instance_id"`
+		ExitCode   int    `json:"exit_code"`
+		Stdout     string `json:"stdout"`
+		Stderr     string `json:"stderr"`
+		ElapsedMS  int64  `json:"elapsed_ms"`
+	}{instanceID, result.ExitCode, result.Stdout, result.Stderr, result.Elapsed.Milliseconds()}); err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return &ssm.ExitError{Code: result.ExitCode}
+	}
+	return nil
 }
 
 // handleCopy handles the -copy flag for file copy (upload or download).
-// Format: -copy src dst (use instance:/path for remote)
+// Format: -copy src dst (use instance:/path for remote, - as src for stdin)
 func handleCopy(ctx context.Context, client *ssm.Client, args []string) error {
 	if len(args) != 2 {
 		return fmt.Errorf("usage: aws-ssm-connect -copy <src> <dst> (use instance:/path for remote)")
@@ -207,64 +1144,297 @@ func handleCopy(ctx context.Context, client *ssm.Client, args []string) error {
 	src, dst := args[0], args[1]
 
 	// Detect direction based on which arg has instance: format
-	srcInstance, srcPath := parseRemotePath(src)
-	dstInstance, dstPath := parseRemotePath(dst)
+	known := &instanceMatcher{ctx: ctx, client: client}
+	srcInstance, srcPath := parseRemotePath(src, known.match)
+	dstInstance, dstPath := parseRemotePath(dst, known.match)
 
 	if srcInstance != "" && dstInstance != "" {
 		return fmt.Errorf("cannot copy between two remote instances")
 	}
 	if srcInstance == "" && dstInstance == "" {
-		return fmt.Errorf("one of src or dst must be remote (instance:/path)")
+		if known.err != nil {
+			return known.err
+		}
+		return fmt.Errorf("one of src or dst must be remote (instance:/path with an instance ID or the name of a running instance)")
+	}
+	if encryptSpec != "" && dstInstance == "" {
+		return fmt.Errorf("--encrypt-uploads only applies to uploads")
+	}
+	if viaS3 && dstInstance == "" {
+		return fmt.Errorf("--via-s3 only applies to uploads")
+	}
+	if noVerify && dstInstance == "" {
+		return fmt.Errorf("--no-verify only applies to uploads")
 	}
 
 	var instanceID string
 	var err error
+	out := newOutput()
+	progress := func(label string) ssm.Progress {
+		if jsonFlag || quietFlag {
+			return nil
+		}
+		return out.Progress(label)
+	}
 
 	if dstInstance != "" {
 		// Upload: local -> remote
-		if instanceID, err = resolveInstance(ctx, client, dstInstance); err != nil {
+		if instanceID, dstPath, err = resolveRemote(ctx, client, dstInstance, dstPath); err != nil {
+			return err
+		}
+		var stats ssm.TransferStats
+		if src == "-" {
+			stats, err = client.UploadReader(ctx, os.Stdin, "stdin", instanceID, dstPath, progress("Upload"))
+		} else {
+			stats, err = client.UploadFile(ctx, src, instanceID, dstPath, progress("Upload"))
+		}
+		if err != nil {
 			return err
 		}
-		return client.UploadFile(ctx, src, instanceID, dstPath)
+		if jsonFlag {
+			return printTransfer(out, "upload", instanceID, src, dstPath, stats)
+		}
+		return nil
 	}
 
 	// Download: remote -> local
-	if instanceID, err = resolveInstance(ctx, client, srcInstance); err != nil {
+	if instanceID, srcPath, err = resolveRemote(ctx, client, srcInstance, srcPath); err != nil {
 		return err
 	}
-	return client.DownloadFile(ctx, instanceID, srcPath, dst)
+	localPath, stats, err := client.DownloadFile(ctx, instanceID, srcPath, dst, progress("Download"))
+	if err != nil {
+		return err
+	}
+	if jsonFlag {
+		if err := printTransfer(out, "download", instanceID, localPath, srcPath, stats); err != nil {
+			return err
+		}
+	}
+	if evalFD > 0 {
+		return writeEval(evalFD, ssm.CdSnippet(localPath))
+	}
+	return nil
+}
+
+// printTransfer prints a finished copy as JSON, with its timing.
+func printTransfer(out *output.Output, direction, instanceID, localPath, remotePath string, stats ssm.TransferStats) error {
+	return out.JSON("transfer", struct {
+		Direction      string  `json:"direction"`
+		InstanceID     string  `json:"instance_id"`
+		LocalPath      string  `json:"local_path"`
+		RemotePath     string  `json:"remote_path"`
+		Bytes          int64   `json:"bytes"`
+		ElapsedMS      int64   `json:"elapsed_ms"`
+		BytesPerSecond float64 `json:"bytes_per_second"`
+	}{direction, instanceID, localPath, remotePath, stats.Bytes, stats.Elapsed.Milliseconds(), stats.BytesPerSecond()})
+}
+
+// writeEval writes a shell snippet to file descriptor fd for a wrapper
+// function to eval, keeping it apart from normal output on stdout.
+func writeEval(fd int, snippet string) error {
+	f := os.NewFile(uintptr(fd), "eval")
+	if f == nil {
+		return fmt.Errorf("invalid --eval-fd %d", fd)
+	}
+	if _, err := fmt.Fprintln(f, snippet); err != nil {
+		return fmt.Errorf("failed to write to --eval-fd %d: %w", fd, err)
+	}
+	return nil
 }
 
 // parseRemotePath parses "instance:/path" format, returns ("", path) if local.
-func parseRemotePath(s string) (instance, path string) {
+// The prefix must be accepted by isInstance; anything else, like a local
+// file named "notes:today", is a local path. Windows drive paths (C:\tmp,
+// C:/tmp) and paths whose prefix contains a separator are always local. An
+// IPv6 address can be given in brackets: [fe80::1]:/path.
+func parseRemotePath(s string, isInstance func(string) bool) (instance, path string) {
+	// Bracketed instance token, e.g. an IPv6 address
+	if strings.HasPrefix(s, "[") {
+		end := strings.Index(s, "]:")
+		if end > 1 && end+2 < len(s) {
+			return s[1:end], s[end+2:]
+		}
+		return "", s
+	}
+
 	idx := strings.Index(s, ":")
 	if idx == -1 {
 		return "", s
 	}
-	// Check if it looks like instance:path (not just /abs/path on windows or similar)
 	instance = s[:idx]
 	path = s[idx+1:]
 	if instance == "" || path == "" {
 		return "", s
 	}
+
+	// Windows drive letter: C:\path or C:/path
+	if len(instance) == 1 && isLetter(instance[0]) && (path[0] == '\\' || path[0] == '/') {
+		return "", s
+	}
+
+	// A prefix containing a path separator is a local path, not an instance
+	if strings.ContainsAny(instance, `/\`) {
+		return "", s
+	}
+	if !isInstance(instance) {
+		return "", s
+	}
 	return instance, path
 }
 
+// anyInstance accepts every prefix, for arguments that are always remote.
+func anyInstance(string) bool { return true }
+
+// instanceMatcher tells instance prefixes of -copy arguments from local
+// paths: instance IDs, and names matching a running instance. Instances are
+// discovered on the first name that needs it, and only once; a failed
+// discovery is kept in err.
+type instanceMatcher struct {
+	ctx       context.Context
+	client    *ssm.Client
+	instances []selector.Instance
+	loaded    bool
+	err       error
+}
+
+func (m *instanceMatcher) match(token string) bool {
+	if ssm.IsInstanceID(token) {
+		return true
+	}
+	if !m.loaded {
+		m.instances, m.err = m.client.GetRunningInstances(m.ctx)
+		m.loaded = true
+	}
+	if globFlag || selector.IsGlob(token) {
+		found, err := selector.FindByGlob(m.instances, token)
+		return err == nil && len(found) > 0
+	}
+	return len(selector.FindByName(m.instances, token)) > 0
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// resolveRemote resolves the instance of an instance:/path argument and
+// expands placeholders such as {tag:Service} in the path against it.
+func resolveRemote(ctx context.Context, client *ssm.Client, instance, remotePath string) (string, string, error) {
+	if !ssm.IsPathTemplate(remotePath) {
+		id, err := resolveInstance(ctx, client, instance)
+		return id, remotePath, err
+	}
+
+	// Placeholders need the instance's tags, so look it up even by ID
+	inst, err := client.FindInstance(ctx, instance)
+	if err != nil {
+		return "", "", err
+	}
+	expanded, err := ssm.ExpandPath(remotePath, inst)
+	if err != nil {
+		return "", "", err
+	}
+	newOutput().Debug("Remote path %s on %s", expanded, inst.ID)
+	targetInstance = inst.ID
+	return inst.ID, expanded, nil
+}
+
 // resolveInstance resolves instance name to ID.
 func resolveInstance(ctx context.Context, client *ssm.Client, instance string) (string, error) {
 	if strings.HasPrefix(instance, "i-") {
+		targetInstance = instance
 		return instance, nil
 	}
 	id, _, err := client.SelectByName(ctx, instance)
+	if err == nil {
+		targetInstance = id
+	}
 	return id, err
 }
 
 func init() {
 	rootCmd.Flags().BoolVarP(&showVersion, "version", "v", false, "Show version information")
-	rootCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug output")
-	rootCmd.Flags().StringVar(&profile, "profile", "", "AWS profile to use")
-	rootCmd.Flags().StringVar(&region, "region", "", "AWS region to use")
+	rootCmd.Flags().BoolVar(&checkUpdate, "check", false, "With --version, check GitHub for a newer release (cached for a day)")
+	rootCmd.Flags().BoolVar(&offline, "offline", false, "Never access the network for update checks (also AWS_SSM_CONNECT_OFFLINE)")
+	rootCmd.PersistentFlags().BoolVarP(&quietFlag, "quiet", "q", false, "With -copy, print no progress or transfer messages; with -run, no completion line")
+	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Enable debug output")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "AWS profile to use")
+	rootCmd.PersistentFlags().BoolVar(&onlineOnly, "exclude-offline", false, "Drop instances whose SSM agent is not Online (e.g. ConnectionLost)")
+	rootCmd.PersistentFlags().StringVar(&retryModeArg, "retry-mode", "", "AWS SDK retry mode: standard, or adaptive to rate-limit client-side when throttled")
+	rootCmd.PersistentFlags().IntVar(&launchTries, "launch-retries", ssm.DefaultLaunchRetries, "Start the session again this many times when session-manager-plugin fails right after launch")
+	rootCmd.PersistentFlags().DurationVar(&maxPingAge, "max-age-warning", selector.DefaultMaxPingAge, "Mark instances whose SSM agent last pinged longer ago as stale (0 disables)")
+	rootCmd.PersistentFlags().StringVar(&reason, "reason", "", "Reason recorded with the SSM session, for auditing")
+	rootCmd.PersistentFlags().StringVar(&labelWidth, "label-width", "", "Width of the finder's name column, or auto to fit the terminal (default 30)")
+	rootCmd.PersistentFlags().BoolVar(&inlineFlag, "inline", false, "Draw the finder on the main screen, below the prompt, so it stays in scrollback")
+	rootCmd.PersistentFlags().IntVar(&inlineSize, "inline-height", selector.DefaultInlineHeight, "Rows of the --inline finder (implies --inline)")
+	rootCmd.PersistentFlags().StringVar(&fromAccount, "profile-from-account", "", "Use the local profile for this account ID or ARN (matched on sso_account_id or role_arn)")
+	rootCmd.PersistentFlags().StringVar(&region, "region", "", "AWS region to use")
+	rootCmd.PersistentFlags().BoolVar(&regionPrompt, "region-from-profile", false, "Use the profile's region, and pick one interactively when neither it nor --region is set (also region_from_profile)")
+	rootCmd.PersistentFlags().BoolVar(&jsonOnError, "output-json-on-error", false, "On failure, also print the error as JSON on stdout (error, code, instance)")
+	rootCmd.PersistentFlags().BoolVar(&jsonFlag, "json", false, "Print machine-readable JSON (list, info, history, run, copy)")
 	rootCmd.Flags().BoolVar(&copyFlag, "copy", false, "Copy file to instance")
+	rootCmd.Flags().BoolVar(&browseCopy, "interactive-copy", false, "Browse a remote directory (instance[:/dir]) and download the picked file")
 	rootCmd.Flags().BoolVarP(&listFlag, "list", "l", false, "List instances and exit")
 	rootCmd.Flags().BoolVar(&runFlag, "run", false, "Run a command on instance")
+	rootCmd.PersistentFlags().StringArrayVar(&tags, "tag", nil, "Only include instances with tag key=value (repeatable, all must match)")
+	rootCmd.PersistentFlags().StringArrayVar(&excludeTags, "exclude-tag", nil, "Exclude instances with tag key=value (repeatable, any match excludes)")
+	rootCmd.PersistentFlags().StringArrayVar(&azs, "az", nil, "Only include instances in this availability zone (repeatable, any match includes)")
+	rootCmd.PersistentFlags().StringVar(&resourceGrp, "resource-group", "", "Only include instances in this Resource Group (name or ARN)")
+	rootCmd.PersistentFlags().StringVar(&queryName, "query", "", "Load filters from a saved query (see 'query save')")
+	rootCmd.PersistentFlags().StringVar(&queryFile, "query-file", "", "Load filters from a query JSON file")
+	rootCmd.PersistentFlags().StringVar(&glyphsFlag, "glyphs", "", "Message markers: unicode, ascii or none (default from config, else unicode)")
+	rootCmd.PersistentFlags().IntVar(&maxInstances, "max-instances", ssm.DefaultMaxInstances, "Stop discovery after this many managed instances (filters apply to that set)")
+	rootCmd.PersistentFlags().BoolVar(&noDaemon, "no-daemon", false, "Always discover instances from AWS, even when 'serve' is running")
+	rootCmd.PersistentFlags().BoolVar(&noEC2, "no-ec2", false, "Skip EC2 lookups: list instances from SSM only (no names, IPs or tags)")
+	rootCmd.Flags().BoolVar(&dumpDisc, "dump-discovery", false, "Print every discovered instance with all fields as JSON, before any filtering (for bug reports)")
+	_ = rootCmd.Flags().MarkHidden("dump-discovery")
+	rootCmd.PersistentFlags().StringArrayVar(&preferTags, "prefer", nil, "Rank instances with tag:key=value first when several match (repeatable, all must match)")
+	rootCmd.PersistentFlags().BoolVar(&selectFirst, "select-first", false, "Pick the best ranked of several matching instances instead of opening the finder")
+	rootCmd.PersistentFlags().BoolVar(&strictFlag, "strict", false, "Fail instead of opening the finder when a name matches several instances (for scripts)")
+	rootCmd.PersistentFlags().BoolVar(&globFlag, "glob", false, "Match instance names as shell-style glob patterns (e.g. web-*-prod)")
+	rootCmd.Flags().StringVar(&actionFlag, "action", "", "Action after selection: shell, print, forward or run (default from config, else shell)")
+	rootCmd.Flags().StringVar(&portFlag, "port", "", "Port to forward for --action forward (port or local:remote)")
+	rootCmd.Flags().StringVar(&commandFlag, "command", "", "Command to run for --action run")
+	rootCmd.Flags().IntVar(&socksPort, "socks", 0, "Open a SOCKS5 proxy on this local port through the instance (requires ssh)")
+	rootCmd.Flags().StringVar(&sshUser, "ssh-user", "ec2-user", "SSH user for --socks and --via")
+	rootCmd.Flags().StringVar(&viaFlag, "via", "", "Reach the instance over SSH through this bastion (name or ID)")
+	rootCmd.Flags().StringVar(&openURL, "open-url", "", "Print and open the console page of the selected instance: ec2 or ssm")
+	rootCmd.Flags().Lookup("open-url").NoOptDefVal = ssm.ConsoleEC2
+	rootCmd.Flags().BoolVar(&printSession, "print-session", false, "Start a session and print its JSON and plugin argv instead of connecting")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Answer yes to all confirmations (also AWS_SSM_CONNECT_ASSUME_YES)")
+	rootCmd.Flags().BoolVar(&menuFlag, "menu", false, "After selecting an instance, choose what to do from a menu: shell, forward, run, info or copy")
+	rootCmd.Flags().BoolVar(&selectOnly, "select-only", false, "Only pick an instance and print its ID (the finder draws on the terminal, not stdout)")
+	rootCmd.Flags().BoolVar(&withName, "with-name", false, "With --select-only, print the ID and name separated by a tab")
+	rootCmd.Flags().StringVar(&columnsFlag, "columns", "", "Comma-separated fields to show in the list and finder: id,name,ip,az,state,platform")
+	rootCmd.Flags().BoolVar(&retrySelect, "retry-select", false, "Reopen the finder with the previous query when connecting fails")
+	rootCmd.Flags().BoolVar(&csvFlag, "csv", false, "With -l, print CSV with a header row of the --columns")
+	rootCmd.Flags().BoolVar(&jsonLines, "jsonl", false, "With -l, stream one JSON object per instance and line as discovery pages arrive")
+	rootCmd.Flags().StringVar(&groupBy, "group-by", "", "With -l, print instances under a header per value of a field (tag:Key, az, state, ...)")
+	rootCmd.Flags().StringVar(&countBy, "count-by", "", "With -l, print instance counts per value of a field (tag:Key, az, state, platform, ...)")
+	rootCmd.Flags().DurationVar(&idleTimeout, "session-idle-timeout", 0, "Have SSM end idle shell sessions after this long (1m-60m), if the session document supports it")
+	rootCmd.Flags().StringVar(&sessionDoc, "session-document", "", "SSM document for shell sessions (overrides session_document in config)")
+	rootCmd.Flags().BoolVar(&stdioFlag, "stdio", false, "Attach the session to stdin/stdout instead of the terminal (no pty; for scripts and other programs)")
+	rootCmd.Flags().StringVar(&shellFlag, "shell", "", "Switch the session to this shell (name or absolute path) if the instance has it")
+	rootCmd.Flags().StringVar(&execFlag, "exec", "", "Type this command into the shell right after it starts (e.g. 'sudo su -')")
+	rootCmd.Flags().StringVar(&encryptSpec, "encrypt-uploads", "", "Encrypt -copy uploads locally with age:RECIPIENT or gpg:RECIPIENT; the remote file stays encrypted")
+	rootCmd.Flags().StringVar(&maxUpload, "max-upload-size", "", "Payload bytes per -copy upload command, e.g. 64KB (default: what fits in SSM's parameter limit)")
+	rootCmd.Flags().BoolVar(&noVerify, "no-verify", false, "Skip comparing the SHA-256 of -copy uploads on the instance (for targets without sha256sum or shasum)")
+	rootCmd.Flags().BoolVar(&viaS3, "via-s3", false, "Stage -copy uploads in S3 and fetch them with a presigned URL, keeping content out of the command")
+	rootCmd.Flags().StringVar(&s3Bucket, "s3-bucket", "", "Bucket for --via-s3 (overrides staging_bucket in config)")
+	rootCmd.Flags().IntVar(&evalFD, "eval-fd", 0, "After a -copy download, write a 'cd' to its directory on this file descriptor")
+	rootCmd.Flags().BoolVar(&recentOnly, "recent", false, "With -l, list only instances connected to before, most recent first")
+	rootCmd.Flags().BoolVar(&showGone, "show-gone", false, "With -l --recent, also list recent instances that are no longer running")
+	rootCmd.Flags().BoolVar(&findRegion, "find-region", false, "If an instance ID is not in the current region, search other enabled regions for it")
+	rootCmd.Flags().StringSliceVar(&profiles, "profiles", nil, "List or select instances across these AWS profiles (comma-separated)")
+	rootCmd.Flags().BoolVar(&failFast, "fail-fast", false, "With --profiles, abort when any profile fails instead of showing the others")
+	rootCmd.Flags().BoolVar(&idsOnly, "ids-only", false, "With -l, print only instance IDs, one per line")
+	rootCmd.Flags().IntVar(&limit, "limit", 0, "With -l, print at most this many instances")
+	rootCmd.Flags().IntVar(&maxRecent, "max-recent", 0, "Pin at most this many recent instances at the top of the finder (default from config, else all)")
+	rootCmd.Flags().BoolVar(&sudoFlag, "sudo", false, "Run -run/--command commands as root via sudo (not on Windows)")
+	rootCmd.Flags().StringVar(&workdir, "workdir", "", "Directory to run -run/--command commands in")
+	rootCmd.Flags().StringArrayVar(&envVars, "env", nil, "Environment variable KEY=VALUE for -run/--command commands (repeatable)")
+	rootCmd.Flags().DurationVar(&killOnIdle, "kill-on-idle", 0, "Cancel -run/--command commands whose output does not change for this long (e.g. 2m)")
+	rootCmd.Flags().BoolVar(&runAll, "all", false, "With -run, run on every instance passing the filters (args are the command; -run-all for short)")
+	rootCmd.Flags().BoolVar(&noWait, "no-wait", false, "With -run, print the command ID right away instead of waiting (see run-status)")
+	rootCmd.Flags().BoolVar(&tailFlag, "tail", false, "Stream -run/--command output while the command runs (best-effort)")
+	rootCmd.Flags().IntVar(&tailLines, "tail-lines", 0, "Print only the last N lines of -run/--command output (stdout and stderr each)")
 }
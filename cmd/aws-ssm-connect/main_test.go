@@ -0,0 +1,761 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"github.com/e/aws-ssm-connect/internal/config"
+	"github.com/e/aws-ssm-connect/internal/history"
+	"github.com/e/aws-ssm-connect/internal/output"
+	"github.com/e/aws-ssm-connect/internal/paths"
+	"github.com/e/aws-ssm-connect/internal/selector"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+func TestParseRemotePath(t *testing.T) {
+	// web-1 stands in for the name of a running instance
+	known := func(token string) bool { return token == "i-123" || token == "web-1" || token == "fe80::1" }
+	tests := []struct {
+		arg          string
+		wantInstance string
+		wantPath     string
+	}{
+		{"i-123:/tmp/x", "i-123", "/tmp/x"},
+		{"web-1:/var/log/app.log", "web-1", "/var/log/app.log"},
+		{"web-1:relative.txt", "web-1", "relative.txt"},
+		{"[fe80::1]:/tmp/x", "fe80::1", "/tmp/x"},
+
+		// Local paths
+		{"/tmp/x", "", "/tmp/x"},
+		{"file.txt", "", "file.txt"},
+		{`C:\tmp\x`, "", `C:\tmp\x`},
+		{"C:/tmp/x", "", "C:/tmp/x"},
+		{"./a:b", "", "./a:b"},
+		{`dir\a:b`, "", `dir\a:b`},
+		{"notes:today", "", "notes:today"},
+		{"unknown-host:/tmp/x", "", "unknown-host:/tmp/x"},
+
+		// Edge cases
+		{":/tmp/x", "", ":/tmp/x"},
+		{"i-123:", "", "i-123:"},
+		{"[fe80::1]", "", "[fe80::1]"},
+		{"[]:/tmp", "", "[]:/tmp"},
+	}
+	for _, tt := range tests {
+		instance, path := parseRemotePath(tt.arg, known)
+		if instance != tt.wantInstance || path != tt.wantPath {
+			t.Errorf("parseRemotePath(%q) = (%q, %q), want (%q, %q)", tt.arg, instance, path, tt.wantInstance, tt.wantPath)
+		}
+	}
+}
+
+func TestParseRemotePathAnyInstance(t *testing.T) {
+	instance, path := parseRemotePath("anything:/dir", anyInstance)
+	if instance != "anything" || path != "/dir" {
+		t.Errorf("got (%q, %q), want (\"anything\", \"/dir\")", instance, path)
+	}
+}
+
+func TestValidateListFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     func()
+		wantErr bool
+	}{
+		{"none", func() {}, false},
+		{"count-by alone", func() { countBy = "az" }, false},
+		{"count-by with columns", func() { countBy, columnsFlag = "az", "id" }, true},
+		{"count-by with limit", func() { countBy, limit = "az", 5 }, true},
+		{"negative limit", func() { limit = -1 }, true},
+		{"show-gone without recent", func() { showGone = true }, true},
+		{"csv alone", func() { csvFlag = true }, false},
+		{"csv with columns", func() { csvFlag, columnsFlag = true, "id,name" }, false},
+		{"csv with json", func() { csvFlag, jsonFlag = true, true }, true},
+		{"csv with jsonl", func() { csvFlag, jsonLines = true, true }, true},
+		{"csv with ids-only", func() { csvFlag, idsOnly = true, true }, true},
+		{"csv with count-by", func() { csvFlag, countBy = true, "az" }, true},
+		{"group-by with ids-only", func() { groupBy, idsOnly = "az", true }, true},
+		{"jsonl with recent", func() { jsonLines, recentOnly = true, true }, true},
+	}
+	for _, tt := range tests {
+		countBy, columnsFlag, limit, showGone, recentOnly = "", "", 0, false, false
+		csvFlag, jsonFlag, jsonLines, groupBy, idsOnly = false, false, false, "", false
+		tt.set()
+		if err := validateListFlags(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateListFlags() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+	countBy, columnsFlag, limit, showGone, recentOnly = "", "", 0, false, false
+	csvFlag, jsonFlag, jsonLines, groupBy, idsOnly = false, false, false, "", false
+}
+
+// fakeSSMClient returns a client whose AWS calls go to handler, as JSON
+// protocol requests named by their X-Amz-Target header.
+func fakeSSMClient(t *testing.T, opts ssm.Options, handler func(target string, body map[string]any) any) *ssm.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if err := json.NewEncoder(w).Encode(handler(r.Header.Get("X-Amz-Target"), body)); err != nil {
+			t.Errorf("encode response: %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+	cfg := aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	}
+	return ssm.NewClient(cfg, output.New(false, output.UnicodeGlyphs), opts)
+}
+
+// captureOutput runs fn with os.Stdout and os.Stderr redirected and returns
+// what was written to each.
+func captureOutput(t *testing.T, fn func()) (stdout, stderr string) {
+	t.Helper()
+	read := func(f **os.File) func() string {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		orig := *f
+		*f = w
+		done := make(chan string)
+		go func() {
+			data, _ := io.ReadAll(r)
+			done <- string(data)
+		}()
+		return func() string {
+			w.Close()
+			*f = orig
+			return <-done
+		}
+	}
+	stopOut, stopErr := read(&os.Stdout), read(&os.Stderr)
+	fn()
+	return stopOut(), stopErr()
+}
+
+// instancePages serves DescribeInstanceInformation in pages of size.
+func instancePages(total, size int) func(target string, body map[string]any) any {
+	return func(target string, body map[string]any) any {
+		if target != "AmazonSSM.DescribeInstanceInformation" {
+			return map[string]any{}
+		}
+		start := 0
+		if token, ok := body["NextToken"].(string); ok {
+			fmt.Sscan(token, &start)
+		}
+		var list []map[string]any
+		for i := start; i < min(start+size, total); i++ {
+			list = append(list, map[string]any{"InstanceId": fmt.Sprintf("i-%08x", i), "PingStatus": "Online", "PlatformType": "Linux"})
+		}
+		page := map[string]any{"InstanceInformationList": list}
+		if start+size < total {
+			page["NextToken"] = fmt.Sprint(start + size)
+		}
+		return page
+	}
+}
+
+func TestStreamListCappedIsValidJSONL(t *testing.T) {
+	client := fakeSSMClient(t, ssm.Options{NoEC2: true, MaxInstances: 3}, instancePages(4, 2))
+	var err error
+	stdout, stderr := captureOutput(t, func() {
+		err = streamList(context.Background(), client, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(strings.NewReader(stdout))
+	for scanner.Scan() {
+		var inst map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &inst); err != nil {
+			t.Fatalf("stdout line %q is not JSON: %v", scanner.Text(), err)
+		}
+		lines++
+	}
+	if lines != 3 {
+		t.Errorf("got %d instances on stdout, want 3", lines)
+	}
+	if !strings.Contains(stderr, "Stopped discovery at 3 instances") {
+		t.Errorf("cap warning missing from stderr: %q", stderr)
+	}
+}
+
+func TestHandleError(t *testing.T) {
+	defer func(orig bool) { jsonOnError = orig }(jsonOnError)
+	jsonOnError = true
+
+	tests := []struct {
+		err        error
+		wantCode   int
+		wantStderr bool
+	}{
+		{fmt.Errorf("no instances found"), 1, true},
+		{&ssm.ExitError{Code: 3}, 3, false},
+		{fmt.Errorf("run: %w", &ssm.ExitError{Code: 42}), 42, false},
+	}
+	for _, tt := range tests {
+		var code int
+		stdout, stderr := captureOutput(t, func() { code = handleError(tt.err) })
+		if code != tt.wantCode {
+			t.Errorf("handleError(%v) = %d, want %d", tt.err, code, tt.wantCode)
+		}
+		if got := strings.Contains(stderr, "Error: "); got != tt.wantStderr {
+			t.Errorf("handleError(%v) printed %q on stderr", tt.err, stderr)
+		}
+		var envelope struct {
+			Data struct {
+				Error string `json:"error"`
+				Code  int    `json:"code"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &envelope); err != nil {
+			t.Fatalf("stdout %q is not JSON: %v", stdout, err)
+		}
+		if envelope.Data.Code != tt.wantCode || envelope.Data.Error != tt.err.Error() {
+			t.Errorf("handleError(%v) printed %s", tt.err, stdout)
+		}
+	}
+}
+
+func TestEntryItems(t *testing.T) {
+	entries := []ssm.DirEntry{
+		{Name: "..", Mode: "drwxr-xr-x", Dir: true},
+		{Name: "app.log", Mode: "-rw-r--r--", Size: 2048},
+	}
+	got := entryItems(entries)
+	want := []selector.Item{
+		{Key: "../", Label: "drwxr-xr-x"},
+		{Key: "app.log", Label: "-rw-r--r--  2.0 KB"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entryItems() = %v, want %v", got, want)
+	}
+}
+
+// withSettings sets empty settings for the test, as preRun would.
+func withSettings(t *testing.T) *config.Settings {
+	orig := settings
+	settings = &config.Settings{}
+	t.Cleanup(func() { settings = orig })
+	return settings
+}
+
+func TestConnectLoopRetriesFailedConnects(t *testing.T) {
+	errOffline := fmt.Errorf("agent offline")
+	errCancelled := fmt.Errorf("selection cancelled")
+	tests := []struct {
+		name string
+		// connects and picks are the results of successive connect and
+		// reselect calls, in order
+		connects     []error
+		picks        []error
+		retry        bool
+		wantErr      error
+		wantConnects []string
+	}{
+		{"first connect succeeds", []error{nil}, nil, true, nil, []string{"i-0"}},
+		{"failure without retry", []error{errOffline}, nil, false, errOffline, []string{"i-0"}},
+		{"retry until success", []error{errOffline, errOffline, nil}, []error{nil, nil}, true, nil, []string{"i-0", "i-1", "i-2"}},
+		{"cancel after failure", []error{errOffline}, []error{errCancelled}, true, errCancelled, []string{"i-0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var connected []string
+			picked := 0
+			reselect := func() (string, string, error) {
+				err := tt.picks[picked]
+				picked++
+				return fmt.Sprintf("i-%d", picked), "", err
+			}
+			connect := func(id, name string) error {
+				connected = append(connected, id)
+				return tt.connects[len(connected)-1]
+			}
+			again := func(err error) bool { return err != nil && tt.retry }
+
+			err := connectLoop(func() (string, string, error) { return "i-0", "", nil }, reselect, connect, again)
+			if err != tt.wantErr {
+				t.Errorf("connectLoop() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(connected, tt.wantConnects) {
+				t.Errorf("connected to %v, want %v", connected, tt.wantConnects)
+			}
+			if picked != len(tt.picks) {
+				t.Errorf("reopened the finder %d times, want %d", picked, len(tt.picks))
+			}
+		})
+	}
+}
+
+func TestConnectLoopFirstSelectionFails(t *testing.T) {
+	errNoMatch := fmt.Errorf("no instances found")
+	err := connectLoop(
+		func() (string, string, error) { return "", "", errNoMatch },
+		func() (string, string, error) { t.Fatal("reselect called"); return "", "", nil },
+		func(string, string) error { t.Fatal("connect called"); return nil },
+		func(error) bool { return true },
+	)
+	if err != errNoMatch {
+		t.Errorf("connectLoop() error = %v, want %v", err, errNoMatch)
+	}
+}
+
+func TestPrintListIDsOnly(t *testing.T) {
+	defer func(ids bool, n int) { idsOnly, limit = ids, n }(idsOnly, limit)
+	idsOnly = true
+
+	instances := []selector.Instance{
+		{ID: "i-web1", Name: "web-1", PrivateIP: "10.0.0.1"},
+		{ID: "i-web2", Name: "web-2", PrivateIP: "10.0.0.2"},
+		{ID: "i-db1", Name: "db-1", PrivateIP: "10.0.1.1"},
+	}
+	tests := []struct {
+		name      string
+		instances []selector.Instance
+		filters   []string
+		limit     int
+		want      string
+	}{
+		{"all", instances, nil, 0, "i-web1\ni-web2\ni-db1\n"},
+		{"filtered", instances, []string{"web"}, 0, "i-web1\ni-web2\n"},
+		{"limited", instances, []string{"web"}, 1, "i-web1\n"},
+		{"no match prints nothing", instances, []string{"cache"}, 0, ""},
+		{"no instances prints nothing", nil, nil, 0, ""},
+	}
+	for _, tt := range tests {
+		limit = tt.limit
+		var err error
+		stdout, stderr := captureOutput(t, func() { err = printList(tt.instances, tt.filters, nil) })
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if stdout != tt.want || stderr != "" {
+			t.Errorf("%s: stdout %q, stderr %q; want exactly %q", tt.name, stdout, stderr, tt.want)
+		}
+	}
+}
+
+func TestValidateFlagsNoEC2(t *testing.T) {
+	defer func(tg, ex, az, pr []string, n bool) { tags, excludeTags, azs, preferTags, noEC2 = tg, ex, az, pr, n }(tags, excludeTags, azs, preferTags, noEC2)
+
+	tests := []struct {
+		name    string
+		set     func()
+		wantErr bool
+	}{
+		{"alone", func() {}, false},
+		{"with a tag filter", func() { tags = []string{"env=prod"} }, true},
+		{"with an excluded tag", func() { excludeTags = []string{"role=bastion"} }, true},
+		{"with an AZ filter", func() { azs = []string{"us-east-1a"} }, true},
+		{"with --prefer", func() { preferTags = []string{"tag:env=prod"} }, true},
+	}
+	for _, tt := range tests {
+		tags, excludeTags, azs, preferTags, noEC2 = nil, nil, nil, nil, true
+		tt.set()
+		if err := validateFlags(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateFlags() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRecentInstances(t *testing.T) {
+	running := []selector.Instance{
+		{ID: "i-a", Name: "a", PrivateIP: "10.0.0.1"},
+		{ID: "i-b", Name: "b", PrivateIP: "10.0.0.2"},
+		{ID: "i-c", Name: "c", PrivateIP: "10.0.0.3"},
+	}
+	// Most recent first, with one instance that has since gone away
+	recent := []history.Entry{
+		{InstanceID: "i-c", Name: "c"},
+		{InstanceID: "i-old", Name: "old"},
+		{InstanceID: "i-a", Name: "a"},
+	}
+	tests := []struct {
+		name     string
+		showGone bool
+		want     []selector.Instance
+	}{
+		{"running only", false, []selector.Instance{running[2], running[0]}},
+		{"with gone", true, []selector.Instance{running[2], {ID: "i-old", Name: "old", State: stateGone}, running[0]}},
+	}
+	for _, tt := range tests {
+		if got := recentInstances(running, recent, tt.showGone); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: recentInstances() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if got := recentInstances(running, nil, true); got != nil {
+		t.Errorf("recentInstances() without history = %+v, want none", got)
+	}
+}
+
+func TestPrintListMarksGone(t *testing.T) {
+	defer func(r bool) { recentOnly = r }(recentOnly)
+	recentOnly = true
+
+	instances := []selector.Instance{{ID: "i-a", Name: "a", PrivateIP: "10.0.0.1"}, {ID: "i-old", Name: "old", State: stateGone}}
+	stdout, _ := captureOutput(t, func() {
+		if err := printList(instances, nil, nil); err != nil {
+			t.Error(err)
+		}
+	})
+	if want := "i-a\ta\t10.0.0.1\ni-old\told\t(gone)\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+
+	stdout, _ = captureOutput(t, func() { printList(nil, nil, nil) })
+	if stdout != "No recently used instances found\n" {
+		t.Errorf("empty recent list printed %q", stdout)
+	}
+}
+
+func TestPreRunGlyphs(t *testing.T) {
+	withSettings(t)
+	defer func(f string, g output.Glyphs) { glyphsFlag, glyphs = f, g }(glyphsFlag, glyphs)
+	dir := t.TempDir()
+	t.Setenv(paths.HomeEnv, dir)
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"glyphs":"ascii"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		flag    string
+		want    output.Glyphs
+		wantErr bool
+	}{
+		{"", output.ASCIIGlyphs, false},
+		{"none", output.NoGlyphs, false},
+		{"unicode", output.UnicodeGlyphs, false},
+		{"boxes", output.Glyphs{}, true},
+	}
+	for _, tt := range tests {
+		glyphsFlag, glyphs = tt.flag, output.Glyphs{}
+		err := preRun(rootCmd, nil)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("--glyphs %q: no error", tt.flag)
+			}
+			continue
+		}
+		if err != nil || glyphs != tt.want {
+			t.Errorf("--glyphs %q: glyphs = %+v, %v; want %+v", tt.flag, glyphs, err, tt.want)
+		}
+	}
+}
+
+func TestFastPath(t *testing.T) {
+	defer func(tg, ex, zones []string, group string) {
+		tags, excludeTags, azs, resourceGrp = tg, ex, zones, group
+	}(tags, excludeTags, azs, resourceGrp)
+	tests := []struct {
+		name string
+		args []string
+		set  func()
+		want bool
+	}{
+		{"full id", []string{"i-0123456789abcdef0"}, func() {}, true},
+		{"short id", []string{"i-0123abcd"}, func() {}, true},
+		{"id prefix", []string{"i-0123"}, func() {}, false},
+		{"name", []string{"web-1"}, func() {}, false},
+		{"several ids", []string{"i-0123abcd", "i-4567abcd"}, func() {}, false},
+		{"no args", nil, func() {}, false},
+		// Filters need EC2 details from discovery
+		{"tag filter", []string{"i-0123abcd"}, func() { tags = []string{"env=prod"} }, false},
+		{"exclude tag", []string{"i-0123abcd"}, func() { excludeTags = []string{"env=dev"} }, false},
+		{"az filter", []string{"i-0123abcd"}, func() { azs = []string{"us-east-1a"} }, false},
+		{"resource group", []string{"i-0123abcd"}, func() { resourceGrp = "fleet" }, false},
+	}
+	for _, tt := range tests {
+		tags, excludeTags, azs, resourceGrp = nil, nil, nil, ""
+		tt.set()
+		if got := fastPath(tt.args); got != tt.want {
+			t.Errorf("%s: fastPath(%q) = %t, want %t", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestSessionDocumentPrecedence(t *testing.T) {
+	defer func(v string) { sessionDoc = v }(sessionDoc)
+	tests := []struct {
+		name, config, flag string
+		want               string
+	}{
+		{"account default", "", "", ""},
+		{"config", "Corp-Shell", "", "Corp-Shell"},
+		{"flag over config", "Corp-Shell", "Audit-Shell", "Audit-Shell"},
+		{"flag only", "", "Audit-Shell", "Audit-Shell"},
+	}
+	for _, tt := range tests {
+		withSettings(t).SessionDocument = tt.config
+		sessionDoc = tt.flag
+		opts, err := clientOptions("")
+		if err != nil || opts.SessionDocument != tt.want {
+			t.Errorf("%s: SessionDocument = %q, %v; want %q", tt.name, opts.SessionDocument, err, tt.want)
+		}
+	}
+}
+
+func TestCheckStrict(t *testing.T) {
+	defer func(s, r bool) { strictFlag, retrySelect = s, r }(strictFlag, retrySelect)
+	tests := []struct {
+		name    string
+		strict  bool
+		retry   bool
+		args    []string
+		wantErr string
+	}{
+		{"off", false, false, nil, ""},
+		{"with a name", true, false, []string{"web"}, ""},
+		// Both would open the finder
+		{"without a name", true, false, nil, "--strict needs an instance name or ID"},
+		{"with --retry-select", true, true, []string{"web"}, "--strict cannot be combined with --retry-select"},
+	}
+	for _, tt := range tests {
+		strictFlag, retrySelect = tt.strict, tt.retry
+		err := checkStrict(tt.args)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+			t.Errorf("%s: checkStrict() = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFanOutTarget(t *testing.T) {
+	defer func(all, glob bool, tg []string, rg string) {
+		runAll, globFlag, tags, resourceGrp = all, glob, tg, rg
+	}(runAll, globFlag, tags, resourceGrp)
+
+	tests := []struct {
+		args        []string
+		all, glob   bool
+		tags        []string
+		group       string
+		wantPattern string
+		wantCommand string
+		wantOK      bool
+	}{
+		{[]string{"uptime"}, true, false, nil, "", "", "uptime", true},
+		{[]string{"df", "-h"}, true, false, nil, "", "", "df -h", true},
+		// Filters alone select the targets
+		{[]string{"uptime"}, false, false, []string{"Env=stage"}, "", "", "uptime", true},
+		{[]string{"uptime"}, false, false, nil, "prod-web", "", "uptime", true},
+		{[]string{"*stage*", "uptime"}, false, false, nil, "", "*stage*", "uptime", true},
+		{[]string{"/^web-[0-9]+$/", "df", "-h"}, false, false, nil, "", "/^web-[0-9]+$/", "df -h", true},
+		{[]string{"web-?", "uptime"}, false, true, nil, "", "web-?", "uptime", true},
+
+		// A single target
+		{[]string{"web-1", "uptime"}, false, false, nil, "", "", "", false},
+		{[]string{"i-0abc", "uptime"}, false, false, []string{"Env=stage"}, "", "", "", false},
+		{[]string{"uptime"}, false, false, nil, "", "", "", false},
+	}
+	for _, tt := range tests {
+		runAll, globFlag, tags, resourceGrp = tt.all, tt.glob, tt.tags, tt.group
+		pattern, command, ok := fanOutTarget(tt.args)
+		if pattern != tt.wantPattern || command != tt.wantCommand || ok != tt.wantOK {
+			t.Errorf("fanOutTarget(%q) with --all=%t --glob=%t --tag=%q --resource-group=%q = (%q, %q, %t), want (%q, %q, %t)",
+				tt.args, tt.all, tt.glob, tt.tags, tt.group, pattern, command, ok, tt.wantPattern, tt.wantCommand, tt.wantOK)
+		}
+	}
+}
+
+func TestDumpDiscovery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") == "" {
+			// EC2 speaks the query protocol and only details running instances
+			w.Header().Set("Content-Type", "text/xml")
+			io.WriteString(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet><item>
+				<instanceId>i-0web</instanceId><instanceState><name>running</name></instanceState>
+				<privateIpAddress>10.0.0.5</privateIpAddress><placement><availabilityZone>us-east-1b</availabilityZone></placement>
+				<tagSet><item><key>Name</key><value>web-1</value></item><item><key>Env</key><value>prod</value></item></tagSet>
+				</item></instancesSet></item></reservationSet></DescribeInstancesResponse>`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if r.Header.Get("X-Amz-Target") != "AmazonSSM.DescribeInstanceInformation" {
+			io.WriteString(w, `{}`)
+			return
+		}
+		io.WriteString(w, `{"InstanceInformationList":[
+			{"InstanceId":"i-0web","PingStatus":"Online","PlatformType":"Linux","AgentVersion":"3.3.987.0","LastPingDateTime":1767225600},
+			{"InstanceId":"i-0old","PingStatus":"ConnectionLost","PlatformType":"Windows","AgentVersion":"3.1.0.0"}]}`)
+	}))
+	t.Cleanup(server.Close)
+	cfg := aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	}
+	// Filters do not apply to the dump
+	client := ssm.NewClient(cfg, output.New(false, output.UnicodeGlyphs), ssm.Options{Tags: []selector.TagFilter{{Key: "Env", Value: "staging"}}})
+
+	var err error
+	stdout, _ := captureOutput(t, func() { err = dumpDiscovery(context.Background(), client) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	kind, data := decodeEnvelope(t, stdout)
+	if kind != "discovery" {
+		t.Errorf("kind = %q, want discovery", kind)
+	}
+	dump := data.(map[string]any)
+	if dump["region"] != "us-east-1" || dump["count"] != float64(2) {
+		t.Errorf("region %v, count %v; want us-east-1, 2", dump["region"], dump["count"])
+	}
+	instances := dump["instances"].([]any)
+	want := []map[string]any{
+		{
+			// SSM fields
+			"ID": "i-0web", "SSMStatus": "Online", "PlatformType": "Linux", "AgentVersion": "3.3.987.0",
+			"LastPing": "2026-01-01T00:00:00Z",
+			// EC2 fields
+			"Name": "web-1", "State": "running", "PrivateIP": "10.0.0.5", "AZ": "us-east-1b",
+			"Tags": map[string]any{"Name": "web-1", "Env": "prod"},
+		},
+		// Offline and without EC2 details, but still there
+		{"ID": "i-0old", "SSMStatus": "ConnectionLost", "PlatformType": "Windows", "AgentVersion": "3.1.0.0", "Name": "", "State": ""},
+	}
+	if len(instances) != len(want) {
+		t.Fatalf("dumped %d instances, want %d: %v", len(instances), len(want), instances)
+	}
+	for i, fields := range want {
+		got := instances[i].(map[string]any)
+		for field, value := range fields {
+			if !reflect.DeepEqual(got[field], value) {
+				t.Errorf("instance %d %s = %v, want %v", i, field, got[field], value)
+			}
+		}
+	}
+}
+
+func TestRegionFallback(t *testing.T) {
+	defer func(r string, p bool) { region, regionPrompt = r, p }(region, regionPrompt)
+	defer func(term func() bool, choose func([]string, string) (string, error)) {
+		stdinIsTerminal, chooseRegion = term, choose
+	}(stdinIsTerminal, chooseRegion)
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	withSettings(t)
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	os.WriteFile(configFile, []byte(`[profile eu]
+region = eu-central-1
+aws_access_key_id = AKID
+aws_secret_access_key = SECRET
+
+[profile bare]
+aws_access_key_id = AKID
+aws_secret_access_key = SECRET
+`), 0600)
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		io.WriteString(w, `<DescribeRegionsResponse><regionInfo>
+			<item><regionName>us-west-2</regionName></item><item><regionName>ap-south-1</regionName></item>
+			</regionInfo></DescribeRegionsResponse>`)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+
+	tests := []struct {
+		name       string
+		profile    string
+		flag       string
+		prompt     bool
+		terminal   bool
+		want       string
+		wantPrompt bool
+		wantErr    string
+	}{
+		{"flag", "eu", "us-west-2", true, true, "us-west-2", false, ""},
+		{"profile", "eu", "", true, true, "eu-central-1", false, ""},
+		// Without the opt-in a missing region is left for AWS to reject
+		{"not opted in", "bare", "", false, true, "", false, ""},
+		{"no terminal", "bare", "", true, false, "", false, "no region configured"},
+		{"picked", "bare", "", true, true, "ap-south-1", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			region, regionPrompt = tt.flag, tt.prompt
+			stdinIsTerminal = func() bool { return tt.terminal }
+			var offered [][]string
+			chooseRegion = func(names []string, profileName string) (string, error) {
+				offered = append(offered, names)
+				return names[0], nil
+			}
+
+			client, err := newClientFor(tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("newClientFor(%q) error = %v, want %q", tt.profile, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if client.Region() != tt.want {
+				t.Errorf("region = %q, want %q", client.Region(), tt.want)
+			}
+			if !tt.wantPrompt {
+				if len(offered) > 0 {
+					t.Errorf("asked for a region: %q", offered)
+				}
+				return
+			}
+
+			// The pick is kept for every later client of the run
+			if _, err := newClientFor(tt.profile); err != nil {
+				t.Fatal(err)
+			}
+			if want := [][]string{{"ap-south-1", "us-west-2"}}; !reflect.DeepEqual(offered, want) {
+				t.Errorf("offered %q, want %q once", offered, want)
+			}
+			if region != tt.want {
+				t.Errorf("--region = %q after the pick, want %q", region, tt.want)
+			}
+		})
+	}
+}
+
+func TestLongFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"aws-ssm-connect", "-run", "web", "uptime"}, []string{"aws-ssm-connect", "--run", "web", "uptime"}},
+		{[]string{"aws-ssm-connect", "-copy", "a", "web:/tmp/a"}, []string{"aws-ssm-connect", "--copy", "a", "web:/tmp/a"}},
+		{[]string{"aws-ssm-connect", "-run-all", "--tag", "Env=stage", "uptime"}, []string{"aws-ssm-connect", "--run", "--all", "--tag", "Env=stage", "uptime"}},
+		{[]string{"aws-ssm-connect", "--run-all", "df -h"}, []string{"aws-ssm-connect", "--run", "--all", "df -h"}},
+		// Only whole arguments are flags
+		{[]string{"aws-ssm-connect", "--run", "web", "echo -run-all"}, []string{"aws-ssm-connect", "--run", "web", "echo -run-all"}},
+	}
+	for _, tt := range tests {
+		if got := longFlags(tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("longFlags(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/e/aws-ssm-connect/internal/confirm"
+	"github.com/e/aws-ssm-connect/internal/selector"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+// Entries of the --menu action menu.
+const (
+	menuShell   = "shell"
+	menuForward = "forward"
+	menuRun     = "run"
+	menuInfo    = "info"
+	menuCopy    = "copy"
+)
+
+// menuItems are shown in the finder, each entry with its description.
+var menuItems = []selector.Item{
+	{Key: menuShell, Label: "Open a shell"},
+	{Key: menuForward, Label: "Forward a port"},
+	{Key: menuRun, Label: "Run a command"},
+	{Key: menuInfo, Label: "Show details"},
+	{Key: menuCopy, Label: "Copy a file"},
+}
+
+// runMenu lets the user choose what to do with the selected instance,
+// asking for what the choice needs (port, command, paths) and dispatching
+// to the matching action. Details are shown and the menu reopened.
+func runMenu(ctx context.Context, client *ssm.Client, instanceID, instanceName string) error {
+	target := instanceID
+	if instanceName != "" {
+		target = instanceName + " (" + instanceID + ")"
+	}
+	for {
+		i, err := pick(client, menuItems, "actions for "+target)
+		if err != nil {
+			return err
+		}
+
+		switch entry := menuItems[i].Key; entry {
+		case menuShell:
+			return runAction(ctx, client, actionShell, instanceID, instanceName)
+		case menuForward:
+			spec, err := confirm.Input("Port (port or local:remote)")
+			if err != nil {
+				return err
+			}
+			if _, _, err := ssm.ParsePortSpec(spec); err != nil {
+				return err
+			}
+			portFlag = spec
+			return runAction(ctx, client, actionForward, instanceID, instanceName)
+		case menuRun:
+			command, err := confirm.Input("Command")
+			if err != nil {
+				return err
+			}
+			if command == "" {
+				return fmt.Errorf("no command given")
+			}
+			commandFlag = command
+			return runAction(ctx, client, actionRun, instanceID, instanceName)
+		case menuInfo:
+			inst, err := client.FindInstance(ctx, instanceID)
+			if err != nil {
+				return err
+			}
+			if err := printInfo(inst); err != nil {
+				return err
+			}
+			if _, err := confirm.Input("Press Enter to return to the menu"); err != nil {
+				return err
+			}
+		case menuCopy:
+			return menuCopyFile(ctx, client, instanceID)
+		default:
+			return fmt.Errorf("unknown menu entry %q", entry)
+		}
+	}
+}
+
+// menuCopyFile asks for an upload, or a download when no local file is
+// given, and runs it like -copy.
+func menuCopyFile(ctx context.Context, client *ssm.Client, instanceID string) error {
+	local, err := confirm.Input("Local file to upload (empty to download)")
+	if err != nil {
+		return err
+	}
+	if local != "" {
+		remote, err := confirm.Input("Remote path")
+		if err != nil {
+			return err
+		}
+		return handleCopy(ctx, client, []string{local, instanceID + ":" + remote})
+	}
+
+	remote, err := confirm.Input("Remote file to download")
+	if err != nil {
+		return err
+	}
+	if remote == "" {
+		return fmt.Errorf("no remote file given")
+	}
+	if local, err = confirm.Input("Local path (empty for the current directory)"); err != nil {
+		return err
+	}
+	if local == "" {
+		local = "."
+	}
+	return handleCopy(ctx, client, []string{instanceID + ":" + remote, local})
+}
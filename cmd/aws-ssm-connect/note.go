@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/e/aws-ssm-connect/internal/notes"
+)
+
+var noteCmd = &cobra.Command{
+	Use:   "note",
+	Short: "Manage local notes attached to instances",
+}
+
+var noteAddCmd = &cobra.Command{
+	Use:   "add <name|id> <text...>",
+	Short: "Attach a note to an instance (replaces any existing note)",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		instanceID, err := resolveNoteTarget(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		n, err := notes.Load()
+		if err != nil {
+			return err
+		}
+		return n.Set(instanceID, strings.Join(args[1:], " "))
+	},
+}
+
+var noteRemoveCmd = &cobra.Command{
+	Use:     "remove <name|id>",
+	Aliases: []string{"rm"},
+	Short:   "Remove the note from an instance",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		instanceID, err := resolveNoteTarget(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		n, err := notes.Load()
+		if err != nil {
+			return err
+		}
+		return n.Remove(instanceID)
+	},
+}
+
+var noteListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all notes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := notes.Load()
+		if err != nil {
+			return err
+		}
+		all := n.All()
+		ids := make([]string, 0, len(all))
+		for id := range all {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			fmt.Printf("%s\t%s\n", id, all[id])
+		}
+		return nil
+	},
+}
+
+// resolveNoteTarget maps a name to an instance ID; IDs are used as-is so notes
+// can be managed without AWS access.
+func resolveNoteTarget(ctx context.Context, instance string) (string, error) {
+	if strings.HasPrefix(instance, "i-") {
+		return instance, nil
+	}
+	client, err := newClient()
+	if err != nil {
+		return "", err
+	}
+	return resolveInstance(ctx, client, instance)
+}
+
+func init() {
+	noteCmd.AddCommand(noteAddCmd, noteRemoveCmd, noteListCmd)
+	rootCmd.AddCommand(noteCmd)
+}
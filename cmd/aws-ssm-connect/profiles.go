@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+	"github.com/e/aws-ssm-connect/internal/selector"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+// profileColumns are shown by default when instances come from several profiles.
+const profileColumns = "id,name,ip,profile"
+
+// profileResult is the discovery outcome for one profile.
+type profileResult struct {
+	profile   string
+	client    *ssm.Client
+	instances []selector.Instance
+	err       error
+}
+
+// runProfiles lists or connects to instances discovered across --profiles.
+// A profile that fails (e.g. expired credentials) is reported and skipped.
+func runProfiles(ctx context.Context, args []string) error {
+	switch {
+	case profile != "":
+		return fmt.Errorf("--profiles cannot be combined with --profile")
+	case copyFlag || runFlag:
+		return fmt.Errorf("--profiles only supports listing and connecting")
+	case recentOnly:
+		return fmt.Errorf("--profiles cannot be combined with --recent")
+	case jsonLines:
+		return fmt.Errorf("--profiles cannot be combined with --jsonl")
+	}
+
+	results := discoverProfiles(ctx, profiles)
+	if failFast {
+		// Profiles canceled because of the failure are not the cause
+		for _, r := range results {
+			if r.err != nil && !errors.Is(r.err, context.Canceled) {
+				return fmt.Errorf("profile %s: %w", r.profile, r.err)
+			}
+		}
+	}
+
+	clients, merged, failed := mergeProfiles(results)
+	if len(clients) == 0 {
+		return fmt.Errorf("discovery failed for all profiles")
+	}
+	warnFailedProfiles(newOutput(), failed, len(results))
+
+	var regionName string
+	for _, r := range results {
+		if r.err == nil {
+			regionName = r.client.Region()
+		}
+	}
+
+	cols := columns
+	if cols == nil {
+		var err error
+		if cols, err = selector.ParseColumns(profileColumns); err != nil {
+			return err
+		}
+	}
+
+	if listFlag {
+		return printList(merged, args, cols)
+	}
+
+	args = withQueryNames(args)
+	action, err := resolveAction(settings.DefaultAction)
+	if err != nil {
+		return err
+	}
+	if err := checkStrict(args); err != nil {
+		return err
+	}
+
+	inst, err := pickAcrossProfiles(merged, args, cols, regionName)
+	if err != nil {
+		return err
+	}
+	return runAction(ctx, clients[inst.Profile], action, inst.ID, inst.Name)
+}
+
+// discoverProfiles lists running instances for each profile concurrently.
+// Results keep the order of names, and each instance is tagged with its profile.
+// With --fail-fast the first failure cancels discovery in the other profiles.
+func discoverProfiles(ctx context.Context, names []string) []profileResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]profileResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			r := profileResult{profile: name}
+			if r.client, r.err = newClientFor(name); r.err == nil {
+				r.instances, r.err = r.client.GetRunningInstances(ctx)
+			}
+			for j := range r.instances {
+				r.instances[j].Profile = name
+			}
+			if r.err != nil && failFast {
+				cancel()
+			}
+			results[i] = r
+		}(i, name)
+	}
+	wg.Wait()
+	return results
+}
+
+// mergeProfiles combines the profiles that were discovered and returns the
+// ones that failed separately, so one bad profile does not hide the others.
+func mergeProfiles(results []profileResult) (map[string]*ssm.Client, []selector.Instance, []profileResult) {
+	clients := make(map[string]*ssm.Client)
+	var merged []selector.Instance
+	var failed []profileResult
+	for _, r := range results {
+		if r.err != nil {
+			failed = append(failed, r)
+			continue
+		}
+		clients[r.profile] = r.client
+		merged = append(merged, r.instances...)
+	}
+	return clients, merged, failed
+}
+
+// warnFailedProfiles summarizes which profiles were skipped and why.
+func warnFailedProfiles(out *output.Output, failed []profileResult, total int) {
+	if len(failed) == 0 {
+		return
+	}
+	out.Warning("Discovery failed for %d of %d profiles; showing instances from the rest:", len(failed), total)
+	for _, r := range failed {
+		out.Warning("  %s: %v", r.profile, r.err)
+	}
+}
+
+// pickAcrossProfiles resolves names against the merged instances like
+// SelectByName, opening the finder when there is more than one candidate.
+func pickAcrossProfiles(instances []selector.Instance, names []string, cols []selector.Column, regionName string) (selector.Instance, error) {
+	prefer, err := parsePrefer(preferTags)
+	if err != nil {
+		return selector.Instance{}, err
+	}
+	candidates := instances
+	if len(names) > 0 {
+		groups := make([][]selector.Instance, 0, len(names))
+		for _, name := range names {
+			if globFlag || selector.IsGlob(name) {
+				found, err := selector.FindByGlob(instances, name)
+				if err != nil {
+					return selector.Instance{}, err
+				}
+				groups = append(groups, found)
+			} else {
+				groups = append(groups, selector.FindByName(instances, name))
+			}
+		}
+		candidates = unionByProfile(groups...)
+		if len(candidates) == 0 {
+			return selector.Instance{}, fmt.Errorf("no instances found matching any of %q", names)
+		}
+		if len(candidates) == 1 {
+			return candidates[0], nil
+		}
+		if strictFlag {
+			return selector.Instance{}, selector.AmbiguousError(names, candidates)
+		}
+		if selectFirst {
+			best := selector.Rank(candidates, names, nil, prefer)[0]
+			newOutput().Notice("Auto-selected %s (%s, profile %s), the best of %d matches", best.ID, best.Name, best.Profile, len(candidates))
+			return best, nil
+		}
+	}
+	if len(candidates) == 0 {
+		return selector.Instance{}, fmt.Errorf("no running SSM-managed instances found")
+	}
+
+	res, err := selector.SelectInstance(candidates, selector.Options{
+		Profile:   strings.Join(profiles, ","),
+		Region:    regionName,
+		Columns:   cols,
+		Inline:    inlineRows,
+		NameWidth: nameWidth,
+		Prefer:    prefer,
+	})
+	return res.Instance, err
+}
+
+// unionByProfile merges instance lists, dropping repeats of the same
+// instance in the same profile. The same ID seen through two profiles
+// (e.g. two roles in one account) is kept once per profile.
+func unionByProfile(groups ...[]selector.Instance) []selector.Instance {
+	seen := make(map[string]bool)
+	var merged []selector.Instance
+	for _, group := range groups {
+		for _, inst := range group {
+			key := inst.Profile + "/" + inst.ID
+			if !seen[key] {
+				seen[key] = true
+				merged = append(merged, inst)
+			}
+		}
+	}
+	return merged
+}
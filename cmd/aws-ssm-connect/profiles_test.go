@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+	"github.com/e/aws-ssm-connect/internal/selector"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+func TestMergeProfiles(t *testing.T) {
+	prod, dev := new(ssm.Client), new(ssm.Client)
+	errExpired := errors.New("token expired")
+	results := []profileResult{
+		{profile: "prod", client: prod, instances: []selector.Instance{{ID: "i-1", Profile: "prod"}, {ID: "i-2", Profile: "prod"}}},
+		{profile: "stage", err: errExpired},
+		{profile: "dev", client: dev, instances: []selector.Instance{{ID: "i-1", Profile: "dev"}}},
+	}
+
+	clients, merged, failed := mergeProfiles(results)
+	if len(clients) != 2 || clients["prod"] != prod || clients["dev"] != dev {
+		t.Errorf("clients = %v, want prod and dev", clients)
+	}
+	want := []selector.Instance{{ID: "i-1", Profile: "prod"}, {ID: "i-2", Profile: "prod"}, {ID: "i-1", Profile: "dev"}}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("merged = %+v, want %+v", merged, want)
+	}
+	if len(failed) != 1 || failed[0].profile != "stage" || failed[0].err != errExpired {
+		t.Errorf("failed = %+v, want stage", failed)
+	}
+
+	_, stderr := captureOutput(t, func() { warnFailedProfiles(newOutput(), failed, len(results)) })
+	if !strings.Contains(stderr, "1 of 3 profiles") || !strings.Contains(stderr, "stage: token expired") {
+		t.Errorf("warning = %q", stderr)
+	}
+}
+
+func TestUnionByProfile(t *testing.T) {
+	a := []selector.Instance{{ID: "i-1", Profile: "prod"}, {ID: "i-2", Profile: "prod"}}
+	b := []selector.Instance{{ID: "i-2", Profile: "prod"}, {ID: "i-2", Profile: "dev"}}
+	want := []selector.Instance{{ID: "i-1", Profile: "prod"}, {ID: "i-2", Profile: "prod"}, {ID: "i-2", Profile: "dev"}}
+	if got := unionByProfile(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("unionByProfile() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPickAcrossProfiles(t *testing.T) {
+	defer func(s, f bool) { strictFlag, selectFirst = s, f }(strictFlag, selectFirst)
+
+	instances := []selector.Instance{
+		{ID: "i-web1", Name: "web", Profile: "prod"},
+		{ID: "i-db1", Name: "db-1", Profile: "prod"},
+		{ID: "i-web9", Name: "web-9", Profile: "dev"},
+	}
+	tests := []struct {
+		name        string
+		names       []string
+		strict      bool
+		first       bool
+		wantID      string
+		wantProfile string
+		wantErr     string
+	}{
+		{"unique name in the second profile", []string{"web-9"}, false, false, "i-web9", "dev", ""},
+		{"union of names", []string{"db", "nothing"}, false, false, "i-db1", "prod", ""},
+		{"no match", []string{"cache"}, false, false, "", "", "no instances found matching"},
+		{"ambiguous with --strict", []string{"web"}, true, false, "", "", `matches 2 instances`},
+		{"best with --select-first", []string{"web"}, false, true, "i-web1", "prod", ""},
+	}
+	for _, tt := range tests {
+		strictFlag, selectFirst = tt.strict, tt.first
+		var got selector.Instance
+		var err error
+		captureOutput(t, func() { got, err = pickAcrossProfiles(instances, tt.names, nil, "us-east-1") })
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		// The action runs with the client of the instance's own profile
+		if err != nil || got.ID != tt.wantID || got.Profile != tt.wantProfile {
+			t.Errorf("%s: picked %s in %s, %v; want %s in %s", tt.name, got.ID, got.Profile, err, tt.wantID, tt.wantProfile)
+		}
+	}
+}
+
+// profileServer answers discovery for one profile with a single online
+// instance named after it, or with AccessDenied when instance is empty.
+func profileServer(t *testing.T, instance string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if instance == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"__type":"AccessDeniedException","Message":"not authorized"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"InstanceInformationList":[{"InstanceId":%q,"PingStatus":"Online","PlatformType":"Linux"}]}`, instance)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestRunProfilesPartialResults(t *testing.T) {
+	defer func(p []string, pr string, l, f, n, ids bool) {
+		profiles, profile, listFlag, failFast, noEC2, idsOnly = p, pr, l, f, n, ids
+	}(profiles, profile, listFlag, failFast, noEC2, idsOnly)
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	withSettings(t)
+
+	// Each profile has its own endpoint; broken and expired are refused
+	var config strings.Builder
+	for _, p := range []struct{ name, instance string }{
+		{"prod", "i-prod"}, {"dev", "i-dev"}, {"broken", ""}, {"expired", ""},
+	} {
+		fmt.Fprintf(&config, "[profile %s]\nregion = us-east-1\naws_access_key_id = AKID\naws_secret_access_key = SECRET\nendpoint_url = %s\n\n",
+			p.name, profileServer(t, p.instance))
+	}
+	configFile := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(configFile, []byte(config.String()), 0600)
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ENDPOINT_URL", "")
+
+	tests := []struct {
+		name        string
+		profiles    []string
+		failFast    bool
+		wantListed  string
+		wantWarning []string
+		wantErr     string
+	}{
+		{"all fine", []string{"prod", "dev"}, false, "i-prod\ni-dev\n", nil, ""},
+		{"partial", []string{"prod", "broken", "dev", "expired"}, false, "i-prod\ni-dev\n",
+			[]string{"Discovery failed for 2 of 4 profiles", "broken: ", "expired: ", "AccessDenied"}, ""},
+		{"fail fast", []string{"prod", "broken", "dev"}, true, "", nil, "profile broken: "},
+		{"all failed", []string{"broken", "expired"}, false, "", nil, "discovery failed for all profiles"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profiles, profile, listFlag, failFast, noEC2, idsOnly = tt.profiles, "", true, tt.failFast, true, true
+			var err error
+			stdout, stderr := captureOutput(t, func() { err = runProfiles(context.Background(), nil) })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("runProfiles() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if stdout != tt.wantListed {
+				t.Errorf("listed %q, want %q", stdout, tt.wantListed)
+			}
+			for _, want := range tt.wantWarning {
+				if !strings.Contains(stderr, want) {
+					t.Errorf("stderr %q does not report %q", stderr, want)
+				}
+			}
+			if tt.wantWarning == nil && strings.Contains(stderr, "Discovery failed") {
+				t.Errorf("unexpected failure summary: %q", stderr)
+			}
+		})
+	}
+}
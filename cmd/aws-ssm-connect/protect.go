@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/e/aws-ssm-connect/internal/confirm"
+	"github.com/e/aws-ssm-connect/internal/history"
+	"github.com/e/aws-ssm-connect/internal/selector"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+// matchProtected returns the first protected tag the instance carries, if any.
+func matchProtected(inst selector.Instance, rules []selector.TagFilter) (selector.TagFilter, bool) {
+	for _, r := range rules {
+		if r.Matches(inst) {
+			return r, true
+		}
+	}
+	return selector.TagFilter{}, false
+}
+
+// confirmProtected asks for confirmation on the terminal before acting on an
+// instance carrying one of the protected_tags from config. --yes skips the
+// prompt; without a terminal the action is refused. The tags are looked up
+// for this one instance in EC2; when they cannot be (including with
+// --no-ec2) the action is refused too, unless --yes is given.
+func confirmProtected(ctx context.Context, client *ssm.Client, instanceID string) error {
+	c := confirm.New(assumeYes)
+	if c.AssumesYes() {
+		return nil // skip the instance lookup too
+	}
+	if len(settings.ProtectedTags) == 0 {
+		return nil
+	}
+	rules, err := selector.ParseTagFilters(settings.ProtectedTags)
+	if err != nil {
+		return fmt.Errorf("config protected_tags: %w", err)
+	}
+
+	if noEC2 {
+		return fmt.Errorf("cannot check %s against protected_tags with --no-ec2 (tags come from EC2); pass --yes to act anyway", instanceID)
+	}
+	inst, err := client.EC2Instance(ctx, instanceID)
+	if err != nil {
+		return fmt.Errorf("cannot check %s against protected_tags (pass --yes to act anyway): %w", instanceID, err)
+	}
+	rule, ok := matchProtected(inst, rules)
+	if !ok {
+		return nil
+	}
+
+	label := inst.ID
+	if inst.Name != "" {
+		label = inst.Name + " (" + inst.ID + ")"
+	}
+	if err := c.Ask(fmt.Sprintf("%s is protected (%s). Continue?", label, rule)); err != nil {
+		return fmt.Errorf("%s is protected: %w", label, err)
+	}
+	return nil
+}
+
+// runPreview describes the command about to be sent to an instance.
+func runPreview(instanceID, instanceName, script string) string {
+	target := instanceID
+	if instanceName != "" && instanceName != instanceID {
+		target = instanceName + " (" + instanceID + ")"
+	}
+	return fmt.Sprintf("Run on %s:\n  %s\nProceed?", target, script)
+}
+
+// confirmRun shows the final script and waits for Enter before it is sent.
+// --yes skips the prompt; without a terminal and --yes the run is refused.
+func confirmRun(client *ssm.Client, instanceID, instanceName, command string) error {
+	c := confirm.New(assumeYes)
+	if c.AssumesYes() {
+		return nil
+	}
+	if err := c.RequireTerminal("run"); err != nil {
+		return err
+	}
+	script, err := client.PreviewCommand(command)
+	if err != nil {
+		return err
+	}
+	return c.AskDefaultYes(runPreview(instanceID, instanceName, script))
+}
+
+// maxReasonLength is the longest session reason SSM accepts.
+const maxReasonLength = 256
+
+// checkReason validates --reason and refuses to start a session without one
+// when config requires it for the profile; no profile counts as "default".
+func checkReason(profileName string) error {
+	if len(reason) > maxReasonLength {
+		return fmt.Errorf("--reason is %d characters, SSM accepts at most %d", len(reason), maxReasonLength)
+	}
+	if strings.TrimSpace(reason) != "" {
+		return nil
+	}
+	if profileName == "" {
+		profileName = history.DefaultScope
+	}
+	if settings.ReasonRequired(profileName) {
+		return fmt.Errorf("a --reason is required for sessions with profile %s", profileName)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/confirm"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+func TestRunPreview(t *testing.T) {
+	tests := []struct {
+		name     string
+		id, inst string
+		command  string
+		opts     ssm.CommandOptions
+		want     string
+	}{
+		{"id only", "i-1", "", "uptime", ssm.CommandOptions{},
+			"Run on i-1:\n  uptime\nProceed?"},
+		{"named", "i-1", "web-1", "uptime", ssm.CommandOptions{},
+			"Run on web-1 (i-1):\n  uptime\nProceed?"},
+		// Addressed by ID, the name is the ID and not repeated
+		{"name is id", "i-1", "i-1", "uptime", ssm.CommandOptions{},
+			"Run on i-1:\n  uptime\nProceed?"},
+		{"wrapped", "i-1", "web-1", "make", ssm.CommandOptions{Sudo: true, Workdir: "/srv", Env: []string{"A=1"}},
+			"Run on web-1 (i-1):\n  sudo sh -c 'export A='\\''1'\\''; cd '\\''/srv'\\'' && make'\nProceed?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeSSMClient(t, ssm.Options{Command: tt.opts}, func(string, map[string]any) any { return map[string]any{} })
+			script, err := client.PreviewCommand(tt.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := runPreview(tt.id, tt.inst, script); got != tt.want {
+				t.Errorf("preview = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfirmRunWithoutTerminal(t *testing.T) {
+	defer func(v bool) { assumeYes = v }(assumeYes)
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	t.Setenv(confirm.AssumeYesEnv, "")
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	os.Stdin = devNull
+
+	tests := []struct {
+		name    string
+		yes     bool
+		wantErr string
+	}{
+		{"refused", false, "refusing to run non-interactively without --yes"},
+		{"with --yes", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assumeYes = tt.yes
+			// No preview is built either way, so no client is needed
+			err := confirmRun(nil, "i-1", "web-1", "uptime")
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("confirmRun() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckReason(t *testing.T) {
+	defer func(v string) { reason = v }(reason)
+	tests := []struct {
+		name     string
+		require  bool
+		profiles []string
+		profile  string
+		reason   string
+		wantErr  string
+	}{
+		{"not required", false, nil, "prod", "", ""},
+		{"required", true, nil, "dev", "", "a --reason is required for sessions with profile dev"},
+		{"required and given", true, nil, "dev", "INC-42 disk full", ""},
+		{"blank reason", true, nil, "dev", "   ", "a --reason is required"},
+		{"required for the profile", false, []string{"prod"}, "prod", "", "profile prod"},
+		{"other profile", false, []string{"prod"}, "dev", "", ""},
+		{"default profile", false, []string{"default"}, "", "", "profile default"},
+		// Checked even when no reason is required
+		{"too long", false, nil, "dev", strings.Repeat("x", maxReasonLength+1), "SSM accepts at most 256"},
+	}
+	for _, tt := range tests {
+		s := withSettings(t)
+		s.RequireReason, s.RequireReasonProfiles = tt.require, tt.profiles
+		reason = tt.reason
+		err := checkReason(tt.profile)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: checkReason(%q) = %v, want %q", tt.name, tt.profile, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRunActionRequiresReason(t *testing.T) {
+	resetActionFlags(t)
+	defer func(v string) { reason = v }(reason)
+	reason = ""
+	withSettings(t).RequireReason = true
+	client := fakeSSMClient(t, ssm.Options{}, func(string, map[string]any) any { return map[string]any{} })
+
+	tests := []struct {
+		action  string
+		wantErr bool
+	}{
+		{actionShell, true},
+		{actionSocks, true},
+		// Printing starts no session
+		{actionPrint, false},
+	}
+	for _, tt := range tests {
+		var err error
+		captureOutput(t, func() { err = runAction(context.Background(), client, tt.action, "i-1", "web-1") })
+		if tt.wantErr != (err != nil && strings.Contains(err.Error(), "a --reason is required")) {
+			t.Errorf("runAction(%s) = %v, want reason error %t", tt.action, err, tt.wantErr)
+		}
+	}
+}
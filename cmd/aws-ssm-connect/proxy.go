@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// proxyCmd is used as an ssh ProxyCommand by --socks; it pipes stdio to the
+// instance's SSH port over an SSM session.
+var proxyCmd = &cobra.Command{
+	Use:    "proxy <instance-id> [port]",
+	Short:  "Tunnel stdin/stdout to an instance's SSH port (for ssh ProxyCommand)",
+	Hidden: true,
+	Args:   cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		port := 22
+		if len(args) > 1 {
+			p, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid port %q", args[1])
+			}
+			port = p
+		}
+
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		return client.ProxySSH(cmd.Context(), args[0], profile, port)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(proxyCmd)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/e/aws-ssm-connect/internal/query"
+)
+
+// savedQuery holds filters loaded via --query or --query-file.
+var savedQuery = &query.Query{}
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Manage saved discovery queries",
+}
+
+var querySaveCmd = &cobra.Command{
+	Use:   "save <name> [name words...]",
+	Short: "Save the current --tag, --exclude-tag, --region and name words as a query",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q := &query.Query{
+			Tags:        tags,
+			ExcludeTags: excludeTags,
+			Names:       withQueryNames(args[1:]),
+			Region:      region,
+		}
+		if err := query.Save(args[0], q); err != nil {
+			return err
+		}
+
+		path, _ := query.Path(args[0])
+		fmt.Printf("Saved query %q to %s\n", args[0], path)
+		return nil
+	},
+}
+
+// applyQuery loads --query/--query-file and merges it into the flag values.
+// Flags given on the command line take precedence for scalar settings.
+func applyQuery(cmd *cobra.Command, args []string) error {
+	if queryName == "" && queryFile == "" {
+		return nil
+	}
+	if queryName != "" && queryFile != "" {
+		return fmt.Errorf("--query and --query-file are mutually exclusive")
+	}
+
+	var err error
+	if queryFile != "" {
+		savedQuery, err = query.LoadFile(queryFile)
+	} else {
+		savedQuery, err = query.Load(queryName)
+	}
+	if err != nil {
+		return err
+	}
+
+	tags = append(savedQuery.Tags, tags...)
+	excludeTags = append(savedQuery.ExcludeTags, excludeTags...)
+	if region == "" {
+		region = savedQuery.Region
+	}
+	return nil
+}
+
+// withQueryNames prepends name words from the saved query to args.
+func withQueryNames(args []string) []string {
+	if len(savedQuery.Names) == 0 {
+		return args
+	}
+	return append(append([]string{}, savedQuery.Names...), args...)
+}
+
+func init() {
+	queryCmd.AddCommand(querySaveCmd)
+	rootCmd.AddCommand(queryCmd)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/e/aws-ssm-connect/internal/confirm"
+	"github.com/e/aws-ssm-connect/internal/selector"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+// fanOutConfirmAt is the target count above which -run always asks first,
+// even off a terminal (where only --yes lets it through).
+const fanOutConfirmAt = 5
+
+// fanOutTarget reports whether -run args select several instances, returning
+// the target pattern ("" for every discovered instance) and the command:
+//
+//	-run --all <command>               every instance passing the filters
+//	-run --tag K=V <command>           the same, with only filters given
+//	-run <glob|/regex/> <command>      instances whose name matches
+func fanOutTarget(args []string) (pattern, command string, ok bool) {
+	filtered := len(tags) > 0 || len(excludeTags) > 0 || len(azs) > 0 || resourceGrp != ""
+	switch {
+	case runAll:
+		return "", strings.Join(args, " "), true
+	case len(args) == 1 && filtered:
+		return "", args[0], true
+	case len(args) >= 2 && (globFlag || selector.IsGlob(args[0]) || selector.IsRegex(args[0])):
+		return args[0], strings.Join(args[1:], " "), true
+	}
+	return "", "", false
+}
+
+// handleRunMulti runs command on every instance the pattern selects and
+// prints each instance's output under a header. It exits non-zero when the
+// command failed anywhere.
+func handleRunMulti(ctx context.Context, client *ssm.Client, pattern, command string) error {
+	if command == "" {
+		return fmt.Errorf("usage: aws-ssm-connect -run --all <command>")
+	}
+	if err := checkFanOutFlags(); err != nil {
+		return err
+	}
+
+	instances, err := client.GetRunningInstances(ctx)
+	if err != nil {
+		return err
+	}
+	targets, err := selector.MatchTargets(instances, pattern, globFlag)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no running instances match the -run target")
+	}
+	return runOnTargets(ctx, client, targets, command)
+}
+
+// handleRunStdin runs command on the instances listed on stdin, one ID or
+// name per line (-run - <command>). Blank lines and # comments are skipped,
+// and only the first field of a line counts, so --with-name output works.
+func handleRunStdin(ctx context.Context, client *ssm.Client, command string) error {
+	if err := checkFanOutFlags(); err != nil {
+		return err
+	}
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("-run - reads instance IDs or names from stdin; pipe them in")
+	}
+	entries, err := readTargets(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read targets from stdin: %w", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no instance IDs or names on stdin")
+	}
+
+	instances, err := client.GetRunningInstances(ctx)
+	if err != nil {
+		return err
+	}
+	targets, err := selector.ResolveTargets(instances, entries)
+	if err != nil {
+		return err
+	}
+	return runOnTargets(ctx, client, targets, command)
+}
+
+// readTargets reads the first field of each non-blank, non-comment line.
+func readTargets(r io.Reader) ([]string, error) {
+	var entries []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		entries = append(entries, fields[0])
+	}
+	return entries, scanner.Err()
+}
+
+// checkFanOutFlags rejects flags that only work with a single -run target.
+func checkFanOutFlags() error {
+	if noWait || tailFlag || killOnIdle > 0 {
+		return fmt.Errorf("--no-wait, --tail and --kill-on-idle only apply to a single -run target")
+	}
+	return nil
+}
+
+// runOnTargets confirms and runs command on targets, then prints each
+// instance's output under a header, exiting non-zero when the command
+// failed anywhere.
+func runOnTargets(ctx context.Context, client *ssm.Client, targets []selector.Instance, command string) error {
+	if err := confirmFanOut(client, targets, command); err != nil {
+		return err
+	}
+
+	ids := make([]string, len(targets))
+	names := make(map[string]string, len(targets))
+	for i, inst := range targets {
+		ids[i] = inst.ID
+		names[inst.ID] = inst.Name
+	}
+	invocations, err := client.RunCommandMulti(ctx, ids, command)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, inv := range invocations {
+		if inv.ExitCode != 0 {
+			failed++
+		}
+	}
+	if jsonFlag {
+		if err := newOutput().JSON("commands", invocations); err != nil {
+			return err
+		}
+	} else {
+		out := newOutput()
+		for _, inv := range invocations {
+			header := inv.InstanceID
+			if name := names[inv.InstanceID]; name != "" {
+				header = name + " (" + inv.InstanceID + ")"
+			}
+			out.Header(fmt.Sprintf("%s: %s", header, inv.Status))
+			printCommandOutput(out, inv.Stdout, inv.Stderr)
+		}
+		fmt.Println()
+		if failed > 0 {
+			out.Warning("%d of %d instances failed", failed, len(invocations))
+		} else {
+			out.Success("Succeeded on %d instances", len(invocations))
+		}
+	}
+	if failed > 0 {
+		return &ssm.ExitError{Code: 1}
+	}
+	return nil
+}
+
+// confirmFanOut lists the targets and asks before sending. Fan-outs larger
+// than fanOutConfirmAt or touching protected instances must be confirmed
+// (or --yes given); smaller ones default to yes, but like confirmRun still
+// need a terminal or --yes.
+func confirmFanOut(client *ssm.Client, targets []selector.Instance, command string) error {
+	c := confirm.New(assumeYes)
+	if c.AssumesYes() {
+		return nil
+	}
+	rules, err := selector.ParseTagFilters(settings.ProtectedTags)
+	if err != nil {
+		return fmt.Errorf("config protected_tags: %w", err)
+	}
+
+	var b strings.Builder
+	protected := 0
+	for _, inst := range targets {
+		b.WriteString("  " + inst.ID)
+		if inst.Name != "" {
+			b.WriteString("  " + inst.Name)
+		}
+		if rule, ok := matchProtected(inst, rules); ok {
+			b.WriteString("  (protected: " + rule.String() + ")")
+			protected++
+		}
+		b.WriteString("\n")
+	}
+	script, err := client.PreviewCommand(command)
+	if err != nil {
+		return err
+	}
+	question := fmt.Sprintf("Run on %d instances:\n%s  %s\nProceed?", len(targets), b.String(), script)
+
+	if len(targets) > fanOutConfirmAt || protected > 0 {
+		return c.Ask(question)
+	}
+	if err := c.RequireTerminal("run"); err != nil {
+		return err
+	}
+	return c.AskDefaultYes(question)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/selector"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+func TestStdinTargets(t *testing.T) {
+	instances := []selector.Instance{
+		{ID: "i-0aaa", Name: "web-1"},
+		{ID: "i-0bbb", Name: "web-2"},
+		{ID: "i-0ccc", Name: "db-1"},
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"ids", "i-0aaa\ni-0ccc\n", []string{"i-0aaa", "i-0ccc"}},
+		{"names", "web-2\ndb-1", []string{"i-0bbb", "i-0ccc"}},
+		// As printed by -l --with-name, with blanks and comments around
+		{"with names", "# staging\ni-0aaa\tweb-1\n\n  i-0bbb  web-2\n", []string{"i-0aaa", "i-0bbb"}},
+		{"repeated", "i-0aaa\nweb-1\ni-0aaa\n", []string{"i-0aaa"}},
+	}
+	for _, tt := range tests {
+		// An unbuffered pipe, as from another command
+		r, w := io.Pipe()
+		go func() {
+			io.WriteString(w, tt.input)
+			w.Close()
+		}()
+		entries, err := readTargets(r)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		targets, err := selector.ResolveTargets(instances, entries)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var ids []string
+		for _, inst := range targets {
+			ids = append(ids, inst.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("%s: targets %q, want %q", tt.name, ids, tt.want)
+		}
+	}
+}
+
+func TestRunOnTargets(t *testing.T) {
+	defer func(y, j bool) { assumeYes, jsonFlag = y, j }(assumeYes, jsonFlag)
+	assumeYes, jsonFlag = true, false
+	withSettings(t)
+
+	results := map[string]map[string]any{
+		"i-web1": {"Status": "Success", "ResponseCode": 0, "StandardOutputContent": "up 3 days\n"},
+		"i-web2": {"Status": "Success", "ResponseCode": 0, "StandardOutputContent": "up 9 days\n"},
+		"i-db1":  {"Status": "Failed", "ResponseCode": 1, "StandardErrorContent": "uptime: not found\n"},
+	}
+	tests := []struct {
+		name       string
+		targets    []selector.Instance
+		wantHeader []string
+		wantTail   string
+		wantExit   int
+	}{
+		{
+			name:       "all succeed",
+			targets:    []selector.Instance{{ID: "i-web1", Name: "web-1"}, {ID: "i-web2"}},
+			wantHeader: []string{"web-1 (i-web1): Success", "i-web2: Success"},
+			wantTail:   "Succeeded on 2 instances",
+		},
+		{
+			name:       "one fails",
+			targets:    []selector.Instance{{ID: "i-web1", Name: "web-1"}, {ID: "i-db1", Name: "db-1"}},
+			wantHeader: []string{"web-1 (i-web1): Success", "db-1 (i-db1): Failed"},
+			wantTail:   "1 of 2 instances failed",
+			wantExit:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent [][]any
+			client := fakeSSMClient(t, ssm.Options{}, func(target string, body map[string]any) any {
+				switch target {
+				case "AmazonSSM.SendCommand":
+					sent = append(sent, body["InstanceIds"].([]any))
+					return map[string]any{"Command": map[string]any{"CommandId": "cmd-1"}}
+				case "AmazonSSM.GetCommandInvocation":
+					return results[body["InstanceId"].(string)]
+				}
+				return map[string]any{}
+			})
+
+			var err error
+			stdout, stderr := captureOutput(t, func() { err = runOnTargets(context.Background(), client, tt.targets, "uptime") })
+			var exitErr *ssm.ExitError
+			switch {
+			case tt.wantExit == 0 && err != nil:
+				t.Fatal(err)
+			case tt.wantExit != 0 && (!errors.As(err, &exitErr) || exitErr.Code != tt.wantExit):
+				t.Fatalf("runOnTargets() = %v, want exit %d", err, tt.wantExit)
+			}
+			// One SendCommand for every target
+			if len(sent) != 1 || len(sent[0]) != len(tt.targets) {
+				t.Errorf("SendCommand calls %v, want one naming all %d targets", sent, len(tt.targets))
+			}
+
+			// Each instance's output follows its header, in target order
+			all := stdout + stderr
+			last := -1
+			for i, header := range tt.wantHeader {
+				at := strings.Index(stdout, header)
+				if at < last {
+					t.Errorf("header %q missing or out of order in %q", header, stdout)
+				}
+				last = at
+				out := results[tt.targets[i].ID]
+				for _, text := range []any{out["StandardOutputContent"], out["StandardErrorContent"]} {
+					if text != nil && !strings.Contains(all, text.(string)) {
+						t.Errorf("output %q of %s not shown", text, tt.targets[i].ID)
+					}
+				}
+			}
+			if !strings.Contains(all, tt.wantTail) {
+				t.Errorf("summary %q missing from %q", tt.wantTail, all)
+			}
+		})
+	}
+}
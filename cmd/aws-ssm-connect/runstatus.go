@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+var runStatusOutput string
+
+var runStatusCmd = &cobra.Command{
+	Use:   "run-status <command-id> [name|id]",
+	Short: "Show the status and output of a command sent earlier, on all or one of its instances",
+	Long: `Show the status and output of a command sent earlier (e.g. with -run --no-wait),
+on every instance it targeted or only the given one.
+With a single finished invocation, the exit code is the command's.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+
+		var instanceID string
+		if len(args) > 1 {
+			if instanceID, err = resolveInstance(ctx, client, args[1]); err != nil {
+				return err
+			}
+		}
+
+		invocations, err := client.CommandStatus(ctx, args[0], instanceID)
+		if err != nil {
+			return err
+		}
+		if runStatusOutput != "" {
+			if err := writeInvocationOutput(runStatusOutput, invocations); err != nil {
+				return err
+			}
+		}
+
+		if jsonFlag {
+			if err := newOutput().JSON("command_status", invocations); err != nil {
+				return err
+			}
+		} else {
+			printInvocations(invocations)
+		}
+
+		if len(invocations) == 1 && invocations[0].Done && invocations[0].ExitCode > 0 {
+			return &ssm.ExitError{Code: invocations[0].ExitCode}
+		}
+		return nil
+	},
+}
+
+// dispatchRun sends command without waiting and prints its ID: bare on
+// stdout for scripts, or as a "dispatch" JSON envelope.
+func dispatchRun(ctx context.Context, client *ssm.Client, instanceID, command string) error {
+	commandID, err := client.Dispatch(ctx, instanceID, command)
+	if err != nil {
+		return err
+	}
+	if jsonFlag {
+		return newOutput().JSON("dispatch", struct {
+			CommandID  string `json:"command_id"`
+			InstanceID string `json:"instance_id"`
+		}{commandID, instanceID})
+	}
+	fmt.Println(commandID)
+	fmt.Fprintf(os.Stderr, "Check on it with: aws-ssm-connect run-status %s\n", commandID)
+	return nil
+}
+
+// printInvocations prints each instance's status, then the output of the
+// finished ones.
+func printInvocations(invocations []ssm.Invocation) {
+	out := newOutput()
+	for _, inv := range invocations {
+		if !inv.Done {
+			out.Info("%s: %s", inv.InstanceID, inv.Status)
+			continue
+		}
+		if inv.Status == "Success" {
+			out.Success("%s: %s (exit %d)", inv.InstanceID, inv.Status, inv.ExitCode)
+		} else {
+			out.Warning("%s: %s (exit %d)", inv.InstanceID, inv.Status, inv.ExitCode)
+		}
+		printCommandOutput(out, inv.Stdout, inv.Stderr)
+	}
+}
+
+// printCommandOutput prints a command's stdout and stderr, only their last
+// --tail-lines lines when set.
+func printCommandOutput(out *output.Output, stdout, stderr string) {
+	stdout, dropped := ssm.LastLines(stdout, tailLines)
+	stderr, droppedErr := ssm.LastLines(stderr, tailLines)
+	if dropped+droppedErr > 0 {
+		out.Info("Showing the last %d lines (%d earlier lines not shown)", tailLines, dropped+droppedErr)
+	}
+	fmt.Print(stdout)
+	fmt.Fprint(os.Stderr, stderr)
+}
+
+// writeInvocationOutput saves the standard output of the finished
+// invocations to path. With several instances, each output is preceded by
+// a "==> instance <==" header.
+func writeInvocationOutput(path string, invocations []ssm.Invocation) error {
+	var b strings.Builder
+	for _, inv := range invocations {
+		if !inv.Done {
+			continue
+		}
+		if len(invocations) > 1 {
+			fmt.Fprintf(&b, "==> %s <==\n", inv.InstanceID)
+		}
+		b.WriteString(inv.Stdout)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func init() {
+	runStatusCmd.Flags().StringVar(&runStatusOutput, "output-file", "", "Also write the finished instances' output to this file")
+	runStatusCmd.Flags().IntVar(&tailLines, "tail-lines", 0, "Print only the last N lines of each output (--output-file still gets all of it)")
+	rootCmd.AddCommand(runStatusCmd)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+func TestWriteInvocationOutput(t *testing.T) {
+	done := ssm.Invocation{InstanceID: "i-1", Status: "Success", Done: true, Stdout: "one\n"}
+	failed := ssm.Invocation{InstanceID: "i-2", Status: "Failed", Done: true, ExitCode: 1, Stdout: "two\n"}
+	running := ssm.Invocation{InstanceID: "i-3", Status: "InProgress", ExitCode: -1, Stdout: "partial"}
+	tests := []struct {
+		name        string
+		invocations []ssm.Invocation
+		want        string
+	}{
+		{"single", []ssm.Invocation{done}, "one\n"},
+		{"several", []ssm.Invocation{done, failed}, "==> i-1 <==\none\n==> i-2 <==\ntwo\n"},
+		// Unfinished output is left out until it is complete
+		{"still running", []ssm.Invocation{done, running}, "==> i-1 <==\none\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "out")
+		if err := writeInvocationOutput(path, tt.invocations); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: wrote %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/e/aws-ssm-connect/internal/daemon"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+var serveRefresh time.Duration
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Cache instance discovery for other invocations, over a local socket",
+	Long: `Run in the foreground, caching discovered instances per profile and region.
+Other invocations list instances from the cache instead of AWS while it
+runs (unless --no-daemon is given). Entries are refreshed every --refresh.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if serveRefresh <= 0 {
+			return fmt.Errorf("--refresh must be positive")
+		}
+		socket, err := daemon.SocketPath()
+		if err != nil {
+			return err
+		}
+
+		ln, err := daemon.Listen(socket)
+		if err != nil {
+			return err
+		}
+
+		out := newOutput()
+		server := daemon.NewServer(newDaemonFetcher(), serveRefresh, out.Warning)
+		out.Info("Caching discovery on %s (refresh every %s)", socket, serveRefresh)
+		return server.Serve(cmd.Context(), ln)
+	},
+}
+
+// newDaemonFetcher returns a daemon.FetchFunc that reuses one client per
+// profile and region, so credentials are not reloaded on every refresh.
+func newDaemonFetcher() daemon.FetchFunc {
+	var mu sync.Mutex
+	clients := make(map[daemon.Request]*ssm.Client)
+	return func(ctx context.Context, profileName, regionName string) ([]ssm.Instance, error) {
+		key := daemon.Request{Profile: profileName, Region: regionName}
+		mu.Lock()
+		client, ok := clients[key]
+		if !ok {
+			var err error
+			if client, err = newClientIn(profileName, regionName); err != nil {
+				mu.Unlock()
+				return nil, err
+			}
+			clients[key] = client
+		}
+		mu.Unlock()
+		return client.DiscoverInstances(ctx)
+	}
+}
+
+func init() {
+	serveCmd.Flags().DurationVar(&serveRefresh, "refresh", time.Minute, "How long cached instances stay fresh")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/e/aws-ssm-connect/internal/config"
+	"github.com/e/aws-ssm-connect/internal/selector"
+)
+
+var taskCmd = &cobra.Command{
+	Use:   "task [name [key=value...]]",
+	Short: "Run a command template from config on the instances it targets",
+	Long: `Run a task defined under "tasks" in config.json: its command, with {{param}}
+placeholders filled from key=value arguments or their defaults, is run on
+every running instance matching the task's tags and target, after the same
+confirmation as a -run fan-out. --tag and other filters narrow it further.
+Without a name, the defined tasks are listed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			printTasks(settings)
+			return nil
+		}
+
+		task, err := settings.Task(args[0])
+		if err != nil {
+			return err
+		}
+		command, err := task.Expand(args[1:])
+		if err != nil {
+			return fmt.Errorf("task %s: %w", args[0], err)
+		}
+
+		tags = append(task.Tags, tags...)
+		excludeTags = append(task.ExcludeTags, excludeTags...)
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+
+		instances, err := client.GetRunningInstances(ctx)
+		if err != nil {
+			return err
+		}
+		// A task's target is always a pattern, so plain names are globs.
+		targets, err := selector.MatchTargets(instances, task.Target, true)
+		if err != nil {
+			return fmt.Errorf("task %s: %w", args[0], err)
+		}
+		if len(targets) == 0 {
+			return fmt.Errorf("no running instances match task %s", args[0])
+		}
+		return runOnTargets(ctx, client, targets, command)
+	},
+}
+
+// printTasks lists the tasks defined in config with their descriptions.
+func printTasks(settings *config.Settings) {
+	names := settings.TaskNames()
+	if len(names) == 0 {
+		fmt.Println(`No tasks defined (add them under "tasks" in config.json)`)
+		return
+	}
+	for _, name := range names {
+		fmt.Printf("%s\t%s\n", name, settings.Tasks[name].Description)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(taskCmd)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+var (
+	urlPage string
+	urlOpen bool
+)
+
+var urlCmd = &cobra.Command{
+	Use:   "url <name|id>...",
+	Short: "Print the AWS console URL of an instance",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		inst, err := client.FindInstance(cmd.Context(), withQueryNames(args)...)
+		if err != nil {
+			return err
+		}
+		return printConsoleURL(client, inst.ID, urlPage, urlOpen)
+	},
+}
+
+// printConsoleURL prints the instance's console URL and, with open, opens
+// it in the default browser. A browser that fails to start is only a warning.
+func printConsoleURL(client *ssm.Client, instanceID, page string, open bool) error {
+	link, err := ssm.ConsoleURL(client.Region(), instanceID, page)
+	if err != nil {
+		return err
+	}
+	fmt.Println(link)
+	if open {
+		if err := openBrowser(link); err != nil {
+			newOutput().Warning("Could not open a browser: %v", err)
+		}
+	}
+	return nil
+}
+
+// openBrowser opens link with the operating system's URL handler.
+func openBrowser(link string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", link)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	default:
+		cmd = exec.Command("xdg-open", link)
+	}
+	return cmd.Start()
+}
+
+func init() {
+	urlCmd.Flags().StringVar(&urlPage, "page", ssm.ConsoleEC2, "Console page: ec2 (instance details) or ssm (Session Manager)")
+	urlCmd.Flags().BoolVar(&urlOpen, "open", false, "Also open the URL in the default browser")
+	rootCmd.AddCommand(urlCmd)
+}
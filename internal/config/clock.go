@@ -0,0 +1,45 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// skewErrorCodes are API error codes AWS returns when the request signature
+// time is too far from server time.
+var skewErrorCodes = map[string]bool{
+	"SignatureDoesNotMatch":     true,
+	"InvalidSignatureException": true,
+	"RequestTimeTooSkewed":      true,
+	"RequestExpired":            true,
+}
+
+// ClockSkewHint returns advice for errors caused by a skewed local clock, or ""
+// if err is unrelated. When the response carries a Date header, the measured
+// offset is included.
+func ClockSkewHint(err error) string {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || !skewErrorCodes[apiErr.ErrorCode()] {
+		return ""
+	}
+
+	hint := "request signing failed, which is often caused by a wrong system clock; sync it (e.g. enable NTP) and retry"
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && respErr.Response != nil && respErr.Response.Response != nil {
+		if serverTime, perr := http.ParseTime(respErr.Response.Header.Get("Date")); perr == nil {
+			skew := time.Since(serverTime).Round(time.Second)
+			direction := "ahead of"
+			if skew < 0 {
+				skew, direction = -skew, "behind"
+			}
+			hint += fmt.Sprintf(" (local clock is %s %s AWS)", skew, direction)
+		}
+	}
+	return hint
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// withDate wraps err as a response error whose Date header is date.
+func withDate(err error, date time.Time) error {
+	header := http.Header{}
+	header.Set("Date", date.UTC().Format(http.TimeFormat))
+	return &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 403, Header: header}},
+		Err:      err,
+	}
+}
+
+func TestClockSkewHint(t *testing.T) {
+	skewed := &smithy.GenericAPIError{Code: "SignatureDoesNotMatch", Message: "signature mismatch"}
+	tests := []struct {
+		name string
+		err  error
+		want string // substring; "" means no hint
+	}{
+		{"unrelated error", errors.New("connection refused"), ""},
+		{"unrelated API error", &smithy.GenericAPIError{Code: "AccessDeniedException"}, ""},
+		{"signature mismatch", skewed, "wrong system clock"},
+		{"wrapped", fmt.Errorf("failed to list instances: %w", &smithy.GenericAPIError{Code: "RequestTimeTooSkewed"}), "wrong system clock"},
+		{"expired", &smithy.GenericAPIError{Code: "RequestExpired"}, "sync it"},
+		{"server time behind", withDate(skewed, time.Now().Add(-10*time.Minute)), "ahead of AWS"},
+		{"server time ahead", withDate(skewed, time.Now().Add(10*time.Minute)), "behind AWS"},
+	}
+	for _, tt := range tests {
+		got := ClockSkewHint(tt.err)
+		if tt.want == "" {
+			if got != "" {
+				t.Errorf("%s: ClockSkewHint() = %q, want none", tt.name, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s: ClockSkewHint() = %q, want containing %q", tt.name, got, tt.want)
+		}
+	}
+}
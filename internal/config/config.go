@@ -2,13 +2,15 @@ package config
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 )
 
 // Load returns an AWS configuration based on the provided profile and region.
-func Load(profile, region string) (aws.Config, error) {
+// A non-empty retryMode overrides the SDK retryer mode (see ParseRetryMode).
+func Load(profile, region string, retryMode aws.RetryMode) (aws.Config, error) {
 	var opts []func(*config.LoadOptions) error
 
 	if profile != "" {
@@ -19,5 +21,31 @@ func Load(profile, region string) (aws.Config, error) {
 		opts = append(opts, config.WithRegion(region))
 	}
 
+	if retryMode != "" {
+		opts = append(opts, config.WithRetryMode(retryMode))
+	}
+
 	return config.LoadDefaultConfig(context.Background(), opts...)
 }
+
+// ParseRetryMode validates an SDK retry mode name: standard or adaptive.
+// An empty name keeps the SDK default (standard, unless AWS_RETRY_MODE or
+// the profile's retry_mode says otherwise).
+func ParseRetryMode(name string) (aws.RetryMode, error) {
+	if name == "" {
+		return "", nil
+	}
+	mode, err := aws.ParseRetryMode(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid retry mode %q (expected standard or adaptive)", name)
+	}
+	return mode, nil
+}
+
+// RetryModeNote explains what mode trades off, for debug output.
+func RetryModeNote(mode aws.RetryMode) string {
+	if mode == aws.RetryModeAdaptive {
+		return "adaptive: client-side rate limiting slows calls down after throttling errors, trading latency for fewer failures"
+	}
+	return "standard: throttled calls are retried with backoff up to 3 attempts, failing fast when throttling persists"
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+func TestParseRetryMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    aws.RetryMode
+		wantErr bool
+	}{
+		{"", "", false},
+		{"standard", aws.RetryModeStandard, false},
+		{"adaptive", aws.RetryModeAdaptive, false},
+		{"legacy", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRetryMode(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRetryMode(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadAppliesRetryMode(t *testing.T) {
+	// Keep the user's config and environment out of the SDK defaults
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_RETRY_MODE", "")
+
+	tests := []struct {
+		mode     aws.RetryMode
+		env      string
+		want     aws.RetryMode
+		adaptive bool
+	}{
+		{"", "", "", false},
+		{aws.RetryModeStandard, "", aws.RetryModeStandard, false},
+		{aws.RetryModeAdaptive, "", aws.RetryModeAdaptive, true},
+		// The flag wins over the environment; leaving it unset keeps it
+		{aws.RetryModeStandard, "adaptive", aws.RetryModeStandard, false},
+		{"", "adaptive", aws.RetryModeAdaptive, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("AWS_RETRY_MODE", tt.env)
+		cfg, err := Load("", "us-east-1", tt.mode)
+		if err != nil {
+			t.Fatalf("Load(%q) with AWS_RETRY_MODE=%q: %v", tt.mode, tt.env, err)
+		}
+		if cfg.RetryMode != tt.want {
+			t.Errorf("Load(%q) with AWS_RETRY_MODE=%q: RetryMode = %q, want %q", tt.mode, tt.env, cfg.RetryMode, tt.want)
+		}
+		// The service clients build their retryer from the mode
+		retryer := ssm.NewFromConfig(cfg).Options().Retryer
+		if _, adaptive := retryer.(*retry.AdaptiveMode); adaptive != tt.adaptive {
+			t.Errorf("Load(%q) with AWS_RETRY_MODE=%q: retryer %T, want adaptive %t", tt.mode, tt.env, retryer, tt.adaptive)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+
+// AccountID extracts the 12-digit account ID from an ARN, or validates a bare
+// account ID.
+func AccountID(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "arn:") {
+		// arn:partition:service:region:account-id:resource
+		parts := strings.SplitN(s, ":", 6)
+		if len(parts) < 6 || !accountIDPattern.MatchString(parts[4]) {
+			return "", fmt.Errorf("ARN %q has no account ID", s)
+		}
+		return parts[4], nil
+	}
+	if !accountIDPattern.MatchString(s) {
+		return "", fmt.Errorf("invalid account ID %q (want 12 digits or an ARN)", s)
+	}
+	return s, nil
+}
+
+// SharedConfigPath returns the AWS shared config file: $AWS_CONFIG_FILE, else
+// ~/.aws/config.
+func SharedConfigPath() (string, error) {
+	if p := os.Getenv("AWS_CONFIG_FILE"); p != "" {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".aws", "config"), nil
+}
+
+// ProfileForAccount returns the profile in the shared config file at path
+// whose sso_account_id or role_arn belongs to account. It fails when no
+// profile or more than one matches, listing the candidates.
+func ProfileForAccount(path, account string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read AWS config: %w", err)
+	}
+	defer f.Close()
+
+	var matches []string
+	seen := make(map[string]bool)
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = profileSection(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		if section == "" || seen[section] {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if accountMatches(key, value, account) {
+			seen[section] = true
+			matches = append(matches, section)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read AWS config: %w", err)
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no profile in %s uses account %s", path, account)
+	case 1:
+		return matches[0], nil
+	}
+	sort.Strings(matches)
+	return "", fmt.Errorf("several profiles use account %s, pick one with --profile: %s",
+		account, strings.Join(matches, ", "))
+}
+
+// profileSection returns the profile named by a config section header, or ""
+// for sections that are not profiles (e.g. sso-session).
+func profileSection(header string) string {
+	if header == "default" {
+		return header
+	}
+	if name, ok := strings.CutPrefix(header, "profile "); ok {
+		return strings.TrimSpace(name)
+	}
+	return ""
+}
+
+func accountMatches(key, value, account string) bool {
+	switch key {
+	case "sso_account_id":
+		return value == account
+	case "role_arn":
+		id, err := AccountID(value)
+		return err == nil && id == account
+	}
+	return false
+}
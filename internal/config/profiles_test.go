@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAccountID(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"123456789012", "123456789012", false},
+		{" 123456789012\n", "123456789012", false},
+		{"arn:aws:ec2:us-east-1:123456789012:instance/i-0abc", "123456789012", false},
+		{"arn:aws:iam::210987654321:role/admin", "210987654321", false},
+		{"12345", "", true},
+		{"arn:aws:s3:::bucket", "", true},
+		{"arn:aws:ec2", "", true},
+	}
+	for _, tt := range tests {
+		got, err := AccountID(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("AccountID(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+const sharedConfig = `[default]
+region = us-east-1
+
+[profile dev]
+sso_session = corp
+sso_account_id = 111111111111
+sso_role_name = Admin
+
+# Commented out: sso_account_id = 222222222222
+[profile staging]
+role_arn = arn:aws:iam::222222222222:role/deploy
+source_profile = dev
+
+[profile prod-admin]
+sso_account_id=333333333333
+[profile prod-ro]
+role_arn = arn:aws:iam::333333333333:role/readonly
+
+[sso-session corp]
+sso_account_id = 444444444444
+
+[profile both]
+sso_account_id = 555555555555
+role_arn = arn:aws:iam::555555555555:role/x
+`
+
+func TestProfileForAccount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(sharedConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		account string
+		want    string
+		wantErr string
+	}{
+		{"sso account", "111111111111", "dev", ""},
+		{"role arn", "222222222222", "staging", ""},
+		{"ambiguous", "333333333333", "", "pick one with --profile: prod-admin, prod-ro"},
+		// Only profile sections count
+		{"sso session", "444444444444", "", "no profile in"},
+		// Matching on both keys is still one profile
+		{"both keys", "555555555555", "both", ""},
+		{"unknown", "999999999999", "", "no profile in"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProfileForAccount(path, tt.account)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ProfileForAccount() = %q, %v; want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ProfileForAccount() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ProfileForAccount(filepath.Join(t.TempDir(), "missing"), "111111111111"); err == nil {
+		t.Error("missing config file: want an error")
+	}
+}
+
+func TestSharedConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	t.Setenv("AWS_CONFIG_FILE", "")
+	if got, err := SharedConfigPath(); err != nil || got != filepath.Join(home, ".aws", "config") {
+		t.Errorf("SharedConfigPath() = %q, %v; want ~/.aws/config", got, err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", "/etc/aws/config")
+	if got, err := SharedConfigPath(); err != nil || got != "/etc/aws/config" {
+		t.Errorf("SharedConfigPath() = %q, %v; want $AWS_CONFIG_FILE", got, err)
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+)
+
+const settingsFile = "config.json"
+
+// HistorySizeEnv overrides history_limit. Values that are not positive
+// integers are ignored.
+const HistorySizeEnv = "AWS_SSM_CONNECT_HISTORY_SIZE"
+
+// Settings holds user preferences from config.json in the state directory.
+type Settings struct {
+	// DefaultAction is what to do once an instance is resolved (shell, print, forward, run).
+	DefaultAction string `json:"default_action,omitempty"`
+	// MaxRecent caps how many recent instances are pinned in the finder (0: all).
+	MaxRecent int `json:"max_recent,omitempty"`
+	// HistoryLimit is how many recent connections are kept per profile
+	// (default 5, or $AWS_SSM_CONNECT_HISTORY_SIZE).
+	HistoryLimit int `json:"history_limit,omitempty"`
+	// HistoryOrder orders the pinned recent instances: recency (default),
+	// frequency or frecency.
+	HistoryOrder string `json:"history_order,omitempty"`
+	// LabelWidth is the finder's name column width, a number or "auto".
+	LabelWidth string `json:"label_width,omitempty"`
+	// ContinueKey accepts in the finder and reopens it afterwards (default ctrl-o).
+	ContinueKey string `json:"continue_key,omitempty"`
+	// Glyphs selects message markers: unicode (default), ascii or none.
+	Glyphs string `json:"glyphs,omitempty"`
+	// ProtectedTags lists key=value tags; acting on an instance carrying any
+	// of them asks for confirmation unless --yes is given.
+	ProtectedTags []string `json:"protected_tags,omitempty"`
+	// RequireReason refuses to start sessions without --reason.
+	RequireReason bool `json:"require_reason,omitempty"`
+	// RequireReasonProfiles refuses sessions without --reason only for
+	// these AWS profiles.
+	RequireReasonProfiles []string `json:"require_reason_profiles,omitempty"`
+	// MaxPingAge marks instances whose agent last pinged longer ago as
+	// stale, as a duration such as "30m" (default 15m; "0" disables it).
+	MaxPingAge string `json:"max_ping_age,omitempty"`
+	// RegionFromProfile asks for a region in the finder when neither
+	// --region nor the profile sets one, like --region-from-profile.
+	RegionFromProfile bool `json:"region_from_profile,omitempty"`
+	// RetryMode is the AWS SDK retry mode: standard or adaptive.
+	RetryMode string `json:"retry_mode,omitempty"`
+	// LaunchRetries is how often a session whose plugin fails right after
+	// launch is started again (default 1; 0 disables).
+	LaunchRetries *int `json:"launch_retries,omitempty"`
+	// SessionDocument is the SSM document used for shell sessions, e.g. a
+	// custom one that enforces logging (default: the account's default).
+	SessionDocument string `json:"session_document,omitempty"`
+	// RemoteDecode and RemoteEncode replace the base64 commands run on the
+	// instance for file copies (e.g. "openssl base64 -d -A").
+	RemoteDecode string `json:"remote_decode,omitempty"`
+	RemoteEncode string `json:"remote_encode,omitempty"`
+	// StagingBucket is the S3 bucket -copy --via-s3 stages uploads in.
+	StagingBucket string `json:"staging_bucket,omitempty"`
+	// Tasks are named command templates run with 'task <name>'.
+	Tasks map[string]Task `json:"tasks,omitempty"`
+}
+
+// LoadSettings reads config.json from the state directory (see paths.Dir),
+// then applies environment overrides. A missing file yields empty settings.
+func LoadSettings() (*Settings, error) {
+	s, err := loadSettingsFile()
+	if err != nil {
+		return nil, err
+	}
+	if n, err := strconv.Atoi(os.Getenv(HistorySizeEnv)); err == nil && n > 0 {
+		s.HistoryLimit = n
+	}
+	return s, nil
+}
+
+func loadSettingsFile() (*Settings, error) {
+	s := &Settings{}
+
+	path, err := paths.File(settingsFile)
+	if err != nil {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// ReasonRequired reports whether sessions with profile must give a reason.
+func (s *Settings) ReasonRequired(profile string) bool {
+	return s.RequireReason || slices.Contains(s.RequireReasonProfiles, profile)
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+)
+
+func TestHistorySizeEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		file int // history_limit in config.json; 0 leaves it out
+		env  string
+		want int
+	}{
+		{"default", 0, "", 0},
+		{"config", 8, "", 8},
+		{"env", 0, "12", 12},
+		{"env over config", 8, "12", 12},
+		// Invalid values leave the config or the default
+		{"not a number", 8, "lots", 8},
+		{"negative", 0, "-3", 0},
+		{"zero", 8, "0", 8},
+	}
+	for _, tt := range tests {
+		t.Setenv(paths.HomeEnv, t.TempDir())
+		t.Setenv(HistorySizeEnv, tt.env)
+		if tt.file > 0 {
+			path, err := paths.File(settingsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(fmt.Sprintf(`{"history_limit": %d}`, tt.file)), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s, err := LoadSettings()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if s.HistoryLimit != tt.want {
+			t.Errorf("%s: HistoryLimit = %d, want %d", tt.name, s.HistoryLimit, tt.want)
+		}
+	}
+}
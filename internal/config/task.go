@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Task is a named command template from the "tasks" section of config.json,
+// run with 'task <name>' on every instance its filters select.
+type Task struct {
+	// Description is shown when listing tasks.
+	Description string `json:"description,omitempty"`
+	// Tags and ExcludeTags filter instances like --tag and --exclude-tag.
+	Tags        []string `json:"tags,omitempty"`
+	ExcludeTags []string `json:"exclude_tags,omitempty"`
+	// Target narrows the instances by name: a glob or a /regex/.
+	Target string `json:"target,omitempty"`
+	// Command is the shell command, with {{param}} placeholders.
+	Command string `json:"command"`
+	// Params declares the placeholders and their defaults; an empty default
+	// makes the parameter required.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// taskParam matches a {{param}} placeholder in a task command.
+var taskParam = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_-]+)\s*\}\}`)
+
+// Task returns the task called name, or an error listing the defined ones.
+func (s *Settings) Task(name string) (Task, error) {
+	t, ok := s.Tasks[name]
+	if !ok {
+		if len(s.Tasks) == 0 {
+			return Task{}, fmt.Errorf("unknown task %q: no tasks in config", name)
+		}
+		return Task{}, fmt.Errorf("unknown task %q (defined: %s)", name, strings.Join(s.TaskNames(), ", "))
+	}
+	if err := t.Validate(); err != nil {
+		return Task{}, fmt.Errorf("task %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// TaskNames returns the names of the defined tasks, sorted.
+func (s *Settings) TaskNames() []string {
+	names := make([]string, 0, len(s.Tasks))
+	for name := range s.Tasks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Validate checks that the task has a command and that every placeholder
+// in it is declared in Params.
+func (t Task) Validate() error {
+	if strings.TrimSpace(t.Command) == "" {
+		return fmt.Errorf("no command")
+	}
+	for _, m := range taskParam.FindAllStringSubmatch(t.Command, -1) {
+		if _, ok := t.Params[m[1]]; !ok {
+			return fmt.Errorf("placeholder {{%s}} is not declared in params", m[1])
+		}
+	}
+	return nil
+}
+
+// Expand fills the command's placeholders from key=value args, falling back
+// to the declared defaults. Unknown keys and missing required parameters
+// are errors.
+func (t Task) Expand(args []string) (string, error) {
+	values := make(map[string]string, len(t.Params))
+	for name, def := range t.Params {
+		if def != "" {
+			values[name] = def
+		}
+	}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return "", fmt.Errorf("invalid parameter %q: want key=value", arg)
+		}
+		if _, declared := t.Params[key]; !declared {
+			return "", fmt.Errorf("unknown parameter %q (declared: %s)", key, strings.Join(t.paramNames(), ", "))
+		}
+		values[key] = value
+	}
+
+	var missing []string
+	for _, name := range t.paramNames() {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing required parameters: %s (pass them as key=value)", strings.Join(missing, ", "))
+	}
+
+	return taskParam.ReplaceAllStringFunc(t.Command, func(m string) string {
+		return values[taskParam.FindStringSubmatch(m)[1]]
+	}), nil
+}
+
+// paramNames returns the declared parameter names, sorted.
+func (t Task) paramNames() []string {
+	names := make([]string, 0, len(t.Params))
+	for name := range t.Params {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
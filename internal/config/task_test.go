@@ -0,0 +1,35 @@
+package config
+
+import "testing"
+
+func TestTaskExpand(t *testing.T) {
+	task := Task{
+		Command: "systemctl {{ action }} {{service}} && journalctl -u {{service}} -n {{lines}}",
+		Params:  map[string]string{"action": "restart", "service": "", "lines": "20"},
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{"defaults", []string{"service=nginx"}, "systemctl restart nginx && journalctl -u nginx -n 20", false},
+		{"override", []string{"service=nginx", "action=reload", "lines=5"}, "systemctl reload nginx && journalctl -u nginx -n 5", false},
+		{"value with =", []string{"service=a=b"}, "systemctl restart a=b && journalctl -u a=b -n 20", false},
+		{"empty value", []string{"service="}, "systemctl restart  && journalctl -u  -n 20", false},
+		{"missing required", nil, "", true},
+		{"unknown key", []string{"service=nginx", "user=root"}, "", true},
+		{"not key=value", []string{"nginx"}, "", true},
+		{"empty key", []string{"=nginx"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := task.Expand(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Expand(%q) error = %v, wantErr %v", tt.name, tt.args, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: Expand(%q) = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
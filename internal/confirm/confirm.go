@@ -0,0 +1,97 @@
+package confirm
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// AssumeYesEnv answers yes to every confirmation when set to a non-empty value.
+const AssumeYesEnv = "AWS_SSM_CONNECT_ASSUME_YES"
+
+// Confirmer asks yes/no questions before risky actions.
+type Confirmer struct {
+	assumeYes bool
+}
+
+// New returns a Confirmer. assumeYes (or AssumeYesEnv) answers yes without
+// prompting.
+func New(assumeYes bool) *Confirmer {
+	return &Confirmer{assumeYes: assumeYes || os.Getenv(AssumeYesEnv) != ""}
+}
+
+// AssumesYes reports whether every question is answered yes without asking.
+func (c *Confirmer) AssumesYes() bool {
+	return c.assumeYes
+}
+
+// stdinIsTerminal reports whether stdin is a terminal. Tests replace it.
+var stdinIsTerminal = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+
+// RequireTerminal refuses to action without a terminal on stdin unless
+// every question is answered yes, so unattended runs must opt in.
+func (c *Confirmer) RequireTerminal(action string) error {
+	if c.assumeYes || stdinIsTerminal() {
+		return nil
+	}
+	return fmt.Errorf("refusing to %s non-interactively without --yes", action)
+}
+
+// Ask prompts "question [y/N]" on the terminal and returns nil only on yes.
+// Without a terminal the answer is no, with a hint to pass --yes.
+func (c *Confirmer) Ask(question string) error {
+	return c.ask(question, false)
+}
+
+// AskDefaultYes is like Ask, but an empty answer (just Enter) means yes.
+func (c *Confirmer) AskDefaultYes(question string) error {
+	return c.ask(question, true)
+}
+
+func (c *Confirmer) ask(question string, defaultYes bool) error {
+	if c.assumeYes {
+		return nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("%s: no terminal to confirm on; re-run with --yes to proceed", question)
+	}
+	defer tty.Close()
+
+	choices := "[y/N]"
+	if defaultYes {
+		choices = "[Y/n]"
+	}
+	fmt.Fprintf(tty, "%s %s ", question, choices)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	case "":
+		if defaultYes {
+			return nil
+		}
+	}
+	return fmt.Errorf("aborted")
+}
+
+// Input prompts for a line of text on the terminal and returns it trimmed.
+// Without a terminal it fails.
+func Input(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("%s: no terminal to ask on", prompt)
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "%s: ", prompt)
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && answer == "" {
+		return "", fmt.Errorf("aborted")
+	}
+	return strings.TrimSpace(answer), nil
+}
@@ -0,0 +1,108 @@
+package confirm
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestAssumeYes(t *testing.T) {
+	tests := []struct {
+		name string
+		flag bool
+		env  string
+		want bool
+	}{
+		{"neither", false, "", false},
+		{"flag", true, "", true},
+		{"env", false, "1", true},
+		{"both", true, "1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(AssumeYesEnv, tt.env)
+			c := New(tt.flag)
+			if got := c.AssumesYes(); got != tt.want {
+				t.Fatalf("AssumesYes() = %v, want %v", got, tt.want)
+			}
+			if !tt.want {
+				return
+			}
+			// Answered without touching the terminal.
+			if err := c.Ask("Delete?"); err != nil {
+				t.Errorf("Ask: %v", err)
+			}
+			if err := c.AskDefaultYes("Send?"); err != nil {
+				t.Errorf("AskDefaultYes: %v", err)
+			}
+		})
+	}
+}
+
+func TestRequireTerminal(t *testing.T) {
+	defer func(f func() bool) { stdinIsTerminal = f }(stdinIsTerminal)
+	tests := []struct {
+		name      string
+		assumeYes bool
+		terminal  bool
+		wantErr   bool
+	}{
+		{"terminal", false, true, false},
+		{"terminal with yes", true, true, false},
+		{"no terminal with yes", true, false, false},
+		{"no terminal", false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(AssumeYesEnv, "")
+			stdinIsTerminal = func() bool { return tt.terminal }
+			err := New(tt.assumeYes).RequireTerminal("run")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RequireTerminal() = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && err.Error() != "refusing to run non-interactively without --yes" {
+				t.Errorf("error = %q", err)
+			}
+		})
+	}
+}
+
+// TestAskWithoutTerminal asks in a child process that has no controlling
+// terminal, where the answer must be no with a hint to pass --yes.
+func TestAskWithoutTerminal(t *testing.T) {
+	if os.Getenv("ASK_WITHOUT_TERMINAL") == "1" {
+		os.Unsetenv(AssumeYesEnv)
+		c := New(false)
+		for _, err := range []error{c.Ask("Delete?"), c.AskDefaultYes("Send?")} {
+			if err == nil {
+				os.Stdout.WriteString("confirmed without a terminal\n")
+				os.Exit(0)
+			}
+			os.Stderr.WriteString(err.Error() + "\n")
+		}
+		if _, err := Input("Port"); err == nil {
+			os.Stdout.WriteString("read input without a terminal\n")
+			os.Exit(0)
+		}
+		os.Exit(3)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestAskWithoutTerminal$")
+	cmd.Env = append(os.Environ(), "ASK_WITHOUT_TERMINAL=1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 3 {
+		t.Fatalf("ask without a terminal: %v, stdout %q, stderr %q", err, stdout.String(), stderr.String())
+	}
+	for _, want := range []string{"Delete?: no terminal to confirm on; re-run with --yes", "Send?: no terminal to confirm on; re-run with --yes"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr = %q, want %q", stderr.String(), want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+// ErrNotRunning is returned when no daemon socket exists.
+var ErrNotRunning = errors.New("daemon is not running")
+
+// dialTimeout bounds connecting to a socket whose daemon has died.
+const dialTimeout = 200 * time.Millisecond
+
+// Client queries a running daemon. It implements ssm.InstanceCache.
+type Client struct {
+	socket string
+}
+
+// NewClient returns a client for the daemon listening on socket.
+func NewClient(socket string) *Client {
+	return &Client{socket: socket}
+}
+
+// Instances returns the daemon's cached instances for profile and region.
+func (c *Client) Instances(ctx context.Context, profile, region string) ([]ssm.Instance, error) {
+	resp, err := c.do(ctx, Request{Profile: profile, Region: region})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Instances, nil
+}
+
+// Stats returns how many profile and region entries the daemon caches and
+// when the oldest of them was fetched (zero when none was).
+func (c *Client) Stats(ctx context.Context) (int, time.Time, error) {
+	resp, err := c.do(ctx, Request{Op: OpStats})
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return resp.Entries, resp.FetchedAt, nil
+}
+
+// Flush drops the daemon's cached entries and returns how many there were.
+func (c *Client) Flush(ctx context.Context) (int, error) {
+	resp, err := c.do(ctx, Request{Op: OpFlush})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Entries, nil
+}
+
+// do sends one request to the daemon and reads its response.
+func (c *Client) do(ctx context.Context, req Request) (Response, error) {
+	if _, err := os.Stat(c.socket); err != nil {
+		return Response{}, ErrNotRunning
+	}
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "unix", c.socket)
+	if err != nil {
+		return Response{}, fmt.Errorf("connect to daemon: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if err := writeMessage(conn, req); err != nil {
+		return Response{}, fmt.Errorf("send daemon request: %w", err)
+	}
+	var resp Response
+	if err := readMessage(conn, &resp); err != nil {
+		return Response{}, fmt.Errorf("read daemon response: %w", err)
+	}
+	if resp.Error != "" {
+		return Response{}, fmt.Errorf("daemon: %s", resp.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,57 @@
+// Package daemon caches instance discovery in a long-running process and
+// serves it to the CLI over a unix socket.
+//
+// The protocol is one JSON request and one JSON response per connection.
+package daemon
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+// socketName is the socket file inside the state directory.
+const socketName = "daemon.sock"
+
+// SocketPath returns the path of the daemon's unix socket.
+func SocketPath() (string, error) {
+	return paths.File(socketName)
+}
+
+// Request operations besides the default instance lookup.
+const (
+	// OpStats reports how many entries are cached and the oldest fetch time.
+	OpStats = "stats"
+	// OpFlush drops every cached entry.
+	OpFlush = "flush"
+)
+
+// Request asks for the instances of one profile and region. An empty
+// profile uses the daemon's default credentials. Op selects another
+// operation; lookups leave it empty.
+type Request struct {
+	Profile string `json:"profile"`
+	Region  string `json:"region"`
+	Op      string `json:"op,omitempty"`
+}
+
+// Response carries unfiltered discovery results; the CLI applies its own
+// tag, AZ and status filters. Stats and flush report their entry count in
+// Entries, stats with the oldest fetch time in FetchedAt.
+type Response struct {
+	Instances []ssm.Instance `json:"instances"`
+	FetchedAt time.Time      `json:"fetched_at"`
+	Entries   int            `json:"entries,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+func writeMessage(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func readMessage(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+func TestRequestWire(t *testing.T) {
+	tests := []struct {
+		req  Request
+		want string
+	}{
+		// Lookups leave the operation out
+		{Request{Profile: "prod", Region: "eu-west-1"}, `{"profile":"prod","region":"eu-west-1"}`},
+		{Request{}, `{"profile":"","region":""}`},
+		{Request{Op: OpStats}, `{"profile":"","region":"","op":"stats"}`},
+		{Request{Op: OpFlush}, `{"profile":"","region":"","op":"flush"}`},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := writeMessage(&b, tt.req); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(b.String()); got != tt.want {
+			t.Errorf("writeMessage(%+v) = %s, want %s", tt.req, got, tt.want)
+		}
+		var back Request
+		if err := readMessage(&b, &back); err != nil || back != tt.req {
+			t.Errorf("readMessage() = %+v, %v; want %+v", back, err, tt.req)
+		}
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	fetched := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		resp Response
+	}{
+		{"instances", Response{
+			Instances: []ssm.Instance{{
+				ID: "i-1", Name: "web, blue", State: "running", PrivateIP: "10.0.0.1", AZ: "eu-west-1a",
+				SSMStatus: "Online", PlatformType: "Linux", AgentVersion: "3.2.0.0",
+				LastPing: fetched.Add(-time.Minute), Tags: map[string]string{"env": "prod"},
+			}},
+			FetchedAt: fetched,
+		}},
+		{"stats", Response{Entries: 3, FetchedAt: fetched}},
+		{"error", Response{Error: "no credentials"}},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := writeMessage(&b, tt.resp); err != nil {
+			t.Fatal(err)
+		}
+		// Empty entry counts and errors stay off the wire
+		if tt.resp.Error == "" && strings.Contains(b.String(), `"error"`) ||
+			tt.resp.Entries == 0 && strings.Contains(b.String(), `"entries"`) {
+			t.Errorf("%s: wire form %s carries empty fields", tt.name, b.String())
+		}
+		var back Response
+		if err := readMessage(&b, &back); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(back, tt.resp) {
+			t.Errorf("%s: round trip = %+v, want %+v", tt.name, back, tt.resp)
+		}
+	}
+}
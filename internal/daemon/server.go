@@ -0,0 +1,221 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+// FetchFunc discovers the instances of one profile and region.
+type FetchFunc func(ctx context.Context, profile, region string) ([]ssm.Instance, error)
+
+// idleRefreshes is how many refresh intervals an entry is kept refreshed
+// after it was last requested.
+const idleRefreshes = 10
+
+// Server caches discovery per profile and region and refreshes entries in
+// the background.
+type Server struct {
+	fetch   FetchFunc
+	refresh time.Duration
+	logf    func(format string, args ...any)
+
+	mu      sync.Mutex
+	entries map[Request]*entry
+}
+
+type entry struct {
+	mu        sync.Mutex
+	instances []ssm.Instance
+	fetchedAt time.Time
+	err       error
+	lastUsed  time.Time
+}
+
+// NewServer returns a server that fetches with fetch and considers results
+// fresh for refresh. logf reports background refresh failures.
+func NewServer(fetch FetchFunc, refresh time.Duration, logf func(string, ...any)) *Server {
+	return &Server{
+		fetch:   fetch,
+		refresh: refresh,
+		logf:    logf,
+		entries: make(map[Request]*entry),
+	}
+}
+
+// Listen creates the daemon socket, readable only by the current user.
+// A stale socket left by a dead daemon is replaced; a live one is an error.
+func Listen(socket string) (net.Listener, error) {
+	if _, err := os.Stat(socket); err == nil {
+		if conn, err := net.DialTimeout("unix", socket, dialTimeout); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a daemon is already listening on %s", socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", socket, err)
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Serve answers requests on ln until ctx is cancelled, then closes it
+// (which removes a unix socket).
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	go s.refreshLoop(ctx)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			continue
+		}
+		go s.handle(ctx, conn)
+	}
+}
+
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	var req Request
+	if err := readMessage(conn, &req); err != nil {
+		_ = writeMessage(conn, Response{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	switch req.Op {
+	case "":
+		_ = writeMessage(conn, s.lookup(ctx, req))
+	case OpStats:
+		_ = writeMessage(conn, s.stats())
+	case OpFlush:
+		_ = writeMessage(conn, s.flush())
+	default:
+		_ = writeMessage(conn, Response{Error: fmt.Sprintf("unknown operation %q", req.Op)})
+	}
+}
+
+// stats counts the cached entries and finds the oldest successful fetch.
+func (s *Server) stats() Response {
+	s.mu.Lock()
+	entries := make([]*entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+
+	var oldest time.Time
+	for _, e := range entries {
+		e.mu.Lock()
+		if !e.fetchedAt.IsZero() && (oldest.IsZero() || e.fetchedAt.Before(oldest)) {
+			oldest = e.fetchedAt
+		}
+		e.mu.Unlock()
+	}
+	return Response{Entries: len(entries), FetchedAt: oldest}
+}
+
+// flush forgets every cached entry, so the next lookups fetch afresh.
+func (s *Server) flush() Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.entries)
+	s.entries = make(map[Request]*entry)
+	return Response{Entries: n}
+}
+
+// lookup returns the cached entry for req, fetching it first when it is
+// missing or older than the refresh interval.
+func (s *Server) lookup(ctx context.Context, req Request) Response {
+	s.mu.Lock()
+	e, ok := s.entries[req]
+	if !ok {
+		e = &entry{}
+		s.entries[req] = e
+	}
+	s.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastUsed = time.Now()
+	if e.fetchedAt.IsZero() || time.Since(e.fetchedAt) > s.refresh || e.err != nil {
+		s.update(ctx, req, e)
+	}
+	if e.err != nil {
+		return Response{Error: e.err.Error()}
+	}
+	return Response{Instances: e.instances, FetchedAt: e.fetchedAt}
+}
+
+// update fetches req into e; e.mu must be held. After a failure the next
+// request fetches again instead of getting stale results.
+func (s *Server) update(ctx context.Context, req Request, e *entry) {
+	instances, err := s.fetch(ctx, req.Profile, req.Region)
+	e.err = err
+	if err == nil {
+		e.instances = instances
+		e.fetchedAt = time.Now()
+	}
+}
+
+// refreshLoop refetches recently used entries every refresh interval and
+// forgets entries nobody asked for in idleRefreshes intervals.
+func (s *Server) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		pending := make(map[Request]*entry, len(s.entries))
+		for req, e := range s.entries {
+			pending[req] = e
+		}
+		s.mu.Unlock()
+
+		for req, e := range pending {
+			e.mu.Lock()
+			if time.Since(e.lastUsed) > idleRefreshes*s.refresh {
+				e.mu.Unlock()
+				s.mu.Lock()
+				delete(s.entries, req)
+				s.mu.Unlock()
+				continue
+			}
+			s.update(ctx, req, e)
+			if e.err != nil && ctx.Err() == nil {
+				s.logf("refresh %s/%s: %v", req.Profile, req.Region, e.err)
+			}
+			e.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/ssm"
+)
+
+// serve runs a server with fetch on a socket in a temporary directory and
+// returns a client for it.
+func serve(t *testing.T, fetch FetchFunc) *Client {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), socketName)
+	ln, err := Listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewServer(fetch, time.Hour, t.Logf).Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return NewClient(socket)
+}
+
+func TestServerCachesPerProfileAndRegion(t *testing.T) {
+	var fetches atomic.Int32
+	client := serve(t, func(_ context.Context, profile, region string) ([]ssm.Instance, error) {
+		fetches.Add(1)
+		if profile == "broken" {
+			return nil, errors.New("no credentials")
+		}
+		return []ssm.Instance{{ID: "i-" + profile + "-" + region}}, nil
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		profile, region string
+		want            string
+		wantErr         string
+		wantFetches     int32
+	}{
+		{"prod", "eu-west-1", "i-prod-eu-west-1", "", 1},
+		// Served from the cache
+		{"prod", "eu-west-1", "i-prod-eu-west-1", "", 1},
+		{"prod", "us-east-1", "i-prod-us-east-1", "", 2},
+		{"dev", "eu-west-1", "i-dev-eu-west-1", "", 3},
+		{"broken", "eu-west-1", "", "daemon: no credentials", 4},
+		// Failures are fetched again rather than cached
+		{"broken", "eu-west-1", "", "daemon: no credentials", 5},
+	}
+	for _, tt := range tests {
+		instances, err := client.Instances(ctx, tt.profile, tt.region)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Instances(%s, %s) error = %v, want %q", tt.profile, tt.region, err, tt.wantErr)
+			}
+		} else if err != nil || len(instances) != 1 || instances[0].ID != tt.want {
+			t.Errorf("Instances(%s, %s) = %v, %v; want %s", tt.profile, tt.region, instances, err, tt.want)
+		}
+		if got := fetches.Load(); got != tt.wantFetches {
+			t.Errorf("Instances(%s, %s): %d fetches, want %d", tt.profile, tt.region, got, tt.wantFetches)
+		}
+	}
+
+	entries, oldest, err := client.Stats(ctx)
+	if err != nil || entries != 4 || oldest.IsZero() {
+		t.Errorf("Stats() = %d, %v, %v; want 4 entries with a fetch time", entries, oldest, err)
+	}
+	if n, err := client.Flush(ctx); err != nil || n != 4 {
+		t.Errorf("Flush() = %d, %v; want 4", n, err)
+	}
+	if entries, _, err := client.Stats(ctx); err != nil || entries != 0 {
+		t.Errorf("Stats() after Flush = %d, %v; want 0", entries, err)
+	}
+	if _, err := client.Instances(ctx, "prod", "eu-west-1"); err != nil || fetches.Load() != 6 {
+		t.Errorf("after Flush: %v, %d fetches; want a new fetch", err, fetches.Load())
+	}
+}
+
+func TestServerRejectsUnknownOperation(t *testing.T) {
+	client := serve(t, func(context.Context, string, string) ([]ssm.Instance, error) { return nil, nil })
+	_, err := client.do(context.Background(), Request{Op: "reboot"})
+	if err == nil || !strings.Contains(err.Error(), `unknown operation "reboot"`) {
+		t.Errorf("unknown op: %v", err)
+	}
+}
+
+func TestClientWithoutDaemon(t *testing.T) {
+	client := NewClient(filepath.Join(t.TempDir(), socketName))
+	if _, err := client.Instances(context.Background(), "", ""); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Instances() error = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestListen(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "state", socketName)
+
+	ln, err := Listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	// A live daemon keeps its socket
+	if second, err := Listen(socket); err == nil {
+		second.Close()
+		t.Error("Listen() on a live socket succeeded")
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	// A dead daemon's socket is replaced
+	ln, err = Listen(socket)
+	if err != nil {
+		t.Fatalf("Listen() over a stale socket: %v", err)
+	}
+	ln.Close()
+}
@@ -2,57 +2,143 @@ package history
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
 )
 
+// Store is a history file, how many entries each of its scopes keeps and
+// how RecentIDs orders them.
+type Store struct {
+	FileName string
+	Limit    int
+	Order    string
+}
+
+// Orders for RecentIDs.
 const (
-	maxRecent = 5
-	appDir    = ".aws-ssm-connect"
-	fileName  = "history.json"
+	// OrderRecency puts the most recently used first (the default).
+	OrderRecency = "recency"
+	// OrderFrequency puts the most often used first, then the most recent.
+	OrderFrequency = "frequency"
+	// OrderFrecency weighs use counts by how recent the last use was.
+	OrderFrecency = "frecency"
 )
 
+// ValidateOrder checks a history order name; empty means OrderRecency.
+func ValidateOrder(order string) error {
+	switch order {
+	case "", OrderRecency, OrderFrequency, OrderFrecency:
+		return nil
+	}
+	return fmt.Errorf("invalid history order %q (expected %s, %s or %s)", order, OrderRecency, OrderFrequency, OrderFrecency)
+}
+
+// Connections is the store of recently connected instances.
+var Connections = Store{FileName: "history.json", Limit: 5}
+
+// WithLimit returns a copy of the store keeping limit entries per scope;
+// a limit of 0 or less keeps the store's own.
+func (s Store) WithLimit(limit int) Store {
+	if limit > 0 {
+		s.Limit = limit
+	}
+	return s
+}
+
+// WithOrder returns a copy of the store whose RecentIDs uses order; an empty
+// order keeps the store's own.
+func (s Store) WithOrder(order string) Store {
+	if order != "" {
+		s.Order = order
+	}
+	return s
+}
+
 // Entry represents a recently connected instance.
 type Entry struct {
 	InstanceID string    `json:"instance_id"`
 	Name       string    `json:"name,omitempty"`
 	LastUsed   time.Time `json:"last_used"`
+	// UseCount is how often the instance was used while in the history.
+	// Entries written before it existed count as one use.
+	UseCount int `json:"use_count,omitempty"`
 }
 
-// History manages recently connected instances.
+// DefaultScope is the bucket used when no profile is active. History written
+// before scoping was introduced is migrated into it.
+const DefaultScope = "default"
+
+// History manages the recent entries of one store for one scope (AWS profile).
 type History struct {
-	Recent []Entry `json:"recent"`
+	// Recent holds the entries of the loaded scope.
+	Recent []Entry
+	store  Store
+	scope  string
+	scopes map[string][]Entry
 	path   string
 }
 
-// Load reads history from ~/.aws-ssm-connect/history.json.
-func Load() (*History, error) {
-	h := &History{}
+// file is the on-disk layout of a history store.
+type file struct {
+	Scopes map[string][]Entry `json:"scopes"`
+	// Recent is the pre-scoping flat list, read only for migration.
+	Recent []Entry `json:"recent,omitempty"`
+}
+
+// Load reads the bucket for scope from the store's file in the state
+// directory. An empty scope selects DefaultScope.
+func (s Store) Load(scope string) (*History, error) {
+	if scope == "" {
+		scope = DefaultScope
+	}
+	h := &History{store: s, scope: scope, scopes: map[string][]Entry{}}
 
-	home, err := os.UserHomeDir()
+	path, err := paths.File(s.FileName)
 	if err != nil {
 		return h, nil // Return empty history on error
 	}
 
-	h.path = filepath.Join(home, appDir, fileName)
+	h.path = path
 
 	data, err := os.ReadFile(h.path)
 	if err != nil {
 		return h, nil // Return empty history if file doesn't exist
 	}
 
-	_ = json.Unmarshal(data, h)
+	var f file
+	_ = json.Unmarshal(data, &f)
+	if f.Scopes != nil {
+		h.scopes = f.Scopes
+	}
+	if len(f.Recent) > 0 && h.scopes[DefaultScope] == nil {
+		h.scopes[DefaultScope] = f.Recent
+	}
+	for _, entries := range h.scopes {
+		for i := range entries {
+			if entries[i].UseCount < 1 {
+				entries[i].UseCount = 1
+			}
+		}
+	}
+	h.Recent = h.trim(h.scopes[scope])
 	return h, nil
 }
 
 // Add records a connection to an instance.
 func (h *History) Add(instanceID, name string) error {
-	// Remove existing entry for this instance
+	// Remove existing entry for this instance, keeping its use count
+	uses := 0
 	filtered := make([]Entry, 0, len(h.Recent))
 	for _, e := range h.Recent {
 		if e.InstanceID != instanceID {
 			filtered = append(filtered, e)
+		} else {
+			uses = e.UseCount
 		}
 	}
 
@@ -61,32 +147,68 @@ func (h *History) Add(instanceID, name string) error {
 		InstanceID: instanceID,
 		Name:       name,
 		LastUsed:   time.Now(),
+		UseCount:   uses + 1,
 	}}, filtered...)
 
-	// Keep only maxRecent entries
-	if len(h.Recent) > maxRecent {
-		h.Recent = h.Recent[:maxRecent]
-	}
+	h.Recent = h.trim(h.Recent)
+	h.scopes[h.scope] = h.Recent
 
 	return h.save()
 }
 
-// RecentIDs returns instance IDs in order of most recent use.
+// trim keeps the first Limit entries; a store without a limit keeps all.
+func (h *History) trim(entries []Entry) []Entry {
+	if h.store.Limit > 0 && len(entries) > h.store.Limit {
+		return entries[:h.store.Limit]
+	}
+	return entries
+}
+
+// RecentIDs returns instance IDs in the store's order: most recent use
+// first by default, else by use count or frecency with ties kept in
+// recency order. Recent itself always stays in recency order.
 func (h *History) RecentIDs() []string {
-	ids := make([]string, len(h.Recent))
-	for i, e := range h.Recent {
+	entries := append([]Entry(nil), h.Recent...)
+	switch h.store.Order {
+	case OrderFrequency:
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].UseCount > entries[j].UseCount })
+	case OrderFrecency:
+		now := time.Now()
+		sort.SliceStable(entries, func(i, j int) bool { return frecency(entries[i], now) > frecency(entries[j], now) })
+	}
+
+	ids := make([]string, len(entries))
+	for i, e := range entries {
 		ids[i] = e.InstanceID
 	}
 	return ids
 }
 
+// frecency scores an entry by its use count, weighted by the age of its
+// last use in buckets, like browser history ranking.
+func frecency(e Entry, now time.Time) int {
+	age := now.Sub(e.LastUsed)
+	weight := 10
+	switch {
+	case age < 4*24*time.Hour:
+		weight = 100
+	case age < 14*24*time.Hour:
+		weight = 70
+	case age < 31*24*time.Hour:
+		weight = 50
+	case age < 90*24*time.Hour:
+		weight = 30
+	}
+	return e.UseCount * weight
+}
+
 func (h *History) save() error {
 	if h.path == "" {
-		home, err := os.UserHomeDir()
+		path, err := paths.File(h.store.FileName)
 		if err != nil {
 			return err
 		}
-		h.path = filepath.Join(home, appDir, fileName)
+		h.path = path
 	}
 
 	// Create directory if needed
@@ -95,7 +217,7 @@ func (h *History) save() error {
 		return err
 	}
 
-	data, err := json.MarshalIndent(h, "", "  ")
+	data, err := json.MarshalIndent(file{Scopes: h.scopes}, "", "  ")
 	if err != nil {
 		return err
 	}
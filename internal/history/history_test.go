@@ -0,0 +1,188 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+)
+
+func TestFrecency(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tests := []struct {
+		age  time.Duration
+		uses int
+		want int
+	}{
+		{0, 1, 100},
+		{4*day - time.Second, 3, 300},
+		{4 * day, 3, 210},
+		{14 * day, 2, 100},
+		{31 * day, 2, 60},
+		{90 * day, 2, 20},
+		{400 * day, 5, 50},
+		{time.Hour, 0, 0},
+	}
+	for _, tt := range tests {
+		got := frecency(Entry{UseCount: tt.uses, LastUsed: now.Add(-tt.age)}, now)
+		if got != tt.want {
+			t.Errorf("frecency(%d uses, %v ago) = %d, want %d", tt.uses, tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestScopesArePartitioned(t *testing.T) {
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	store := Store{FileName: "history.json", Limit: 5}
+
+	adds := []struct{ scope, id string }{
+		{"prod", "i-prod1"},
+		{"dev", "i-dev1"},
+		{"prod", "i-prod2"},
+		{"", "i-none"},
+	}
+	for _, a := range adds {
+		h, err := store.Load(a.scope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Add(a.id, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		scope string
+		want  []string
+	}{
+		{"prod", []string{"i-prod2", "i-prod1"}},
+		{"dev", []string{"i-dev1"}},
+		{"", []string{"i-none"}},
+		{DefaultScope, []string{"i-none"}},
+		{"staging", []string{}},
+	}
+	for _, tt := range tests {
+		h, err := store.Load(tt.scope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := h.RecentIDs(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("RecentIDs() of scope %q = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestLoadMigratesFlatHistory(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(paths.HomeEnv, dir)
+	flat := `{"recent":[{"instance_id":"i-old","last_used":"2024-01-01T00:00:00Z"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "history.json"), []byte(flat), 0600); err != nil {
+		t.Fatal(err)
+	}
+	store := Store{FileName: "history.json", Limit: 5}
+
+	h, err := store.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Recent) != 1 || h.Recent[0].InstanceID != "i-old" || h.Recent[0].UseCount != 1 {
+		t.Fatalf("migrated history = %+v", h.Recent)
+	}
+	if other, _ := store.Load("prod"); len(other.Recent) != 0 {
+		t.Errorf("flat history leaked into scope prod: %+v", other.Recent)
+	}
+
+	// Saving rewrites the file in the scoped layout
+	if err := h.Add("i-new", ""); err != nil {
+		t.Fatal(err)
+	}
+	h, _ = store.Load(DefaultScope)
+	if got, want := h.RecentIDs(), []string{"i-new", "i-old"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RecentIDs() after save = %v, want %v", got, want)
+	}
+}
+
+func TestStoresTrimIndependently(t *testing.T) {
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	connections := Store{FileName: "history.json", Limit: 2}
+	commands := Store{FileName: "commands.json", Limit: 4}
+	unlimited := Store{FileName: "all.json"}
+
+	for _, store := range []Store{connections, commands, unlimited} {
+		for _, id := range []string{"i-1", "i-2", "i-3", "i-4", "i-5"} {
+			h, err := store.Load("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := h.Add(id, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name  string
+		store Store
+		want  []string
+	}{
+		{"connections", connections, []string{"i-5", "i-4"}},
+		{"commands", commands, []string{"i-5", "i-4", "i-3", "i-2"}},
+		{"no limit", unlimited, []string{"i-5", "i-4", "i-3", "i-2", "i-1"}},
+		// A lower limit trims what an earlier, larger one kept
+		{"lowered limit", commands.WithLimit(1), []string{"i-5"}},
+		// A limit of 0 keeps the store's own
+		{"zero override", connections.WithLimit(0), []string{"i-5", "i-4"}},
+	}
+	for _, tt := range tests {
+		h, err := tt.store.Load("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := h.RecentIDs(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: RecentIDs() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConnectionsLimit(t *testing.T) {
+	var ids []string
+	for i := range 15 {
+		ids = append(ids, fmt.Sprintf("i-%d", i))
+	}
+	tests := []struct {
+		name  string
+		limit int
+		want  int
+	}{
+		{"default", 0, 5},
+		// Someone rotating between a dozen instances keeps them all
+		{"raised", 12, 12},
+		{"lowered", 3, 3},
+	}
+	for _, tt := range tests {
+		t.Setenv(paths.HomeEnv, t.TempDir())
+		store := Connections.WithLimit(tt.limit)
+		for _, id := range ids {
+			h, err := store.Load("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := h.Add(id, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+		h, err := store.Load("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := h.RecentIDs()
+		if len(got) != tt.want || got[0] != "i-14" {
+			t.Errorf("%s: RecentIDs() = %v, want the latest %d", tt.name, got, tt.want)
+		}
+	}
+}
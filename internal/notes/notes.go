@@ -0,0 +1,89 @@
+package notes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+)
+
+const fileName = "notes.json"
+
+// Notes manages free-form annotations keyed by instance ID.
+type Notes struct {
+	Notes map[string]string `json:"notes"`
+	path  string
+}
+
+// Load reads notes from notes.json in the state directory.
+func Load() (*Notes, error) {
+	n := &Notes{Notes: make(map[string]string)}
+
+	path, err := paths.File(fileName)
+	if err != nil {
+		return n, nil // Return empty notes on error
+	}
+
+	n.path = path
+
+	data, err := os.ReadFile(n.path)
+	if err != nil {
+		return n, nil // Return empty notes if file doesn't exist
+	}
+
+	_ = json.Unmarshal(data, n)
+	if n.Notes == nil {
+		n.Notes = make(map[string]string)
+	}
+	return n, nil
+}
+
+// Get returns the note for an instance, or "" if none.
+func (n *Notes) Get(instanceID string) string {
+	return n.Notes[instanceID]
+}
+
+// All returns all notes keyed by instance ID.
+func (n *Notes) All() map[string]string {
+	return n.Notes
+}
+
+// Set stores a note for an instance, replacing any existing one.
+func (n *Notes) Set(instanceID, text string) error {
+	n.Notes[instanceID] = text
+	return n.save()
+}
+
+// Remove deletes the note for an instance.
+func (n *Notes) Remove(instanceID string) error {
+	if _, ok := n.Notes[instanceID]; !ok {
+		return fmt.Errorf("no note for %s", instanceID)
+	}
+	delete(n.Notes, instanceID)
+	return n.save()
+}
+
+func (n *Notes) save() error {
+	if n.path == "" {
+		path, err := paths.File(fileName)
+		if err != nil {
+			return err
+		}
+		n.path = path
+	}
+
+	// Create directory if needed
+	dir := filepath.Dir(n.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(n, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(n.path, data, 0600)
+}
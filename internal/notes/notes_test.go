@@ -0,0 +1,77 @@
+package notes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+)
+
+func TestNotesCRUD(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(paths.HomeEnv, dir)
+
+	n, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := n.Get("i-1"); got != "" {
+		t.Fatalf("empty store has note %q", got)
+	}
+
+	steps := []struct {
+		name string
+		do   func(*Notes) error
+		want map[string]string
+	}{
+		{"add", func(n *Notes) error { return n.Set("i-1", "flaky disk") }, map[string]string{"i-1": "flaky disk"}},
+		{"add another", func(n *Notes) error { return n.Set("i-2", "db primary") }, map[string]string{"i-1": "flaky disk", "i-2": "db primary"}},
+		{"replace", func(n *Notes) error { return n.Set("i-1", "disk replaced") }, map[string]string{"i-1": "disk replaced", "i-2": "db primary"}},
+		{"remove", func(n *Notes) error { return n.Remove("i-2") }, map[string]string{"i-1": "disk replaced"}},
+	}
+	for _, step := range steps {
+		if err := step.do(n); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		// Every change is saved: a fresh load sees it
+		reloaded, err := Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reloaded.All()) != len(step.want) {
+			t.Errorf("%s: reloaded %v, want %v", step.name, reloaded.All(), step.want)
+		}
+		for id, text := range step.want {
+			if got := reloaded.Get(id); got != text {
+				t.Errorf("%s: Get(%q) = %q, want %q", step.name, id, got, text)
+			}
+		}
+	}
+
+	if err := n.Remove("i-404"); err == nil {
+		t.Error("removing a missing note succeeded")
+	}
+	info, err := os.Stat(filepath.Join(dir, fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("notes.json mode %o, want 600", perm)
+	}
+}
+
+func TestLoadCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(paths.HomeEnv, dir)
+	if err := os.WriteFile(filepath.Join(dir, fileName), []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	n, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Set("i-1", "still works"); err != nil {
+		t.Errorf("Set after a corrupt file: %v", err)
+	}
+}
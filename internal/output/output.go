@@ -1,6 +1,7 @@
 package output
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 )
@@ -17,34 +18,73 @@ const (
 	Bold   = "\033[1m"
 )
 
+// SchemaVersion is the version of the JSON envelope emitted with --json.
+// Bump it when field names or structure change incompatibly.
+const SchemaVersion = 1
+
+// Glyphs are the markers printed before info, success, warning and error
+// messages. Each includes its trailing space, so empty glyphs print nothing.
+type Glyphs struct {
+	Info, Success, Warning, Error string
+}
+
+// Glyph sets selectable with ParseGlyphs.
+var (
+	UnicodeGlyphs = Glyphs{Info: "ℹ ", Success: "✓ ", Warning: "⚠ ", Error: "✗ "}
+	ASCIIGlyphs   = Glyphs{Info: "[i] ", Success: "[ok] ", Warning: "[!] ", Error: "[x] "}
+	NoGlyphs      = Glyphs{}
+)
+
+// ParseGlyphs returns the glyph set named unicode, ascii or none.
+// An empty name selects unicode.
+func ParseGlyphs(name string) (Glyphs, error) {
+	switch name {
+	case "", "unicode":
+		return UnicodeGlyphs, nil
+	case "ascii":
+		return ASCIIGlyphs, nil
+	case "none":
+		return NoGlyphs, nil
+	}
+	return Glyphs{}, fmt.Errorf("invalid glyphs %q (expected unicode, ascii or none)", name)
+}
+
 // Output handles formatted console output.
 type Output struct {
-	debug bool
+	debug  bool
+	glyphs Glyphs
 }
 
-// New creates a new Output instance.
-func New(debug bool) *Output {
-	return &Output{debug: debug}
+// New creates a new Output instance that marks messages with glyphs.
+func New(debug bool, glyphs Glyphs) *Output {
+	return &Output{debug: debug, glyphs: glyphs}
 }
 
 // Info prints an informational message.
 func (o *Output) Info(format string, args ...any) {
-	fmt.Printf(Cyan+"ℹ "+Reset+format+"\n", args...)
+	fmt.Printf(Cyan+o.glyphs.Info+Reset+format+"\n", args...)
 }
 
 // Success prints a success message.
 func (o *Output) Success(format string, args ...any) {
-	fmt.Printf(Green+"✓ "+Reset+format+"\n", args...)
+	fmt.Printf(Green+o.glyphs.Success+Reset+format+"\n", args...)
 }
 
-// Warning prints a warning message.
+// Warning prints a warning message on stderr, so warnings never mix with
+// JSON or session data on stdout.
 func (o *Output) Warning(format string, args ...any) {
-	fmt.Printf(Yellow+"⚠ "+Reset+format+"\n", args...)
+	fmt.Fprintf(os.Stderr, Yellow+o.glyphs.Warning+Reset+format+"\n", args...)
+}
+
+// Notice prints an informational message on stderr, for messages that
+// follow command output on stdout.
+func (o *Output) Notice(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, Cyan+o.glyphs.Info+Reset+format+"\n", args...)
 }
 
 // Error prints an error message.
 func (o *Output) Error(format string, args ...any) {
-	fmt.Fprintf(os.Stderr, Red+"✗ "+Reset+format+"\n", args...)
+	fmt.Fprintf(os.Stderr, Red+o.glyphs.Error+Reset+format+"\n", args...)
 }
 
 // Debug prints a debug message if debug mode is enabled.
@@ -64,3 +104,21 @@ func (o *Output) Header(title string) {
 	fmt.Printf("\n"+Bold+"%s"+Reset+"\n", title)
 	fmt.Println(Gray + "─────────────────────────────────────────" + Reset)
 }
+
+// envelope wraps JSON output so consumers can detect the payload kind and schema.
+type envelope struct {
+	SchemaVersion int    `json:"schema_version"`
+	Kind          string `json:"kind"`
+	Data          any    `json:"data"`
+}
+
+// JSON prints data as indented JSON wrapped in a versioned envelope.
+func (o *Output) JSON(kind string, data any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(envelope{
+		SchemaVersion: SchemaVersion,
+		Kind:          kind,
+		Data:          data,
+	})
+}
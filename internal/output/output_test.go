@@ -0,0 +1,165 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	return capture(t, &os.Stdout, fn)
+}
+
+// capture returns what fn writes to the file *f, such as os.Stderr.
+func capture(t *testing.T, f **os.File, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := *f
+	*f = w
+	fn()
+	w.Close()
+	*f = orig
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestJSONEnvelope(t *testing.T) {
+	tests := []struct {
+		kind string
+		data any
+		want string
+	}{
+		{"history", []string{}, `{"schema_version":1,"kind":"history","data":[]}`},
+		{"instance", struct {
+			ID string `json:"id"`
+		}{"i-1"}, `{"schema_version":1,"kind":"instance","data":{"id":"i-1"}}`},
+	}
+	for _, tt := range tests {
+		var err error
+		out := captureStdout(t, func() { err = New(false, UnicodeGlyphs).JSON(tt.kind, tt.data) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got, want any
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("%s: output %q is not JSON: %v", tt.kind, out, err)
+		}
+		json.Unmarshal([]byte(tt.want), &want)
+		// Round trips sort the keys, so field order does not matter
+		if g := mustMarshal(got); string(g) != string(mustMarshal(want)) {
+			t.Errorf("%s: JSON() = %s, want %s", tt.kind, g, tt.want)
+		}
+	}
+}
+
+func mustMarshal(v any) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+func TestMessageStreams(t *testing.T) {
+	out := New(true, ASCIIGlyphs)
+	tests := []struct {
+		name       string
+		print      func()
+		wantStderr bool
+	}{
+		{"info", func() { out.Info("msg") }, false},
+		{"success", func() { out.Success("msg") }, false},
+		{"debug", func() { out.Debug("msg") }, false},
+		{"warning", func() { out.Warning("msg") }, true},
+		{"notice", func() { out.Notice("msg") }, true},
+		{"error", func() { out.Error("msg") }, true},
+	}
+	for _, tt := range tests {
+		var stderr string
+		stdout := captureStdout(t, func() { stderr = capture(t, &os.Stderr, tt.print) })
+		got, other := stdout, stderr
+		if tt.wantStderr {
+			got, other = stderr, stdout
+		}
+		if !strings.Contains(got, "msg") || other != "" {
+			t.Errorf("%s: stdout %q, stderr %q", tt.name, stdout, stderr)
+		}
+	}
+}
+
+func TestParseGlyphs(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Glyphs
+		wantErr bool
+	}{
+		{"", UnicodeGlyphs, false},
+		{"unicode", UnicodeGlyphs, false},
+		{"ascii", ASCIIGlyphs, false},
+		{"none", NoGlyphs, false},
+		{"emoji", Glyphs{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGlyphs(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseGlyphs(%q) = %+v, %v; want %+v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestGlyphOutput(t *testing.T) {
+	tests := []struct {
+		glyphs Glyphs
+		want   string
+	}{
+		{UnicodeGlyphs, Cyan + "ℹ " + Reset + "info\n" + Green + "✓ " + Reset + "done\n"},
+		{ASCIIGlyphs, Cyan + "[i] " + Reset + "info\n" + Green + "[ok] " + Reset + "done\n"},
+		// Colors stay without glyphs
+		{NoGlyphs, Cyan + Reset + "info\n" + Green + Reset + "done\n"},
+	}
+	for _, tt := range tests {
+		out := New(false, tt.glyphs)
+		got := captureStdout(t, func() {
+			out.Info("info")
+			out.Success("done")
+		})
+		if got != tt.want {
+			t.Errorf("%+v: printed %q, want %q", tt.glyphs, got, tt.want)
+		}
+	}
+
+	// Warnings and errors go to stderr with their own markers
+	stderr := capture(t, &os.Stderr, func() {
+		out := New(false, ASCIIGlyphs)
+		out.Warning("careful")
+		out.Error("failed")
+	})
+	if want := Yellow + "[!] " + Reset + "careful\n" + Red + "[x] " + Reset + "failed\n"; stderr != want {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	tests := []struct {
+		rate float64
+		want string
+	}{
+		{0, "0.0 B/s"},
+		{512, "512.0 B/s"},
+		{1024, "1.0 KB/s"},
+		{1536, "1.5 KB/s"},
+		{5 * 1024 * 1024, "5.0 MB/s"},
+		{3 * 1024 * 1024 * 1024, "3.0 GB/s"},
+		// GB/s is the largest unit
+		{2048 * 1024 * 1024 * 1024, "2048.0 GB/s"},
+	}
+	for _, tt := range tests {
+		if got := FormatRate(tt.rate); got != tt.want {
+			t.Errorf("FormatRate(%v) = %q, want %q", tt.rate, got, tt.want)
+		}
+	}
+}
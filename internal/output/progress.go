@@ -0,0 +1,145 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// progressBarWidth is the number of cells in the terminal progress bar.
+const progressBarWidth = 24
+
+// progressStep is the percentage between progress lines when stdout is not
+// a terminal.
+const progressStep = 25
+
+// Progress returns a transfer progress callback for label. On a terminal a
+// bar with percentage, bytes, rate and ETA is redrawn in place; elsewhere a
+// line is printed every progressStep percent. Completion replaces either
+// with the elapsed time and average rate.
+func (o *Output) Progress(label string) func(done, total int64) {
+	tty := term.IsTerminal(int(os.Stdout.Fd()))
+	start := time.Now()
+	lastStep := -1
+	return func(done, total int64) {
+		elapsed := time.Since(start)
+		rate, eta := estimate(done, total, elapsed)
+		prefix := Cyan + o.glyphs.Info + Reset
+
+		if total > 0 && done >= total {
+			if tty {
+				fmt.Print("\r\033[K")
+			}
+			fmt.Printf("%s%s complete (%s in %s, %s)\n", prefix, label, FormatBytes(done),
+				elapsed.Round(time.Millisecond), FormatRate(rate))
+			return
+		}
+
+		if !tty {
+			if total <= 0 {
+				return
+			}
+			step := int(done * 100 / total / progressStep)
+			if step == lastStep {
+				return
+			}
+			lastStep = step
+			fmt.Printf("%s%s %d%% (%s of %s)\n", prefix, label, step*progressStep, FormatBytes(done), FormatBytes(total))
+			return
+		}
+
+		var line string
+		if total > 0 {
+			line = fmt.Sprintf("%s %s %3d%%  %s/%s  %s", label, progressBar(done, total),
+				done*100/total, FormatBytes(done), FormatBytes(total), FormatRate(rate))
+			if eta = eta.Round(time.Second); eta > 0 {
+				line += "  ETA " + eta.String()
+			}
+		} else {
+			line = fmt.Sprintf("%s %s...", label, FormatBytes(done))
+		}
+		fmt.Print("\r\033[K" + prefix + line)
+	}
+}
+
+// ClearLine erases a progress bar drawn in place on a terminal, so that a
+// message printed during the transfer starts on a clean line.
+func (o *Output) ClearLine() {
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Print("\r\033[K")
+	}
+}
+
+// estimate returns the average rate in bytes per second and the time left
+// at that rate. The ETA is 0 until something has been transferred or when
+// the total is unknown.
+func estimate(done, total int64, elapsed time.Duration) (float64, time.Duration) {
+	if elapsed <= 0 || done <= 0 {
+		return 0, 0
+	}
+	rate := float64(done) / elapsed.Seconds()
+	if total <= done {
+		return rate, 0
+	}
+	return rate, time.Duration(float64(total-done) / rate * float64(time.Second))
+}
+
+// progressBar renders done/total as a fixed-width bar, e.g. "[#####.....]".
+func progressBar(done, total int64) string {
+	filled := int(done * progressBarWidth / total)
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled) + "]"
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. "1.5 MB".
+func FormatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	units := []string{"KB", "MB", "GB"}
+	v := float64(n) / 1024
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", v, units[i])
+}
+
+// ParseBytes parses a size such as "65536", "64K", "64KB" or "1MB", with
+// binary units.
+func ParseBytes(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	number, unit := upper, int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"B", 1}} {
+		if n, ok := strings.CutSuffix(upper, u.suffix); ok {
+			number, unit = strings.TrimSpace(n), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (e.g. 65536, 64KB or 1MB)", s)
+	}
+	return n * unit, nil
+}
+
+// FormatRate renders a bytes-per-second rate with a binary unit, e.g. "12.3 KB/s".
+func FormatRate(bytesPerSecond float64) string {
+	units := []string{"B/s", "KB/s", "MB/s", "GB/s"}
+	i := 0
+	for bytesPerSecond >= 1024 && i < len(units)-1 {
+		bytesPerSecond /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", bytesPerSecond, units[i])
+}
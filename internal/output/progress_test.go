@@ -0,0 +1,75 @@
+package output
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{"65536", 65536, false},
+		{"0", 0, false},
+		{"64K", 64 << 10, false},
+		{"64KB", 64 << 10, false},
+		{"64kb", 64 << 10, false},
+		{" 64 KB ", 64 << 10, false},
+		{"1MB", 1 << 20, false},
+		{"2G", 2 << 30, false},
+		{"100B", 100, false},
+		{"", 0, true},
+		{"KB", 0, true},
+		{"1.5MB", 0, true},
+		{"-1", 0, true},
+		{"64TB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBytes(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBytes(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseBytes(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestProgressLinesOffTerminal(t *testing.T) {
+	tests := []struct {
+		name  string
+		calls [][2]int64
+		want  []string
+	}{
+		{
+			name:  "one line per step",
+			calls: [][2]int64{{0, 100}, {10, 100}, {30, 100}, {40, 100}, {60, 100}, {100, 100}},
+			want:  []string{"up 0% (0 B of 100 B)", "up 25% (30 B of 100 B)", "up 50% (60 B of 100 B)", "up complete (100 B in"},
+		},
+		{
+			name:  "unknown total prints only completion",
+			calls: [][2]int64{{0, 0}, {5, 0}, {10, 10}},
+			want:  []string{"up complete (10 B in"},
+		},
+	}
+	for _, tt := range tests {
+		stdout := captureStdout(t, func() {
+			progress := New(false, NoGlyphs).Progress("up")
+			for _, c := range tt.calls {
+				progress(c[0], c[1])
+			}
+		})
+		lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+		if len(lines) != len(tt.want) {
+			t.Fatalf("%s: printed %q, want %d lines", tt.name, stdout, len(tt.want))
+		}
+		for i, want := range tt.want {
+			if !strings.Contains(lines[i], want) {
+				t.Errorf("%s: line %d = %q, want containing %q", tt.name, i, lines[i], want)
+			}
+		}
+	}
+}
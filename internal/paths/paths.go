@@ -0,0 +1,49 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	// HomeEnv overrides the state directory.
+	HomeEnv = "AWS_SSM_CONNECT_HOME"
+
+	legacyDir = ".aws-ssm-connect"
+	xdgDir    = "aws-ssm-connect"
+)
+
+// Dir returns the directory holding history, notes, queries and config.
+//
+// Resolution order:
+//  1. $AWS_SSM_CONNECT_HOME
+//  2. ~/.aws-ssm-connect, if it already exists
+//  3. $XDG_CONFIG_HOME/aws-ssm-connect, if XDG_CONFIG_HOME is set
+//  4. ~/.aws-ssm-connect
+func Dir() (string, error) {
+	if dir := os.Getenv(HomeEnv); dir != "" {
+		return dir, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	legacy := filepath.Join(home, legacyDir)
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy, nil
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, xdgDir), nil
+	}
+	return legacy, nil
+}
+
+// File returns the path of elem inside the state directory.
+func File(elem ...string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{dir}, elem...)...), nil
+}
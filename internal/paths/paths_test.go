@@ -0,0 +1,62 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDir(t *testing.T) {
+	tests := []struct {
+		name      string
+		override  bool
+		legacy    bool
+		xdg       bool
+		wantUnder string // "override", "home" or "xdg"
+		wantElem  string
+	}{
+		{"override wins", true, true, true, "override", ""},
+		{"existing legacy dir", false, true, true, "home", legacyDir},
+		{"xdg when no legacy dir", false, false, true, "xdg", xdgDir},
+		{"legacy by default", false, false, false, "home", legacyDir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots := map[string]string{"override": t.TempDir(), "home": t.TempDir(), "xdg": t.TempDir()}
+			t.Setenv("HOME", roots["home"])
+			t.Setenv(HomeEnv, "")
+			t.Setenv("XDG_CONFIG_HOME", "")
+			if tt.override {
+				t.Setenv(HomeEnv, roots["override"])
+			}
+			if tt.xdg {
+				t.Setenv("XDG_CONFIG_HOME", roots["xdg"])
+			}
+			if tt.legacy {
+				if err := os.Mkdir(filepath.Join(roots["home"], legacyDir), 0700); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := Dir()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(roots[tt.wantUnder], tt.wantElem); got != want {
+				t.Errorf("Dir() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(HomeEnv, dir)
+	got, err := File("queries", "prod.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "queries", "prod.json"); got != want {
+		t.Errorf("File() = %s, want %s", got, want)
+	}
+}
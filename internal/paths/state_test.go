@@ -0,0 +1,67 @@
+package paths_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/config"
+	"github.com/e/aws-ssm-connect/internal/history"
+	"github.com/e/aws-ssm-connect/internal/notes"
+	"github.com/e/aws-ssm-connect/internal/paths"
+	"github.com/e/aws-ssm-connect/internal/query"
+)
+
+// TestStateLandsUnderHome writes every kind of state and checks it all ends
+// up in the overridden directory, with nothing in the real home.
+func TestStateLandsUnderHome(t *testing.T) {
+	dir, home := t.TempDir(), t.TempDir()
+	t.Setenv(paths.HomeEnv, dir)
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"history_limit": 3}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := config.LoadSettings()
+	if err != nil || s.HistoryLimit != 3 {
+		t.Fatalf("LoadSettings() = %+v, %v; want config.json from %s", s, err, dir)
+	}
+
+	h, _ := history.Connections.Load("prod")
+	if err := h.Add("i-1", "web-1"); err != nil {
+		t.Fatal(err)
+	}
+	n, _ := notes.Load()
+	if err := n.Set("i-1", "primary"); err != nil {
+		t.Fatal(err)
+	}
+	if err := query.Save("web", &query.Query{Names: []string{"web"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var written []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			written = append(written, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(written)
+	for _, want := range []string{"config.json", "history.json", "notes.json", "queries/web.json"} {
+		if i := sort.SearchStrings(written, want); i == len(written) || written[i] != want {
+			t.Errorf("%s not written under %s; found %v", want, dir, written)
+		}
+	}
+
+	entries, _ := os.ReadDir(home)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), "aws-ssm-connect") {
+			t.Errorf("state written to the home directory: %s", e.Name())
+		}
+	}
+}
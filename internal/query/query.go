@@ -0,0 +1,96 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+	"github.com/e/aws-ssm-connect/internal/selector"
+)
+
+const queriesDir = "queries"
+
+// Query is a saved set of discovery filters.
+type Query struct {
+	Tags        []string `json:"tags,omitempty"`
+	ExcludeTags []string `json:"exclude_tags,omitempty"`
+	Names       []string `json:"names,omitempty"`
+	Region      string   `json:"region,omitempty"`
+}
+
+// Validate checks that tag filters are well-formed.
+func (q *Query) Validate() error {
+	if _, err := selector.ParseTagFilters(q.Tags); err != nil {
+		return err
+	}
+	if _, err := selector.ParseTagFilters(q.ExcludeTags); err != nil {
+		return err
+	}
+	return nil
+}
+
+// LoadFile reads a query from a JSON file. Unknown keys are rejected.
+func LoadFile(path string) (*Query, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read query file: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	q := &Query{}
+	if err := dec.Decode(q); err != nil {
+		return nil, fmt.Errorf("invalid query file %s: %w", path, err)
+	}
+	if err := q.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid query file %s: %w", path, err)
+	}
+	return q, nil
+}
+
+// Load reads a named query from queries/<name>.json in the state directory.
+func Load(name string) (*Query, error) {
+	path, err := Path(name)
+	if err != nil {
+		return nil, err
+	}
+	return LoadFile(path)
+}
+
+// Save writes a named query to queries/<name>.json in the state directory.
+func Save(name string, q *Query) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+
+	// Create directory if needed
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+// Path returns the file path of a named query.
+func Path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid query name %q", name)
+	}
+
+	return paths.File(queriesDir, name+".json")
+}
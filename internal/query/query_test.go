@@ -0,0 +1,114 @@
+package query
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+)
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	t.Setenv(paths.HomeEnv, t.TempDir())
+
+	tests := []struct {
+		name string
+		q    *Query
+	}{
+		{"empty", &Query{}},
+		{"tags only", &Query{Tags: []string{"env=prod", "team=core"}}},
+		{"everything", &Query{
+			Tags:        []string{"env=prod"},
+			ExcludeTags: []string{"role=bastion"},
+			Names:       []string{"web", "api"},
+			Region:      "eu-west-1",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Save(tt.name, tt.q); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Load(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.q) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.q)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *Query
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			content: `{"tags": ["env=prod"], "names": ["web"], "region": "us-east-1"}`,
+			want:    &Query{Tags: []string{"env=prod"}, Names: []string{"web"}, Region: "us-east-1"},
+		},
+		{
+			name:    "unknown key",
+			content: `{"tags": ["env=prod"], "tag": ["typo"]}`,
+			wantErr: `unknown field "tag"`,
+		},
+		{
+			name:    "wrong type",
+			content: `{"region": ["us-east-1"]}`,
+			wantErr: "invalid query file",
+		},
+		{
+			name:    "malformed tag",
+			content: `{"tags": ["=prod"]}`,
+			wantErr: "invalid query file",
+		},
+		{
+			name:    "not json",
+			content: `tags: [env=prod]`,
+			wantErr: "invalid query file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "q.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadFile(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadFile() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadFile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathRejectsUnsafeNames(t *testing.T) {
+	t.Setenv(paths.HomeEnv, t.TempDir())
+
+	for _, name := range []string{"", "../etc", `a\b`, "a/b", ".hidden"} {
+		if _, err := Path(name); err == nil {
+			t.Errorf("Path(%q) succeeded, want error", name)
+		}
+	}
+	if _, err := Path("prod-web"); err != nil {
+		t.Errorf("Path(prod-web) = %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package selector
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// azPattern loosely matches availability zone names such as us-east-1a,
+// eu-central-1b or local zones like us-west-2-lax-1a.
+var azPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z0-9]+)+-?[0-9][a-z]$`)
+
+// ParseAZs validates a list of availability zone names.
+func ParseAZs(values []string) ([]string, error) {
+	for _, v := range values {
+		if !azPattern.MatchString(v) {
+			return nil, fmt.Errorf("invalid availability zone %q (expected e.g. us-east-1a)", v)
+		}
+	}
+	return values, nil
+}
+
+// FilterByAZ returns instances placed in any of the given availability zones.
+// No zones means no filtering.
+func FilterByAZ(instances []Instance, zones []string) []Instance {
+	if len(zones) == 0 {
+		return instances
+	}
+
+	allowed := make(map[string]bool, len(zones))
+	for _, z := range zones {
+		allowed[z] = true
+	}
+
+	var filtered []Instance
+	for _, inst := range instances {
+		if allowed[inst.AZ] {
+			filtered = append(filtered, inst)
+		}
+	}
+	return filtered
+}
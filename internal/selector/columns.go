@@ -0,0 +1,95 @@
+package selector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Column is an instance field that can be displayed in the finder and list.
+type Column struct {
+	Name  string
+	Width int
+	Value func(Instance) string
+}
+
+var columns = []Column{
+	{Name: "id", Width: 19, Value: func(i Instance) string { return i.ID }},
+	{Name: "name", Width: 30, Value: func(i Instance) string { return i.Name }},
+	{Name: "ip", Width: 15, Value: func(i Instance) string { return i.PrivateIP }},
+	{Name: "az", Width: 12, Value: func(i Instance) string { return i.AZ }},
+	{Name: "state", Width: 10, Value: func(i Instance) string { return i.State }},
+	{Name: "platform", Width: 8, Value: func(i Instance) string { return i.Platform }},
+	{Name: "profile", Width: 16, Value: func(i Instance) string { return i.Profile }},
+	{Name: "ssm", Width: 14, Value: func(i Instance) string { return i.SSMStatus }},
+	{Name: "agent", Width: 12, Value: func(i Instance) string { return i.AgentVersion }},
+	{Name: "ping", Width: 8, Value: func(i Instance) string { return PingAge(i, time.Now()) }},
+}
+
+// DefaultColumns are shown when no column list is given.
+var DefaultColumns = []string{"id", "name", "ip"}
+
+// ColumnNames returns the names of all known columns.
+func ColumnNames() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// ParseColumns parses a comma-separated column list, preserving order.
+func ParseColumns(spec string) ([]Column, error) {
+	var parsed []Column
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		col, ok := lookupColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q (valid: %s)", name, strings.Join(ColumnNames(), ", "))
+		}
+		parsed = append(parsed, col)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("no columns given (valid: %s)", strings.Join(ColumnNames(), ", "))
+	}
+	return parsed, nil
+}
+
+func lookupColumn(name string) (Column, bool) {
+	for _, c := range columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+func defaultColumns() []Column {
+	cols, _ := ParseColumns(strings.Join(DefaultColumns, ","))
+	return cols
+}
+
+// FormatRow renders the instance as tab-separated column values.
+func FormatRow(inst Instance, cols []Column) string {
+	values := make([]string, len(cols))
+	for i, c := range cols {
+		values[i] = c.Value(inst)
+	}
+	return strings.Join(values, "\t")
+}
+
+// formatLine renders the instance as fixed-width columns for the finder.
+func formatLine(inst Instance, cols []Column) string {
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		value := c.Value(inst)
+		if c.Name == "name" && value == "" {
+			value = "(no name)"
+		}
+		parts[i] = fmt.Sprintf("%-*s", c.Width, truncate(value, c.Width))
+	}
+	return strings.Join(parts, "  ")
+}
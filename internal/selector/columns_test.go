@@ -0,0 +1,66 @@
+package selector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr string
+	}{
+		{"id,name,ip", []string{"id", "name", "ip"}, ""},
+		// Order is kept, case and blanks are ignored
+		{" State, ID ,,az", []string{"state", "id", "az"}, ""},
+		{"id,bogus", nil, `unknown column "bogus" (valid: id, name, ip, az, state`},
+		{"", nil, "no columns given"},
+		{" , ", nil, "no columns given"},
+	}
+	for _, tt := range tests {
+		cols, err := ParseColumns(tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseColumns(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseColumns(%q) error = %v", tt.spec, err)
+			continue
+		}
+		var got []string
+		for _, c := range cols {
+			got = append(got, c.Name)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("ParseColumns(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestRenderColumns(t *testing.T) {
+	inst := Instance{ID: "i-0abc", PrivateIP: "10.0.0.5", AZ: "eu-west-1a", State: "running"}
+	tests := []struct {
+		spec     string
+		wantRow  string
+		wantLine string
+	}{
+		{"ip,id", "10.0.0.5\ti-0abc", "10.0.0.5         i-0abc             "},
+		// An empty name reads as such in the finder but stays empty in rows
+		{"name,state", "\trunning", "(no name)                       running   "},
+	}
+	for _, tt := range tests {
+		cols, err := ParseColumns(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := FormatRow(inst, cols); got != tt.wantRow {
+			t.Errorf("FormatRow(%s) = %q, want %q", tt.spec, got, tt.wantRow)
+		}
+		if got := formatLine(inst, cols); got != tt.wantLine {
+			t.Errorf("formatLine(%s) = %q, want %q", tt.spec, got, tt.wantLine)
+		}
+	}
+}
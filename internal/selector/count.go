@@ -0,0 +1,61 @@
+package selector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NoValue labels instances without a value for the counted field.
+const NoValue = "(none)"
+
+// Count is the number of instances sharing one value of a field.
+type Count struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// CountBy groups instances by field, either a column name (az, state,
+// platform, ...) or tag:Key, and returns the counts, largest first.
+func CountBy(instances []Instance, field string) ([]Count, error) {
+	value, err := fieldValue(field)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, inst := range instances {
+		v := value(inst)
+		if v == "" {
+			v = NoValue
+		}
+		counts[v]++
+	}
+
+	result := make([]Count, 0, len(counts))
+	for v, n := range counts {
+		result = append(result, Count{Value: v, Count: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result, nil
+}
+
+// fieldValue returns the accessor for a column name or tag:Key.
+func fieldValue(field string) (func(Instance) string, error) {
+	if key, ok := strings.CutPrefix(field, "tag:"); ok {
+		if key == "" {
+			return nil, fmt.Errorf("invalid field %q (expected tag:Key)", field)
+		}
+		return func(i Instance) string { return i.Tags[key] }, nil
+	}
+	col, ok := lookupColumn(strings.ToLower(field))
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q (valid: tag:Key, %s)", field, strings.Join(ColumnNames(), ", "))
+	}
+	return col.Value, nil
+}
@@ -0,0 +1,32 @@
+package selector
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// WriteCSV writes instances as CSV with a header row of column names.
+// Values containing commas, quotes or newlines are quoted.
+func WriteCSV(w io.Writer, instances []Instance, cols []Column) error {
+	if len(cols) == 0 {
+		cols = defaultColumns()
+	}
+	cw := csv.NewWriter(w)
+	record := make([]string, len(cols))
+	for i, c := range cols {
+		record[i] = c.Name
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	for _, inst := range instances {
+		for i, c := range cols {
+			record[i] = c.Value(inst)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
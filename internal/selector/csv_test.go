@@ -0,0 +1,46 @@
+package selector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		instances []Instance
+		spec      string // empty for the default columns
+		want      string
+	}{
+		{"plain", []Instance{{ID: "i-1", Name: "web-1", PrivateIP: "10.0.0.1"}}, "",
+			"id,name,ip\ni-1,web-1,10.0.0.1\n"},
+		{"comma in name", []Instance{{ID: "i-1", Name: "api, blue"}}, "id,name",
+			"id,name\ni-1,\"api, blue\"\n"},
+		{"quote in name", []Instance{{ID: "i-1", Name: `the "old" box`}}, "id,name",
+			"id,name\ni-1,\"the \"\"old\"\" box\"\n"},
+		{"newline in name", []Instance{{ID: "i-1", Name: "two\nlines"}}, "id,name",
+			"id,name\ni-1,\"two\nlines\"\n"},
+		// --columns order is kept, header included
+		{"column order", []Instance{{ID: "i-1", Name: "web-1", State: "running"}}, "state,name,id",
+			"state,name,id\nrunning,web-1,i-1\n"},
+		{"no instances", nil, "id,name", "id,name\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cols []Column
+			if tt.spec != "" {
+				var err error
+				if cols, err = ParseColumns(tt.spec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var b strings.Builder
+			if err := WriteCSV(&b, tt.instances, cols); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("WriteCSV() = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package selector
+
+import (
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// filterDebounce is how long the finder waits for typing to pause before
+// re-filtering when keystrokes arrive in quick succession.
+const filterDebounce = 50 * time.Millisecond
+
+// debouncer coalesces rapid query changes into a single filter pass.
+// A change after a quiet period is applied immediately, so single keystrokes
+// still feel instant; changes arriving faster than the delay are deferred.
+type debouncer struct {
+	delay   time.Duration
+	last    time.Time
+	pending bool
+}
+
+// change records a query change at now and reports whether it should be
+// applied immediately. When it returns false, the caller must schedule a
+// flush after the delay.
+func (d *debouncer) change(now time.Time) bool {
+	immediate := d.last.IsZero() || now.Sub(d.last) >= d.delay
+	d.last = now
+	d.pending = !immediate
+	return immediate
+}
+
+// flush reports whether a deferred change is due at now. Once the delay has
+// elapsed since the last change, the pending change is always released, even
+// when the timer fires exactly on the boundary.
+func (d *debouncer) flush(now time.Time) bool {
+	if !d.pending || now.Sub(d.last) < d.delay {
+		return false
+	}
+	d.pending = false
+	return true
+}
+
+// filterEvent is posted to the screen when a deferred filter is due.
+type filterEvent struct {
+	tcell.EventTime
+}
+
+func scheduleFilter(screen tcell.Screen, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		ev := &filterEvent{}
+		ev.SetEventNow()
+		_ = screen.PostEvent(ev)
+	})
+}
@@ -0,0 +1,51 @@
+package selector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDebouncerFirstChangeIsImmediate(t *testing.T) {
+	d := &debouncer{delay: 50 * time.Millisecond}
+	if !d.change(time.Unix(0, 0)) {
+		t.Error("first change was deferred")
+	}
+	if d.flush(time.Unix(1, 0)) {
+		t.Error("flush released a change that was already applied")
+	}
+}
+
+func TestDebouncerCoalescesRapidChanges(t *testing.T) {
+	start := time.Unix(100, 0)
+	d := &debouncer{delay: 50 * time.Millisecond}
+	d.change(start)
+
+	// Three keystrokes 10ms apart are all deferred
+	for i := 1; i <= 3; i++ {
+		if d.change(start.Add(time.Duration(i) * 10 * time.Millisecond)) {
+			t.Fatalf("keystroke %d applied immediately", i)
+		}
+	}
+	last := start.Add(30 * time.Millisecond)
+
+	// Timers scheduled by earlier keystrokes fire too soon and are ignored
+	if d.flush(last.Add(20 * time.Millisecond)) {
+		t.Error("flushed before the delay passed since the last change")
+	}
+	// Exactly on the boundary the final query is applied, once
+	if !d.flush(last.Add(50 * time.Millisecond)) {
+		t.Error("final change not released on the debounce boundary")
+	}
+	if d.flush(last.Add(60 * time.Millisecond)) {
+		t.Error("change released twice")
+	}
+}
+
+func TestDebouncerChangeAfterPauseIsImmediate(t *testing.T) {
+	start := time.Unix(100, 0)
+	d := &debouncer{delay: 50 * time.Millisecond}
+	d.change(start)
+	if !d.change(start.Add(50 * time.Millisecond)) {
+		t.Error("change after a full delay was deferred")
+	}
+}
@@ -0,0 +1,270 @@
+package selector
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// finder is the interactive loop behind SelectInstance and Select: a prompt
+// above the rows matching the query, filtered as the user types.
+type finder[T any] struct {
+	// header describes what is listed, on the top row.
+	header string
+	// total is the number of rows before filtering.
+	total int
+	// query is the initial filter text.
+	query string
+	// continueKey accepts like Enter but sets the continue flag; 0 for none.
+	continueKey tcell.Key
+	// inline is the height of an inline finder, 0 for the alternate screen.
+	inline int
+	// filter returns the rows matching query, best first.
+	filter func(query string) []T
+	// id identifies a row, to keep it highlighted across filter changes.
+	id func(row T) string
+	// line formats a row for a terminal w cells wide, with its style.
+	line func(row T, w int) (string, tcell.Style)
+}
+
+// run shows the finder until a row is accepted and returns it, with the
+// query at that time and whether the continue key accepted it.
+func (f *finder[T]) run() (row T, query string, cont bool, err error) {
+	screen, cleanupScreen, err := openFinderScreen(f.inline)
+	if err != nil {
+		return row, "", false, err
+	}
+
+	debounce := &debouncer{delay: filterDebounce}
+	query = f.query
+	applied := query
+	cursor := len(query)
+	selected := 0
+	// selectedID is the highlighted row, followed across filter changes.
+	selectedID := ""
+	filtered := f.filter(query)
+	indexOfID := func(rows []T, id string) int {
+		for i, r := range rows {
+			if f.id(r) == id {
+				return i
+			}
+		}
+		// A filter change dropped it: back to the top
+		return 0
+	}
+
+	for {
+		if selected >= len(filtered) {
+			selected = len(filtered) - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+		if len(filtered) > 0 {
+			selectedID = f.id(filtered[selected])
+		}
+
+		f.draw(screen, filtered, query, cursor, selected)
+		screen.Show()
+
+		prevQuery := query
+		ev := screen.PollEvent()
+		switch ev := ev.(type) {
+		case *tcell.EventKey:
+			// The continue key accepts like Enter, but flags the result
+			key := ev.Key()
+			accepting := f.continueKey != 0 && key == f.continueKey
+			if accepting {
+				key = tcell.KeyEnter
+			}
+			switch key {
+			case tcell.KeyEscape, tcell.KeyCtrlC:
+				cleanupScreen()
+				return row, "", false, fmt.Errorf("selection cancelled")
+			case tcell.KeyEnter:
+				if applied != query {
+					// Typing stopped within the debounce window; filter before accepting
+					filtered, applied = f.filter(query), query
+					selected = indexOfID(filtered, selectedID)
+				}
+				if len(filtered) > 0 {
+					cleanupScreen()
+					return filtered[selected], query, accepting, nil
+				}
+			case tcell.KeyBackspace, tcell.KeyBackspace2:
+				if cursor > 0 {
+					query = query[:cursor-1] + query[cursor:]
+					cursor--
+				}
+			case tcell.KeyDelete:
+				if cursor < len(query) {
+					query = query[:cursor] + query[cursor+1:]
+				}
+			case tcell.KeyLeft:
+				if cursor > 0 {
+					cursor--
+				}
+			case tcell.KeyRight:
+				if cursor < len(query) {
+					cursor++
+				}
+			case tcell.KeyUp, tcell.KeyCtrlP:
+				if selected > 0 {
+					selected--
+				}
+			case tcell.KeyDown, tcell.KeyCtrlN:
+				if selected < len(filtered)-1 {
+					selected++
+				}
+			case tcell.KeyCtrlU:
+				query = query[cursor:]
+				cursor = 0
+			case tcell.KeyCtrlA:
+				cursor = 0
+			case tcell.KeyCtrlE:
+				cursor = len(query)
+			case tcell.KeyRune:
+				query = query[:cursor] + string(ev.Rune()) + query[cursor:]
+				cursor++
+			}
+		case *tcell.EventResize:
+			screen.Sync()
+		case *filterEvent:
+			if debounce.flush(time.Now()) {
+				filtered, applied = f.filter(query), query
+				selected = indexOfID(filtered, selectedID)
+			}
+		}
+
+		if query != prevQuery {
+			if debounce.change(time.Now()) {
+				filtered, applied = f.filter(query), query
+				selected = indexOfID(filtered, selectedID)
+			} else {
+				scheduleFilter(screen, debounce.delay)
+			}
+		}
+	}
+}
+
+func (f *finder[T]) draw(screen tcell.Screen, filtered []T, query string, cursor, selected int) {
+	screen.Clear()
+	w, h := screen.Size()
+
+	promptStyle := tcell.StyleDefault.Foreground(tcell.ColorGreen).Bold(true)
+	inputStyle := tcell.StyleDefault
+	selectedStyle := tcell.StyleDefault.Background(tcell.ColorDarkCyan).Foreground(tcell.ColorWhite)
+	dimStyle := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	countStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	headerStyle := tcell.StyleDefault.Foreground(tcell.ColorTeal)
+
+	// Draw AWS context header on its own row
+	drawString(screen, 0, 0, f.header, headerStyle)
+
+	// Draw prompt
+	prompt := "> "
+	drawString(screen, 0, 1, prompt, promptStyle)
+	drawString(screen, len(prompt), 1, query, inputStyle)
+
+	// Draw cursor
+	screen.ShowCursor(len(prompt)+cursor, 1)
+
+	// Draw count
+	countStr := fmt.Sprintf("  %d/%d", len(filtered), f.total)
+	drawString(screen, len(prompt)+len(query), 1, countStr, countStyle)
+
+	// Draw separator
+	drawString(screen, 0, 2, strings.Repeat("─", w), dimStyle)
+
+	// Draw rows
+	maxVisible := h - 4
+	startIdx := 0
+	if selected >= maxVisible {
+		startIdx = selected - maxVisible + 1
+	}
+
+	for i := 0; i < maxVisible && startIdx+i < len(filtered); i++ {
+		y := i + 3
+
+		text, style := f.line(filtered[startIdx+i], w)
+		line := "  " + text
+		if startIdx+i == selected {
+			style = selectedStyle
+			line = "> " + text
+		}
+
+		// Pad line to full width for selection highlight
+		if n := utf8.RuneCountInString(line); n < w {
+			line += strings.Repeat(" ", w-n)
+		}
+
+		drawString(screen, 0, y, line, style)
+	}
+
+	// Draw help at bottom
+	helpText := "↑/↓ navigate • Enter select • Esc cancel • Type to filter (words are AND-matched)"
+	drawString(screen, 0, h-1, helpText, dimStyle)
+}
+
+// openFinderScreen opens the screen the finder runs on. Tests replace it
+// with a simulation screen.
+var openFinderScreen = openScreen
+
+// openScreen starts a tcell screen, inline when inline rows are asked for,
+// and returns it with the function that must be called before returning to
+// restore the terminal.
+func openScreen(inline int) (tcell.Screen, func(), error) {
+	// Save original file descriptors BEFORE tcell takes over.
+	// tcell's Fini() closes stdin/stdout/stderr on macOS, so we need to
+	// restore them afterward for subprocess execution to work properly.
+	savedStdin, _ := unix.Dup(int(os.Stdin.Fd()))
+	savedStdout, _ := unix.Dup(int(os.Stdout.Fd()))
+	savedStderr, _ := unix.Dup(int(os.Stderr.Fd()))
+	// Terminal modes to put back after tcell, when stdin is a terminal
+	savedState, _ := term.GetState(int(os.Stdin.Fd()))
+
+	var screen tcell.Screen
+	var afterFini func()
+	var err error
+	if inline > 0 {
+		screen, afterFini, err = newInlineScreen(inline)
+	} else {
+		screen, err = tcell.NewScreen()
+	}
+	if err != nil {
+		unix.Close(savedStdin)
+		unix.Close(savedStdout)
+		unix.Close(savedStderr)
+		return nil, nil, fmt.Errorf("failed to create screen: %w", err)
+	}
+	if err := screen.Init(); err != nil {
+		unix.Close(savedStdin)
+		unix.Close(savedStdout)
+		unix.Close(savedStderr)
+		return nil, nil, fmt.Errorf("failed to init screen: %w", err)
+	}
+
+	return screen, func() {
+		screen.Fini()
+		// Restore original file descriptors that tcell's Fini() closed
+		_ = unix.Dup2(savedStdin, int(os.Stdin.Fd()))
+		_ = unix.Dup2(savedStdout, int(os.Stdout.Fd()))
+		_ = unix.Dup2(savedStderr, int(os.Stderr.Fd()))
+		_ = unix.Close(savedStdin)
+		_ = unix.Close(savedStdout)
+		_ = unix.Close(savedStderr)
+		// Put the terminal modes back as they were before tcell took over
+		if savedState != nil {
+			_ = term.Restore(int(os.Stdin.Fd()), savedState)
+		}
+		if afterFini != nil {
+			afterFini()
+		}
+	}, nil
+}
@@ -0,0 +1,115 @@
+package selector
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPty returns the master and slave ends of a new pseudo-terminal.
+func openPty(t *testing.T) (master, slave *os.File) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatal(err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: 24, Col: 80}); err != nil {
+		t.Fatal(err)
+	}
+	slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { slave.Close() })
+	return master, slave
+}
+
+// TestScreenRestoresTerminalModes opens and closes the finder screen in a
+// child process whose controlling terminal is a pseudo-terminal, then
+// checks the terminal modes are back as before and stty was never run.
+func TestScreenRestoresTerminalModes(t *testing.T) {
+	if result := os.Getenv("SCREEN_RESTORE_RESULT"); result != "" {
+		os.WriteFile(result, []byte(screenRoundTrip()), 0600)
+		os.Exit(0)
+	}
+
+	master, slave := openPty(t)
+	go io.Copy(io.Discard, master)
+
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "stty-ran")
+	stty := "#!/bin/sh\ntouch " + marker + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "stty"), []byte(stty), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	result := filepath.Join(dir, "result")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestScreenRestoresTerminalModes$")
+	cmd.Env = append(os.Environ(), "SCREEN_RESTORE_RESULT="+result, "TERM=xterm", "PATH="+dir+":"+os.Getenv("PATH"))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "ok" {
+		t.Error(string(got))
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("stty was run")
+	}
+}
+
+// screenRoundTrip opens the finder screen on the controlling terminal and
+// closes it again, describing any terminal mode that was not restored.
+func screenRoundTrip() string {
+	fd := int(os.Stdin.Fd())
+	before, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err.Error()
+	}
+	_, cleanup, err := openScreen(0)
+	if err != nil {
+		return err.Error()
+	}
+	during, _ := unix.IoctlGetTermios(fd, unix.TCGETS)
+	cleanup()
+	after, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err.Error()
+	}
+
+	var problems []string
+	if during.Lflag&unix.ICANON != 0 {
+		problems = append(problems, "screen left canonical mode on")
+	}
+	if before.Iflag != after.Iflag || before.Oflag != after.Oflag || before.Cflag != after.Cflag ||
+		before.Lflag != after.Lflag || !reflect.DeepEqual(before.Cc, after.Cc) {
+		problems = append(problems, fmt.Sprintf("modes not restored: before %+v, after %+v", *before, *after))
+	}
+	if len(problems) == 0 {
+		return "ok"
+	}
+	return strings.Join(problems, "; ")
+}
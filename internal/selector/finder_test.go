@@ -0,0 +1,42 @@
+package selector
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// TestFinderWithoutTerminal runs the finder in a child process that has no
+// controlling terminal, as under cron or in CI, where it must fail rather
+// than print a pick.
+func TestFinderWithoutTerminal(t *testing.T) {
+	if os.Getenv("FINDER_WITHOUT_TERMINAL") == "1" {
+		_, err := SelectInstance([]Instance{{ID: "i-1"}, {ID: "i-2"}}, Options{})
+		if err == nil {
+			os.Stdout.WriteString("picked without a terminal\n")
+			os.Exit(0)
+		}
+		os.Stderr.WriteString(err.Error())
+		os.Exit(3)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFinderWithoutTerminal$")
+	cmd.Env = append(os.Environ(), "FINDER_WITHOUT_TERMINAL=1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 3 {
+		t.Fatalf("finder without a terminal: %v, stdout %q, stderr %q", err, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "screen") {
+		t.Errorf("error = %q, want a screen error", stderr.String())
+	}
+	if stdout.String() != "" {
+		t.Errorf("stdout = %q, want nothing", stdout.String())
+	}
+}
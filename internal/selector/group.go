@@ -0,0 +1,54 @@
+package selector
+
+import (
+	"sort"
+	"strings"
+)
+
+// Group is the instances sharing one value of a field.
+type Group struct {
+	Value     string     `json:"value"`
+	Instances []Instance `json:"instances"`
+	// Zones counts the group's instances per availability zone, largest
+	// first, when grouping by name, to help spread load across duplicates.
+	Zones []Count `json:"zones,omitempty"`
+}
+
+// GroupBy splits instances by field (see CountBy), keeping their order
+// within each group. Groups are sorted by value, with NoValue last.
+// Grouped by name, each group also gets its per-AZ breakdown.
+func GroupBy(instances []Instance, field string) ([]Group, error) {
+	value, err := fieldValue(field)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	var groups []Group
+	for _, inst := range instances {
+		v := value(inst)
+		if v == "" {
+			v = NoValue
+		}
+		i, ok := index[v]
+		if !ok {
+			i = len(groups)
+			index[v] = i
+			groups = append(groups, Group{Value: v})
+		}
+		groups[i].Instances = append(groups[i].Instances, inst)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if (groups[i].Value == NoValue) != (groups[j].Value == NoValue) {
+			return groups[j].Value == NoValue
+		}
+		return groups[i].Value < groups[j].Value
+	})
+	if strings.EqualFold(field, "name") {
+		for i := range groups {
+			groups[i].Zones, _ = CountBy(groups[i].Instances, "az")
+		}
+	}
+	return groups, nil
+}
@@ -0,0 +1,82 @@
+package selector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupBy(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-1", AZ: "us-east-1b", Tags: map[string]string{"Env": "prod"}},
+		{ID: "i-2", AZ: "us-east-1a"},
+		{ID: "i-3", AZ: "us-east-1b", Tags: map[string]string{"Env": "dev"}},
+		{ID: "i-4", Tags: map[string]string{"Env": "prod"}},
+	}
+	tests := []struct {
+		field   string
+		want    map[string][]string
+		order   []string
+		wantErr bool
+	}{
+		{field: "az", order: []string{"us-east-1a", "us-east-1b", NoValue}, want: map[string][]string{
+			"us-east-1a": {"i-2"}, "us-east-1b": {"i-1", "i-3"}, NoValue: {"i-4"},
+		}},
+		{field: "AZ", order: []string{"us-east-1a", "us-east-1b", NoValue}, want: map[string][]string{
+			"us-east-1a": {"i-2"}, "us-east-1b": {"i-1", "i-3"}, NoValue: {"i-4"},
+		}},
+		{field: "tag:Env", order: []string{"dev", "prod", NoValue}, want: map[string][]string{
+			"dev": {"i-3"}, "prod": {"i-1", "i-4"}, NoValue: {"i-2"},
+		}},
+		{field: "tag:", wantErr: true},
+		{field: "color", wantErr: true},
+	}
+	for _, tt := range tests {
+		groups, err := GroupBy(instances, tt.field)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("GroupBy(%q) error = %v, wantErr %v", tt.field, err, tt.wantErr)
+			continue
+		}
+		var order []string
+		for _, g := range groups {
+			order = append(order, g.Value)
+			if got := ids(g.Instances); !reflect.DeepEqual(got, tt.want[g.Value]) {
+				t.Errorf("GroupBy(%q) group %s = %v, want %v", tt.field, g.Value, got, tt.want[g.Value])
+			}
+		}
+		if !reflect.DeepEqual(order, tt.order) {
+			t.Errorf("GroupBy(%q) order = %v, want %v", tt.field, order, tt.order)
+		}
+	}
+}
+
+func TestGroupByNameZones(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-1", Name: "web", AZ: "us-east-1a"},
+		{ID: "i-2", Name: "web", AZ: "us-east-1b"},
+		{ID: "i-3", Name: "web", AZ: "us-east-1b"},
+		{ID: "i-4", Name: "db"},
+	}
+	groups, err := GroupBy(instances, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]Count{
+		"db":  {{Value: NoValue, Count: 1}},
+		"web": {{Value: "us-east-1b", Count: 2}, {Value: "us-east-1a", Count: 1}},
+	}
+	for _, g := range groups {
+		if !reflect.DeepEqual(g.Zones, want[g.Value]) {
+			t.Errorf("group %s zones = %v, want %v", g.Value, g.Zones, want[g.Value])
+		}
+	}
+
+	groups, err = GroupBy(instances, "az")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range groups {
+		if g.Zones != nil {
+			t.Errorf("group %s by az has zones %v", g.Value, g.Zones)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package selector
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// DefaultInlineHeight is the finder height used by --inline without --inline-height.
+const DefaultInlineHeight = 15
+
+// minInlineHeight fits the header, prompt, separator, one instance and the
+// help line.
+const minInlineHeight = 5
+
+// inlineHeight returns how many rows an inline finder asking for requested
+// rows gets on a terminal that is rows tall.
+func inlineHeight(requested, rows int) int {
+	return min(max(requested, minInlineHeight), rows)
+}
+
+// inlineTty reports a shortened window so tcell only draws the top rows.
+type inlineTty struct {
+	tcell.Tty
+	requested int
+	height    int
+}
+
+func (t *inlineTty) WindowSize() (int, int, error) {
+	w, h, err := t.Tty.WindowSize()
+	if err != nil {
+		return w, h, err
+	}
+	t.height = inlineHeight(t.requested, h)
+	return w, t.height, nil
+}
+
+// newInlineScreen returns a screen that draws on the main buffer instead of
+// the alternate one, so the finder stays in scrollback. The current screen
+// contents are scrolled away first and the finder is drawn in the top rows.
+// After Fini, the returned function moves the cursor below the finder.
+func newInlineScreen(height int) (tcell.Screen, func(), error) {
+	ti, err := tcell.LookupTerminfo(os.Getenv("TERM"))
+	if err != nil {
+		return nil, nil, err
+	}
+	inline := *ti
+	inline.EnterCA, inline.ExitCA, inline.Clear = "", "", ""
+
+	devTty, err := tcell.NewDevTty()
+	if err != nil {
+		return nil, nil, err
+	}
+	tty := &inlineTty{Tty: devTty, requested: height}
+	_, rows, err := devTty.WindowSize()
+	if err != nil {
+		devTty.Close()
+		return nil, nil, err
+	}
+
+	screen, err := tcell.NewTerminfoScreenFromTtyTerminfo(tty, &inline)
+	if err != nil {
+		devTty.Close()
+		return nil, nil, err
+	}
+
+	// Scroll what is on screen into scrollback rather than drawing over it
+	writeTty(strings.Repeat("\n", rows))
+	return screen, func() {
+		writeTty(fmt.Sprintf("\033[%d;1H", tty.height+1))
+	}, nil
+}
+
+// writeTty writes terminal control output directly to the controlling
+// terminal, which stays correct when stdout is redirected.
+func writeTty(s string) {
+	f, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, _ = f.WriteString(s)
+}
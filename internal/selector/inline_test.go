@@ -0,0 +1,60 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestInlineHeight(t *testing.T) {
+	tests := []struct {
+		requested, rows, want int
+	}{
+		{15, 50, 15},
+		{DefaultInlineHeight, 40, DefaultInlineHeight},
+		// Never shorter than the finder's fixed rows plus one instance
+		{1, 50, minInlineHeight},
+		{0, 50, minInlineHeight},
+		// Never taller than the terminal
+		{30, 20, 20},
+		{15, 3, 3},
+	}
+	for _, tt := range tests {
+		if got := inlineHeight(tt.requested, tt.rows); got != tt.want {
+			t.Errorf("inlineHeight(%d, %d) = %d, want %d", tt.requested, tt.rows, got, tt.want)
+		}
+	}
+}
+
+// sizedTty reports a fixed window size.
+type sizedTty struct {
+	tcell.Tty
+	w, h int
+}
+
+func (t *sizedTty) WindowSize() (int, int, error) { return t.w, t.h, nil }
+
+func TestInlineTtyFollowsResize(t *testing.T) {
+	dev := &sizedTty{w: 120, h: 50}
+	tty := &inlineTty{Tty: dev, requested: 15}
+	tests := []struct {
+		rows       int
+		wantHeight int
+	}{
+		{50, 15},
+		// Shrinking the terminal shrinks the finder, growing it back restores it
+		{10, 10},
+		{40, 15},
+	}
+	for _, tt := range tests {
+		dev.h = tt.rows
+		w, h, err := tty.WindowSize()
+		if err != nil || w != 120 || h != tt.wantHeight {
+			t.Errorf("%d rows: WindowSize() = %d, %d, %v; want 120, %d", tt.rows, w, h, err, tt.wantHeight)
+		}
+		// The cleanup moves the cursor below the last drawn height
+		if tty.height != tt.wantHeight {
+			t.Errorf("%d rows: height = %d, want %d", tt.rows, tty.height, tt.wantHeight)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package selector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// DefaultContinueKey accepts the current instance and asks the caller to
+// reopen the finder afterwards.
+const DefaultContinueKey = tcell.KeyCtrlO
+
+// reservedKeys are Ctrl combinations the finder already uses, or that
+// terminals send for Backspace, Tab, Enter and Escape.
+var reservedKeys = map[byte]bool{
+	'a': true, 'c': true, 'e': true, 'h': true, 'i': true,
+	'm': true, 'n': true, 'p': true, 'u': true,
+}
+
+// ParseKey parses a key name such as "ctrl-o" for the continue key.
+func ParseKey(name string) (tcell.Key, error) {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	letter, ok := strings.CutPrefix(lower, "ctrl-")
+	if !ok || len(letter) != 1 || letter[0] < 'a' || letter[0] > 'z' {
+		return 0, fmt.Errorf("invalid key %q (expected ctrl-<letter>, e.g. ctrl-o)", name)
+	}
+	if reservedKeys[letter[0]] {
+		return 0, fmt.Errorf("key %q is already used by the finder", name)
+	}
+	return tcell.KeyCtrlA + tcell.Key(letter[0]-'a'), nil
+}
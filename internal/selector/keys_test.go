@@ -0,0 +1,144 @@
+package selector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// simulateFinder makes the finder run on a simulation screen that receives
+// keys, as if typed, once it opens.
+func simulateFinder(t *testing.T, keys ...*tcell.EventKey) {
+	t.Helper()
+	orig := openFinderScreen
+	t.Cleanup(func() { openFinderScreen = orig })
+	openFinderScreen = func(int) (tcell.Screen, func(), error) {
+		screen := tcell.NewSimulationScreen("UTF-8")
+		if err := screen.Init(); err != nil {
+			return nil, nil, err
+		}
+		screen.SetSize(80, 24)
+		for _, ev := range keys {
+			screen.InjectKey(ev.Key(), ev.Rune(), ev.Modifiers())
+		}
+		return screen, screen.Fini, nil
+	}
+}
+
+// typed returns the key events for typing s.
+func typed(s string) []*tcell.EventKey {
+	var keys []*tcell.EventKey
+	for _, r := range s {
+		keys = append(keys, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	return keys
+}
+
+func key(k tcell.Key) *tcell.EventKey {
+	return tcell.NewEventKey(k, 0, tcell.ModNone)
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    tcell.Key
+		wantErr string
+	}{
+		{"ctrl-o", tcell.KeyCtrlO, ""},
+		{" Ctrl-G ", tcell.KeyCtrlG, ""},
+		{"ctrl-z", tcell.KeyCtrlZ, ""},
+		{"ctrl-n", 0, "already used by the finder"},
+		{"ctrl-m", 0, "already used by the finder"},
+		{"alt-o", 0, "expected ctrl-<letter>"},
+		{"ctrl-1", 0, "expected ctrl-<letter>"},
+		{"ctrl-", 0, "expected ctrl-<letter>"},
+		{"o", 0, "expected ctrl-<letter>"},
+	}
+	for _, tt := range tests {
+		got, err := ParseKey(tt.name)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseKey(%q) error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseKey(%q) = %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSelectInstanceContinueKey(t *testing.T) {
+	instances := []Instance{{ID: "i-web1", Name: "web-1"}, {ID: "i-db1", Name: "db-1"}}
+	tests := []struct {
+		name         string
+		continueKey  tcell.Key
+		keys         []*tcell.EventKey
+		wantID       string
+		wantQuery    string
+		wantContinue bool
+	}{
+		{"enter accepts", 0, []*tcell.EventKey{key(tcell.KeyEnter)}, "i-web1", "", false},
+		{"default continue key", 0, []*tcell.EventKey{key(tcell.KeyCtrlO)}, "i-web1", "", true},
+		{"continue keeps the query", 0, append(typed("db"), key(tcell.KeyCtrlO)), "i-db1", "db", true},
+		{"custom continue key", tcell.KeyCtrlG, []*tcell.EventKey{key(tcell.KeyDown), key(tcell.KeyCtrlG)}, "i-db1", "", true},
+		// Another key replaces the default, which then does nothing
+		{"default replaced", tcell.KeyCtrlG, []*tcell.EventKey{key(tcell.KeyCtrlO), key(tcell.KeyEnter)}, "i-web1", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simulateFinder(t, tt.keys...)
+			got, err := SelectInstance(instances, Options{ContinueKey: tt.continueKey})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != tt.wantID || got.Query != tt.wantQuery || got.Continue != tt.wantContinue {
+				t.Errorf("SelectInstance() = %s, query %q, continue %t; want %s, %q, %t",
+					got.ID, got.Query, got.Continue, tt.wantID, tt.wantQuery, tt.wantContinue)
+			}
+		})
+	}
+}
+
+func TestSelectInstanceCancel(t *testing.T) {
+	simulateFinder(t, key(tcell.KeyEscape))
+	if _, err := SelectInstance([]Instance{{ID: "i-1"}}, Options{}); err == nil || err.Error() != "selection cancelled" {
+		t.Errorf("SelectInstance() error = %v, want cancellation", err)
+	}
+}
+
+func TestSelectInstanceKeepsHighlight(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-aaa", Name: "web-1"},
+		{ID: "i-bbb", Name: "web-2"},
+		{ID: "i-ccc", Name: "db-1"},
+		{ID: "i-ddd", Name: "web-3"},
+		{ID: "i-eee", Name: "db-2"},
+	}
+	down := key(tcell.KeyDown)
+	tests := []struct {
+		name   string
+		query  string
+		keys   []*tcell.EventKey
+		wantID string
+	}{
+		{"narrowing keeps it", "", append([]*tcell.EventKey{down, down}, typed("1")...), "i-ccc"},
+		{"clearing keeps it", "db", []*tcell.EventKey{down, key(tcell.KeyCtrlU)}, "i-eee"},
+		{"widening keeps it", "db-2", []*tcell.EventKey{key(tcell.KeyBackspace2), key(tcell.KeyBackspace2)}, "i-eee"},
+		// Only a highlight the filter drops goes back to the top
+		{"filtered out", "", append([]*tcell.EventKey{down, down}, typed("w")...), "i-aaa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simulateFinder(t, append(tt.keys, key(tcell.KeyEnter))...)
+			got, err := SelectInstance(instances, Options{Query: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != tt.wantID {
+				t.Errorf("SelectInstance() = %s, want %s", got.ID, tt.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package selector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Item is an entry of a list picked with Select that is not an instance,
+// such as a menu action, a region or a file.
+type Item struct {
+	// Key identifies the item and is shown first.
+	Key string
+	// Label describes the item, shown after the key.
+	Label string
+}
+
+// ListOptions configures Select.
+type ListOptions struct {
+	// Profile and Region describe the AWS context shown in the header;
+	// an empty Region is left out.
+	Profile string
+	Region  string
+	// Label says what is listed, e.g. "regions", after the AWS context.
+	Label string
+	// Inline is the height of an inline finder, as in Options.
+	Inline int
+}
+
+// Select presents items in the finder and returns the index of the one
+// picked. Filtering matches every word in the key or the label.
+func Select(items []Item, opts ListOptions) (int, error) {
+	if len(items) == 0 {
+		return 0, fmt.Errorf("no %s available", opts.Label)
+	}
+	keyWidth := 0
+	for _, item := range items {
+		keyWidth = max(keyWidth, len([]rune(item.Key)))
+	}
+	f := &finder[int]{
+		header: listHeader(opts.Profile, opts.Region, opts.Label),
+		total:  len(items),
+		inline: opts.Inline,
+		filter: func(query string) []int { return matchItems(items, query) },
+		id:     strconv.Itoa,
+		line: func(i, w int) (string, tcell.Style) {
+			return itemLine(items[i], keyWidth, w), tcell.StyleDefault
+		},
+	}
+	i, _, _, err := f.run()
+	return i, err
+}
+
+// FilterItems returns the items matching every word of query, like the
+// filter of Select.
+func FilterItems(items []Item, query string) []Item {
+	var matched []Item
+	for _, i := range matchItems(items, query) {
+		matched = append(matched, items[i])
+	}
+	return matched
+}
+
+// matchItems returns the indexes of the items matching every word of query,
+// those matching in the key first. Ties keep their order.
+func matchItems(items []Item, query string) []int {
+	words := strings.Fields(strings.ToLower(query))
+	var matched, scores []int
+next:
+	for i, item := range items {
+		key, label := strings.ToLower(item.Key), strings.ToLower(item.Label)
+		score := 0
+		for _, word := range words {
+			switch {
+			case strings.Contains(key, word):
+				score += weightName
+			case strings.Contains(label, word):
+				score += weightID
+			default:
+				continue next
+			}
+		}
+		matched = append(matched, i)
+		scores = append(scores, score)
+	}
+	order := make([]int, len(matched))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	ranked := make([]int, len(order))
+	for i, o := range order {
+		ranked[i] = matched[o]
+	}
+	return ranked
+}
+
+// itemLine formats an item with its key padded to keyWidth, at most half of
+// the w cells of the terminal.
+func itemLine(item Item, keyWidth, w int) string {
+	if item.Label == "" {
+		return item.Key
+	}
+	width := min(keyWidth, max(w/2, 10))
+	return fmt.Sprintf("%-*s  %s", width, truncate(item.Key, width), item.Label)
+}
+
+// listHeader describes the AWS context and what Select is listing.
+func listHeader(profile, region, label string) string {
+	if profile == "" {
+		profile = "default"
+	}
+	parts := []string{"profile: " + profile}
+	if region != "" {
+		parts = append(parts, "region: "+region)
+	}
+	if label != "" {
+		parts = append(parts, label)
+	}
+	return strings.Join(parts, " • ")
+}
@@ -0,0 +1,74 @@
+package selector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchItems(t *testing.T) {
+	items := []Item{
+		{Key: "shell", Label: "Open a shell"},
+		{Key: "run", Label: "Run a command"},
+		{Key: "copy", Label: "Copy a file"},
+	}
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{0, 1, 2}},
+		{"  ", []int{0, 1, 2}},
+		// A key match outranks a label match
+		{"a", []int{0, 1, 2}},
+		{"file", []int{2}},
+		{"SHELL", []int{0}},
+		{"co", []int{2, 1}},
+		{"run file", []int{}},
+	}
+	for _, tt := range tests {
+		if got := matchItems(items, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("matchItems(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestFilterItems(t *testing.T) {
+	items := []Item{{Key: "task-1", Label: "web"}, {Key: "task-2", Label: "worker"}}
+	got := FilterItems(items, "work")
+	if want := []Item{{Key: "task-2", Label: "worker"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterItems() = %v, want %v", got, want)
+	}
+}
+
+func TestItemLine(t *testing.T) {
+	tests := []struct {
+		item     Item
+		keyWidth int
+		w        int
+		want     string
+	}{
+		{Item{Key: "us-east-1"}, 14, 80, "us-east-1"},
+		{Item{Key: "run", Label: "Run a command"}, 7, 80, "run      Run a command"},
+		{Item{Key: "a-very-long-file-name.txt", Label: "-rw-r--r--"}, 25, 24, "a-very-lo...  -rw-r--r--"},
+	}
+	for _, tt := range tests {
+		if got := itemLine(tt.item, tt.keyWidth, tt.w); got != tt.want {
+			t.Errorf("itemLine(%+v) = %q, want %q", tt.item, got, tt.want)
+		}
+	}
+}
+
+func TestListHeader(t *testing.T) {
+	tests := []struct {
+		profile, region, label string
+		want                   string
+	}{
+		{"", "", "regions", "profile: default • regions"},
+		{"prod", "eu-west-1", "/var/log", "profile: prod • region: eu-west-1 • /var/log"},
+		{"prod", "eu-west-1", "", "profile: prod • region: eu-west-1"},
+	}
+	for _, tt := range tests {
+		if got := listHeader(tt.profile, tt.region, tt.label); got != tt.want {
+			t.Errorf("listHeader(%q, %q, %q) = %q, want %q", tt.profile, tt.region, tt.label, got, tt.want)
+		}
+	}
+}
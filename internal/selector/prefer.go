@@ -0,0 +1,21 @@
+package selector
+
+// isPreferred reports whether inst carries every preferred tag. Without
+// preferences nothing is preferred.
+func isPreferred(inst Instance, prefer []TagFilter) bool {
+	return len(prefer) > 0 && matchesAllTags(inst, prefer)
+}
+
+// sortByPreference moves instances carrying every preferred tag to the
+// top, keeping the order within both parts.
+func sortByPreference(instances []Instance, prefer []TagFilter) []Instance {
+	var preferred, other []Instance
+	for _, inst := range instances {
+		if isPreferred(inst, prefer) {
+			preferred = append(preferred, inst)
+		} else {
+			other = append(other, inst)
+		}
+	}
+	return append(preferred, other...)
+}
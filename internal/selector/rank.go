@@ -0,0 +1,35 @@
+package selector
+
+import (
+	"sort"
+	"strings"
+)
+
+// Rank orders instances by how well they match any of names, best first,
+// scored like the finder's filter, including the bonus for the prefer tags.
+// Ties put recentIDs first (most recent first) and otherwise keep the given
+// order.
+func Rank(instances []Instance, names []string, recentIDs []string, prefer []TagFilter) []Instance {
+	ranked := sortByPreference(sortByRecent(instances, recentIDs), prefer)
+	scores := make([]int, len(ranked))
+	for i, inst := range ranked {
+		key := searchKey(inst)
+		key.preferred = isPreferred(inst, prefer)
+		for _, name := range names {
+			if s := key.score(strings.Fields(strings.ToLower(name))); s > scores[i] {
+				scores[i] = s
+			}
+		}
+	}
+
+	order := make([]int, len(ranked))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	result := make([]Instance, len(order))
+	for i, o := range order {
+		result[i] = ranked[o]
+	}
+	return result
+}
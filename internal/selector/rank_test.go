@@ -0,0 +1,36 @@
+package selector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRank(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-web0", Name: "api-1", PrivateIP: "10.0.0.1"},
+		{ID: "i-2", Name: "web-1", PrivateIP: "10.0.0.2", Tags: map[string]string{"Env": "prod"}},
+		{ID: "i-3", Name: "web-2", PrivateIP: "10.0.0.3"},
+		{ID: "i-4", Name: "db-1", PrivateIP: "10.0.0.4"},
+	}
+	prod := []TagFilter{{Key: "Env", Value: "prod"}}
+	tests := []struct {
+		name   string
+		names  []string
+		recent []string
+		prefer []TagFilter
+		want   []string
+	}{
+		{"name beats ID", []string{"web"}, nil, nil, []string{"i-2", "i-3", "i-web0", "i-4"}},
+		{"recent breaks ties", []string{"web"}, []string{"i-3"}, nil, []string{"i-3", "i-2", "i-web0", "i-4"}},
+		{"prefer breaks ties", []string{"web"}, []string{"i-3"}, prod, []string{"i-2", "i-3", "i-web0", "i-4"}},
+		{"best of several names", []string{"db", "web-2"}, nil, nil, []string{"i-3", "i-4", "i-web0", "i-2"}},
+		{"all words must match", []string{"web 10.0.0.3"}, nil, nil, []string{"i-3", "i-web0", "i-2", "i-4"}},
+		{"no names keeps recent order", nil, []string{"i-4", "i-3"}, nil, []string{"i-4", "i-3", "i-web0", "i-2"}},
+	}
+	for _, tt := range tests {
+		got := ids(Rank(instances, tt.names, tt.recent, tt.prefer))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Rank() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,144 +2,134 @@ package selector
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
+	"path"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
-	"golang.org/x/sys/unix"
 )
 
 // Instance represents an EC2 instance for selection.
 type Instance struct {
-	ID        string
-	Name      string
-	PrivateIP string
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	PrivateIP string            `json:"private_ip"`
+	AZ        string            `json:"az,omitempty"`
+	State     string            `json:"state,omitempty"`
+	Platform  string            `json:"platform,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	// SSMStatus is the SSM agent ping status, e.g. Online or ConnectionLost.
+	SSMStatus string `json:"ssm_status,omitempty"`
+	// AgentVersion is the SSM agent version reported by the instance.
+	AgentVersion string `json:"agent_version,omitempty"`
+	// LastPing is when the SSM agent last reported in.
+	LastPing time.Time `json:"last_ping,omitzero"`
+	// Stale is set when LastPing is older than the allowed age, even if the
+	// status still reads Online: the agent may have stopped reporting.
+	Stale bool `json:"stale,omitempty"`
+	// Profile is the AWS profile the instance was discovered with, when
+	// listing across several profiles.
+	Profile string `json:"profile,omitempty"`
+}
+
+// Options configures the interactive finder.
+type Options struct {
+	// Profile and Region describe the AWS context shown in the header.
+	Profile string
+	Region  string
+	// RecentIDs lists recently used instances, most recent first.
+	// Those instances appear at the top of the list.
+	RecentIDs []string
+	// MaxRecent caps how many recents are pinned to the top; 0 pins all.
+	MaxRecent int
+	// Notes maps instance IDs to local notes, shown next to the instance.
+	Notes map[string]string
+	// Columns selects the fields shown per instance (default: id, name, ip).
+	Columns []Column
+	// Query is the initial filter text.
+	Query string
+	// ContinueKey accepts like Enter but sets Result.Continue
+	// (default DefaultContinueKey).
+	ContinueKey tcell.Key
+	// NameWidth sets the width of the name column: a number of characters,
+	// NameWidthAuto to fit the terminal, or 0 for the default.
+	NameWidth int
+	// Prefer lists tags that bias the ranking: instances carrying all of
+	// them are listed first and win ties between equally good matches.
+	Prefer []TagFilter
+	// Inline draws the finder in this many rows on the main screen instead
+	// of the alternate screen, keeping it in scrollback; 0 uses the full
+	// alternate screen.
+	Inline int
+}
+
+// Result is the outcome of an interactive selection.
+type Result struct {
+	Instance
+	// Query is the filter text at the time of selection.
+	Query string
+	// Continue is set when the instance was accepted with the continue key:
+	// the caller should act on it and then reopen the finder.
+	Continue bool
 }
 
 // SelectInstance presents an interactive fuzzy finder for instance selection.
 // Supports multi-word AND filtering (space-separated words all must match).
-// If recentIDs is provided, those instances appear at the top of the list.
-func SelectInstance(instances []Instance, recentIDs ...string) (Instance, error) {
+func SelectInstance(instances []Instance, opts Options) (Result, error) {
 	if len(instances) == 0 {
-		return Instance{}, fmt.Errorf("no instances available")
+		return Result{}, fmt.Errorf("no instances available")
 	}
 
+	opts.RecentIDs = pinnedRecents(opts.RecentIDs, opts.MaxRecent)
+
 	// Build set of recent IDs for highlighting
 	recentSet := make(map[string]bool)
-	for _, id := range recentIDs {
+	for _, id := range opts.RecentIDs {
 		recentSet[id] = true
 	}
 
-	// Sort recent instances to the top
-	if len(recentIDs) > 0 {
-		instances = sortByRecent(instances, recentIDs)
+	// Sort recent instances to the top, and preferred ones above them
+	if len(opts.RecentIDs) > 0 {
+		instances = sortByRecent(instances, opts.RecentIDs)
+	}
+	if len(opts.Prefer) > 0 {
+		instances = sortByPreference(instances, opts.Prefer)
 	}
 
-	// Save original file descriptors BEFORE tcell takes over.
-	// tcell's Fini() closes stdin/stdout/stderr on macOS, so we need to
-	// restore them afterward for subprocess execution to work properly.
-	savedStdin, _ := unix.Dup(int(os.Stdin.Fd()))
-	savedStdout, _ := unix.Dup(int(os.Stdout.Fd()))
-	savedStderr, _ := unix.Dup(int(os.Stderr.Fd()))
-
-	screen, err := tcell.NewScreen()
-	if err != nil {
-		unix.Close(savedStdin)
-		unix.Close(savedStdout)
-		unix.Close(savedStderr)
-		return Instance{}, fmt.Errorf("failed to create screen: %w", err)
+	if len(opts.Columns) == 0 {
+		opts.Columns = defaultColumns()
 	}
-	if err := screen.Init(); err != nil {
-		unix.Close(savedStdin)
-		unix.Close(savedStdout)
-		unix.Close(savedStderr)
-		return Instance{}, fmt.Errorf("failed to init screen: %w", err)
+	continueKey := opts.ContinueKey
+	if continueKey == 0 {
+		continueKey = DefaultContinueKey
 	}
 
-	// cleanupScreen must be called before any return to restore terminal state
-	cleanupScreen := func() {
-		screen.Fini()
-		// Restore original file descriptors that tcell's Fini() closed
-		_ = unix.Dup2(savedStdin, int(os.Stdin.Fd()))
-		_ = unix.Dup2(savedStdout, int(os.Stdout.Fd()))
-		_ = unix.Dup2(savedStderr, int(os.Stderr.Fd()))
-		_ = unix.Close(savedStdin)
-		_ = unix.Close(savedStdout)
-		_ = unix.Close(savedStderr)
-		// Reset terminal to sane state after restoring FDs
-		_ = exec.Command("stty", "sane").Run()
+	index := newSearchIndex(instances, opts.Prefer)
+	f := &finder[Instance]{
+		header:      headerText(opts.Profile, opts.Region, len(instances)),
+		total:       len(instances),
+		query:       opts.Query,
+		continueKey: continueKey,
+		inline:      opts.Inline,
+		filter:      index.filter,
+		id:          func(inst Instance) string { return inst.ID },
+		line:        instanceLine(recentSet, opts),
 	}
+	inst, query, cont, err := f.run()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Instance: inst, Query: query, Continue: cont}, nil
+}
 
-	query := ""
-	cursor := 0
-	selected := 0
-
-	for {
-		filtered := filterInstances(instances, query)
-		if selected >= len(filtered) {
-			selected = len(filtered) - 1
-		}
-		if selected < 0 {
-			selected = 0
-		}
-
-		drawScreen(screen, filtered, len(instances), query, cursor, selected, recentSet)
-		screen.Show()
-
-		ev := screen.PollEvent()
-		switch ev := ev.(type) {
-		case *tcell.EventKey:
-			switch ev.Key() {
-			case tcell.KeyEscape, tcell.KeyCtrlC:
-				cleanupScreen()
-				return Instance{}, fmt.Errorf("selection cancelled")
-			case tcell.KeyEnter:
-				if len(filtered) > 0 {
-					cleanupScreen()
-					return filtered[selected], nil
-				}
-			case tcell.KeyBackspace, tcell.KeyBackspace2:
-				if cursor > 0 {
-					query = query[:cursor-1] + query[cursor:]
-					cursor--
-				}
-			case tcell.KeyDelete:
-				if cursor < len(query) {
-					query = query[:cursor] + query[cursor+1:]
-				}
-			case tcell.KeyLeft:
-				if cursor > 0 {
-					cursor--
-				}
-			case tcell.KeyRight:
-				if cursor < len(query) {
-					cursor++
-				}
-			case tcell.KeyUp, tcell.KeyCtrlP:
-				if selected > 0 {
-					selected--
-				}
-			case tcell.KeyDown, tcell.KeyCtrlN:
-				if selected < len(filtered)-1 {
-					selected++
-				}
-			case tcell.KeyCtrlU:
-				query = query[cursor:]
-				cursor = 0
-			case tcell.KeyCtrlA:
-				cursor = 0
-			case tcell.KeyCtrlE:
-				cursor = len(query)
-			case tcell.KeyRune:
-				query = query[:cursor] + string(ev.Rune()) + query[cursor:]
-				cursor++
-				selected = 0 // Reset selection on new input
-			}
-		case *tcell.EventResize:
-			screen.Sync()
-		}
+// pinnedRecents returns the recents that are pinned and highlighted: the
+// first max of them, or all when max is 0.
+func pinnedRecents(recentIDs []string, max int) []string {
+	if max > 0 && len(recentIDs) > max {
+		return recentIDs[:max]
 	}
+	return recentIDs
 }
 
 func sortByRecent(instances []Instance, recentIDs []string) []Instance {
@@ -171,116 +161,204 @@ func sortByRecent(instances []Instance, recentIDs []string) []Instance {
 	return append(recent, other...)
 }
 
-func filterInstances(instances []Instance, query string) []Instance {
+// searchIndex caches the lowercased search fields of each instance so that
+// filtering on every keystroke doesn't rebuild them for large fleets. The
+// finder builds one per session; it is not shared between callers.
+type searchIndex struct {
+	instances []Instance
+	keys      []searchFields
+	prefer    []TagFilter
+}
+
+// newSearchIndex builds the search text of instances once; the instance
+// set must not change while the index is in use.
+func newSearchIndex(instances []Instance, prefer []TagFilter) *searchIndex {
+	idx := &searchIndex{instances: instances, keys: make([]searchFields, len(instances)), prefer: prefer}
+	for i, inst := range instances {
+		idx.keys[i] = searchKey(inst)
+		idx.keys[i].preferred = isPreferred(inst, prefer)
+	}
+	return idx
+}
+
+// filter returns the instances matching every word of query, best matches
+// first: a word found in the name outranks one found in the IP, which
+// outranks one found in the ID. Ties keep their order, so recents stay on top.
+func (idx *searchIndex) filter(query string) []Instance {
 	if query == "" {
-		return instances
+		return idx.instances
 	}
 
 	words := strings.Fields(strings.ToLower(query))
 	if len(words) == 0 {
-		return instances
+		return idx.instances
 	}
 
 	var filtered []Instance
-	for _, inst := range instances {
-		searchStr := strings.ToLower(fmt.Sprintf("%s %s %s", inst.ID, inst.Name, inst.PrivateIP))
-		if matchesAllWords(searchStr, words) {
-			filtered = append(filtered, inst)
+	var scores []int
+	for i, key := range idx.keys {
+		if score := key.score(words); score > 0 {
+			filtered = append(filtered, idx.instances[i])
+			scores = append(scores, score)
 		}
 	}
-	return filtered
+	order := make([]int, len(filtered))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	ranked := make([]Instance, len(order))
+	for i, o := range order {
+		ranked[i] = filtered[o]
+	}
+	return ranked
 }
 
-func matchesAllWords(s string, words []string) bool {
+// Field weights for ranking matches. weightPrefer is a bonus for
+// instances carrying the preferred tags, enough to break ties.
+const (
+	weightID     = 1
+	weightIP     = 2
+	weightName   = 3
+	weightPrefer = 1
+)
+
+// searchFields holds the lowercased fields a query is matched against.
+type searchFields struct {
+	name, ip, id string
+	preferred    bool
+}
+
+func searchKey(inst Instance) searchFields {
+	return searchFields{
+		name: strings.ToLower(inst.Name),
+		ip:   strings.ToLower(inst.PrivateIP),
+		id:   strings.ToLower(inst.ID),
+	}
+}
+
+// score returns the summed weight of the best field each word is found in,
+// or 0 unless every word is found somewhere.
+func (f searchFields) score(words []string) int {
+	total := 0
 	for _, word := range words {
-		if !strings.Contains(s, word) {
-			return false
+		switch {
+		case strings.Contains(f.name, word):
+			total += weightName
+		case strings.Contains(f.ip, word):
+			total += weightIP
+		case strings.Contains(f.id, word):
+			total += weightID
+		default:
+			return 0
 		}
 	}
-	return true
+	if f.preferred {
+		total += weightPrefer
+	}
+	return total
 }
 
-func drawScreen(screen tcell.Screen, filtered []Instance, total int, query string, cursor, selected int, recentSet map[string]bool) {
-	screen.Clear()
-	w, h := screen.Size()
-
-	promptStyle := tcell.StyleDefault.Foreground(tcell.ColorGreen).Bold(true)
-	inputStyle := tcell.StyleDefault
+// instanceLine returns the finder row format for instances: the columns,
+// then a stale ping mark and the note; recents are highlighted.
+func instanceLine(recentSet map[string]bool, opts Options) func(Instance, int) (string, tcell.Style) {
 	normalStyle := tcell.StyleDefault
 	recentStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
-	selectedStyle := tcell.StyleDefault.Background(tcell.ColorDarkCyan).Foreground(tcell.ColorWhite)
-	dimStyle := tcell.StyleDefault.Foreground(tcell.ColorGray)
-	countStyle := tcell.StyleDefault.Foreground(tcell.ColorYellow)
-
-	// Draw prompt
-	prompt := "> "
-	drawString(screen, 0, 0, prompt, promptStyle)
-	drawString(screen, len(prompt), 0, query, inputStyle)
-
-	// Draw cursor
-	screen.ShowCursor(len(prompt)+cursor, 0)
-
-	// Draw count
-	countStr := fmt.Sprintf("  %d/%d", len(filtered), total)
-	drawString(screen, len(prompt)+len(query), 0, countStr, countStyle)
-
-	// Draw separator
-	drawString(screen, 0, 1, strings.Repeat("─", w), dimStyle)
-
-	// Draw instances
-	maxVisible := h - 3
-	startIdx := 0
-	if selected >= maxVisible {
-		startIdx = selected - maxVisible + 1
-	}
-
-	for i := 0; i < maxVisible && startIdx+i < len(filtered); i++ {
-		inst := filtered[startIdx+i]
-		y := i + 2
-
-		name := inst.Name
-		if name == "" {
-			name = "(no name)"
+	// The columns only change with the terminal width
+	var cols []Column
+	colsWidth := -1
+	return func(inst Instance, w int) (string, tcell.Style) {
+		if w != colsWidth {
+			cols, colsWidth = withNameWidth(opts.Columns, opts.NameWidth, w), w
 		}
-		line := fmt.Sprintf("  %s  %-30s  %s", inst.ID, truncate(name, 30), inst.PrivateIP)
-
-		style := normalStyle
-		if recentSet[inst.ID] {
-			style = recentStyle
+		line := formatLine(inst, cols)
+		if inst.Stale {
+			line += "  ⚠ stale ping"
 		}
-		if startIdx+i == selected {
-			style = selectedStyle
-			line = "> " + line[2:]
+		if note := opts.Notes[inst.ID]; note != "" {
+			line += "  ✎ " + note
 		}
-
-		// Pad line to full width for selection highlight
-		if len(line) < w {
-			line += strings.Repeat(" ", w-len(line))
+		if recentSet[inst.ID] {
+			return line, recentStyle
 		}
-
-		drawString(screen, 0, y, line, style)
+		return line, normalStyle
 	}
+}
 
-	// Draw help at bottom
-	helpText := "↑/↓ navigate • Enter select • Esc cancel • Type to filter (words are AND-matched)"
-	drawString(screen, 0, h-1, helpText, dimStyle)
+// headerText describes the AWS context the finder is showing.
+func headerText(profile, region string, total int) string {
+	if profile == "" {
+		profile = "default"
+	}
+	if region == "" {
+		region = "unknown"
+	}
+	noun := "instances"
+	if total == 1 {
+		noun = "instance"
+	}
+	return fmt.Sprintf("profile: %s • region: %s • %d %s", profile, region, total, noun)
 }
 
 func drawString(screen tcell.Screen, x, y int, s string, style tcell.Style) {
-	for i, r := range s {
-		screen.SetContent(x+i, y, r, nil, style)
+	// Advance one cell per rune so multi-byte glyphs don't leave gaps
+	for _, r := range s {
+		screen.SetContent(x, y, r, nil, style)
+		x++
 	}
 }
 
+// truncate shortens s to maxLen characters, marking the cut with "...".
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
 		return s
 	}
-	return s[:maxLen-3] + "..."
+	if maxLen <= 3 {
+		return string(runes[:max(maxLen, 0)])
+	}
+	return string(runes[:maxLen-3]) + "..."
 }
 
 // FindByName finds instances matching the given name filter.
 // Returns all instances where all space-separated words match (case-insensitive).
 func FindByName(instances []Instance, filter string) []Instance {
-	return filterInstances(instances, filter)
+	return newSearchIndex(instances, nil).filter(filter)
+}
+
+// Union merges instance lists, keeping the first occurrence of each instance ID.
+func Union(groups ...[]Instance) []Instance {
+	seen := make(map[string]bool)
+	var merged []Instance
+	for _, group := range groups {
+		for _, inst := range group {
+			if !seen[inst.ID] {
+				seen[inst.ID] = true
+				merged = append(merged, inst)
+			}
+		}
+	}
+	return merged
+}
+
+// FindByGlob finds instances whose name matches a shell-style glob pattern
+// (e.g. "web-*-prod"). Unlike FindByName, only the Name field is matched.
+func FindByGlob(instances []Instance, pattern string) ([]Instance, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+	}
+
+	var matches []Instance
+	for _, inst := range instances {
+		if ok, _ := path.Match(pattern, inst.Name); ok {
+			matches = append(matches, inst)
+		}
+	}
+	return matches, nil
+}
+
+// IsGlob reports whether a name filter should be treated as a glob pattern.
+// A leading "*" is taken as a hint that glob semantics are wanted.
+func IsGlob(filter string) bool {
+	return strings.HasPrefix(filter, "*")
 }
@@ -0,0 +1,210 @@
+package selector
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func testFleet(n int) []Instance {
+	instances := make([]Instance, n)
+	for i := range instances {
+		instances[i] = Instance{
+			ID:        fmt.Sprintf("i-%017x", i),
+			Name:      fmt.Sprintf("Web-%d.prod", i),
+			PrivateIP: fmt.Sprintf("10.0.%d.%d", i/256, i%256),
+		}
+	}
+	return instances
+}
+
+func ids(instances []Instance) []string {
+	out := make([]string, len(instances))
+	for i, inst := range instances {
+		out[i] = inst.ID
+	}
+	return out
+}
+
+func TestFindByName(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-web", Name: "db-1", PrivateIP: "10.0.0.1"},
+		{ID: "i-2", Name: "Web-1", PrivateIP: "10.0.0.2"},
+		{ID: "i-3", Name: "cache", PrivateIP: "10.0.0.3"},
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"i-web", "i-2", "i-3"}},
+		{"   ", []string{"i-web", "i-2", "i-3"}},
+		// A name match outranks an ID match
+		{"web", []string{"i-2", "i-web"}},
+		{"WEB 10.0.0.2", []string{"i-2"}},
+		{"10.0.0", []string{"i-web", "i-2", "i-3"}},
+		{"web cache", nil},
+	}
+	for _, tt := range tests {
+		got := ids(FindByName(instances, tt.query))
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FindByName(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchIndexIsPerInstanceSet(t *testing.T) {
+	first := []Instance{{ID: "i-1", Name: "alpha"}}
+	second := []Instance{{ID: "i-2", Name: "beta"}}
+	if got := ids(FindByName(first, "alpha")); !reflect.DeepEqual(got, []string{"i-1"}) {
+		t.Fatalf("first set: got %v", got)
+	}
+	if got := FindByName(second, "alpha"); len(got) != 0 {
+		t.Errorf("matched %v from an earlier instance set", ids(got))
+	}
+
+	// A slice changed in place is searched as it is now
+	first[0].Name = "gamma"
+	if got := ids(FindByName(first, "gamma")); !reflect.DeepEqual(got, []string{"i-1"}) {
+		t.Errorf("changed slice: got %v", got)
+	}
+}
+
+// BenchmarkFilterCached filters with one index, as the finder does per
+// keystroke.
+func BenchmarkFilterCached(b *testing.B) {
+	instances := testFleet(5000)
+	idx := newSearchIndex(instances, nil)
+	b.ResetTimer()
+	for range b.N {
+		idx.filter("web 10.0.1")
+	}
+}
+
+// BenchmarkFilterRebuild rebuilds the index for every query, as filtering
+// did before the search text was cached.
+func BenchmarkFilterRebuild(b *testing.B) {
+	instances := testFleet(5000)
+	b.ResetTimer()
+	for range b.N {
+		newSearchIndex(instances, nil).filter("web 10.0.1")
+	}
+}
+
+func TestFindByGlobVersusName(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-1", Name: "web-1-prod"},
+		{ID: "i-2", Name: "web-2-staging"},
+		{ID: "i-3", Name: "my-web-1-prod"},
+		{ID: "i-web-9", Name: "db-prod"},
+	}
+	tests := []struct {
+		filter    string
+		wantGlob  []string
+		wantFuzzy []string
+	}{
+		// The glob is anchored to the whole name; substring matching is not
+		{"web-*-prod", []string{"i-1"}, nil},
+		{"*web*", []string{"i-1", "i-2", "i-3"}, nil},
+		{"web-?-*", []string{"i-1", "i-2"}, nil},
+		{"web", nil, []string{"i-1", "i-2", "i-3", "i-web-9"}},
+		// Globs match the name only, never the ID
+		{"i-web-*", nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := FindByGlob(instances, tt.filter)
+		if err != nil {
+			t.Fatalf("FindByGlob(%q): %v", tt.filter, err)
+		}
+		if g := ids(got); !(len(g) == 0 && len(tt.wantGlob) == 0) && !reflect.DeepEqual(g, tt.wantGlob) {
+			t.Errorf("FindByGlob(%q) = %v, want %v", tt.filter, g, tt.wantGlob)
+		}
+		if g := ids(FindByName(instances, tt.filter)); !(len(g) == 0 && len(tt.wantFuzzy) == 0) && !reflect.DeepEqual(g, tt.wantFuzzy) {
+			t.Errorf("FindByName(%q) = %v, want %v", tt.filter, g, tt.wantFuzzy)
+		}
+	}
+}
+
+func TestFindByGlobInvalidPattern(t *testing.T) {
+	if _, err := FindByGlob(testFleet(1), "web-[1"); err == nil {
+		t.Error("FindByGlob accepted an unterminated character class")
+	}
+}
+
+func TestIsGlob(t *testing.T) {
+	tests := map[string]bool{"*-prod": true, "*": true, "web-*": false, "web": false, "": false}
+	for filter, want := range tests {
+		if got := IsGlob(filter); got != want {
+			t.Errorf("IsGlob(%q) = %t, want %t", filter, got, want)
+		}
+	}
+}
+
+func TestUnion(t *testing.T) {
+	a := []Instance{{ID: "i-1"}, {ID: "i-2"}}
+	b := []Instance{{ID: "i-2", Name: "later copy"}, {ID: "i-3"}}
+	got := Union(a, nil, b)
+	if want := []string{"i-1", "i-2", "i-3"}; !reflect.DeepEqual(ids(got), want) {
+		t.Fatalf("Union() = %v, want %v", ids(got), want)
+	}
+	if got[1].Name != "" {
+		t.Errorf("Union() kept the later copy of i-2")
+	}
+}
+
+func TestInstanceLine(t *testing.T) {
+	cols, err := ParseColumns("id,name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Columns: cols, Notes: map[string]string{"i-2": "flaky disk"}}
+	line := instanceLine(map[string]bool{"i-3": true}, opts)
+	tests := []struct {
+		inst       Instance
+		wantSuffix string
+		wantRecent bool
+	}{
+		{Instance{ID: "i-1", Name: "web"}, "web", false},
+		{Instance{ID: "i-2", Name: "db"}, "  ✎ flaky disk", false},
+		{Instance{ID: "i-3", Name: "cache", Stale: true}, "  ⚠ stale ping", true},
+	}
+	for _, tt := range tests {
+		got, style := line(tt.inst, 120)
+		if !strings.HasSuffix(strings.TrimRight(got, " "), tt.wantSuffix) || !strings.HasPrefix(got, tt.inst.ID) {
+			t.Errorf("line(%s) = %q, want %s first and ending in %q", tt.inst.ID, got, tt.inst.ID, tt.wantSuffix)
+		}
+		if recent := style != tcell.StyleDefault; recent != tt.wantRecent {
+			t.Errorf("line(%s) highlighted %t, want %t", tt.inst.ID, recent, tt.wantRecent)
+		}
+	}
+}
+
+func TestCappedRecentPinning(t *testing.T) {
+	instances := []Instance{{ID: "i-a"}, {ID: "i-b"}, {ID: "i-c"}, {ID: "i-d"}, {ID: "i-e"}}
+	recent := []string{"i-d", "i-b", "i-e"}
+	tests := []struct {
+		max        int
+		wantPinned []string
+		want       []string
+	}{
+		{0, recent, []string{"i-d", "i-b", "i-e", "i-a", "i-c"}},
+		// Unpinned recents fall back to the normal order
+		{2, []string{"i-d", "i-b"}, []string{"i-d", "i-b", "i-a", "i-c", "i-e"}},
+		{1, []string{"i-d"}, []string{"i-d", "i-a", "i-b", "i-c", "i-e"}},
+		{10, recent, []string{"i-d", "i-b", "i-e", "i-a", "i-c"}},
+	}
+	for _, tt := range tests {
+		pinned := pinnedRecents(recent, tt.max)
+		if !reflect.DeepEqual(pinned, tt.wantPinned) {
+			t.Errorf("pinnedRecents(max %d) = %v, want %v", tt.max, pinned, tt.wantPinned)
+		}
+		if got := ids(sortByRecent(instances, pinned)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("max %d: order = %v, want %v", tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package selector
+
+import (
+	"strings"
+	"time"
+)
+
+// StatusOnline is the SSM ping status of an instance whose agent is
+// reachable.
+const StatusOnline = "Online"
+
+// FilterOnline drops instances whose SSM agent is not online (for example
+// ConnectionLost), since sessions to them cannot start. Instances without a
+// known status are kept.
+func FilterOnline(instances []Instance) []Instance {
+	var filtered []Instance
+	for _, inst := range instances {
+		if inst.SSMStatus == "" || inst.SSMStatus == StatusOnline {
+			filtered = append(filtered, inst)
+		}
+	}
+	return filtered
+}
+
+// DefaultMaxPingAge is how long after its last ping an instance counts as
+// stale. Agents normally ping every few minutes.
+const DefaultMaxPingAge = 15 * time.Minute
+
+// IsStale reports whether lastPing is more than maxAge before now. Unknown
+// pings and a zero maxAge are never stale.
+func IsStale(lastPing time.Time, maxAge time.Duration, now time.Time) bool {
+	return maxAge > 0 && !lastPing.IsZero() && now.Sub(lastPing) > maxAge
+}
+
+// MarkStale sets Stale on instances whose last ping is older than maxAge.
+func MarkStale(instances []Instance, maxAge time.Duration, now time.Time) {
+	for i := range instances {
+		instances[i].Stale = IsStale(instances[i].LastPing, maxAge, now)
+	}
+}
+
+// PingAge renders how long ago the instance last pinged, e.g. "3m" or
+// "2h15m", with a "!" when it is stale.
+func PingAge(inst Instance, now time.Time) string {
+	if inst.LastPing.IsZero() {
+		return ""
+	}
+	age := now.Sub(inst.LastPing).Round(time.Minute)
+	text := strings.TrimSuffix(age.String(), "0s")
+	if age < time.Minute {
+		text = "<1m"
+	}
+	if inst.Stale {
+		text += "!"
+	}
+	return text
+}
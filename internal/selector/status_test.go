@@ -0,0 +1,46 @@
+package selector
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsStale(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		lastPing time.Time
+		maxAge   time.Duration
+		want     bool
+	}{
+		{"recent", now.Add(-time.Minute), 15 * time.Minute, false},
+		{"exactly max age", now.Add(-15 * time.Minute), 15 * time.Minute, false},
+		{"older", now.Add(-16 * time.Minute), 15 * time.Minute, true},
+		{"disabled", now.Add(-24 * time.Hour), 0, false},
+		{"unknown ping", time.Time{}, 15 * time.Minute, false},
+		{"ping in the future", now.Add(time.Minute), 15 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		if got := IsStale(tt.lastPing, tt.maxAge, now); got != tt.want {
+			t.Errorf("%s: IsStale() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterOnline(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-online", SSMStatus: StatusOnline},
+		{ID: "i-lost", SSMStatus: "ConnectionLost"},
+		{ID: "i-inactive", SSMStatus: "Inactive"},
+		// Without a known status the instance is kept
+		{ID: "i-unknown"},
+	}
+	var got []string
+	for _, inst := range FilterOnline(instances) {
+		got = append(got, inst.ID)
+	}
+	if want := []string{"i-online", "i-unknown"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("FilterOnline() = %v, want %v", got, want)
+	}
+}
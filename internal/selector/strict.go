@@ -0,0 +1,27 @@
+package selector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AmbiguousError reports that names matched several instances, listing all
+// of them so a script can be fixed without opening the finder.
+func AmbiguousError(names []string, matches []Instance) error {
+	var b strings.Builder
+	if len(names) == 1 {
+		fmt.Fprintf(&b, "%q matches %d instances:", names[0], len(matches))
+	} else {
+		fmt.Fprintf(&b, "%q match %d instances:", names, len(matches))
+	}
+	for _, inst := range matches {
+		b.WriteString("\n  " + inst.ID)
+		if inst.Name != "" {
+			b.WriteString("\t" + inst.Name)
+		}
+		if inst.Profile != "" {
+			b.WriteString("\t(" + inst.Profile + ")")
+		}
+	}
+	return fmt.Errorf("%s", b.String())
+}
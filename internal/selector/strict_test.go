@@ -0,0 +1,27 @@
+package selector
+
+import "testing"
+
+func TestAmbiguousError(t *testing.T) {
+	matches := []Instance{
+		{ID: "i-web1", Name: "web-1"},
+		{ID: "i-web2"},
+		{ID: "i-web3", Name: "web-3", Profile: "dev"},
+	}
+	tests := []struct {
+		name    string
+		names   []string
+		matches []Instance
+		want    string
+	}{
+		{"one name", []string{"web"}, matches[:2],
+			"\"web\" matches 2 instances:\n  i-web1\tweb-1\n  i-web2"},
+		{"several names", []string{"web-1", "web-3"}, []Instance{matches[0], matches[2]},
+			"[\"web-1\" \"web-3\"] match 2 instances:\n  i-web1\tweb-1\n  i-web3\tweb-3\t(dev)"},
+	}
+	for _, tt := range tests {
+		if got := AmbiguousError(tt.names, tt.matches).Error(); got != tt.want {
+			t.Errorf("%s: AmbiguousError() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
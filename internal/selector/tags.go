@@ -0,0 +1,79 @@
+package selector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TagFilter matches instances carrying a tag with the given key and value.
+type TagFilter struct {
+	Key   string
+	Value string
+}
+
+// ParseTagFilter parses a "key=value" tag filter.
+func ParseTagFilter(s string) (TagFilter, error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return TagFilter{}, fmt.Errorf("invalid tag filter %q (expected key=value)", s)
+	}
+	return TagFilter{Key: key, Value: value}, nil
+}
+
+// ParseTagFilters parses a list of "key=value" tag filters.
+func ParseTagFilters(values []string) ([]TagFilter, error) {
+	var filters []TagFilter
+	for _, v := range values {
+		f, err := ParseTagFilter(v)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// String returns the filter in "key=value" form.
+func (f TagFilter) String() string {
+	return f.Key + "=" + f.Value
+}
+
+// Matches reports whether the instance carries the filter's tag.
+func (f TagFilter) Matches(inst Instance) bool {
+	value, ok := inst.Tags[f.Key]
+	return ok && value == f.Value
+}
+
+// FilterByTags returns instances that match all include filters and none of
+// the exclude filters. Exclusions are OR-ed: matching any one removes the instance.
+func FilterByTags(instances []Instance, include, exclude []TagFilter) []Instance {
+	if len(include) == 0 && len(exclude) == 0 {
+		return instances
+	}
+
+	var filtered []Instance
+	for _, inst := range instances {
+		if matchesAllTags(inst, include) && !matchesAnyTag(inst, exclude) {
+			filtered = append(filtered, inst)
+		}
+	}
+	return filtered
+}
+
+func matchesAllTags(inst Instance, filters []TagFilter) bool {
+	for _, f := range filters {
+		if !f.Matches(inst) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAnyTag(inst Instance, filters []TagFilter) bool {
+	for _, f := range filters {
+		if f.Matches(inst) {
+			return true
+		}
+	}
+	return false
+}
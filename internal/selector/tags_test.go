@@ -0,0 +1,58 @@
+package selector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTagFilter(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    TagFilter
+		wantErr bool
+	}{
+		{"env=prod", TagFilter{Key: "env", Value: "prod"}, false},
+		{"decommissioned=", TagFilter{Key: "decommissioned"}, false},
+		{"url=a=b", TagFilter{Key: "url", Value: "a=b"}, false},
+		{"env", TagFilter{}, true},
+		{"=prod", TagFilter{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTagFilter(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTagFilter(%q) = %+v, %v; want %+v, error %t", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFilterByTags(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-web", Tags: map[string]string{"env": "prod", "role": "web"}},
+		{ID: "i-old", Tags: map[string]string{"env": "prod", "decommissioned": "true"}},
+		{ID: "i-dev", Tags: map[string]string{"env": "dev"}},
+		{ID: "i-none"},
+	}
+	prod := TagFilter{Key: "env", Value: "prod"}
+	web := TagFilter{Key: "role", Value: "web"}
+	gone := TagFilter{Key: "decommissioned", Value: "true"}
+	dev := TagFilter{Key: "env", Value: "dev"}
+	tests := []struct {
+		name             string
+		include, exclude []TagFilter
+		want             []string
+	}{
+		{"no filters", nil, nil, []string{"i-web", "i-old", "i-dev", "i-none"}},
+		{"include", []TagFilter{prod}, nil, []string{"i-web", "i-old"}},
+		{"includes are AND-ed", []TagFilter{prod, web}, nil, []string{"i-web"}},
+		{"exclude", nil, []TagFilter{gone}, []string{"i-web", "i-dev", "i-none"}},
+		{"excludes are OR-ed", nil, []TagFilter{gone, dev}, []string{"i-web", "i-none"}},
+		{"exclude wins over include", []TagFilter{prod}, []TagFilter{gone}, []string{"i-web"}},
+		{"value must match", nil, []TagFilter{{Key: "decommissioned", Value: "false"}}, []string{"i-web", "i-old", "i-dev", "i-none"}},
+	}
+	for _, tt := range tests {
+		got := ids(FilterByTags(instances, tt.include, tt.exclude))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: FilterByTags() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// IsRegex reports whether filter is a /regular expression/ to match names.
+func IsRegex(filter string) bool {
+	return len(filter) > 2 && strings.HasPrefix(filter, "/") && strings.HasSuffix(filter, "/")
+}
+
+// FindByRegex returns instances whose name matches the /regular expression/.
+func FindByRegex(instances []Instance, filter string) ([]Instance, error) {
+	re, err := regexp.Compile(filter[1 : len(filter)-1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %s: %w", filter, err)
+	}
+	var matches []Instance
+	for _, inst := range instances {
+		if re.MatchString(inst.Name) {
+			matches = append(matches, inst)
+		}
+	}
+	return matches, nil
+}
+
+// MatchTargets returns every instance a target expression selects: all of
+// them for an empty expression, names matching a /regex/, or names matching
+// a glob (any expression when glob is set).
+func MatchTargets(instances []Instance, expr string, glob bool) ([]Instance, error) {
+	switch {
+	case expr == "":
+		return instances, nil
+	case IsRegex(expr):
+		return FindByRegex(instances, expr)
+	case glob || IsGlob(expr):
+		return FindByGlob(instances, expr)
+	}
+	return nil, fmt.Errorf("%q is not a target pattern (use a glob like 'web-*' or a /regex/)", expr)
+}
+
+// ResolveTargets maps a list of instance IDs and names to instances, without
+// repeats. An ID must be among instances; a name selects the instances with
+// exactly that name, else its single fuzzy match (several are an error).
+func ResolveTargets(instances []Instance, entries []string) ([]Instance, error) {
+	groups := make([][]Instance, 0, len(entries))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, "i-") {
+			found := false
+			for _, inst := range instances {
+				if inst.ID == entry {
+					groups = append(groups, []Instance{inst})
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("%s is not a running SSM-managed instance", entry)
+			}
+			continue
+		}
+
+		var exact []Instance
+		for _, inst := range instances {
+			if inst.Name == entry {
+				exact = append(exact, inst)
+			}
+		}
+		if len(exact) > 0 {
+			groups = append(groups, exact)
+			continue
+		}
+		switch matches := FindByName(instances, entry); len(matches) {
+		case 0:
+			return nil, fmt.Errorf("no instances found matching %q", entry)
+		case 1:
+			groups = append(groups, matches)
+		default:
+			return nil, AmbiguousError([]string{entry}, matches)
+		}
+	}
+	return Union(groups...), nil
+}
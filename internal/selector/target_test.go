@@ -0,0 +1,92 @@
+package selector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchTargets(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-1", Name: "web-stage-1"},
+		{ID: "i-2", Name: "web-stage-2"},
+		{ID: "i-3", Name: "db-stage"},
+		{ID: "i-4", Name: "web-prod"},
+	}
+	tests := []struct {
+		expr    string
+		glob    bool
+		want    []string
+		wantErr bool
+	}{
+		// No expression: everything the filters let through
+		{"", false, []string{"i-1", "i-2", "i-3", "i-4"}, false},
+		{"*stage*", false, []string{"i-1", "i-2", "i-3"}, false},
+		{"web-stage-?", true, []string{"i-1", "i-2"}, false},
+		{"/^web-(stage|prod)$/", false, []string{"i-4"}, false},
+		{"/-stage-[0-9]+$/", false, []string{"i-1", "i-2"}, false},
+		{"*nothing*", false, nil, false},
+		// A plain name is a single target, not a fan-out
+		{"web-prod", false, nil, true},
+		{"/[/", false, nil, true},
+		{"[", true, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := MatchTargets(instances, tt.expr, tt.glob)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("MatchTargets(%q, %t) error = %v, want error %t", tt.expr, tt.glob, err, tt.wantErr)
+			continue
+		}
+		var ids []string
+		for _, inst := range got {
+			ids = append(ids, inst.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("MatchTargets(%q, %t) = %q, want %q", tt.expr, tt.glob, ids, tt.want)
+		}
+	}
+}
+
+func TestResolveTargets(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-1", Name: "web-1"},
+		{ID: "i-2", Name: "web-2"},
+		{ID: "i-3", Name: "db-primary"},
+		// Two instances may share a name
+		{ID: "i-4", Name: "web-1"},
+	}
+	tests := []struct {
+		entries []string
+		want    []string
+		wantErr string
+	}{
+		{[]string{"i-2", "i-3"}, []string{"i-2", "i-3"}, ""},
+		{[]string{"web-1"}, []string{"i-1", "i-4"}, ""},
+		{[]string{"primary"}, []string{"i-3"}, ""},
+		// Repeats, by ID or by name, are run once
+		{[]string{"i-1", "web-1", "i-1", "web-2"}, []string{"i-1", "i-4", "i-2"}, ""},
+		{[]string{"i-9"}, nil, "i-9 is not a running SSM-managed instance"},
+		{[]string{"cache"}, nil, `no instances found matching "cache"`},
+		{[]string{"web"}, nil, `"web" matches 3 instances`},
+	}
+	for _, tt := range tests {
+		got, err := ResolveTargets(instances, tt.entries)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveTargets(%q) error = %v, want %q", tt.entries, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolveTargets(%q): %v", tt.entries, err)
+			continue
+		}
+		var ids []string
+		for _, inst := range got {
+			ids = append(ids, inst.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("ResolveTargets(%q) = %q, want %q", tt.entries, ids, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package selector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NameWidthAuto sizes the finder's name column to the terminal width.
+const NameWidthAuto = -1
+
+const (
+	// minNameWidth keeps some of the name visible on narrow terminals.
+	minNameWidth = 10
+	// linePrefix is the width of the selection marker before each row.
+	linePrefix = 2
+	// columnGap is the space between finder columns.
+	columnGap = 2
+)
+
+// ParseNameWidth parses a --label-width value: a width of at least
+// minNameWidth characters, or "auto".
+func ParseNameWidth(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "auto") {
+		return NameWidthAuto, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < minNameWidth {
+		return 0, fmt.Errorf("invalid label width %q (expected auto or a number of at least %d)", s, minNameWidth)
+	}
+	return n, nil
+}
+
+// withNameWidth returns cols with the name column resized for a terminal
+// termWidth wide. width is a fixed width, NameWidthAuto, or 0 to keep the
+// default.
+func withNameWidth(cols []Column, width, termWidth int) []Column {
+	if width == 0 {
+		return cols
+	}
+	if width == NameWidthAuto {
+		width = autoNameWidth(cols, termWidth)
+	}
+	resized := make([]Column, len(cols))
+	copy(resized, cols)
+	for i := range resized {
+		if resized[i].Name == "name" {
+			resized[i].Width = width
+		}
+	}
+	return resized
+}
+
+// autoNameWidth gives the name column whatever the other columns leave of
+// the terminal width, but never less than minNameWidth.
+func autoNameWidth(cols []Column, termWidth int) int {
+	used := linePrefix
+	for i, c := range cols {
+		if i > 0 {
+			used += columnGap
+		}
+		if c.Name != "name" {
+			used += c.Width
+		}
+	}
+	return max(termWidth-used, minNameWidth)
+}
@@ -0,0 +1,54 @@
+package selector
+
+import "testing"
+
+func TestParseNameWidth(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"auto", NameWidthAuto, false},
+		{" AUTO ", NameWidthAuto, false},
+		{"40", 40, false},
+		{"10", 10, false},
+		{"9", 0, true},
+		{"-1", 0, true},
+		{"wide", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseNameWidth(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseNameWidth(%q) = %d, %v; want %d, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWithNameWidth(t *testing.T) {
+	cols := []Column{{Name: "name", Width: 30}, {Name: "id", Width: 19}, {Name: "ip", Width: 15}}
+	// Marker, the other two columns and the gaps between all three
+	fixed := linePrefix + 19 + 15 + 2*columnGap
+
+	tests := []struct {
+		name      string
+		width     int
+		termWidth int
+		want      int
+	}{
+		{"default", 0, 200, 30},
+		{"fixed", 45, 200, 45},
+		{"fixed wider than the terminal", 120, 80, 120},
+		{"auto", NameWidthAuto, 120, 120 - fixed},
+		{"auto on a narrow terminal", NameWidthAuto, fixed + 3, minNameWidth},
+	}
+	for _, tt := range tests {
+		got := withNameWidth(cols, tt.width, tt.termWidth)
+		if got[0].Width != tt.want || got[1].Width != 19 || got[2].Width != 15 {
+			t.Errorf("%s: widths = %d/%d/%d, want name %d", tt.name, got[0].Width, got[1].Width, got[2].Width, tt.want)
+		}
+	}
+	if cols[0].Width != 30 {
+		t.Errorf("withNameWidth changed the caller's columns: name width %d", cols[0].Width)
+	}
+}
@@ -0,0 +1,84 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MinAgentVersions maps session documents to the oldest SSM agent that
+// supports them. Entries may be changed or removed to relax the check.
+var MinAgentVersions = map[string]string{
+	portForwardDocument:       "2.3.672.0",
+	sshSessionDocument:        "2.3.672.0",
+	remoteHostForwardDocument: "3.1.1374.0",
+}
+
+// requireAgent refuses to start document on an instance whose SSM agent is
+// older than MinAgentVersions allows. Unknown versions are let through.
+func (c *Client) requireAgent(ctx context.Context, instanceID, document string) error {
+	minimum, ok := MinAgentVersions[document]
+	if !ok {
+		return nil
+	}
+	info, err := c.instanceInformation(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	current := ""
+	if info.AgentVersion != nil {
+		current = *info.AgentVersion
+	}
+	older, err := agentOlder(current, minimum)
+	if err != nil {
+		c.out.Debug("Cannot check SSM agent version on %s: %v", instanceID, err)
+		return nil
+	}
+	if older {
+		return fmt.Errorf("%s needs SSM agent %s or newer, but %s runs %s (update it, e.g. with the AWS-UpdateSSMAgent document)",
+			document, minimum, instanceID, current)
+	}
+	return nil
+}
+
+// agentOlder reports whether dotted version v is older than minimum.
+func agentOlder(v, minimum string) (bool, error) {
+	a, err := parseVersion(v)
+	if err != nil {
+		return false, err
+	}
+	b, err := parseVersion(minimum)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x < y, nil
+		}
+	}
+	return false, nil
+}
+
+func parseVersion(v string) ([]int, error) {
+	if v == "" {
+		return nil, fmt.Errorf("no version reported")
+	}
+	parts := strings.Split(v, ".")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", v)
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
@@ -0,0 +1,81 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+)
+
+func TestAgentOlder(t *testing.T) {
+	tests := []struct {
+		v, minimum string
+		want       bool
+		wantErr    bool
+	}{
+		{"2.3.672.0", "2.3.672.0", false, false},
+		{"2.3.671.9", "2.3.672.0", true, false},
+		{"3.0.0.0", "2.3.672.0", false, false},
+		// Numeric, not lexical, comparison
+		{"2.3.1000.0", "2.3.672.0", false, false},
+		{"2.10.0.0", "2.9.0.0", false, false},
+		// Missing parts count as zero
+		{"3.1", "3.1.0.0", false, false},
+		{"3.1", "3.1.1374.0", true, false},
+		{"", "2.3.672.0", false, true},
+		{"2.3.x", "2.3.672.0", false, true},
+	}
+	for _, tt := range tests {
+		got, err := agentOlder(tt.v, tt.minimum)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("agentOlder(%q, %q) = %t, %v; want %t, error %t", tt.v, tt.minimum, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRequireAgent(t *testing.T) {
+	defer func(m map[string]string) { MinAgentVersions = m }(MinAgentVersions)
+	MinAgentVersions = map[string]string{portForwardDocument: "2.3.672.0"}
+
+	tests := []struct {
+		name     string
+		document string
+		version  string // empty when the agent reports none
+		wantErr  string
+	}{
+		{"new enough", portForwardDocument, "3.2.0.0", ""},
+		{"too old", portForwardDocument, "2.3.500.0",
+			"AWS-StartPortForwardingSession needs SSM agent 2.3.672.0 or newer, but i-1 runs 2.3.500.0"},
+		// Unknown versions are let through
+		{"no version", portForwardDocument, "", ""},
+		{"garbled version", portForwardDocument, "latest", ""},
+		{"no minimum", "Corp-Shell", "1.0.0.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				version := ""
+				if tt.version != "" {
+					version = fmt.Sprintf(`,"AgentVersion":%q`, tt.version)
+				}
+				fmt.Fprintf(w, `{"InstanceInformationList":[{"InstanceId":"i-1","PingStatus":"Online"%s}]}`, version)
+			}))
+			c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{})
+
+			err := c.requireAgent(context.Background(), "i-1", tt.document)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("requireAgent() = %v, want %q", err, tt.wantErr)
+			}
+			// Documents without a minimum need no lookup
+			if _, gated := MinAgentVersions[tt.document]; !gated && calls != 0 {
+				t.Errorf("looked up the agent %d times for an ungated document", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package ssm
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// cleanupTimeout bounds removing a partial upload after a failed chunk,
+// which also runs when the upload itself was interrupted.
+const cleanupTimeout = 30 * time.Second
+
+// sendPayload uploads payload to remotePath in one command when it fits,
+// otherwise in chunks. A gzipped upload to a target without gunzip ends
+// with exitNoGunzip before anything is written.
+func (c *Client) sendPayload(ctx context.Context, instanceID string, payload []byte, remotePath string, gzipped bool, progress Progress, total int64) (*CommandResult, error) {
+	decode := c.opts.Transfer.decodeCommand()
+	if len(payload) <= c.uploadLimit(remotePath, gzipped) {
+		c.out.Debug("Sending %d bytes (gzipped: %t)...", len(payload), gzipped)
+		return c.runScript(ctx, instanceID, uploadScript(payload, remotePath, gzipped, decode))
+	}
+	return c.uploadChunked(ctx, instanceID, payload, remotePath, gzipped, progress, total)
+}
+
+// uploadChunked appends payload to a temporary file next to remotePath in
+// commands of at most chunkCommandLimit bytes, then moves it into place
+// (through gunzip when gzipped). The temporary file is removed when any
+// step fails.
+func (c *Client) uploadChunked(ctx context.Context, instanceID string, payload []byte, remotePath string, gzipped bool, progress Progress, total int64) (*CommandResult, error) {
+	decode := c.opts.Transfer.decodeCommand()
+	part := fmt.Sprintf("%s.part-%d", remotePath, time.Now().UnixNano())
+	size := maxPayload(chunkCommandLimit, len(chunkScript(nil, part, true, gzipped, decode)))
+	if c.opts.MaxUploadSize > 0 && size > 0 {
+		size = min(size, c.opts.MaxUploadSize)
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("remote path %s is too long to upload in chunks", remotePath)
+	}
+
+	n := (len(payload) + size - 1) / size
+	for i := range n {
+		end := min((i+1)*size, len(payload))
+		// Print the chunk above the progress bar, then draw the bar again
+		if progress != nil {
+			c.out.ClearLine()
+		}
+		c.info("Uploading chunk %d of %d", i+1, n)
+		progress.report(total*int64(i*size)/int64(len(payload)), total)
+		result, err := c.runScript(ctx, instanceID, chunkScript(payload[i*size:end], part, i == 0, gzipped, decode))
+		if err == nil && i == 0 && result.ExitCode == exitNoGunzip {
+			return result, nil
+		}
+		if err == nil && result.ExitCode != 0 {
+			err = fmt.Errorf("exit %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+		}
+		if err != nil {
+			c.removePart(ctx, instanceID, part)
+			return nil, fmt.Errorf("upload chunk %d of %d failed: %w", i+1, n, err)
+		}
+		progress.report(total*int64(end)/int64(len(payload)), total)
+	}
+
+	// The final command removes the part file whether or not it succeeds
+	result, err := c.runScript(ctx, instanceID, assembleScript(part, remotePath, gzipped))
+	if err != nil {
+		c.removePart(ctx, instanceID, part)
+		return nil, err
+	}
+	return result, nil
+}
+
+// removePart deletes a partial upload, best effort, even if ctx was cancelled.
+func (c *Client) removePart(ctx context.Context, instanceID, part string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+	defer cancel()
+	if _, err := c.runScript(ctx, instanceID, "rm -f -- "+shellQuote(part)); err != nil {
+		c.out.Warning("Could not remove partial upload %s: %v", part, err)
+	}
+}
+
+// chunkScript builds the command that decodes chunk into part, truncating
+// it for the first chunk and appending afterwards. The first chunk of a
+// gzipped upload checks for gunzip before writing anything.
+func chunkScript(chunk []byte, part string, first, gzipped bool, decode string) string {
+	encoded := base64.StdEncoding.EncodeToString(chunk)
+	if !first {
+		return fmt.Sprintf("echo '%s' | %s >> %s", encoded, decode, shellQuote(part))
+	}
+	script := fmt.Sprintf("echo '%s' | %s > %s", encoded, decode, shellQuote(part))
+	if gzipped {
+		script = fmt.Sprintf("command -v gunzip >/dev/null 2>&1 || exit %d; %s", exitNoGunzip, script)
+	}
+	return script
+}
+
+// assembleScript builds the command that moves the finished part file to
+// remotePath, decompressing it when gzipped, and then removes it.
+func assembleScript(part, remotePath string, gzipped bool) string {
+	move := fmt.Sprintf("mv -f -- %s %s", shellQuote(part), shellQuote(remotePath))
+	if gzipped {
+		move = fmt.Sprintf("gunzip < %s > %s", shellQuote(part), shellQuote(remotePath))
+	}
+	return fmt.Sprintf("%s; rc=$?; rm -f -- %s; exit $rc", move, shellQuote(part))
+}
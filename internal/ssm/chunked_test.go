@@ -0,0 +1,241 @@
+package ssm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+)
+
+func TestMaxPayload(t *testing.T) {
+	tests := []struct {
+		limit, overhead int
+		want            int
+	}{
+		{commandParamLimit, 0, commandParamLimit / 4 * 3},
+		{commandParamLimit, 100, (commandParamLimit - 100) / 4 * 3},
+		{commandParamLimit, commandParamLimit - 3, 0},
+		{commandParamLimit, commandParamLimit - 4, 3},
+		{commandParamLimit, commandParamLimit, 0},
+		{commandParamLimit, commandParamLimit + 1, 0},
+		{chunkCommandLimit, 0, chunkCommandLimit / 4 * 3},
+		{chunkCommandLimit, 100, (chunkCommandLimit - 100) / 4 * 3},
+		{chunkCommandLimit, chunkCommandLimit + 1, 0},
+	}
+	for _, tt := range tests {
+		got := maxPayload(tt.limit, tt.overhead)
+		if got != tt.want {
+			t.Errorf("maxPayload(%d, %d) = %d, want %d", tt.limit, tt.overhead, got, tt.want)
+		}
+		if encoded := base64.StdEncoding.EncodedLen(got); encoded+tt.overhead > tt.limit && got > 0 {
+			t.Errorf("maxPayload(%d, %d) = %d encodes to %d bytes, over the limit", tt.limit, tt.overhead, got, encoded)
+		}
+	}
+}
+
+func TestChunkScript(t *testing.T) {
+	tests := []struct {
+		name           string
+		first, gzipped bool
+		want           string
+	}{
+		{"first", true, false, "echo 'aGk=' | base64 -d > '/tmp/x.part'"},
+		{"append", false, false, "echo 'aGk=' | base64 -d >> '/tmp/x.part'"},
+		{"first gzipped", true, true, "command -v gunzip >/dev/null 2>&1 || exit 86; echo 'aGk=' | base64 -d > '/tmp/x.part'"},
+		{"append gzipped", false, true, "echo 'aGk=' | base64 -d >> '/tmp/x.part'"},
+	}
+	for _, tt := range tests {
+		if got := chunkScript([]byte("hi"), "/tmp/x.part", tt.first, tt.gzipped, "base64 -d"); got != tt.want {
+			t.Errorf("%s: chunkScript() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAssembleScript(t *testing.T) {
+	tests := []struct {
+		gzipped bool
+		want    string
+	}{
+		{false, "mv -f -- '/tmp/x.part' '/tmp/it'\\''s'; rc=$?; rm -f -- '/tmp/x.part'; exit $rc"},
+		{true, "gunzip < '/tmp/x.part' > '/tmp/it'\\''s'; rc=$?; rm -f -- '/tmp/x.part'; exit $rc"},
+	}
+	for _, tt := range tests {
+		if got := assembleScript("/tmp/x.part", "/tmp/it's", tt.gzipped); got != tt.want {
+			t.Errorf("assembleScript(gzipped %t) = %q, want %q", tt.gzipped, got, tt.want)
+		}
+	}
+}
+
+// TestChunkedRoundTrip runs the chunk and assemble scripts in a local shell,
+// as the instance would, and checks the file comes out whole.
+func TestChunkedRoundTrip(t *testing.T) {
+	for _, tool := range []string{"sh", "base64", "gunzip"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+	content := bytes.Repeat([]byte("line of text\n"), 1000)
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(content)
+	w.Close()
+
+	for _, gzipped := range []bool{false, true} {
+		payload := content
+		if gzipped {
+			payload = gz.Bytes()
+		}
+		dir := t.TempDir()
+		part := filepath.Join(dir, "out.part")
+		dest := filepath.Join(dir, "out")
+		const size = 1000
+		for i := 0; i*size < len(payload); i++ {
+			chunk := payload[i*size : min((i+1)*size, len(payload))]
+			runShell(t, chunkScript(chunk, part, i == 0, gzipped, "base64 -d"))
+		}
+		runShell(t, assembleScript(part, dest, gzipped))
+
+		got, err := os.ReadFile(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("gzipped %t: assembled %d bytes, want %d", gzipped, len(got), len(content))
+		}
+		if _, err := os.Stat(part); !os.IsNotExist(err) {
+			t.Errorf("gzipped %t: part file left behind", gzipped)
+		}
+	}
+}
+
+func runShell(t *testing.T, script string) string {
+	t.Helper()
+	out, err := exec.Command("sh", "-c", script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: %v: %s", script, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestUploadChunkedProgress(t *testing.T) {
+	var scripts []string
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.SendCommand":
+			var body struct{ Parameters map[string][]string }
+			json.NewDecoder(r.Body).Decode(&body)
+			scripts = append(scripts, body.Parameters["commands"][0])
+			io.WriteString(w, `{"Command":{"CommandId":"cmd-1"}}`)
+		case "AmazonSSM.GetCommandInvocation":
+			io.WriteString(w, `{"Status":"Success","ResponseCode":0}`)
+		}
+	}))
+	c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{MaxUploadSize: 4})
+
+	var reports []string
+	progress := func(done, total int64) {
+		reports = append(reports, fmt.Sprintf("%d/%d after %d commands", done, total, len(scripts)))
+	}
+	stdout, _ := captureOutput(t, func() {
+		if _, err := c.uploadChunked(context.Background(), "i-1", []byte("0123456789"), "/tmp/x", false, progress, 10); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// Each chunk is announced on its own line and the bar redrawn below it
+	// before the chunk is sent, then advanced once it is written
+	want := []string{
+		"0/10 after 0 commands", "4/10 after 1 commands",
+		"4/10 after 1 commands", "8/10 after 2 commands",
+		"8/10 after 2 commands", "10/10 after 3 commands",
+	}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("progress reports %q, want %q", reports, want)
+	}
+	for i := 1; i <= 3; i++ {
+		if line := fmt.Sprintf("Uploading chunk %d of 3\n", i); !strings.Contains(stdout, line) {
+			t.Errorf("stdout %q lacks %q", stdout, line)
+		}
+	}
+	if strings.Contains(stdout, "\033[K") {
+		t.Errorf("line cleared although stdout is not a terminal: %q", stdout)
+	}
+	if len(scripts) != 4 {
+		t.Errorf("sent %d commands, want 3 chunks and the assembly", len(scripts))
+	}
+}
+
+func TestUploadChunkedCommandSize(t *testing.T) {
+	if chunkCommandLimit >= 2500 {
+		t.Fatalf("chunkCommandLimit = %d, want well under the 2500 character parameter limit", chunkCommandLimit)
+	}
+	defer func(d time.Duration) { commandPollInterval = d }(commandPollInterval)
+	commandPollInterval = time.Millisecond
+
+	payload := noise(8 * 1024)
+	tests := []struct {
+		name    string
+		maxSize int
+		gzipped bool
+		path    string
+		// wantChunks is 0 to only check the bound
+		wantChunks int
+	}{
+		{"default", 0, false, "/tmp/x", 0},
+		{"gzipped", 0, true, "/tmp/x", 0},
+		{"long path", 0, false, "/var/lib/" + strings.Repeat("d", 200) + "/file", 0},
+		// A larger --max-upload-size does not lift the per-command bound
+		{"large max upload size", 64 * 1024, false, "/tmp/x", 0},
+		{"small max upload size", 1024, false, "/tmp/x", 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var scripts []string
+			cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				switch r.Header.Get("X-Amz-Target") {
+				case "AmazonSSM.SendCommand":
+					var body struct{ Parameters map[string][]string }
+					json.NewDecoder(r.Body).Decode(&body)
+					scripts = append(scripts, strings.Join(body.Parameters["commands"], "\n"))
+					io.WriteString(w, `{"Command":{"CommandId":"cmd-1"}}`)
+				case "AmazonSSM.GetCommandInvocation":
+					io.WriteString(w, `{"Status":"Success","ResponseCode":0}`)
+				}
+			}))
+			c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{MaxUploadSize: tt.maxSize, Quiet: true})
+
+			if _, err := c.uploadChunked(context.Background(), "i-1", payload, tt.path, tt.gzipped, nil, 0); err != nil {
+				t.Fatal(err)
+			}
+			for i, script := range scripts {
+				if len(script) > chunkCommandLimit {
+					t.Errorf("command %d of %d is %d characters, over %d", i+1, len(scripts), len(script), chunkCommandLimit)
+				}
+			}
+			// Chunks are not needlessly small either
+			chunks := len(scripts) - 1
+			if want := tt.wantChunks; want == 0 {
+				if most := (len(payload)*4/3)/(chunkCommandLimit/2) + 1; chunks > most {
+					t.Errorf("sent %d chunks, want at most %d", chunks, most)
+				}
+			} else if chunks != want {
+				t.Errorf("sent %d chunks, want %d", chunks, want)
+			}
+		})
+	}
+}
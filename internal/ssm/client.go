@@ -1,71 +1,336 @@
 package ssm
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroups"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"golang.org/x/term"
 
 	"github.com/e/aws-ssm-connect/internal/history"
+	"github.com/e/aws-ssm-connect/internal/notes"
 	"github.com/e/aws-ssm-connect/internal/output"
 	"github.com/e/aws-ssm-connect/internal/selector"
 )
 
 // Client provides SSM operations.
 type Client struct {
-	cfg aws.Config
-	ssm *ssm.Client
-	ec2 *ec2.Client
-	out *output.Output
+	cfg  aws.Config
+	ssm  *ssm.Client
+	ec2  *ec2.Client
+	ecs  *ecs.Client
+	rg   *resourcegroups.Client
+	s3   *s3.Client
+	out  *output.Output
+	opts Options
+	// creds lets expired credentials be reloaded; nil without credentials.
+	creds *reloadableCredentials
+	// query is the last finder filter, restored when the finder reopens.
+	query string
+	// reopen records that the last pick used the finder's continue key.
+	reopen bool
+	// docMu guards docs, the cached description of each document.
+	docMu sync.Mutex
+	docs  map[string]*ssmtypes.DocumentDescription
+	// groupMu guards members, the cached instance IDs of Options.ResourceGroup.
+	groupMu sync.Mutex
+	members map[string]bool
+}
+
+// Options controls instance discovery.
+type Options struct {
+	// Tags keeps only instances carrying all of these tags.
+	Tags []selector.TagFilter
+	// ExcludeTags removes instances carrying any of these tags.
+	ExcludeTags []selector.TagFilter
+	// AZs keeps only instances in one of these availability zones.
+	AZs []string
+	// ResourceGroup keeps only instances in this Resource Group (name or ARN).
+	ResourceGroup string
+	// Glob matches names passed to SelectByName as shell-style globs.
+	Glob bool
+	// Strict makes names matching several instances an error instead of
+	// opening the finder.
+	Strict bool
+	// Prefer biases selection toward instances carrying these tags.
+	Prefer []selector.TagFilter
+	// SelectFirst picks the best ranked of several matching instances
+	// instead of opening the finder.
+	SelectFirst bool
+	// Profile is the AWS profile in use, shown in the finder header.
+	Profile string
+	// Columns selects the fields shown in the finder.
+	Columns []selector.Column
+	// Exec is sent to interactive shell sessions right after they start.
+	Exec string
+	// Shell replaces the default shell of interactive sessions when the
+	// instance has it; Exec then runs in it.
+	Shell string
+	// Reason is recorded with every session started, for auditing.
+	Reason string
+	// SessionDocument is the SSM document for shell sessions; empty uses
+	// the account default.
+	SessionDocument string
+	// IdleTimeout is passed as the session document's idleSessionTimeout
+	// parameter, when it has one; 0 leaves the document's default.
+	IdleTimeout time.Duration
+	// Stdio attaches shell sessions to stdin/stdout instead of /dev/tty.
+	Stdio bool
+	// LaunchRetries is how often a session is started again when the
+	// plugin fails right after launch.
+	LaunchRetries int
+	// MaxRecent caps how many recent instances the finder pins; 0 pins all.
+	MaxRecent int
+	// Tail streams RunCommand output while the command is still running.
+	Tail bool
+	// TailLines prints only the last lines of -run output when > 0.
+	TailLines int
+	// KillOnIdle cancels a RunCommand whose output has not changed for this
+	// long; 0 disables it.
+	KillOnIdle time.Duration
+	// NoEC2 skips EC2 DescribeInstances, listing instances from SSM only.
+	NoEC2 bool
+	// Transfer overrides the remote base64 commands used by -copy.
+	Transfer TransferCommands
+	// Encrypt encrypts -copy uploads locally; they stay encrypted remotely.
+	Encrypt Encryption
+	// MaxUploadSize overrides the payload bytes sent per upload command,
+	// and caps those of each chunk; 0 derives both from the SSM limits.
+	MaxUploadSize int
+	// NoVerify skips comparing the SHA-256 of uploaded files on the instance.
+	NoVerify bool
+	// StagingBucket, if set, stages -copy uploads in this S3 bucket instead
+	// of embedding them in the command.
+	StagingBucket string
+	// MaxInstances stops discovery after this many managed instances;
+	// 0 means DefaultMaxInstances.
+	MaxInstances int
+	// HistoryLimit is how many recent connections are kept per profile;
+	// 0 keeps the default.
+	HistoryLimit int
+	// HistoryOrder orders the recent instances pinned in the finder (see
+	// history.OrderRecency); empty means recency.
+	HistoryOrder string
+	// ExcludeOffline drops instances whose SSM agent is not online.
+	ExcludeOffline bool
+	// MaxPingAge marks instances whose agent last pinged longer ago as
+	// stale; 0 disables it.
+	MaxPingAge time.Duration
+	// NameWidth sets the finder's name column width (see selector.Options).
+	NameWidth int
+	// Inline draws the finder in this many rows without the alternate
+	// screen; 0 uses the full screen.
+	Inline int
+	// JSON keeps -copy quiet on stdout so the caller can print a JSON summary.
+	JSON bool
+	// Quiet drops -copy's transfer messages.
+	Quiet bool
+	// Command adjusts commands sent by RunCommand (sudo, workdir, env).
+	Command CommandOptions
+	// Cache serves discovery results instead of AWS when set.
+	Cache InstanceCache
+	// Finder holds finder settings only the caller knows, such as the
+	// continue key; the client fills in profile, region, recent instances
+	// and the query on a copy.
+	Finder selector.Options
 }
 
 // NewClient creates a new SSM client.
-func NewClient(cfg aws.Config, out *output.Output) *Client {
+func NewClient(cfg aws.Config, out *output.Output, opts Options) *Client {
+	var creds *reloadableCredentials
+	if cfg.Credentials != nil {
+		creds = newReloadableCredentials(cfg.Credentials)
+		cfg.Credentials = creds.cache
+	}
 	return &Client{
-		cfg: cfg,
-		ssm: ssm.NewFromConfig(cfg),
-		ec2: ec2.NewFromConfig(cfg),
-		out: out,
+		cfg:   cfg,
+		ssm:   ssm.NewFromConfig(cfg),
+		ec2:   ec2.NewFromConfig(cfg),
+		ecs:   ecs.NewFromConfig(cfg),
+		rg:    resourcegroups.NewFromConfig(cfg),
+		s3:    s3.NewFromConfig(cfg),
+		out:   out,
+		opts:  opts,
+		creds: creds,
 	}
 }
 
+// Region returns the AWS region the client operates in.
+func (c *Client) Region() string {
+	return c.cfg.Region
+}
+
+// Profile returns the AWS profile in use, if known.
+func (c *Client) Profile() string {
+	return c.opts.Profile
+}
+
 // Instance represents an EC2 instance with SSM status.
 type Instance struct {
 	ID           string
 	Name         string
 	State        string
 	PrivateIP    string
+	AZ           string
 	SSMStatus    string
 	PlatformType string
+	AgentVersion string
+	LastPing     time.Time
+	Tags         map[string]string
+}
+
+// InstanceCache serves discovered instances without calling AWS, e.g. a
+// local daemon. Results are unfiltered, like DiscoverInstances.
+type InstanceCache interface {
+	Instances(ctx context.Context, profile, region string) ([]Instance, error)
 }
 
 // GetRunningInstances returns running instances that can be connected via SSM.
+// Tag filters from the client options are applied after the API fetch.
 func (c *Client) GetRunningInstances(ctx context.Context) ([]selector.Instance, error) {
-	instances, err := c.getSSMInstances(ctx)
+	members, err := c.groupMembers(ctx)
 	if err != nil {
 		return nil, err
 	}
+	instances, err := c.cachedInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.runningInstances(instances, members), nil
+}
+
+// DiscoverInstances returns all SSM-managed instances with EC2 details,
+// before any filtering, always asking AWS.
+func (c *Client) DiscoverInstances(ctx context.Context) ([]Instance, error) {
+	return c.getSSMInstances(ctx)
+}
+
+// cachedInstances asks Options.Cache first and falls back to AWS when it
+// fails. The cache is skipped when options change what discovery returns.
+func (c *Client) cachedInstances(ctx context.Context) ([]Instance, error) {
+	customLimit := c.opts.MaxInstances > 0 && c.opts.MaxInstances != DefaultMaxInstances
+	if c.opts.Cache == nil || c.opts.NoEC2 || customLimit {
+		return c.getSSMInstances(ctx)
+	}
+	instances, err := c.opts.Cache.Instances(ctx, c.opts.Profile, c.Region())
+	if err != nil {
+		c.out.Debug("Instance cache unavailable: %v", err)
+		return c.getSSMInstances(ctx)
+	}
+	c.out.Debug("Using %d cached instances", len(instances))
+	return instances, nil
+}
+
+// StreamRunningInstances is GetRunningInstances for large fleets: each page
+// of SSM results is enriched and filtered on its own, and matching instances
+// are passed to emit as soon as their page is done. An error from emit stops
+// discovery and is returned.
+func (c *Client) StreamRunningInstances(ctx context.Context, emit func(selector.Instance) error) error {
+	members, err := c.groupMembers(ctx)
+	if err != nil {
+		return err
+	}
+	c.out.Debug("Streaming SSM-managed instances...")
+	return c.eachInstanceInformationPage(ctx, func(infos []ssmtypes.InstanceInformation) error {
+		var instances []Instance
+		if c.opts.NoEC2 {
+			instances = ssmOnlyInstances(infos)
+		} else {
+			instances = c.withEC2Details(ctx, infos)
+		}
+		for _, inst := range c.runningInstances(instances, members) {
+			if err := emit(inst); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
 
+// runningInstances converts discovered instances to finder entries, keeping
+// running ones that pass the tag, status and AZ filters. A non-nil members
+// keeps only the instances it contains.
+func (c *Client) runningInstances(instances []Instance, members map[string]bool) []selector.Instance {
 	var running []selector.Instance
 	for _, inst := range instances {
+		if members != nil && !members[inst.ID] {
+			continue
+		}
 		if inst.State == "running" {
 			running = append(running, selector.Instance{
-				ID:        inst.ID,
-				Name:      inst.Name,
-				PrivateIP: inst.PrivateIP,
+				ID:           inst.ID,
+				Name:         inst.Name,
+				PrivateIP:    inst.PrivateIP,
+				AZ:           inst.AZ,
+				State:        inst.State,
+				Platform:     inst.PlatformType,
+				Tags:         inst.Tags,
+				SSMStatus:    inst.SSMStatus,
+				AgentVersion: inst.AgentVersion,
+				LastPing:     inst.LastPing,
 			})
 		}
 	}
 
-	return running, nil
+	running = selector.FilterByTags(running, c.opts.Tags, c.opts.ExcludeTags)
+	if c.opts.MaxPingAge > 0 {
+		selector.MarkStale(running, c.opts.MaxPingAge, time.Now())
+	}
+	if c.opts.ExcludeOffline {
+		running = selector.FilterOnline(running)
+	}
+	return selector.FilterByAZ(running, c.opts.AZs)
+}
+
+// ManagedInstance looks up one instance by ID without discovery: a single
+// filtered DescribeInstanceInformation call, then one DescribeInstances call
+// for its name, state, IP and tags. With NoEC2, or when EC2 cannot describe
+// it, only SSM details are filled in and the state is left unknown.
+func (c *Client) ManagedInstance(ctx context.Context, instanceID string) (selector.Instance, error) {
+	c.out.Debug("Looking up %s without discovery...", instanceID)
+	info, err := c.instanceInformation(ctx, instanceID)
+	if err != nil {
+		return selector.Instance{}, err
+	}
+	if info.PingStatus != ssmtypes.PingStatusOnline {
+		return selector.Instance{}, fmt.Errorf("SSM agent on %s is %s, not Online", instanceID, info.PingStatus)
+	}
+
+	var inst selector.Instance
+	if !c.opts.NoEC2 {
+		if inst, err = c.EC2Instance(ctx, instanceID); err != nil {
+			c.out.Debug("No EC2 details for %s: %v", instanceID, err)
+		}
+	}
+	if inst.State != "" && inst.State != "running" {
+		return selector.Instance{}, fmt.Errorf("instance %s is %s, not running", instanceID, inst.State)
+	}
+	inst.ID = instanceID
+	inst.Platform = string(info.PlatformType)
+	inst.SSMStatus = string(info.PingStatus)
+	inst.AgentVersion = aws.ToString(info.AgentVersion)
+	inst.LastPing = aws.ToTime(info.LastPingDateTime)
+	return inst, nil
 }
 
 // SelectInstance prompts the user to select an instance using fuzzy finder.
@@ -80,10 +345,7 @@ func (c *Client) SelectInstance(ctx context.Context) (string, string, error) {
 		return "", "", fmt.Errorf("no running SSM-managed instances found")
 	}
 
-	// Load history to show recent instances first
-	hist, _ := history.Load()
-
-	selected, err := selector.SelectInstance(instances, hist.RecentIDs()...)
+	selected, err := c.selectInstance(instances)
 	if err != nil {
 		return "", "", err
 	}
@@ -92,77 +354,314 @@ func (c *Client) SelectInstance(ctx context.Context) (string, string, error) {
 }
 
 // SelectByName finds instances by name and returns the matching instance ID and name.
+// Names are substring-matched unless glob mode is enabled or the name starts with "*".
+// With several names, instances matching any of them are offered (OR semantics).
 // If multiple instances match, presents fuzzy finder for selection.
-func (c *Client) SelectByName(ctx context.Context, name string) (string, string, error) {
-	instances, err := c.GetRunningInstances(ctx)
+func (c *Client) SelectByName(ctx context.Context, names ...string) (string, string, error) {
+	selected, err := c.FindInstance(ctx, names...)
 	if err != nil {
 		return "", "", err
 	}
+	return selected.ID, selected.Name, nil
+}
+
+// FindInstance resolves names (or exact instance IDs) to a single running instance,
+// presenting the fuzzy finder when several match (an error with Options.Strict,
+// the best ranked one with Options.SelectFirst).
+func (c *Client) FindInstance(ctx context.Context, names ...string) (selector.Instance, error) {
+	instances, err := c.GetRunningInstances(ctx)
+	if err != nil {
+		return selector.Instance{}, err
+	}
 
 	if len(instances) == 0 {
-		return "", "", fmt.Errorf("no running SSM-managed instances found")
+		return selector.Instance{}, fmt.Errorf("no running SSM-managed instances found")
 	}
 
-	matches := selector.FindByName(instances, name)
+	groups := make([][]selector.Instance, 0, len(names))
+	for _, name := range names {
+		found, err := c.findByName(instances, name)
+		if err != nil {
+			return selector.Instance{}, err
+		}
+		groups = append(groups, found)
+	}
+
+	matches := selector.Union(groups...)
 	if len(matches) == 0 {
-		return "", "", fmt.Errorf("no instances found matching %q", name)
+		if len(names) == 1 {
+			return selector.Instance{}, fmt.Errorf("no instances found matching %q", names[0])
+		}
+		return selector.Instance{}, fmt.Errorf("no instances found matching any of %q", names)
 	}
 
 	if len(matches) == 1 {
-		return matches[0].ID, matches[0].Name, nil
+		return matches[0], nil
+	}
+	if c.opts.Strict {
+		return selector.Instance{}, selector.AmbiguousError(names, matches)
+	}
+	if c.opts.SelectFirst {
+		var recent []string
+		if hist, err := c.history(); err == nil {
+			recent = hist.RecentIDs()
+		}
+		best := selector.Rank(matches, names, recent, c.opts.Prefer)[0]
+		c.out.Notice("Auto-selected %s (%s), the best of %d matches", best.ID, best.Name, len(matches))
+		return best, nil
 	}
 
 	// Multiple matches - let user select
-	hist, _ := history.Load()
-	selected, err := selector.SelectInstance(matches, hist.RecentIDs()...)
+	return c.selectInstance(matches)
+}
+
+// selectInstance runs the fuzzy finder with recent instances shown first.
+// The filter text is kept so a reopened finder starts where the user left off.
+func (c *Client) selectInstance(instances []selector.Instance) (selector.Instance, error) {
+	hist, _ := c.history()
+	n, _ := notes.Load()
+	opts := c.opts.Finder
+	opts.Profile = c.opts.Profile
+	opts.Region = c.cfg.Region
+	opts.RecentIDs = hist.RecentIDs()
+	opts.MaxRecent = c.opts.MaxRecent
+	opts.Notes = n.All()
+	opts.Columns = c.opts.Columns
+	opts.Query = c.query
+	opts.Inline = c.opts.Inline
+	opts.NameWidth = c.opts.NameWidth
+	opts.Prefer = c.opts.Prefer
+	res, err := selector.SelectInstance(instances, opts)
+	c.reopen = false
 	if err != nil {
-		return "", "", err
+		return selector.Instance{}, err
 	}
+	c.query = res.Query
+	c.reopen = res.Continue
+	return res.Instance, nil
+}
 
-	return selected.ID, selected.Name, nil
+// ReopenRequested reports whether the last finder pick asked to reopen the
+// finder once the action on it is done.
+func (c *Client) ReopenRequested() bool {
+	return c.reopen
+}
+
+// findByName matches a single name filter using glob or substring semantics.
+func (c *Client) findByName(instances []selector.Instance, name string) ([]selector.Instance, error) {
+	if c.opts.Glob || selector.IsGlob(name) {
+		return selector.FindByGlob(instances, name)
+	}
+	return selector.FindByName(instances, name), nil
 }
 
 // StartSession starts an interactive SSM session with the specified instance.
+// With the Stdio option the session uses this process's stdin and stdout
+// instead of the terminal, and nothing else is printed to stdout.
 func (c *Client) StartSession(ctx context.Context, instanceID, instanceName, profile string) error {
+	if c.opts.Stdio {
+		return c.startStdioSession(ctx, instanceID, instanceName, profile)
+	}
+
 	c.out.Info("Starting session with %s...", instanceID)
 	c.out.Debug("Region: %s", c.cfg.Region)
 
-	// Save to history (unless disabled)
-	if os.Getenv("AWS_SSM_CONNECT_HISTORY_DISABLED") == "" {
-		if hist, err := history.Load(); err == nil {
-			_ = hist.Add(instanceID, instanceName)
+	c.recordHistory(instanceID, instanceName)
+
+	var err error
+	if input := sessionInput(c.opts.Shell, c.opts.Exec); input != "" {
+		err = c.runPluginWithInput(ctx, instanceID, profile, input)
+	} else {
+		err = c.runPlugin(ctx, c.shellSessionInput(ctx, instanceID), profile, streamsTTY)
+	}
+
+	// Print instance info on exit
+	if instanceName != "" {
+		fmt.Printf("Disconnected from %s %s\n", instanceName, instanceID)
+	} else {
+		fmt.Printf("Disconnected from %s\n", instanceID)
+	}
+
+	return err
+}
+
+// startStdioSession runs a session on stdin/stdout for scripts and other
+// programs. There is no pty, so prompts, line editing and Ctrl-C
+// passthrough do not work; a terminal on stdin gets a warning.
+func (c *Client) startStdioSession(ctx context.Context, instanceID, instanceName, profile string) error {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		c.out.Warning("--stdio on a terminal gives a session without a pty; interactive programs will not work")
+	}
+	c.recordHistory(instanceID, instanceName)
+	return c.runPlugin(ctx, c.shellSessionInput(ctx, instanceID), profile, streamsStdio)
+}
+
+// recordHistory saves the instance to history (unless disabled).
+func (c *Client) recordHistory(instanceID, instanceName string) {
+	if os.Getenv("AWS_SSM_CONNECT_HISTORY_DISABLED") != "" {
+		return
+	}
+	if hist, err := c.history(); err == nil {
+		_ = hist.Add(instanceID, instanceName)
+	}
+}
+
+// history loads the connection history of the client's profile.
+func (c *Client) history() (*history.History, error) {
+	return history.Connections.WithLimit(c.opts.HistoryLimit).WithOrder(c.opts.HistoryOrder).Load(c.opts.Profile)
+}
+
+// pluginStreams selects what session-manager-plugin is attached to.
+type pluginStreams int
+
+const (
+	// streamsTTY attaches the plugin to a fresh /dev/tty for interactive use.
+	streamsTTY pluginStreams = iota
+	// streamsStdio attaches the plugin to this process's stdin/stdout, e.g. as an ssh ProxyCommand.
+	streamsStdio
+)
+
+// PluginSession is a started SSM session along with the session-manager-plugin
+// arguments needed to attach to it.
+type PluginSession struct {
+	SessionID  string   `json:"session_id"`
+	StreamURL  string   `json:"stream_url"`
+	TokenValue string   `json:"token_value"`
+	PluginArgs []string `json:"plugin_args"`
+}
+
+// CreateSession calls the StartSession API for an interactive shell without
+// launching the plugin, so another tool can attach to the session.
+func (c *Client) CreateSession(ctx context.Context, instanceID, profile string) (*PluginSession, error) {
+	return c.startPluginSession(ctx, c.shellSessionInput(ctx, instanceID), profile)
+}
+
+// shellSessionInput builds the StartSession request for an interactive
+// shell, using the configured session document if any (otherwise the
+// account's default, SSM-SessionManagerRunShell).
+func (c *Client) shellSessionInput(ctx context.Context, instanceID string) *ssm.StartSessionInput {
+	input := &ssm.StartSessionInput{Target: aws.String(instanceID)}
+	if c.opts.SessionDocument != "" {
+		input.DocumentName = aws.String(c.opts.SessionDocument)
+	}
+	if c.opts.IdleTimeout > 0 {
+		c.setIdleTimeout(ctx, input)
+	}
+	return input
+}
+
+// startPluginSession calls the StartSession API and builds the plugin arguments.
+func (c *Client) startPluginSession(ctx context.Context, input *ssm.StartSessionInput, profile string) (*PluginSession, error) {
+	if input.Reason == nil && c.opts.Reason != "" {
+		input.Reason = aws.String(c.opts.Reason)
+	}
+	if input.DocumentName != nil {
+		if err := c.requireAgent(ctx, aws.ToString(input.Target), *input.DocumentName); err != nil {
+			return nil, err
 		}
 	}
 
 	// Call StartSession API using SDK
-	input := &ssm.StartSessionInput{
-		Target: &instanceID,
-	}
 	resp, err := c.ssm.StartSession(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to start session: %w", err)
+		var badDoc *ssmtypes.InvalidDocument
+		if errors.As(err, &badDoc) && input.DocumentName != nil {
+			return nil, fmt.Errorf("session document %q was not found or cannot be used for sessions (check the name and its region): %w",
+				*input.DocumentName, err)
+		}
+		return nil, fmt.Errorf("failed to start session: %w", err)
 	}
 
-	// Find session-manager-plugin
-	pluginPath, err := exec.LookPath("session-manager-plugin")
-	if err != nil {
-		return fmt.Errorf("session-manager-plugin not found (install via: brew install session-manager-plugin): %w", err)
+	// Build target JSON, including the document for non-shell sessions
+	target := map[string]any{"Target": aws.ToString(input.Target)}
+	if input.DocumentName != nil {
+		target["DocumentName"] = *input.DocumentName
+	}
+	if len(input.Parameters) > 0 {
+		target["Parameters"] = input.Parameters
+	}
+
+	return c.newPluginSession(resp.SessionId, resp.StreamUrl, resp.TokenValue, target, profile)
+}
+
+// newPluginSession builds the plugin arguments for a session started by any API.
+func (c *Client) newPluginSession(sessionID, streamURL, token *string, target map[string]any, profile string) (*PluginSession, error) {
+	sess := &PluginSession{
+		SessionID:  aws.ToString(sessionID),
+		StreamURL:  aws.ToString(streamURL),
+		TokenValue: aws.ToString(token),
 	}
 
 	// Build session response JSON for the plugin
-	sessionJSON := fmt.Sprintf(`{"SessionId":"%s","StreamUrl":"%s","TokenValue":"%s"}`,
-		*resp.SessionId, *resp.StreamUrl, *resp.TokenValue)
+	sessionJSON, err := json.Marshal(map[string]string{
+		"SessionId":  sess.SessionID,
+		"StreamUrl":  sess.StreamURL,
+		"TokenValue": sess.TokenValue,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	// Build target JSON
-	targetJSON := fmt.Sprintf(`{"Target":"%s"}`, instanceID)
+	targetJSON, err := json.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
 
 	// session-manager-plugin <session-json> <region> StartSession <profile> <target-json>
-	args := []string{
-		sessionJSON,
+	sess.PluginArgs = []string{
+		pluginName,
+		string(sessionJSON),
 		c.cfg.Region,
 		"StartSession",
 		profile,
-		targetJSON,
+		string(targetJSON),
+	}
+	return sess, nil
+}
+
+const pluginName = "session-manager-plugin"
+
+// runPlugin calls the StartSession API and hands the session to session-manager-plugin.
+func (c *Client) runPlugin(ctx context.Context, input *ssm.StartSessionInput, profile string, streams pluginStreams) error {
+	// Find session-manager-plugin
+	pluginPath, err := lookPlugin()
+	if err != nil {
+		return err
+	}
+
+	return c.launchSession(ctx, input, profile, func(sess *PluginSession) error {
+		return execPlugin(pluginPath, sess, streams)
+	})
+}
+
+// runPluginWithInput starts a shell session and types command into it first.
+func (c *Client) runPluginWithInput(ctx context.Context, instanceID, profile, command string) error {
+	pluginPath, err := lookPlugin()
+	if err != nil {
+		return err
+	}
+
+	return c.launchSession(ctx, c.shellSessionInput(ctx, instanceID), profile, func(sess *PluginSession) error {
+		return execPluginWithInput(pluginPath, sess, command)
+	})
+}
+
+func lookPlugin() (string, error) {
+	pluginPath, err := exec.LookPath(pluginName)
+	if err != nil {
+		return "", fmt.Errorf("session-manager-plugin not found (install via: brew install session-manager-plugin): %w", err)
+	}
+	return pluginPath, nil
+}
+
+// execPlugin runs session-manager-plugin attached to the given streams.
+func execPlugin(pluginPath string, sess *PluginSession, streams pluginStreams) error {
+	cmd := exec.Command(pluginPath, sess.PluginArgs[1:]...)
+	if streams == streamsStdio {
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
 	}
 
 	// Open fresh /dev/tty for the plugin
@@ -172,67 +671,214 @@ func (c *Client) StartSession(ctx context.Context, instanceID, instanceName, pro
 	}
 	defer tty.Close()
 
-	cmd := exec.Command(pluginPath, args...)
 	cmd.Stdin = tty
 	cmd.Stdout = tty
 	cmd.Stderr = tty
-	err = cmd.Run()
+	return cmd.Run()
+}
 
-	// Print instance info on exit
-	if instanceName != "" {
-		fmt.Printf("Disconnected from %s %s\n", instanceName, instanceID)
-	} else {
-		fmt.Printf("Disconnected from %s\n", instanceID)
+const (
+	// commandParamLimit is how large the script of one SendCommand may be:
+	// SSM caps the request's parameters at about 100 KB, and some of that
+	// is kept for the rest of the request.
+	commandParamLimit = 96 * 1024
+	// chunkCommandLimit is how long each command of a chunked upload may
+	// be, well under the 2500 characters of an SSM command parameter.
+	chunkCommandLimit = 2000
+	// maxUploadInput limits how much input is read before compression.
+	maxUploadInput = 10 * 1024 * 1024
+	// exitNoGunzip is the exit code of a gzip upload on a target without gunzip.
+	exitNoGunzip = 86
+)
+
+// maxRegistrationWait bounds how long a sent command may stay unknown to
+// GetCommandInvocation before we give up. Tests shorten it.
+var maxRegistrationWait = 30 * time.Second
+
+// commandPollInterval is the first wait before polling a sent command; it
+// doubles while the command runs. Tests shorten it.
+var commandPollInterval = 500 * time.Millisecond
+
+// Progress receives transfer progress in bytes. total is 0 when the size is
+// not known yet. A nil Progress is a no-op.
+type Progress func(done, total int64)
+
+func (p Progress) report(done, total int64) {
+	if p != nil {
+		p(done, total)
 	}
+}
 
-	return err
+// UploadFile uploads a local file to a remote instance via SSM SendCommand.
+func (c *Client) UploadFile(ctx context.Context, localPath, instanceID, remotePath string, progress Progress) (TransferStats, error) {
+	// Open and validate local file
+	f, err := os.Open(localPath)
+	if err != nil {
+		return TransferStats{}, fmt.Errorf("failed to read local file: %w", err)
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > maxUploadInput {
+		return TransferStats{}, fmt.Errorf("file size %d bytes exceeds maximum allowed size of %d bytes (10MB)", info.Size(), maxUploadInput)
+	}
+
+	return c.UploadReader(ctx, f, localPath, instanceID, remotePath, progress)
 }
 
-const maxUploadSize = 100 * 1024 // 100KB limit due to SSM command size constraints
+// UploadReader uploads everything read from r to a remote instance. The size
+// need not be known up front: input is buffered up to the upload limit.
+// source names the input in progress messages.
+//
+// Content is gzipped before base64 so more fits in one command; if the
+// target has no gunzip, the upload is retried uncompressed. Content that
+// still does not fit is appended to a part file in several commands. With
+// Options.Encrypt the content is encrypted instead and sent as is, since
+// ciphertext does not compress. With Options.StagingBucket it goes through
+// S3 and only a presigned URL is in the command. Unless Options.NoVerify
+// is set, the remote file's SHA-256 is then compared with what was sent.
+// The returned stats time the transfer from the first command sent.
+func (c *Client) UploadReader(ctx context.Context, r io.Reader, source, instanceID, remotePath string, progress Progress) (TransferStats, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxUploadInput+1))
+	if err != nil {
+		return TransferStats{}, fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	if len(data) > maxUploadInput {
+		return TransferStats{}, fmt.Errorf("%s exceeds maximum allowed size of %d bytes (10MB)", source, maxUploadInput)
+	}
 
-// UploadFile uploads a local file to a remote instance via SSM SendCommand.
-func (c *Client) UploadFile(ctx context.Context, localPath, instanceID, remotePath string) error {
-	// Read and validate local file
-	data, err := os.ReadFile(localPath)
+	c.info("Uploading %s (%d bytes) to %s:%s", source, len(data), instanceID, remotePath)
+	total := int64(len(data))
+	progress.report(0, total)
+	start := time.Now()
+
+	payload := data
+	enc := c.opts.Encrypt
+	if enc.Enabled() {
+		if payload, err = enc.encrypt(ctx, data); err != nil {
+			return TransferStats{}, fmt.Errorf("failed to encrypt %s: %w", source, err)
+		}
+		c.out.Debug("Encrypted %s with %s: %d bytes", source, enc.Tool, len(payload))
+	}
+
+	result, err := c.sendUpload(ctx, data, payload, source, instanceID, remotePath, progress, total)
 	if err != nil {
-		return fmt.Errorf("failed to read local file: %w", err)
+		return TransferStats{}, err
+	}
+	stats, err := finishUpload(result, progress, total, start)
+	if err != nil || c.opts.NoVerify {
+		return stats, err
+	}
+	// What lands remotely is the payload, ciphertext when encrypted
+	if err := c.verifyUpload(ctx, instanceID, remotePath, payload); err != nil {
+		return TransferStats{}, err
 	}
+	return stats, nil
+}
 
-	if len(data) > maxUploadSize {
-		return fmt.Errorf("file size %d bytes exceeds maximum allowed size of %d bytes (100KB)", len(data), maxUploadSize)
+// sendUpload writes payload (data, or its ciphertext) to remotePath: via
+// S3 when staging, else gzipped when not encrypted, falling back to plain
+// data on targets without gunzip.
+func (c *Client) sendUpload(ctx context.Context, data, payload []byte, source, instanceID, remotePath string, progress Progress, total int64) (*CommandResult, error) {
+	if c.opts.StagingBucket != "" {
+		return c.uploadViaS3(ctx, payload, instanceID, remotePath)
+	}
+	if c.opts.Encrypt.Enabled() {
+		return c.sendPayload(ctx, instanceID, payload, remotePath, false, progress, total)
 	}
 
-	c.out.Info("Uploading %s (%d bytes) to %s:%s", localPath, len(data), instanceID, remotePath)
+	compressed, err := gzipBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress %s: %w", source, err)
+	}
+	result, err := c.sendPayload(ctx, instanceID, compressed, remotePath, true, progress, total)
+	if err != nil || result.ExitCode != exitNoGunzip {
+		return result, err
+	}
+	c.out.Debug("gunzip not available on %s, retrying uncompressed", instanceID)
+	return c.sendPayload(ctx, instanceID, data, remotePath, false, progress, total)
+}
+
+// uploadLimit returns how many payload bytes fit in one upload command to
+// remotePath: Options.MaxUploadSize if set, else what is left of
+// commandParamLimit after the script around the payload, in base64.
+func (c *Client) uploadLimit(remotePath string, gzipped bool) int {
+	if c.opts.MaxUploadSize > 0 {
+		return c.opts.MaxUploadSize
+	}
+	return maxPayload(commandParamLimit, len(uploadScript(nil, remotePath, gzipped, c.opts.Transfer.decodeCommand())))
+}
+
+// maxPayload returns the largest payload whose base64 encoding fits in a
+// command of limit bytes next to overhead bytes of script.
+func maxPayload(limit, overhead int) int {
+	room := limit - overhead
+	if room < 0 {
+		return 0
+	}
+	// Every 3 payload bytes take 4 in base64
+	return room / 4 * 3
+}
+
+// uploadScript builds the remote script that decodes payload into remotePath
+// using the decode command. Gzipped payloads exit with exitNoGunzip when
+// gunzip is missing.
+func uploadScript(payload []byte, remotePath string, gzipped bool, decode string) string {
+	encoded := base64.StdEncoding.EncodeToString(payload)
+	if !gzipped {
+		return fmt.Sprintf("echo '%s' | %s > %s", encoded, decode, shellQuote(remotePath))
+	}
+	return fmt.Sprintf("command -v gunzip >/dev/null 2>&1 || exit %d; echo '%s' | %s | gunzip > %s",
+		exitNoGunzip, encoded, decode, shellQuote(remotePath))
+}
 
-	// Base64 encode the file content
-	encoded := base64.StdEncoding.EncodeToString(data)
+// finishUpload checks the outcome of an upload command started at start and
+// reports completion.
+func finishUpload(result *CommandResult, progress Progress, total int64, start time.Time) (TransferStats, error) {
+	if result.ExitCode != 0 {
+		return TransferStats{}, fmt.Errorf("upload failed (exit %d): %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	progress.report(total, total)
+	return TransferStats{Bytes: total, Elapsed: time.Since(start)}, nil
+}
 
-	// Build shell script to decode and write file
-	script := fmt.Sprintf("echo '%s' | base64 -d > '%s'", encoded, remotePath)
+// info prints a progress message unless the caller wants JSON on stdout
+// or no messages at all.
+func (c *Client) info(format string, args ...any) {
+	if !c.opts.JSON && !c.opts.Quiet {
+		c.out.Info(format, args...)
+	}
+}
 
-	// Send command
+// runScript runs a shell script on the instance and waits for its result.
+func (c *Client) runScript(ctx context.Context, instanceID, script string) (*CommandResult, error) {
 	c.out.Debug("Sending command to instance...")
 	sendResult, err := c.ssm.SendCommand(ctx, &ssm.SendCommandInput{
 		InstanceIds:  []string{instanceID},
-		DocumentName: aws.String("AWS-RunShellScript"),
+		DocumentName: aws.String(runShellDocument),
 		Parameters: map[string][]string{
 			"commands": {script},
 		},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to send command: %w", err)
+		return nil, fmt.Errorf("failed to send command: %w", err)
 	}
 
 	commandID := *sendResult.Command.CommandId
 	c.out.Debug("Command ID: %s", commandID)
 
-	// Poll for completion
-	if err := c.waitForCommand(ctx, commandID, instanceID); err != nil {
-		return err
-	}
+	return c.waitForCommandResult(ctx, commandID, instanceID, nil)
+}
 
-	c.out.Info("Upload complete")
-	return nil
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // CommandResult holds the result of a remote command execution.
@@ -240,61 +886,214 @@ type CommandResult struct {
 	Stdout   string
 	Stderr   string
 	ExitCode int
+	// Elapsed is the time from sending the command to its final result.
+	Elapsed time.Duration
+}
+
+// ExitError reports that a remote command finished with a non-zero exit
+// code, after its output was printed. The process exits with Code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command exited with code %d", e.Code)
 }
 
-// RunCommand runs a command on an instance via SSM SendCommand and prints output.
+// RunCommand runs a command on an instance via SSM SendCommand and prints
+// output. A non-zero exit code is returned as an *ExitError.
 func (c *Client) RunCommand(ctx context.Context, instanceID, command string) error {
-	c.out.Debug("Running command on %s: %s", instanceID, command)
+	tail := &outputTail{stdout: os.Stdout, stderr: os.Stderr}
+	var stream func(stdout, stderr string)
+	if c.opts.Tail {
+		stream = tail.update
+	}
+
+	result, err := c.runCommand(ctx, instanceID, command, stream)
+	if err != nil {
+		return err
+	}
+
+	// Print whatever has not been streamed yet (everything, without --tail)
+	stdout, stderr := result.Stdout, result.Stderr
+	if c.opts.TailLines > 0 {
+		var dropped, droppedErr int
+		stdout, dropped = LastLines(stdout, c.opts.TailLines)
+		stderr, droppedErr = LastLines(stderr, c.opts.TailLines)
+		if dropped+droppedErr > 0 {
+			c.info("Showing the last %d lines (%d earlier lines not shown)", c.opts.TailLines, dropped+droppedErr)
+		}
+	}
+	tail.update(stdout, stderr)
+	if !c.opts.Quiet {
+		c.out.Notice("Command finished in %s (exit %d)", result.Elapsed.Round(time.Millisecond), result.ExitCode)
+	}
+
+	if result.ExitCode != 0 {
+		return &ExitError{Code: result.ExitCode}
+	}
+	return nil
+}
+
+// Run runs a command on an instance and returns its result without printing
+// anything. A non-zero exit code is reported in the result, not as an error.
+func (c *Client) Run(ctx context.Context, instanceID, command string) (*CommandResult, error) {
+	return c.runCommand(ctx, instanceID, command, nil)
+}
+
+// Dispatch sends command like Run but returns its command ID right away,
+// without waiting; CommandStatus collects the result later.
+func (c *Client) Dispatch(ctx context.Context, instanceID, command string) (string, error) {
+	return c.sendCommand(ctx, []string{instanceID}, command)
+}
+
+// sendCommand sends command with the configured wrapping to up to
+// maxCommandTargets instances and returns its ID. The document check costs
+// two lookups per instance, so it only runs for a single target.
+func (c *Client) sendCommand(ctx context.Context, instanceIDs []string, command string) (string, error) {
+	if c.opts.Command.Sudo {
+		for _, instanceID := range instanceIDs {
+			platform, err := c.platformType(ctx, instanceID)
+			if err != nil {
+				return "", err
+			}
+			if platform == ssmtypes.PlatformTypeWindows {
+				return "", fmt.Errorf("--sudo is not supported on Windows instance %s", instanceID)
+			}
+		}
+	}
+	command, err := BuildCommand(command, c.opts.Command)
+	if err != nil {
+		return "", err
+	}
+
+	c.out.Debug("Running command on %s: %s", strings.Join(instanceIDs, ", "), command)
+	if len(instanceIDs) == 1 {
+		c.warnDocument(ctx, instanceIDs[0], runShellDocument)
+	}
 
 	sendResult, err := c.ssm.SendCommand(ctx, &ssm.SendCommandInput{
-		InstanceIds:  []string{instanceID},
-		DocumentName: aws.String("AWS-RunShellScript"),
+		InstanceIds:  instanceIDs,
+		DocumentName: aws.String(runShellDocument),
 		Parameters: map[string][]string{
 			"commands": {command},
 		},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to send command: %w", err)
+		return "", fmt.Errorf("failed to send command: %w", err)
 	}
 
 	commandID := *sendResult.Command.CommandId
 	c.out.Debug("Command ID: %s", commandID)
+	return commandID, nil
+}
 
-	result, err := c.waitForCommandResult(ctx, commandID, instanceID)
+// runCommand sends command with the configured wrapping and waits for it,
+// calling stream with the partial output while it runs.
+func (c *Client) runCommand(ctx context.Context, instanceID, command string, stream func(stdout, stderr string)) (*CommandResult, error) {
+	start := time.Now()
+	commandID, err := c.sendCommand(ctx, []string{instanceID}, command)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if result.Stdout != "" {
-		fmt.Print(result.Stdout)
+	// With KillOnIdle, polling stops and the command is cancelled once its
+	// output has not changed for that long.
+	progress := stream
+	waitCtx, stopWaiting := context.WithCancel(ctx)
+	defer stopWaiting()
+	var idle *idleWatch
+	if c.opts.KillOnIdle > 0 {
+		idle = newIdleWatch(c.opts.KillOnIdle, time.Now())
+		progress = func(stdout, stderr string) {
+			if stream != nil {
+				stream(stdout, stderr)
+			}
+			if idle.stalled(stdout+stderr, time.Now()) {
+				stopWaiting()
+			}
+		}
 	}
-	if result.Stderr != "" {
-		fmt.Fprint(os.Stderr, result.Stderr)
+
+	result, err := c.waitForCommandResult(waitCtx, commandID, instanceID, progress)
+	if idle != nil && idle.fired {
+		if _, cerr := c.ssm.CancelCommand(ctx, &ssm.CancelCommandInput{
+			CommandId:   aws.String(commandID),
+			InstanceIds: []string{instanceID},
+		}); cerr != nil {
+			return nil, fmt.Errorf("command idle for %s, and cancelling it failed: %w", c.opts.KillOnIdle, cerr)
+		}
+		return nil, fmt.Errorf("command cancelled after %s without new output", c.opts.KillOnIdle)
 	}
+	if err != nil {
+		return nil, err
+	}
+	result.Elapsed = time.Since(start)
+	return result, nil
+}
 
-	if result.ExitCode != 0 {
-		os.Exit(result.ExitCode)
+// PreviewCommand returns the exact script RunCommand would send for command,
+// after sudo, workdir and env wrapping.
+func (c *Client) PreviewCommand(command string) (string, error) {
+	return BuildCommand(command, c.opts.Command)
+}
+
+// platformType returns the SSM-reported platform of an instance.
+func (c *Client) platformType(ctx context.Context, instanceID string) (ssmtypes.PlatformType, error) {
+	info, err := c.instanceInformation(ctx, instanceID)
+	if err != nil {
+		return "", err
 	}
-	return nil
+	return info.PlatformType, nil
+}
+
+// ErrNotManaged is returned for an instance ID SSM does not know in the
+// client's region.
+var ErrNotManaged = errors.New("not managed by SSM")
+
+// instanceInformation returns the SSM details of one instance.
+func (c *Client) instanceInformation(ctx context.Context, instanceID string) (ssmtypes.InstanceInformation, error) {
+	result, err := c.ssm.DescribeInstanceInformation(ctx, &ssm.DescribeInstanceInformationInput{
+		Filters: []ssmtypes.InstanceInformationStringFilter{
+			{Key: aws.String("InstanceIds"), Values: []string{instanceID}},
+		},
+	})
+	if err != nil {
+		return ssmtypes.InstanceInformation{}, fmt.Errorf("failed to describe instance %s: %w", instanceID, err)
+	}
+	if len(result.InstanceInformationList) == 0 {
+		return ssmtypes.InstanceInformation{}, fmt.Errorf("instance %s is %w", instanceID, ErrNotManaged)
+	}
+	return result.InstanceInformationList[0], nil
 }
 
 // DownloadFile downloads a remote file from an instance via SSM SendCommand.
-func (c *Client) DownloadFile(ctx context.Context, instanceID, remotePath, localPath string) error {
-	c.out.Info("Downloading %s:%s to %s", instanceID, remotePath, localPath)
+// If localPath is an existing directory, the file keeps its remote base name
+// inside it. It returns the absolute path of the written file and how long
+// the transfer took.
+func (c *Client) DownloadFile(ctx context.Context, instanceID, remotePath, localPath string, progress Progress) (string, TransferStats, error) {
+	localPath, err := downloadTarget(remotePath, localPath)
+	if err != nil {
+		return "", TransferStats{}, err
+	}
+
+	c.info("Downloading %s:%s to %s", instanceID, remotePath, localPath)
+	progress.report(0, 0)
+	start := time.Now()
 
 	// Read and base64 encode the remote file
-	script := fmt.Sprintf("base64 '%s'", remotePath)
+	script := fmt.Sprintf("%s < %s", c.opts.Transfer.encodeCommand(), shellQuote(remotePath))
 
 	c.out.Debug("Sending command to instance...")
 	sendResult, err := c.ssm.SendCommand(ctx, &ssm.SendCommandInput{
 		InstanceIds:  []string{instanceID},
-		DocumentName: aws.String("AWS-RunShellScript"),
+		DocumentName: aws.String(runShellDocument),
 		Parameters: map[string][]string{
 			"commands": {script},
 		},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to send command: %w", err)
+		return "", TransferStats{}, fmt.Errorf("failed to send command: %w", err)
 	}
 
 	commandID := *sendResult.Command.CommandId
@@ -303,40 +1102,59 @@ func (c *Client) DownloadFile(ctx context.Context, instanceID, remotePath, local
 	// Poll for completion and get output
 	output, err := c.waitForCommandOutput(ctx, commandID, instanceID)
 	if err != nil {
-		return err
+		return "", TransferStats{}, err
 	}
 
 	// Decode base64 output
 	data, err := base64.StdEncoding.DecodeString(output)
 	if err != nil {
-		return fmt.Errorf("failed to decode file content: %w", err)
+		return "", TransferStats{}, fmt.Errorf("failed to decode file content: %w", err)
 	}
 
 	// Write to local file
 	if err := os.WriteFile(localPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write local file: %w", err)
+		return "", TransferStats{}, fmt.Errorf("failed to write local file: %w", err)
 	}
 
-	c.out.Info("Download complete (%d bytes)", len(data))
-	return nil
+	progress.report(int64(len(data)), int64(len(data)))
+	return localPath, TransferStats{Bytes: int64(len(data)), Elapsed: time.Since(start)}, nil
 }
 
-func (c *Client) waitForCommand(ctx context.Context, commandID, instanceID string) error {
-	_, err := c.waitForCommandResult(ctx, commandID, instanceID)
-	return err
+// downloadTarget resolves where a download is written, as an absolute path.
+func downloadTarget(remotePath, localPath string) (string, error) {
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		localPath = filepath.Join(localPath, path.Base(remotePath))
+	}
+	abs, err := filepath.Abs(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve local path: %w", err)
+	}
+	return abs, nil
+}
+
+// CdSnippet returns a shell command that changes to the directory holding path.
+func CdSnippet(path string) string {
+	return "cd -- " + shellQuote(filepath.Dir(path))
 }
 
 func (c *Client) waitForCommandOutput(ctx context.Context, commandID, instanceID string) (string, error) {
-	result, err := c.waitForCommandResult(ctx, commandID, instanceID)
+	result, err := c.waitForCommandResult(ctx, commandID, instanceID, nil)
 	if err != nil {
 		return "", err
 	}
 	return result.Stdout, nil
 }
 
-func (c *Client) waitForCommandResult(ctx context.Context, commandID, instanceID string) (*CommandResult, error) {
-	pollInterval := 500 * time.Millisecond
+// waitForCommandResult polls until the command finishes. If progress is set,
+// it is called with the partial output on every in-progress poll, and polling
+// backs off to tailInterval rather than the usual maximum.
+func (c *Client) waitForCommandResult(ctx context.Context, commandID, instanceID string, progress func(stdout, stderr string)) (*CommandResult, error) {
+	pollInterval := commandPollInterval
 	maxInterval := 5 * time.Second
+	if progress != nil {
+		maxInterval = tailInterval
+	}
+	start := time.Now()
 
 	for {
 		select {
@@ -345,12 +1163,22 @@ func (c *Client) waitForCommandResult(ctx context.Context, commandID, instanceID
 		case <-time.After(pollInterval):
 		}
 
-		result, err := c.ssm.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
-			CommandId:  aws.String(commandID),
-			InstanceId: aws.String(instanceID),
+		result, err := retryExpired(c, func() (*ssm.GetCommandInvocationOutput, error) {
+			return c.ssm.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
+				CommandId:  aws.String(commandID),
+				InstanceId: aws.String(instanceID),
+			})
 		})
 		if err != nil {
-			// InvocationDoesNotExist means command hasn't registered yet
+			// InvocationDoesNotExist means command hasn't registered yet;
+			// anything else (e.g. AccessDenied) will not go away by waiting.
+			var notYet *ssmtypes.InvocationDoesNotExist
+			if !errors.As(err, &notYet) {
+				return nil, fmt.Errorf("failed to get command result: %w", err)
+			}
+			if time.Since(start) > maxRegistrationWait {
+				return nil, fmt.Errorf("command %s did not register within %s: %w", commandID, maxRegistrationWait, err)
+			}
 			c.out.Debug("Waiting for command to register...")
 			pollInterval = min(pollInterval*2, maxInterval)
 			continue
@@ -376,6 +1204,9 @@ func (c *Client) waitForCommandResult(ctx context.Context, commandID, instanceID
 		case ssmtypes.CommandInvocationStatusInProgress,
 			ssmtypes.CommandInvocationStatusPending:
 			c.out.Debug("Command status: %s", result.Status)
+			if progress != nil {
+				progress(aws.ToString(result.StandardOutputContent), aws.ToString(result.StandardErrorContent))
+			}
 			pollInterval = min(pollInterval*2, maxInterval)
 		default:
 			c.out.Debug("Unknown status: %s", result.Status)
@@ -388,79 +1219,77 @@ func (c *Client) getSSMInstances(ctx context.Context) ([]Instance, error) {
 	c.out.Debug("Fetching SSM-managed instances...")
 
 	// Get SSM managed instances
-	ssmResult, err := c.ssm.DescribeInstanceInformation(ctx, &ssm.DescribeInstanceInformationInput{})
+	infos, err := c.listInstanceInformation(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe SSM instances: %w", err)
+		return nil, err
 	}
 
-	if len(ssmResult.InstanceInformationList) == 0 {
+	if len(infos) == 0 {
 		return nil, nil
 	}
 
+	if c.opts.NoEC2 {
+		return ssmOnlyInstances(infos), nil
+	}
+	return c.withEC2Details(ctx, infos), nil
+}
+
+// withEC2Details combines SSM instance information with names, states, IPs
+// and tags from EC2. Instances EC2 could not describe keep SSM details only.
+func (c *Client) withEC2Details(ctx context.Context, infos []ssmtypes.InstanceInformation) []Instance {
 	// Collect SSM instance IDs
 	var instanceIDs []string
-	for _, info := range ssmResult.InstanceInformationList {
+	for _, info := range infos {
 		if info.InstanceId != nil {
 			instanceIDs = append(instanceIDs, *info.InstanceId)
 		}
 	}
 
-	// Get EC2 instance details (only running instances)
-	ec2Result, err := c.ec2.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-		InstanceIds: instanceIDs,
-		Filters: []ec2types.Filter{
-			{
-				Name:   aws.String("instance-state-name"),
-				Values: []string{"running"},
-			},
-		},
-	})
-	if err != nil {
-		c.out.Debug("Failed to get EC2 details: %v", err)
+	// Get EC2 instance details (only running instances), in batches
+	var reservations []ec2types.Reservation
+	for start := 0; start < len(instanceIDs); start += describeBatchSize {
+		batch := instanceIDs[start:min(start+describeBatchSize, len(instanceIDs))]
+		ec2Result, err := retryExpired(c, func() (*ec2.DescribeInstancesOutput, error) {
+			return c.ec2.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+				InstanceIds: batch,
+				Filters: []ec2types.Filter{
+					{
+						Name:   aws.String("instance-state-name"),
+						Values: []string{"running"},
+					},
+				},
+			})
+		})
+		if err != nil {
+			c.out.Debug("Failed to get EC2 details: %v", err)
+			continue
+		}
+		reservations = append(reservations, ec2Result.Reservations...)
 	}
 
 	// Build instance list with EC2 details
-	instances := make([]Instance, 0, len(ssmResult.InstanceInformationList))
+	instances := make([]Instance, 0, len(infos))
 	ec2Details := make(map[string]*Instance)
 
-	if ec2Result != nil {
-		for _, res := range ec2Result.Reservations {
-			for _, inst := range res.Instances {
-				if inst.InstanceId == nil {
-					continue
-				}
-				name := ""
-				for _, tag := range inst.Tags {
-					if tag.Key != nil && *tag.Key == "Name" && tag.Value != nil {
-						name = *tag.Value
-						break
-					}
-				}
-				privateIP := ""
-				if inst.PrivateIpAddress != nil {
-					privateIP = *inst.PrivateIpAddress
-				}
-				state := ""
-				if inst.State != nil && inst.State.Name != "" {
-					state = string(inst.State.Name)
-				}
-				ec2Details[*inst.InstanceId] = &Instance{
-					ID:        *inst.InstanceId,
-					Name:      name,
-					State:     state,
-					PrivateIP: privateIP,
-				}
+	for _, res := range reservations {
+		for _, inst := range res.Instances {
+			if inst.InstanceId == nil {
+				continue
 			}
+			details := fromEC2(inst)
+			ec2Details[details.ID] = &details
 		}
 	}
 
-	for _, info := range ssmResult.InstanceInformationList {
+	for _, info := range infos {
 		if info.InstanceId == nil {
 			continue
 		}
 		inst := Instance{
-			ID:        *info.InstanceId,
-			SSMStatus: string(info.PingStatus),
+			ID:           *info.InstanceId,
+			SSMStatus:    string(info.PingStatus),
+			AgentVersion: aws.ToString(info.AgentVersion),
+			LastPing:     aws.ToTime(info.LastPingDateTime),
 		}
 		if info.PlatformType != "" {
 			inst.PlatformType = string(info.PlatformType)
@@ -469,9 +1298,138 @@ func (c *Client) getSSMInstances(ctx context.Context) ([]Instance, error) {
 			inst.Name = details.Name
 			inst.State = details.State
 			inst.PrivateIP = details.PrivateIP
+			inst.AZ = details.AZ
+			inst.Tags = details.Tags
 		}
 		instances = append(instances, inst)
 	}
 
-	return instances, nil
+	return instances
+}
+
+// fromEC2 converts an EC2 instance to its name, state, IP, AZ and tags.
+func fromEC2(inst ec2types.Instance) Instance {
+	tags := make(map[string]string, len(inst.Tags))
+	for _, tag := range inst.Tags {
+		if tag.Key != nil && tag.Value != nil {
+			tags[*tag.Key] = *tag.Value
+		}
+	}
+	details := Instance{
+		ID:        aws.ToString(inst.InstanceId),
+		Name:      tags["Name"],
+		PrivateIP: aws.ToString(inst.PrivateIpAddress),
+		Tags:      tags,
+	}
+	if inst.State != nil {
+		details.State = string(inst.State.Name)
+	}
+	if inst.Placement != nil {
+		details.AZ = aws.ToString(inst.Placement.AvailabilityZone)
+	}
+	return details
+}
+
+// EC2Instance describes one instance with a single EC2 DescribeInstances
+// call: its name, state, IP, AZ and tags, whatever its state and the
+// discovery filters.
+func (c *Client) EC2Instance(ctx context.Context, instanceID string) (selector.Instance, error) {
+	result, err := retryExpired(c, func() (*ec2.DescribeInstancesOutput, error) {
+		return c.ec2.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{instanceID}})
+	})
+	if err != nil {
+		return selector.Instance{}, fmt.Errorf("failed to describe instance %s: %w", instanceID, err)
+	}
+	for _, res := range result.Reservations {
+		for _, inst := range res.Instances {
+			if aws.ToString(inst.InstanceId) != instanceID {
+				continue
+			}
+			details := fromEC2(inst)
+			return selector.Instance{
+				ID:        details.ID,
+				Name:      details.Name,
+				State:     details.State,
+				PrivateIP: details.PrivateIP,
+				AZ:        details.AZ,
+				Tags:      details.Tags,
+			}, nil
+		}
+	}
+	return selector.Instance{}, fmt.Errorf("instance %s not found in EC2", instanceID)
+}
+
+// ssmOnlyInstances builds instances from SSM inventory alone, without EC2
+// names, IPs or tags. Online agents are reported as running.
+func ssmOnlyInstances(infos []ssmtypes.InstanceInformation) []Instance {
+	instances := make([]Instance, 0, len(infos))
+	for _, info := range infos {
+		if info.InstanceId == nil {
+			continue
+		}
+		inst := Instance{
+			ID:           *info.InstanceId,
+			SSMStatus:    string(info.PingStatus),
+			PlatformType: string(info.PlatformType),
+			AgentVersion: aws.ToString(info.AgentVersion),
+			LastPing:     aws.ToTime(info.LastPingDateTime),
+		}
+		if info.PingStatus == ssmtypes.PingStatusOnline {
+			inst.State = "running"
+		}
+		instances = append(instances, inst)
+	}
+	return instances
+}
+
+// describeBatchSize is how many instance IDs go into one DescribeInstances call.
+const describeBatchSize = 200
+
+// DefaultMaxInstances caps discovery when no other limit is configured.
+const DefaultMaxInstances = 5000
+
+// listInstanceInformation collects SSM-managed instances, up to MaxInstances.
+func (c *Client) listInstanceInformation(ctx context.Context) ([]ssmtypes.InstanceInformation, error) {
+	var infos []ssmtypes.InstanceInformation
+	err := c.eachInstanceInformationPage(ctx, func(page []ssmtypes.InstanceInformation) error {
+		infos = append(infos, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// eachInstanceInformationPage calls fn with every page of SSM instance
+// information, stopping once MaxInstances have been seen and warning that
+// the result is partial.
+func (c *Client) eachInstanceInformationPage(ctx context.Context, fn func([]ssmtypes.InstanceInformation) error) error {
+	limit := c.opts.MaxInstances
+	if limit <= 0 {
+		limit = DefaultMaxInstances
+	}
+
+	seen := 0
+	paginator := ssm.NewDescribeInstanceInformationPaginator(c.ssm, &ssm.DescribeInstanceInformationInput{})
+	for paginator.HasMorePages() {
+		page, err := retryExpired(c, func() (*ssm.DescribeInstanceInformationOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe SSM instances: %w", err)
+		}
+		infos := page.InstanceInformationList
+		if seen+len(infos) >= limit {
+			if seen+len(infos) > limit || paginator.HasMorePages() {
+				c.out.Warning("Stopped discovery at %d instances (--max-instances); results are partial", limit)
+			}
+			return fn(infos[:limit-seen])
+		}
+		seen += len(infos)
+		if err := fn(infos); err != nil {
+			return err
+		}
+	}
+	return nil
 }
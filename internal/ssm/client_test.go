@@ -0,0 +1,797 @@
+package ssm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+	"github.com/e/aws-ssm-connect/internal/paths"
+	"github.com/e/aws-ssm-connect/internal/selector"
+)
+
+// testConfig returns an AWS config whose calls go to a local server.
+func testConfig(t *testing.T, handler http.Handler) aws.Config {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return aws.Config{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	}
+}
+
+// captureOutput runs fn with os.Stdout and os.Stderr redirected and returns
+// what was written to each.
+func captureOutput(t *testing.T, fn func()) (stdout, stderr string) {
+	t.Helper()
+	redirect := func(f **os.File) func() string {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		orig := *f
+		*f = w
+		done := make(chan string)
+		go func() {
+			data, _ := io.ReadAll(r)
+			done <- string(data)
+		}()
+		return func() string {
+			w.Close()
+			*f = orig
+			return <-done
+		}
+	}
+	stopOut, stopErr := redirect(&os.Stdout), redirect(&os.Stderr)
+	fn()
+	return stopOut(), stopErr()
+}
+
+func TestFromEC2(t *testing.T) {
+	got := fromEC2(ec2types.Instance{
+		InstanceId:       aws.String("i-0abc"),
+		PrivateIpAddress: aws.String("10.0.0.5"),
+		State:            &ec2types.InstanceState{Name: ec2types.InstanceStateNameStopped},
+		Placement:        &ec2types.Placement{AvailabilityZone: aws.String("eu-west-1b")},
+		Tags: []ec2types.Tag{
+			{Key: aws.String("Name"), Value: aws.String("db-1")},
+			{Key: aws.String("Protected"), Value: aws.String("true")},
+			{Key: aws.String("broken")},
+		},
+	})
+	want := Instance{
+		ID:        "i-0abc",
+		Name:      "db-1",
+		State:     "stopped",
+		PrivateIP: "10.0.0.5",
+		AZ:        "eu-west-1b",
+		Tags:      map[string]string{"Name": "db-1", "Protected": "true"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fromEC2() = %+v, want %+v", got, want)
+	}
+
+	if got := fromEC2(ec2types.Instance{InstanceId: aws.String("i-1")}); got.ID != "i-1" || got.State != "" || got.AZ != "" || len(got.Tags) != 0 {
+		t.Errorf("fromEC2() of a bare instance = %+v", got)
+	}
+}
+
+func TestRunCommandReturnsExitError(t *testing.T) {
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.SendCommand":
+			io.WriteString(w, `{"Command":{"CommandId":"cmd-1"}}`)
+		case "AmazonSSM.GetCommandInvocation":
+			io.WriteString(w, `{"Status":"Failed","ResponseCode":3,"StandardOutputContent":"partial\n"}`)
+		case "AmazonSSM.DescribeDocument":
+			io.WriteString(w, `{"Document":{"Name":"AWS-RunShellScript","PlatformTypes":["Linux"]}}`)
+		default:
+			io.WriteString(w, `{}`)
+		}
+	}))
+	c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{Quiet: true})
+
+	var err error
+	stdout, _ := captureOutput(t, func() {
+		err = c.RunCommand(context.Background(), "i-1", "false")
+	})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("RunCommand() error = %v, want exit code 3", err)
+	}
+	if stdout != "partial\n" {
+		t.Errorf("stdout = %q, want the command output", stdout)
+	}
+}
+
+func TestFindInstanceSelectFirstUsesStderr(t *testing.T) {
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		io.WriteString(w, `{"InstanceInformationList":[
+			{"InstanceId":"i-web1","ComputerName":"web-1","PingStatus":"Online"},
+			{"InstanceId":"i-web2","ComputerName":"web-2","PingStatus":"Online"}]}`)
+	}))
+	c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{NoEC2: true, SelectFirst: true})
+
+	var inst selector.Instance
+	var err error
+	stdout, stderr := captureOutput(t, func() {
+		inst, err = c.FindInstance(context.Background(), "web")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.ID == "" || !strings.Contains(stderr, "Auto-selected "+inst.ID) {
+		t.Errorf("picked %q, stderr %q", inst.ID, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing", stdout)
+	}
+}
+
+// fleetClient returns a client that discovers one online instance per
+// ID and name pair, without EC2 details, and keeps its history in a
+// temporary directory.
+func fleetClient(t *testing.T, opts Options, idName ...string) *Client {
+	t.Helper()
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	var list []map[string]string
+	for i := 0; i+1 < len(idName); i += 2 {
+		list = append(list, map[string]string{"InstanceId": idName[i], "ComputerName": idName[i+1], "PingStatus": "Online", "PlatformType": "Linux"})
+	}
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if r.Header.Get("X-Amz-Target") == "AmazonSSM.DescribeInstanceInformation" {
+			json.NewEncoder(w).Encode(map[string]any{"InstanceInformationList": list})
+			return
+		}
+		io.WriteString(w, `{}`)
+	}))
+	opts.NoEC2 = true
+	return NewClient(cfg, output.New(false, output.UnicodeGlyphs), opts)
+}
+
+func TestFindInstanceUnionsNames(t *testing.T) {
+	c := fleetClient(t, Options{Strict: true}, "i-web1", "web-1", "i-api1", "api-1", "i-db1", "db-1")
+	tests := []struct {
+		names   []string
+		want    string
+		wantErr string
+	}{
+		{[]string{"web"}, "i-web1", ""},
+		// Both names match the same instance, which is listed once
+		{[]string{"web", "i-web1"}, "i-web1", ""},
+		{[]string{"web", "api"}, "", `["web" "api"] match 2 instances`},
+		{[]string{"web", "nothing"}, "i-web1", ""},
+		{[]string{"nothing", "none"}, "", `no instances found matching any of ["nothing" "none"]`},
+	}
+	for _, tt := range tests {
+		inst, err := c.FindInstance(context.Background(), tt.names...)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("FindInstance(%q) error = %v, want %q", tt.names, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || inst.ID != tt.want {
+			t.Errorf("FindInstance(%q) = %q, %v; want %q", tt.names, inst.ID, err, tt.want)
+		}
+	}
+}
+
+func TestCreateSessionRoundTrips(t *testing.T) {
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if r.Header.Get("X-Amz-Target") == "AmazonSSM.StartSession" {
+			io.WriteString(w, `{"SessionId":"s-1","StreamUrl":"wss://example/s-1","TokenValue":"tok"}`)
+			return
+		}
+		io.WriteString(w, `{}`)
+	}))
+	c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{})
+
+	sess, err := c.CreateSession(context.Background(), "i-1", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got PluginSession
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, sess) {
+		t.Fatalf("round trip = %+v, want %+v", got, *sess)
+	}
+
+	// The plugin argv carries the same session and the target
+	want := []string{pluginName, "", "us-east-1", "StartSession", "dev", `{"Target":"i-1"}`}
+	args := append([]string{}, got.PluginArgs...)
+	var plugin map[string]string
+	if len(args) != len(want) || json.Unmarshal([]byte(args[1]), &plugin) != nil {
+		t.Fatalf("PluginArgs = %q", args)
+	}
+	if plugin["SessionId"] != "s-1" || plugin["StreamUrl"] != "wss://example/s-1" || plugin["TokenValue"] != "tok" {
+		t.Errorf("plugin session JSON = %v", plugin)
+	}
+	args[1] = ""
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("PluginArgs = %q, want %q", args, want)
+	}
+}
+
+// shellClient returns a client whose commands run in a local sh, as if
+// this machine were the instance, so uploads can be checked end to end.
+func shellClient(t *testing.T, opts Options) *Client {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	var last map[string]any
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.SendCommand":
+			var body struct{ Parameters map[string][]string }
+			json.NewDecoder(r.Body).Decode(&body)
+			var stdout, stderr strings.Builder
+			cmd := exec.Command("sh", "-c", strings.Join(body.Parameters["commands"], "\n"))
+			cmd.Stdout, cmd.Stderr = &stdout, &stderr
+			err := cmd.Run()
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			}
+			status := "Success"
+			if code != 0 {
+				status = "Failed"
+			}
+			last = map[string]any{"Status": status, "ResponseCode": code,
+				"StandardOutputContent": stdout.String(), "StandardErrorContent": stderr.String()}
+			io.WriteString(w, `{"Command":{"CommandId":"cmd-1"}}`)
+		case "AmazonSSM.GetCommandInvocation":
+			json.NewEncoder(w).Encode(last)
+		case "AmazonSSM.DescribeDocument":
+			io.WriteString(w, `{"Document":{"Name":"AWS-RunShellScript","PlatformTypes":["Linux"]}}`)
+		default:
+			io.WriteString(w, `{}`)
+		}
+	}))
+	opts.Quiet = true
+	return NewClient(cfg, output.New(false, output.UnicodeGlyphs), opts)
+}
+
+func TestUploadReaderFromPipe(t *testing.T) {
+	for _, tool := range []string{"base64", "gunzip"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+	tests := []struct {
+		name    string
+		content []byte
+		opts    Options
+		wantErr string
+	}{
+		{"small", []byte("key = value\n"), Options{NoVerify: true}, ""},
+		{"empty", []byte{}, Options{NoVerify: true}, ""},
+		// Forced into several chunks, as stdin of any size may be
+		{"chunked", noise(1000), Options{NoVerify: true, MaxUploadSize: 300}, ""},
+		{"too large", make([]byte, maxUploadInput+1), Options{}, "stdin exceeds maximum allowed size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := shellClient(t, tt.opts)
+			dest := filepath.Join(t.TempDir(), "config")
+
+			// An unbuffered pipe has no size to stat, like stdin
+			r, w := io.Pipe()
+			go func() {
+				w.Write(tt.content)
+				w.Close()
+			}()
+			var err error
+			captureOutput(t, func() {
+				_, err = c.UploadReader(context.Background(), r, "stdin", "i-1", dest, nil)
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("UploadReader() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.content) {
+				t.Errorf("uploaded %d bytes, want %d", len(got), len(tt.content))
+			}
+		})
+	}
+}
+
+// noise returns n bytes that do not compress, so gzip cannot shrink an
+// upload below its chunk size.
+func noise(n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(b)
+	return b
+}
+
+func TestWaitForCommandResultRegistration(t *testing.T) {
+	defer func(d time.Duration) { maxRegistrationWait = d }(maxRegistrationWait)
+
+	notRegistered := `{"__type":"InvocationDoesNotExist"}`
+	denied := `{"__type":"AccessDeniedException","message":"not allowed"}`
+	done := `{"Status":"Success","StandardOutputContent":"ok"}`
+	tests := []struct {
+		name string
+		// responses answer successive polls; the last one repeats
+		responses []string
+		wait      time.Duration
+		want      string
+		wantErr   string
+		wantPolls int
+	}{
+		{"registers after a poll", []string{notRegistered, done}, time.Minute, "ok", "", 2},
+		{"hard error fails fast", []string{denied, done}, time.Minute, "", "AccessDenied", 1},
+		{"never registers", []string{notRegistered}, 0, "", "did not register within", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxRegistrationWait = tt.wait
+			polls := 0
+			cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				body := tt.responses[min(polls, len(tt.responses)-1)]
+				polls++
+				if body != done {
+					w.WriteHeader(http.StatusBadRequest)
+				}
+				io.WriteString(w, body)
+			}))
+			c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{})
+
+			result, err := c.waitForCommandResult(context.Background(), "cmd-1", "i-1", nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || result.Stdout != tt.want {
+				t.Fatalf("result = %+v, %v; want %q", result, err, tt.want)
+			}
+			if polls != tt.wantPolls {
+				t.Errorf("polled %d times, want %d", polls, tt.wantPolls)
+			}
+		})
+	}
+}
+
+func TestDownloadFileReturnsPath(t *testing.T) {
+	remoteDir, localDir := t.TempDir(), t.TempDir()
+	remote := filepath.Join(remoteDir, "app.log")
+	if err := os.WriteFile(remote, []byte("log line\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(localDir)
+
+	tests := []struct {
+		name  string
+		local string
+		want  string
+	}{
+		{"file path", filepath.Join(localDir, "copy.log"), filepath.Join(localDir, "copy.log")},
+		{"relative file path", "rel.log", filepath.Join(localDir, "rel.log")},
+		{"existing directory keeps the remote name", localDir, filepath.Join(localDir, "app.log")},
+		{"current directory", ".", filepath.Join(localDir, "app.log")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := shellClient(t, Options{})
+			var got string
+			var err error
+			captureOutput(t, func() {
+				got, _, err = c.DownloadFile(context.Background(), "i-1", remote, tt.local, nil)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Temp dirs may sit behind symlinks, e.g. /var on macOS
+			want, _ := filepath.EvalSymlinks(tt.want)
+			if resolved, _ := filepath.EvalSymlinks(got); resolved != want || !filepath.IsAbs(got) {
+				t.Errorf("DownloadFile() = %s, want %s", got, tt.want)
+			}
+			if data, err := os.ReadFile(got); err != nil || string(data) != "log line\n" {
+				t.Errorf("downloaded %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestCdSnippet(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/tmp/dl/app.log", "cd -- '/tmp/dl'"},
+		{"/home/me/it's here/f", `cd -- '/home/me/it'\''s here'`},
+	}
+	for _, tt := range tests {
+		if got := CdSnippet(tt.path); got != tt.want {
+			t.Errorf("CdSnippet(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestUploadScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		gzipped bool
+		decode  string
+		want    string
+	}{
+		{"plain", false, "base64 -d", `echo 'aGk=' | base64 -d > '/tmp/my file'`},
+		{"gzipped", true, "base64 -d", `command -v gunzip >/dev/null 2>&1 || exit 86; echo 'aGk=' | base64 -d | gunzip > '/tmp/my file'`},
+		{"custom decoder", true, "openssl base64 -d -A", `command -v gunzip >/dev/null 2>&1 || exit 86; echo 'aGk=' | openssl base64 -d -A | gunzip > '/tmp/my file'`},
+	}
+	for _, tt := range tests {
+		if got := uploadScript([]byte("hi"), "/tmp/my file", tt.gzipped, tt.decode); got != tt.want {
+			t.Errorf("%s: uploadScript() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUploadScriptGzipRoundTrip(t *testing.T) {
+	for _, tool := range []string{"sh", "base64", "gunzip"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+	content := bytes.Repeat([]byte("compressible line\n"), 500)
+	compressed, err := gzipBytes(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(content) {
+		t.Fatalf("gzip grew %d bytes to %d", len(content), len(compressed))
+	}
+
+	dest := filepath.Join(t.TempDir(), "out")
+	runShell(t, uploadScript(compressed, dest, true, "base64 -d"))
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, content) {
+		t.Errorf("decoded %d bytes, want %d", len(got), len(content))
+	}
+
+	// Without gunzip on the PATH the script reports it rather than writing
+	bin := t.TempDir()
+	base64Path, _ := exec.LookPath("base64")
+	if err := os.Symlink(base64Path, filepath.Join(bin, "base64")); err != nil {
+		t.Fatal(err)
+	}
+	shPath, _ := exec.LookPath("sh")
+	cmd := exec.Command(shPath, "-c", uploadScript(compressed, dest+".2", true, "base64 -d"))
+	cmd.Env = []string{"PATH=" + bin}
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); !errors.As(err, &exitErr) || exitErr.ExitCode() != exitNoGunzip {
+		t.Errorf("without gunzip: %v, want exit %d", err, exitNoGunzip)
+	}
+}
+
+func TestUploadFallsBackWithoutGunzip(t *testing.T) {
+	var scripts []string
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.SendCommand":
+			var body struct{ Parameters map[string][]string }
+			json.NewDecoder(r.Body).Decode(&body)
+			scripts = append(scripts, body.Parameters["commands"][0])
+			io.WriteString(w, `{"Command":{"CommandId":"cmd-1"}}`)
+		case "AmazonSSM.GetCommandInvocation":
+			// The target has no gunzip
+			code := 0
+			if strings.Contains(scripts[len(scripts)-1], "gunzip") {
+				code = exitNoGunzip
+			}
+			fmt.Fprintf(w, `{"Status":"Failed","ResponseCode":%d}`, code)
+		}
+	}))
+	c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{Quiet: true, NoVerify: true})
+
+	if _, err := c.UploadReader(context.Background(), strings.NewReader("data"), "stdin", "i-1", "/tmp/x", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) != 2 || !strings.Contains(scripts[0], "gunzip") || strings.Contains(scripts[1], "gunzip") {
+		t.Errorf("sent %q, want a gzipped upload then a plain one", scripts)
+	}
+	if want := uploadScript([]byte("data"), "/tmp/x", false, c.opts.Transfer.decodeCommand()); len(scripts) == 2 && scripts[1] != want {
+		t.Errorf("fallback script = %s, want %s", scripts[1], want)
+	}
+}
+
+func TestGetRunningInstancesWithoutEC2(t *testing.T) {
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	ec2Calls := 0
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") == "" {
+			// EC2 speaks the query protocol; the role may not be allowed to
+			ec2Calls++
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		io.WriteString(w, `{"InstanceInformationList":[
+			{"InstanceId":"i-on","PingStatus":"Online","PlatformType":"Linux","AgentVersion":"3.3.0"},
+			{"InstanceId":"i-lost","PingStatus":"ConnectionLost","PlatformType":"Linux"}]}`)
+	}))
+	c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{NoEC2: true})
+
+	got, err := c.GetRunningInstances(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ec2Calls != 0 {
+		t.Errorf("made %d EC2 calls, want none", ec2Calls)
+	}
+	want := []selector.Instance{{ID: "i-on", State: "running", Platform: "Linux", SSMStatus: "Online", AgentVersion: "3.3.0"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRunningInstances() = %+v, want %+v", got, want)
+	}
+
+	// Rows without a name or IP still render
+	cols, _ := selector.ParseColumns("id,name,ip")
+	if row := selector.FormatRow(got[0], cols); row != "i-on\t\t" {
+		t.Errorf("FormatRow() = %q", row)
+	}
+}
+
+func TestTransferProgressCallbacks(t *testing.T) {
+	for _, tool := range []string{"base64", "gunzip"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+	dir := t.TempDir()
+	content := []byte("0123456789")
+
+	var calls []string
+	progress := func(done, total int64) { calls = append(calls, fmt.Sprintf("%d/%d", done, total)) }
+	tests := []struct {
+		name     string
+		transfer func(c *Client, progress Progress) error
+		want     []string
+	}{
+		{"upload", func(c *Client, progress Progress) error {
+			_, err := c.UploadReader(context.Background(), bytes.NewReader(content), "buffer", "i-1", filepath.Join(dir, "up"), progress)
+			return err
+		}, []string{"0/10", "10/10"}},
+		// The size of a download is only known once it is done
+		{"download", func(c *Client, progress Progress) error {
+			_, _, err := c.DownloadFile(context.Background(), "i-1", filepath.Join(dir, "up"), filepath.Join(dir, "down"), progress)
+			return err
+		}, []string{"0/0", "10/10"}},
+	}
+	for _, tt := range tests {
+		c := shellClient(t, Options{NoVerify: true})
+		calls = nil
+		if err := tt.transfer(c, progress); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(calls, tt.want) {
+			t.Errorf("%s: progress calls %q, want %q", tt.name, calls, tt.want)
+		}
+		// A nil callback is a no-op
+		if err := tt.transfer(c, nil); err != nil {
+			t.Errorf("%s without progress: %v", tt.name, err)
+		}
+	}
+}
+
+func TestDiscoveryStopsAtMaxInstances(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     int
+		max       int
+		wantPages int
+		wantFound int
+		wantWarn  bool
+	}{
+		{"cap inside a page", 10, 5, 2, 5, true},
+		{"cap at a page end with more to come", 10, 6, 2, 6, true},
+		{"fleet ends at the cap", 9, 9, 3, 9, false},
+		{"fleet under the cap", 7, 100, 3, 7, false},
+		{"default cap", 7, 0, 3, 7, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(paths.HomeEnv, t.TempDir())
+			const pageSize = 3
+			pages := 0
+			cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct{ NextToken string }
+				json.NewDecoder(r.Body).Decode(&body)
+				pages++
+				start := 0
+				fmt.Sscan(body.NextToken, &start)
+				var list []map[string]string
+				for i := start; i < min(start+pageSize, tt.total); i++ {
+					list = append(list, map[string]string{"InstanceId": fmt.Sprintf("i-%d", i), "PingStatus": "Online"})
+				}
+				resp := map[string]any{"InstanceInformationList": list}
+				if start+pageSize < tt.total {
+					resp["NextToken"] = fmt.Sprint(start + pageSize)
+				}
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				json.NewEncoder(w).Encode(resp)
+			}))
+			c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{NoEC2: true, MaxInstances: tt.max})
+
+			var found []selector.Instance
+			var err error
+			_, stderr := captureOutput(t, func() { found, err = c.GetRunningInstances(context.Background()) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pages != tt.wantPages || len(found) != tt.wantFound {
+				t.Errorf("fetched %d pages and found %d instances, want %d and %d", pages, len(found), tt.wantPages, tt.wantFound)
+			}
+			if warned := strings.Contains(stderr, "results are partial"); warned != tt.wantWarn {
+				t.Errorf("partial warning = %t, want %t (stderr %q)", warned, tt.wantWarn, stderr)
+			}
+		})
+	}
+}
+
+func TestExcludeOffline(t *testing.T) {
+	// EC2 reports both running; only the agent of one is reachable
+	instances := []Instance{
+		{ID: "i-online", State: "running", SSMStatus: "Online"},
+		{ID: "i-lost", State: "running", SSMStatus: "ConnectionLost"},
+	}
+	tests := []struct {
+		name           string
+		excludeOffline bool
+		want           []string
+	}{
+		{"kept by default", false, []string{"i-online", "i-lost"}},
+		{"dropped", true, []string{"i-online"}},
+	}
+	for _, tt := range tests {
+		c := &Client{opts: Options{ExcludeOffline: tt.excludeOffline}}
+		var got []string
+		for _, inst := range c.runningInstances(instances, nil) {
+			got = append(got, inst.ID)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: runningInstances() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStdioSession(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	// The fake plugin echoes its stdin, or says it was given a terminal
+	bin := t.TempDir()
+	plugin := "#!" + sh + "\nif [ -t 0 ]; then echo tty; else cat; fi\n"
+	if err := os.WriteFile(filepath.Join(bin, pluginName), []byte(plugin), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		io.WriteString(w, `{"SessionId":"s-1","StreamUrl":"wss://example/s-1","TokenValue":"tok"}`)
+	}))
+
+	tests := []struct {
+		name       string
+		stdio      bool
+		wantStdout string
+	}{
+		// Only the session's own output reaches stdout
+		{"stdio", true, "piped script\n"},
+		{"terminal", false, "Disconnected from web-1 i-1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(w, "piped script\n")
+			w.Close()
+			defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+			os.Stdin = r
+
+			c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{Stdio: tt.stdio})
+			stdout, _ := captureOutput(t, func() {
+				err = c.StartSession(context.Background(), "i-1", "web-1", "")
+			})
+			if tt.stdio && err != nil {
+				t.Fatal(err)
+			}
+			// Without --stdio the plugin gets /dev/tty, or fails without one
+			if tt.stdio && stdout != tt.wantStdout ||
+				!tt.stdio && (!strings.HasSuffix(stdout, tt.wantStdout) || strings.Contains(stdout, "piped script")) {
+				t.Errorf("stdout = %q, want %q", stdout, tt.wantStdout)
+			}
+		})
+	}
+}
+
+func TestSessionDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		invalid  bool
+		wantDoc  any
+		wantErr  string
+	}{
+		{"account default", "", false, nil, ""},
+		{"configured", "Corp-Shell", false, "Corp-Shell", ""},
+		{"not found", "Typo-Shell", true, "Typo-Shell", `session document "Typo-Shell" was not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]any
+			cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				if r.Header.Get("X-Amz-Target") != "AmazonSSM.StartSession" {
+					io.WriteString(w, `{}`)
+					return
+				}
+				json.NewDecoder(r.Body).Decode(&body)
+				if tt.invalid {
+					w.WriteHeader(http.StatusBadRequest)
+					io.WriteString(w, `{"__type":"InvalidDocument","Message":"Document not found"}`)
+					return
+				}
+				io.WriteString(w, `{"SessionId":"s-1","StreamUrl":"wss://example/s-1","TokenValue":"tok"}`)
+			}))
+			c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{SessionDocument: tt.document})
+
+			_, err := c.CreateSession(context.Background(), "i-1", "")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CreateSession() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if body["Target"] != "i-1" || body["DocumentName"] != tt.wantDoc {
+				t.Errorf("StartSession request = %v, want DocumentName %v", body, tt.wantDoc)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package ssm
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Console pages an instance URL can point at.
+const (
+	ConsoleEC2 = "ec2"
+	ConsoleSSM = "ssm"
+)
+
+// ValidateConsolePage checks that page is ConsoleEC2 or ConsoleSSM.
+func ValidateConsolePage(page string) error {
+	if page != ConsoleEC2 && page != ConsoleSSM {
+		return fmt.Errorf("invalid console page %q (expected %s or %s)", page, ConsoleEC2, ConsoleSSM)
+	}
+	return nil
+}
+
+// ConsoleURL returns the AWS console URL of the instance's EC2 details page
+// or of a Session Manager session to it.
+func ConsoleURL(region, instanceID, page string) (string, error) {
+	if err := ValidateConsolePage(page); err != nil {
+		return "", err
+	}
+	if region == "" {
+		return "", fmt.Errorf("no region to build a console URL for")
+	}
+	base := "https://" + consoleHost(region)
+	query := "?region=" + url.QueryEscape(region)
+	if page == ConsoleSSM {
+		return base + "/systems-manager/session-manager/" + url.PathEscape(instanceID) + query, nil
+	}
+	return base + "/ec2/home" + query + "#InstanceDetails:instanceId=" + url.QueryEscape(instanceID), nil
+}
+
+// consoleHost returns the console host for the region's partition.
+func consoleHost(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "console.amazonaws.cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "console.amazonaws-us-gov.com"
+	default:
+		return region + ".console.aws.amazon.com"
+	}
+}
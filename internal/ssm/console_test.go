@@ -0,0 +1,38 @@
+package ssm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConsoleURL(t *testing.T) {
+	tests := []struct {
+		name, region, page string
+		want               string
+		wantErr            string
+	}{
+		{"ec2", "eu-west-1", ConsoleEC2,
+			"https://eu-west-1.console.aws.amazon.com/ec2/home?region=eu-west-1#InstanceDetails:instanceId=i-0abc", ""},
+		{"session manager", "us-east-2", ConsoleSSM,
+			"https://us-east-2.console.aws.amazon.com/systems-manager/session-manager/i-0abc?region=us-east-2", ""},
+		// Other partitions have their own console hosts
+		{"china", "cn-north-1", ConsoleEC2,
+			"https://console.amazonaws.cn/ec2/home?region=cn-north-1#InstanceDetails:instanceId=i-0abc", ""},
+		{"govcloud", "us-gov-west-1", ConsoleSSM,
+			"https://console.amazonaws-us-gov.com/systems-manager/session-manager/i-0abc?region=us-gov-west-1", ""},
+		{"no region", "", ConsoleEC2, "", "no region"},
+		{"bad page", "eu-west-1", "rds", "", `invalid console page "rds"`},
+	}
+	for _, tt := range tests {
+		got, err := ConsoleURL(tt.region, "i-0abc", tt.page)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: ConsoleURL() error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: ConsoleURL() = %s, %v\nwant %s", tt.name, got, err, tt.want)
+		}
+	}
+}
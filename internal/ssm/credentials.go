@@ -0,0 +1,75 @@
+package ssm
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+
+	"github.com/e/aws-ssm-connect/internal/config"
+)
+
+// expiredTokenCodes are API error codes for credentials that expired while
+// the tool was running.
+var expiredTokenCodes = map[string]bool{
+	"ExpiredToken":          true,
+	"ExpiredTokenException": true,
+}
+
+// isExpiredToken reports whether err was caused by expired credentials.
+func isExpiredToken(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && expiredTokenCodes[apiErr.ErrorCode()]
+}
+
+// reloadableCredentials lets the client swap in freshly loaded credentials
+// without rebuilding the service clients that hold the provider.
+type reloadableCredentials struct {
+	mu       sync.Mutex
+	provider aws.CredentialsProvider
+	cache    *aws.CredentialsCache
+}
+
+func newReloadableCredentials(provider aws.CredentialsProvider) *reloadableCredentials {
+	r := &reloadableCredentials{provider: provider}
+	r.cache = aws.NewCredentialsCache(r)
+	return r
+}
+
+func (r *reloadableCredentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	r.mu.Lock()
+	provider := r.provider
+	r.mu.Unlock()
+	return provider.Retrieve(ctx)
+}
+
+// reload loads the profile's credentials again (picking up e.g. a new SSO
+// login or rewritten credentials file) and drops the cached ones.
+func (r *reloadableCredentials) reload(profile, region string) error {
+	cfg, err := config.Load(profile, region, "")
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.provider = cfg.Credentials
+	r.mu.Unlock()
+	r.cache.Invalidate()
+	return nil
+}
+
+// retryExpired runs call and, if it failed because the credentials expired,
+// reloads them once and runs it again. Other errors are returned as is.
+func retryExpired[T any](c *Client, call func() (T, error)) (T, error) {
+	result, err := call()
+	if !isExpiredToken(err) || c.creds == nil {
+		return result, err
+	}
+	c.out.Debug("Credentials expired, reloading and retrying once...")
+	if rerr := c.creds.reload(c.opts.Profile, c.cfg.Region); rerr != nil {
+		c.out.Debug("Reloading credentials failed: %v", rerr)
+		return result, err
+	}
+	return call()
+}
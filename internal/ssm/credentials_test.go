@@ -0,0 +1,99 @@
+package ssm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+	"github.com/e/aws-ssm-connect/internal/paths"
+)
+
+func TestIsExpiredToken(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&smithy.GenericAPIError{Code: "ExpiredToken"}, true},
+		{&smithy.GenericAPIError{Code: "ExpiredTokenException"}, true},
+		{fmt.Errorf("describe: %w", &smithy.GenericAPIError{Code: "ExpiredTokenException"}), true},
+		{&smithy.GenericAPIError{Code: "AccessDeniedException"}, false},
+		{errors.New("ExpiredToken"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isExpiredToken(tt.err); got != tt.want {
+			t.Errorf("isExpiredToken(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+// signedKey matches the access key in the credential scope of a signed request.
+var signedKey = regexp.MustCompile(`Credential=([^/]+)/`)
+
+func TestRetryExpiredReloadsOnce(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []string // error codes in order; "" answers with the instance
+		wantErr   string
+		wantCalls int
+	}{
+		{"expired then ok", []string{"ExpiredTokenException", ""}, "", 2},
+		// A single refresh, so a second expiry is returned
+		{"expired twice", []string{"ExpiredTokenException", "ExpiredTokenException", ""}, "ExpiredTokenException", 2},
+		{"other error", []string{"AccessDeniedException", ""}, "AccessDeniedException", 1},
+		{"ok", []string{""}, "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(paths.HomeEnv, t.TempDir())
+			// Reloading picks up these in place of the expired static keys
+			t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+			t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+			t.Setenv("AWS_ACCESS_KEY_ID", "FRESHKEY")
+			t.Setenv("AWS_SECRET_ACCESS_KEY", "FRESHSECRET")
+			t.Setenv("AWS_PROFILE", "")
+
+			var keys []string
+			cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				keys = append(keys, signedKey.FindStringSubmatch(r.Header.Get("Authorization"))[1])
+				code := tt.responses[min(len(keys), len(tt.responses))-1]
+				if code != "" {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprintf(w, `{"__type":%q,"Message":"no"}`, code)
+					return
+				}
+				io.WriteString(w, `{"InstanceInformationList":[{"InstanceId":"i-1","PingStatus":"Online","PlatformType":"Linux"}]}`)
+			}))
+			c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{NoEC2: true})
+
+			instances, err := c.GetRunningInstances(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetRunningInstances() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || len(instances) != 1 {
+				t.Fatalf("GetRunningInstances() = %v, %v; want i-1", instances, err)
+			}
+			if len(keys) != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", len(keys), tt.wantCalls)
+			}
+			if keys[0] != "AKID" {
+				t.Errorf("first call signed with %q, want AKID", keys[0])
+			}
+			// The retry is signed with the reloaded credentials
+			if tt.wantCalls == 2 && keys[1] != "FRESHKEY" {
+				t.Errorf("retry signed with %q, want FRESHKEY", keys[1])
+			}
+		})
+	}
+}
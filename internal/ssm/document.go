@@ -0,0 +1,138 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// runShellDocument is the document RunCommand and file copies send.
+const runShellDocument = "AWS-RunShellScript"
+
+// Document is an SSM document usable on an instance.
+type Document struct {
+	Name      string   `json:"name"`
+	Owner     string   `json:"owner"`
+	Type      string   `json:"type"`
+	Platforms []string `json:"platforms"`
+}
+
+// DocumentsFor lists the documents of docType (e.g. Command or Session)
+// that support the instance's platform.
+func (c *Client) DocumentsFor(ctx context.Context, instanceID, docType string) ([]Document, error) {
+	platform, err := c.platformType(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []ssmtypes.DocumentKeyValuesFilter{
+		{Key: aws.String("PlatformTypes"), Values: []string{string(platform)}},
+	}
+	if docType != "" {
+		filters = append(filters, ssmtypes.DocumentKeyValuesFilter{Key: aws.String("DocumentType"), Values: []string{docType}})
+	}
+
+	var docs []Document
+	paginator := ssm.NewListDocumentsPaginator(c.ssm, &ssm.ListDocumentsInput{Filters: filters})
+	for paginator.HasMorePages() {
+		page, err := retryExpired(c, func() (*ssm.ListDocumentsOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list documents: %w", err)
+		}
+		for _, d := range page.DocumentIdentifiers {
+			docs = append(docs, Document{
+				Name:      aws.ToString(d.Name),
+				Owner:     aws.ToString(d.Owner),
+				Type:      string(d.DocumentType),
+				Platforms: platformNames(d.PlatformTypes),
+			})
+		}
+	}
+	return docs, nil
+}
+
+// CheckDocument returns an error when document does not support the
+// instance's platform, so a mismatch is caught before SendCommand.
+func (c *Client) CheckDocument(ctx context.Context, instanceID, document string) error {
+	mismatch, err := c.documentMismatch(ctx, instanceID, document)
+	if err != nil {
+		return err
+	}
+	if mismatch != "" {
+		return fmt.Errorf("%s", mismatch)
+	}
+	return nil
+}
+
+// warnDocument warns on stderr when document does not support the
+// instance's platform, so stdout stays clean for --json. Failures of the
+// check itself are only logged.
+func (c *Client) warnDocument(ctx context.Context, instanceID, document string) {
+	mismatch, err := c.documentMismatch(ctx, instanceID, document)
+	if err != nil {
+		c.out.Debug("Could not check document %s: %v", document, err)
+	} else if mismatch != "" {
+		c.out.Warning("%s; the command will likely fail", mismatch)
+	}
+}
+
+// documentMismatch describes why document does not support the instance's
+// platform, or returns "" when it does.
+func (c *Client) documentMismatch(ctx context.Context, instanceID, document string) (string, error) {
+	platforms, err := c.documentPlatforms(ctx, document)
+	if err != nil {
+		return "", err
+	}
+	platform, err := c.platformType(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	if slices.Contains(platforms, platform) {
+		return "", nil
+	}
+	return fmt.Sprintf("document %s supports %s, but %s is %s",
+		document, strings.Join(platformNames(platforms), ", "), instanceID, platform), nil
+}
+
+// documentPlatforms returns the platforms document supports.
+func (c *Client) documentPlatforms(ctx context.Context, document string) ([]ssmtypes.PlatformType, error) {
+	desc, err := c.describeDocument(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	return desc.PlatformTypes, nil
+}
+
+// describeDocument returns the description of document, caching it for the
+// client's lifetime.
+func (c *Client) describeDocument(ctx context.Context, document string) (*ssmtypes.DocumentDescription, error) {
+	c.docMu.Lock()
+	defer c.docMu.Unlock()
+	if desc, ok := c.docs[document]; ok {
+		return desc, nil
+	}
+	out, err := c.ssm.DescribeDocument(ctx, &ssm.DescribeDocumentInput{Name: aws.String(document)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe document %s: %w", document, err)
+	}
+	if c.docs == nil {
+		c.docs = make(map[string]*ssmtypes.DocumentDescription)
+	}
+	c.docs[document] = out.Document
+	return out.Document, nil
+}
+
+func platformNames(platforms []ssmtypes.PlatformType) []string {
+	names := make([]string, len(platforms))
+	for i, p := range platforms {
+		names[i] = string(p)
+	}
+	return names
+}
@@ -0,0 +1,36 @@
+package ssm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+)
+
+func TestWarnDocumentUsesStderr(t *testing.T) {
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.DescribeDocument":
+			io.WriteString(w, `{"Document":{"Name":"AWS-RunShellScript","PlatformTypes":["Linux","MacOS"]}}`)
+		case "AmazonSSM.DescribeInstanceInformation":
+			io.WriteString(w, `{"InstanceInformationList":[{"InstanceId":"i-1","PlatformType":"Windows","PingStatus":"Online"}]}`)
+		default:
+			t.Errorf("unexpected call %s", r.Header.Get("X-Amz-Target"))
+		}
+	}))
+	c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{JSON: true})
+
+	stdout, stderr := captureOutput(t, func() {
+		c.warnDocument(context.Background(), "i-1", runShellDocument)
+	})
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing", stdout)
+	}
+	if !strings.Contains(stderr, "i-1 is Windows") {
+		t.Errorf("stderr = %q, want the platform mismatch", stderr)
+	}
+}
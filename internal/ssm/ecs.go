@@ -0,0 +1,148 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// ECSTask is a running ECS task that may be reachable via ECS Exec.
+type ECSTask struct {
+	Cluster     string
+	TaskID      string
+	Group       string
+	ExecEnabled bool
+	Containers  []ECSContainer
+}
+
+// ECSContainer is a container within an ECS task.
+type ECSContainer struct {
+	Name      string
+	RuntimeID string
+}
+
+// ListECSClusters returns the names of ECS clusters in the region.
+func (c *Client) ListECSClusters(ctx context.Context) ([]string, error) {
+	var clusters []string
+	paginator := ecs.NewListClustersPaginator(c.ecs, &ecs.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ECS clusters: %w", err)
+		}
+		for _, arn := range page.ClusterArns {
+			clusters = append(clusters, path.Base(arn))
+		}
+	}
+	return clusters, nil
+}
+
+// ListECSTasks returns running tasks in a cluster, optionally limited to a service.
+func (c *Client) ListECSTasks(ctx context.Context, cluster, service string) ([]ECSTask, error) {
+	c.out.Debug("Listing ECS tasks in %s...", cluster)
+
+	input := &ecs.ListTasksInput{
+		Cluster:       aws.String(cluster),
+		DesiredStatus: "RUNNING",
+	}
+	if service != "" {
+		input.ServiceName = aws.String(service)
+	}
+
+	var arns []string
+	paginator := ecs.NewListTasksPaginator(c.ecs, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ECS tasks: %w", err)
+		}
+		arns = append(arns, page.TaskArns...)
+	}
+
+	// DescribeTasks accepts at most 100 tasks per call
+	var tasks []ECSTask
+	for start := 0; start < len(arns); start += 100 {
+		end := min(start+100, len(arns))
+		result, err := c.ecs.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(cluster),
+			Tasks:   arns[start:end],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe ECS tasks: %w", err)
+		}
+
+		for _, t := range result.Tasks {
+			if aws.ToString(t.LastStatus) != "RUNNING" {
+				continue
+			}
+			task := ECSTask{
+				Cluster:     cluster,
+				TaskID:      path.Base(aws.ToString(t.TaskArn)),
+				Group:       aws.ToString(t.Group),
+				ExecEnabled: t.EnableExecuteCommand,
+			}
+			for _, ctr := range t.Containers {
+				task.Containers = append(task.Containers, ECSContainer{
+					Name:      aws.ToString(ctr.Name),
+					RuntimeID: aws.ToString(ctr.RuntimeId),
+				})
+			}
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks, nil
+}
+
+// ExecECS opens an interactive ECS Exec session in a task's container,
+// using the same session-manager-plugin invocation as StartSession.
+func (c *Client) ExecECS(ctx context.Context, task ECSTask, container ECSContainer, command, profile string) error {
+	if !task.ExecEnabled {
+		return fmt.Errorf("ECS Exec is not enabled for task %s (enable executeCommand on the service or task)", task.TaskID)
+	}
+
+	pluginPath, err := lookPlugin()
+	if err != nil {
+		return err
+	}
+
+	c.out.Info("Starting ECS Exec in %s/%s (%s)...", task.TaskID, container.Name, command)
+
+	resp, err := c.ecs.ExecuteCommand(ctx, &ecs.ExecuteCommandInput{
+		Cluster:     aws.String(task.Cluster),
+		Task:        aws.String(task.TaskID),
+		Container:   aws.String(container.Name),
+		Command:     aws.String(command),
+		Interactive: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute command: %w", err)
+	}
+	if resp.Session == nil {
+		return fmt.Errorf("ECS Exec returned no session")
+	}
+
+	target := map[string]any{
+		"Target": fmt.Sprintf("ecs:%s_%s_%s", task.Cluster, task.TaskID, container.RuntimeID),
+	}
+	sess, err := c.newPluginSession(resp.Session.SessionId, resp.Session.StreamUrl, resp.Session.TokenValue, target, profile)
+	if err != nil {
+		return err
+	}
+
+	err = execPlugin(pluginPath, sess, streamsTTY)
+	fmt.Printf("Disconnected from %s/%s\n", task.TaskID, container.Name)
+	return err
+}
+
+// Service returns the service name for tasks started by a service, or "".
+func (t ECSTask) Service() string {
+	if name, ok := strings.CutPrefix(t.Group, "service:"); ok {
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package ssm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+)
+
+// ecsClient returns a client whose ECS calls are answered by handler with
+// the operation name, e.g. "ListTasks", and the decoded request body.
+func ecsClient(t *testing.T, handler func(op string, body map[string]any) any) *Client {
+	t.Helper()
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		_, op, _ := strings.Cut(r.Header.Get("X-Amz-Target"), ".")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(handler(op, body))
+	}))
+	return NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{})
+}
+
+func TestListECSClusters(t *testing.T) {
+	c := ecsClient(t, func(op string, body map[string]any) any {
+		if body["nextToken"] == nil {
+			return map[string]any{"clusterArns": []string{"arn:aws:ecs:us-east-1:1:cluster/prod"}, "nextToken": "p2"}
+		}
+		return map[string]any{"clusterArns": []string{"arn:aws:ecs:us-east-1:1:cluster/staging"}}
+	})
+	got, err := c.ListECSClusters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"prod", "staging"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListECSClusters() = %q, want %q", got, want)
+	}
+}
+
+func TestListECSTasks(t *testing.T) {
+	const total = 150
+	var listed []map[string]any
+	var batches []int
+	c := ecsClient(t, func(op string, body map[string]any) any {
+		switch op {
+		case "ListTasks":
+			listed = append(listed, body)
+			var arns []string
+			for i := range total {
+				arns = append(arns, fmt.Sprintf("arn:aws:ecs:us-east-1:1:task/prod/t%d", i))
+			}
+			return map[string]any{"taskArns": arns}
+		case "DescribeTasks":
+			arns := body["tasks"].([]any)
+			batches = append(batches, len(arns))
+			var tasks []map[string]any
+			for _, arn := range arns {
+				status := "RUNNING"
+				if strings.HasSuffix(arn.(string), "/t0") {
+					status = "STOPPED"
+				}
+				tasks = append(tasks, map[string]any{
+					"taskArn":              arn,
+					"lastStatus":           status,
+					"group":                "service:api",
+					"enableExecuteCommand": true,
+					"containers": []map[string]any{
+						{"name": "app", "runtimeId": "rt-app"},
+						{"name": "sidecar", "runtimeId": "rt-sidecar"},
+					},
+				})
+			}
+			return map[string]any{"tasks": tasks}
+		}
+		return map[string]any{}
+	})
+
+	tasks, err := c.ListECSTasks(context.Background(), "prod", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(listed) != 1 || listed[0]["serviceName"] != "api" || listed[0]["desiredStatus"] != "RUNNING" {
+		t.Errorf("ListTasks requests = %v", listed)
+	}
+	// DescribeTasks takes at most 100 tasks per call
+	if want := []int{100, 50}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("DescribeTasks batches = %v, want %v", batches, want)
+	}
+	// The stopped task is dropped
+	if len(tasks) != total-1 {
+		t.Fatalf("got %d tasks, want %d", len(tasks), total-1)
+	}
+	want := ECSTask{
+		Cluster:     "prod",
+		TaskID:      "t1",
+		Group:       "service:api",
+		ExecEnabled: true,
+		Containers:  []ECSContainer{{Name: "app", RuntimeID: "rt-app"}, {Name: "sidecar", RuntimeID: "rt-sidecar"}},
+	}
+	if !reflect.DeepEqual(tasks[0], want) {
+		t.Errorf("tasks[0] = %+v, want %+v", tasks[0], want)
+	}
+}
+
+func TestExecECSRequiresExecEnabled(t *testing.T) {
+	c := ecsClient(t, func(op string, body map[string]any) any {
+		t.Errorf("unexpected %s call", op)
+		return map[string]any{}
+	})
+	err := c.ExecECS(context.Background(), ECSTask{TaskID: "t1"}, ECSContainer{Name: "app"}, "/bin/sh", "")
+	if err == nil || !strings.Contains(err.Error(), "ECS Exec is not enabled") {
+		t.Errorf("ExecECS() error = %v", err)
+	}
+}
+
+func TestECSTaskService(t *testing.T) {
+	tests := []struct {
+		group string
+		want  string
+	}{
+		{"service:api", "api"},
+		{"family:batch", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := (ECSTask{Group: tt.group}).Service(); got != tt.want {
+			t.Errorf("Service() of group %q = %q, want %q", tt.group, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package ssm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Encryption tools for --encrypt-uploads.
+const (
+	EncryptAge = "age"
+	EncryptGPG = "gpg"
+)
+
+// Encryption encrypts upload content locally before it is encoded into the
+// command, so only ciphertext reaches SSM and CloudTrail. The file stays
+// encrypted on the instance. The zero value disables encryption.
+type Encryption struct {
+	Tool      string
+	Recipient string
+}
+
+// ParseEncryption parses an --encrypt-uploads spec: "age:RECIPIENT" or
+// "gpg:RECIPIENT". A bare age1... public key implies age.
+func ParseEncryption(spec string) (Encryption, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return Encryption{}, nil
+	}
+	tool, recipient, found := strings.Cut(spec, ":")
+	if !found {
+		if !strings.HasPrefix(spec, "age1") {
+			return Encryption{}, fmt.Errorf("invalid encryption %q: want age:RECIPIENT or gpg:RECIPIENT", spec)
+		}
+		tool, recipient = EncryptAge, spec
+	}
+	if tool != EncryptAge && tool != EncryptGPG {
+		return Encryption{}, fmt.Errorf("unknown encryption tool %q: want %s or %s", tool, EncryptAge, EncryptGPG)
+	}
+	if recipient == "" {
+		return Encryption{}, fmt.Errorf("%s encryption needs a recipient", tool)
+	}
+	return Encryption{Tool: tool, Recipient: recipient}, nil
+}
+
+// Enabled reports whether uploads are encrypted.
+func (e Encryption) Enabled() bool {
+	return e.Tool != ""
+}
+
+// argv returns the local command that encrypts stdin to stdout.
+func (e Encryption) argv() []string {
+	if e.Tool == EncryptGPG {
+		return []string{"gpg", "--batch", "--yes", "--encrypt", "--recipient", e.Recipient, "--output", "-"}
+	}
+	return []string{"age", "--encrypt", "--recipient", e.Recipient}
+}
+
+// encrypt runs the local tool over data.
+func (e Encryption) encrypt(ctx context.Context, data []byte) ([]byte, error) {
+	argv := e.argv()
+	if _, err := exec.LookPath(argv[0]); err != nil {
+		return nil, fmt.Errorf("%s not found in PATH (needed for --encrypt-uploads)", argv[0])
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s", argv[0], msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", argv[0], err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,34 @@
+package ssm
+
+import "testing"
+
+func TestParseEncryption(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Encryption
+		wantErr bool
+	}{
+		{"", Encryption{}, false},
+		{"  ", Encryption{}, false},
+		{"age:age1abc", Encryption{Tool: EncryptAge, Recipient: "age1abc"}, false},
+		{"age1abc", Encryption{Tool: EncryptAge, Recipient: "age1abc"}, false},
+		{"gpg:ops@example.com", Encryption{Tool: EncryptGPG, Recipient: "ops@example.com"}, false},
+		{" gpg:ABCDEF12 ", Encryption{Tool: EncryptGPG, Recipient: "ABCDEF12"}, false},
+		{"ops@example.com", Encryption{}, true},
+		{"pgp:ops@example.com", Encryption{}, true},
+		{"gpg:", Encryption{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEncryption(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseEncryption(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseEncryption(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+		if got.Enabled() != (tt.want.Tool != "") {
+			t.Errorf("ParseEncryption(%q).Enabled() = %t", tt.spec, got.Enabled())
+		}
+	}
+}
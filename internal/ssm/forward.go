@@ -0,0 +1,55 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+const portForwardDocument = "AWS-StartPortForwardingSession"
+
+// StartPortForward forwards localPort on this machine to remotePort on the instance.
+// It blocks until the session ends.
+func (c *Client) StartPortForward(ctx context.Context, instanceID, instanceName, profile string, localPort, remotePort int) error {
+	c.out.Info("Forwarding localhost:%d to %s:%d...", localPort, instanceID, remotePort)
+	c.out.Debug("Region: %s", c.cfg.Region)
+
+	c.recordHistory(instanceID, instanceName)
+
+	return c.runPlugin(ctx, &ssm.StartSessionInput{
+		Target:       aws.String(instanceID),
+		DocumentName: aws.String(portForwardDocument),
+		Parameters: map[string][]string{
+			"portNumber":      {strconv.Itoa(remotePort)},
+			"localPortNumber": {strconv.Itoa(localPort)},
+		},
+	}, profile, streamsTTY)
+}
+
+// ParsePortSpec parses "port" or "local:remote" into local and remote port numbers.
+func ParsePortSpec(spec string) (local, remote int, err error) {
+	localStr, remoteStr, ok := strings.Cut(spec, ":")
+	if !ok {
+		remoteStr = localStr
+	}
+
+	if local, err = parsePort(localStr); err != nil {
+		return 0, 0, err
+	}
+	if remote, err = parsePort(remoteStr); err != nil {
+		return 0, 0, err
+	}
+	return local, remote, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q (expected 1-65535)", s)
+	}
+	return port, nil
+}
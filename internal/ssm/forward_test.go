@@ -0,0 +1,27 @@
+package ssm
+
+import "testing"
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		spec          string
+		local, remote int
+		wantErr       bool
+	}{
+		{"5432", 5432, 5432, false},
+		{"15432:5432", 15432, 5432, false},
+		{"1:65535", 1, 65535, false},
+		{"0", 0, 0, true},
+		{"65536", 0, 0, true},
+		{"db", 0, 0, true},
+		{"8080:", 0, 0, true},
+		{":80", 0, 0, true},
+		{"1:2:3", 0, 0, true},
+	}
+	for _, tt := range tests {
+		local, remote, err := ParsePortSpec(tt.spec)
+		if (err != nil) != tt.wantErr || local != tt.local || remote != tt.remote {
+			t.Errorf("ParsePortSpec(%q) = %d, %d, %v; want %d, %d, error %t", tt.spec, local, remote, err, tt.local, tt.remote, tt.wantErr)
+		}
+	}
+}
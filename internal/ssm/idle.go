@@ -0,0 +1,62 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+const (
+	// idleTimeoutParam is the session document parameter for the
+	// server-side idle timeout, in minutes.
+	idleTimeoutParam = "idleSessionTimeout"
+	// defaultShellDocument is used for shell sessions without a session document.
+	defaultShellDocument = "SSM-SessionManagerRunShell"
+	// MinIdleTimeout and MaxIdleTimeout bound what Session Manager accepts.
+	MinIdleTimeout = time.Minute
+	MaxIdleTimeout = 60 * time.Minute
+)
+
+// ValidateIdleTimeout checks a session idle timeout: whole minutes between
+// MinIdleTimeout and MaxIdleTimeout.
+func ValidateIdleTimeout(d time.Duration) error {
+	if d < MinIdleTimeout || d > MaxIdleTimeout || d%time.Minute != 0 {
+		return fmt.Errorf("session idle timeout must be whole minutes from %v to %v, got %v", MinIdleTimeout, MaxIdleTimeout, d)
+	}
+	return nil
+}
+
+// idleTimeoutParameters returns the StartSession parameters that set the
+// idle timeout to d.
+func idleTimeoutParameters(d time.Duration) map[string][]string {
+	return map[string][]string{idleTimeoutParam: {strconv.Itoa(int(d / time.Minute))}}
+}
+
+// setIdleTimeout adds Options.IdleTimeout to a shell session request when
+// its document declares an idleSessionTimeout parameter. Otherwise, or when
+// the document cannot be checked, it warns and leaves the request as is:
+// StartSession rejects parameters a document does not declare.
+func (c *Client) setIdleTimeout(ctx context.Context, input *ssm.StartSessionInput) {
+	document := aws.ToString(input.DocumentName)
+	if document == "" {
+		document = defaultShellDocument
+	}
+	desc, err := c.describeDocument(ctx, document)
+	if err != nil {
+		c.out.Warning("Session idle timeout not applied: %v", err)
+		return
+	}
+	for _, p := range desc.Parameters {
+		if aws.ToString(p.Name) == idleTimeoutParam {
+			c.out.Debug("Session idle timeout %v via %s", c.opts.IdleTimeout, document)
+			input.Parameters = idleTimeoutParameters(c.opts.IdleTimeout)
+			return
+		}
+	}
+	c.out.Warning("Document %s has no %s parameter; the session idle timeout is not applied (set it in Session Manager preferences, or use a --session-document that declares it)",
+		document, idleTimeoutParam)
+}
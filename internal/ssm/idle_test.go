@@ -0,0 +1,88 @@
+package ssm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+func TestValidateIdleTimeout(t *testing.T) {
+	tests := []struct {
+		d       time.Duration
+		wantErr bool
+	}{
+		{time.Minute, false},
+		{20 * time.Minute, false},
+		{time.Hour, false},
+		{30 * time.Second, true},
+		{61 * time.Minute, true},
+		{90 * time.Second, true},
+		{-time.Minute, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateIdleTimeout(tt.d); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateIdleTimeout(%v) = %v, want error %t", tt.d, err, tt.wantErr)
+		}
+	}
+}
+
+func TestShellSessionIdleTimeout(t *testing.T) {
+	withParam := map[string]any{"Name": defaultShellDocument, "Parameters": []map[string]any{
+		{"Name": "linuxcommands"}, {"Name": idleTimeoutParam},
+	}}
+	withoutParam := map[string]any{"Name": "Custom-Shell", "Parameters": []map[string]any{{"Name": "linuxcommands"}}}
+	tests := []struct {
+		name        string
+		document    string
+		timeout     time.Duration
+		want        map[string][]string
+		wantWarning string
+	}{
+		{"default document", "", 20 * time.Minute, map[string][]string{"idleSessionTimeout": {"20"}}, ""},
+		{"document declares it", "Shell-With-Timeout", time.Hour, map[string][]string{"idleSessionTimeout": {"60"}}, ""},
+		{"document lacks it", "Custom-Shell", 20 * time.Minute, nil, "Document Custom-Shell has no idleSessionTimeout parameter"},
+		{"document unreadable", "Secret-Shell", 20 * time.Minute, nil, "Session idle timeout not applied"},
+		// Without a timeout the document is not even looked up
+		{"not set", "Secret-Shell", 0, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var described []string
+			c := commandClient(t, func(op string, body map[string]any) any {
+				if op != "DescribeDocument" {
+					return map[string]any{}
+				}
+				name := body["Name"].(string)
+				described = append(described, name)
+				switch name {
+				case defaultShellDocument, "Shell-With-Timeout":
+					return map[string]any{"Document": withParam}
+				case "Custom-Shell":
+					return map[string]any{"Document": withoutParam}
+				}
+				return "AccessDeniedException"
+			})
+			c.opts.SessionDocument, c.opts.IdleTimeout = tt.document, tt.timeout
+
+			var input *ssm.StartSessionInput
+			_, stderr := captureOutput(t, func() { input = c.shellSessionInput(context.Background(), "i-1") })
+			if !reflect.DeepEqual(input.Parameters, tt.want) {
+				t.Errorf("Parameters = %v, want %v", input.Parameters, tt.want)
+			}
+			if aws.ToString(input.DocumentName) != tt.document {
+				t.Errorf("DocumentName = %q, want %q", aws.ToString(input.DocumentName), tt.document)
+			}
+			if (tt.wantWarning == "" && stderr != "") || !strings.Contains(stderr, tt.wantWarning) {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantWarning)
+			}
+			if tt.timeout == 0 && len(described) > 0 {
+				t.Errorf("described %q without a timeout", described)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package ssm
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// DefaultLaunchRetries is how often a session whose plugin fails right
+// away is started again.
+const DefaultLaunchRetries = 1
+
+const (
+	// fastFailWindow is how soon after launch a failing plugin counts as a
+	// stream setup error rather than the end of a real session.
+	fastFailWindow = 3 * time.Second
+	// exitInterrupted is the exit status of a process ended by Ctrl-C.
+	exitInterrupted = 130
+)
+
+// launchBackoff is the wait before the first relaunch; it doubles after
+// each further failure. Tests shorten it.
+var launchBackoff = time.Second
+
+// launchSession starts a session for input and runs launch on it. When the
+// plugin fails within fastFailWindow, the session is terminated and started
+// again, up to Options.LaunchRetries times with a growing backoff.
+func (c *Client) launchSession(ctx context.Context, input *ssm.StartSessionInput, profile string, launch func(*PluginSession) error) error {
+	delay := launchBackoff
+	for attempt := 1; ; attempt++ {
+		sess, err := c.startPluginSession(ctx, input, profile)
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		err = launch(sess)
+		if attempt > c.opts.LaunchRetries || ctx.Err() != nil || !fastFailure(err, time.Since(start)) {
+			return err
+		}
+
+		c.out.Warning("session-manager-plugin failed right away (%v); retrying in %s (%d/%d)",
+			err, delay, attempt, c.opts.LaunchRetries)
+		_, _ = c.ssm.TerminateSession(ctx, &ssm.TerminateSessionInput{SessionId: aws.String(sess.SessionID)})
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// fastFailure reports whether the plugin exited with an error within
+// fastFailWindow. Exits caused by the user (a signal or Ctrl-C) do not count.
+func fastFailure(err error, elapsed time.Duration) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || elapsed >= fastFailWindow {
+		return false
+	}
+	code := exitErr.ExitCode()
+	return code > 0 && code != exitInterrupted
+}
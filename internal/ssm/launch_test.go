@@ -0,0 +1,102 @@
+package ssm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+)
+
+// exitError runs script in sh and returns how it exited.
+func exitError(t *testing.T, script string) error {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	return exec.Command("sh", "-c", script).Run()
+}
+
+func TestFastFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		elapsed time.Duration
+		want    bool
+	}{
+		{"fast failure", exitError(t, "exit 1"), time.Second, true},
+		{"slow failure", exitError(t, "exit 1"), fastFailWindow, false},
+		{"clean exit", exitError(t, "exit 0"), time.Second, false},
+		// Exits the user caused
+		{"ctrl-c", exitError(t, "exit 130"), time.Second, false},
+		{"signal", exitError(t, "kill -TERM $$"), time.Second, false},
+		{"not an exit", errors.New("plugin not found"), time.Second, false},
+	}
+	for _, tt := range tests {
+		if got := fastFailure(tt.err, tt.elapsed); got != tt.want {
+			t.Errorf("%s: fastFailure(%v, %s) = %t, want %t", tt.name, tt.err, tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestLaunchSessionRetries(t *testing.T) {
+	defer func(d time.Duration) { launchBackoff = d }(launchBackoff)
+	launchBackoff = time.Millisecond
+
+	fast := exitError(t, "exit 1")
+	tests := []struct {
+		name          string
+		retries       int
+		results       []error // what each launch returns; the last repeats
+		wantLaunches  int
+		wantTerminate int
+		wantErr       bool
+	}{
+		{"success", 1, []error{nil}, 1, 0, false},
+		{"fast failure then success", 1, []error{fast, nil}, 2, 1, false},
+		{"out of retries", 2, []error{fast}, 3, 2, true},
+		{"retries disabled", 0, []error{fast}, 1, 0, true},
+		// A non-exit error is not a stream setup failure
+		{"other error", 3, []error{errors.New("boom")}, 1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			starts, terminates := 0, 0
+			cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				switch r.Header.Get("X-Amz-Target") {
+				case "AmazonSSM.StartSession":
+					starts++
+					io.WriteString(w, `{"SessionId":"s-1","StreamUrl":"wss://example/s-1","TokenValue":"tok"}`)
+				case "AmazonSSM.TerminateSession":
+					terminates++
+					io.WriteString(w, `{}`)
+				}
+			}))
+			c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{LaunchRetries: tt.retries})
+
+			launches := 0
+			var err error
+			captureOutput(t, func() {
+				err = c.launchSession(context.Background(), &ssm.StartSessionInput{Target: new(string)}, "", func(*PluginSession) error {
+					launches++
+					return tt.results[min(launches, len(tt.results))-1]
+				})
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("launchSession() = %v, want error %t", err, tt.wantErr)
+			}
+			// Each launch gets a fresh session; failed ones are terminated
+			if launches != tt.wantLaunches || starts != tt.wantLaunches || terminates != tt.wantTerminate {
+				t.Errorf("launches %d, StartSession %d, TerminateSession %d; want %d, %d, %d",
+					launches, starts, terminates, tt.wantLaunches, tt.wantLaunches, tt.wantTerminate)
+			}
+		})
+	}
+}
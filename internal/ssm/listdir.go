@@ -0,0 +1,84 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxDirEntries caps how many entries a remote directory listing returns.
+// SSM keeps only the first 24,000 characters of command output, so a larger
+// listing would be cut off mid-line anyway.
+const MaxDirEntries = 300
+
+// DirEntry is one entry of a remote directory listing.
+type DirEntry struct {
+	Name string
+	Mode string
+	Size int64
+	Dir  bool
+}
+
+// ListDir lists a remote directory with ls over Run Command. It returns the
+// directory's absolute path, its entries (directories first, including ".."
+// unless at the root) and whether the listing was cut at MaxDirEntries.
+// Symlinks are followed, so a link to a directory is listed as one.
+func (c *Client) ListDir(ctx context.Context, instanceID, dir string) (string, []DirEntry, bool, error) {
+	if dir == "" {
+		dir = "/"
+	}
+	// "." and ".." come first in ls output, plus the "total" line
+	script := fmt.Sprintf("cd -- %s && pwd && LC_ALL=C ls -lanL 2>/dev/null | head -n %d", shellQuote(dir), MaxDirEntries+4)
+	result, err := c.Run(ctx, instanceID, script)
+	if err != nil {
+		return "", nil, false, err
+	}
+	if result.ExitCode != 0 {
+		return "", nil, false, fmt.Errorf("cannot list %s: %s", dir, strings.TrimSpace(result.Stderr))
+	}
+
+	abs, listing, _ := strings.Cut(result.Stdout, "\n")
+	abs = strings.TrimSpace(abs)
+	entries := ParseLs(listing)
+	truncated := len(entries) > MaxDirEntries
+	if truncated {
+		entries = entries[:MaxDirEntries]
+	}
+	if abs != "/" {
+		entries = append([]DirEntry{{Name: "..", Mode: "d", Dir: true}}, entries...)
+	}
+	return abs, entries, truncated, nil
+}
+
+// ParseLs parses `ls -l` output into entries, directories first. The
+// "total" line, "." and ".." and lines that do not look like entries are
+// skipped. Names keep single inner spaces; runs of spaces collapse to one.
+func ParseLs(output string) []DirEntry {
+	var dirs, files []DirEntry
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		// mode links owner group size month day time|year name...
+		if len(fields) < 9 || len(fields[0]) < 10 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			continue
+		}
+		name := strings.Join(fields[8:], " ")
+		if fields[0][0] == 'l' {
+			name, _, _ = strings.Cut(name, " -> ")
+		}
+		if name == "." || name == ".." {
+			continue
+		}
+		e := DirEntry{Name: name, Mode: fields[0], Size: size, Dir: fields[0][0] == 'd'}
+		if e.Dir {
+			dirs = append(dirs, e)
+		} else {
+			files = append(files, e)
+		}
+	}
+	return append(dirs, files...)
+}
@@ -0,0 +1,31 @@
+package ssm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLs(t *testing.T) {
+	listing := `total 24
+drwxr-xr-x  4 0 0 4096 Jan  2 10:00 .
+drwxr-xr-x 20 0 0 4096 Jan  2 10:00 ..
+-rw-r--r--  1 0 0  120 Jan  2 10:00 app.log
+drwxr-xr-x  2 0 0 4096 Jan  2  2023 conf
+-rw-r--r--  1 0 0    7 Jan  2 10:00 my  notes.txt
+lrwxrwxrwx  1 0 0   11 Jan  2 10:00 current -> releases/42
+ls: cannot access 'broken': No such file or directory
+-rw-r--r--  1 0 0 big Jan  2 10:00 odd
+`
+	want := []DirEntry{
+		{Name: "conf", Mode: "drwxr-xr-x", Size: 4096, Dir: true},
+		{Name: "app.log", Mode: "-rw-r--r--", Size: 120},
+		{Name: "my notes.txt", Mode: "-rw-r--r--", Size: 7},
+		{Name: "current", Mode: "lrwxrwxrwx", Size: 11},
+	}
+	if got := ParseLs(listing); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLs() =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := ParseLs(""); len(got) != 0 {
+		t.Errorf("ParseLs(\"\") = %+v, want none", got)
+	}
+}
@@ -0,0 +1,61 @@
+package ssm
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	// maxCommandTargets is how many instances one SendCommand call may name.
+	maxCommandTargets = 50
+	// maxParallelWaits bounds the invocations polled at the same time.
+	maxParallelWaits = 10
+)
+
+// RunCommandMulti runs command on every instance and waits for all of them.
+// Instances are sent the command in batches of maxCommandTargets. A failure
+// on one instance (including a timeout) is reported in its Invocation; only
+// errors sending a batch are returned.
+func (c *Client) RunCommandMulti(ctx context.Context, instanceIDs []string, command string) ([]Invocation, error) {
+	commandIDs := make(map[string]string, len(instanceIDs))
+	for start := 0; start < len(instanceIDs); start += maxCommandTargets {
+		batch := instanceIDs[start:min(start+maxCommandTargets, len(instanceIDs))]
+		commandID, err := c.sendCommand(ctx, batch, command)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range batch {
+			commandIDs[id] = commandID
+		}
+	}
+
+	results := make([]Invocation, len(instanceIDs))
+	sem := make(chan struct{}, maxParallelWaits)
+	var wg sync.WaitGroup
+	for i, id := range instanceIDs {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			inv := Invocation{InstanceID: id, Done: true, ExitCode: -1}
+			result, err := c.waitForCommandResult(ctx, commandIDs[id], id, nil)
+			if err != nil {
+				inv.Status = "Error"
+				inv.Stderr = err.Error()
+			} else {
+				inv.Status = "Success"
+				if result.ExitCode != 0 {
+					inv.Status = "Failed"
+				}
+				inv.ExitCode = result.ExitCode
+				inv.Stdout = result.Stdout
+				inv.Stderr = result.Stderr
+			}
+			results[i] = inv
+		}(i, id)
+	}
+	wg.Wait()
+	return results, nil
+}
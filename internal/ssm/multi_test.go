@@ -0,0 +1,81 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunCommandMulti(t *testing.T) {
+	defer func(d time.Duration) { commandPollInterval = d }(commandPollInterval)
+	commandPollInterval = time.Millisecond
+
+	var mu sync.Mutex
+	var batches []int
+	sent := map[string]string{} // instance -> command ID
+	c := commandClient(t, func(op string, body map[string]any) any {
+		mu.Lock()
+		defer mu.Unlock()
+		switch op {
+		case "SendCommand":
+			ids := body["InstanceIds"].([]any)
+			batches = append(batches, len(ids))
+			commandID := fmt.Sprintf("cmd-%d", len(batches))
+			for _, id := range ids {
+				sent[id.(string)] = commandID
+			}
+			return map[string]any{"Command": map[string]any{"CommandId": commandID}}
+		case "GetCommandInvocation":
+			id := body["InstanceId"].(string)
+			if body["CommandId"] != sent[id] {
+				return "InvalidCommandId"
+			}
+			switch id {
+			case "i-7":
+				return map[string]any{"Status": "Failed", "ResponseCode": 3, "StandardErrorContent": "no space\n"}
+			case "i-8":
+				return "AccessDeniedException"
+			}
+			return map[string]any{"Status": "Success", "ResponseCode": 0, "StandardOutputContent": id + "\n"}
+		case "DescribeDocument":
+			return map[string]any{"Document": map[string]any{"Name": runShellDocument}}
+		}
+		return map[string]any{}
+	})
+
+	ids := make([]string, 2*maxCommandTargets+20)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("i-%d", i)
+	}
+	var got []Invocation
+	var err error
+	captureOutput(t, func() { got, err = c.RunCommandMulti(context.Background(), ids, "df -h") })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int{maxCommandTargets, maxCommandTargets, 20}; fmt.Sprint(batches) != fmt.Sprint(want) {
+		t.Errorf("SendCommand batches %v, want %v", batches, want)
+	}
+	if len(got) != len(ids) {
+		t.Fatalf("got %d invocations, want %d", len(got), len(ids))
+	}
+	// Results keep the target order; one failure does not stop the rest
+	for i, inv := range got {
+		want := Invocation{InstanceID: ids[i], Status: "Success", Done: true, Stdout: ids[i] + "\n"}
+		switch ids[i] {
+		case "i-7":
+			want = Invocation{InstanceID: "i-7", Status: "Failed", Done: true, ExitCode: 3, Stderr: "no space\n"}
+		case "i-8":
+			if inv.Status != "Error" || inv.ExitCode != -1 || inv.Stderr == "" {
+				t.Errorf("invocation on i-8 = %+v, want an Error with the reason", inv)
+			}
+			continue
+		}
+		if inv != want {
+			t.Errorf("invocation %d = %+v, want %+v", i, inv, want)
+		}
+	}
+}
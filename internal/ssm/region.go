@@ -0,0 +1,112 @@
+package ssm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/smithy-go"
+)
+
+// IsInstanceID reports whether s looks like an EC2 instance ID.
+func IsInstanceID(s string) bool {
+	return strings.HasPrefix(s, "i-")
+}
+
+// fullInstanceID matches a complete EC2 instance ID in the short (8 hex
+// digits) or long (17 hex digits) form.
+var fullInstanceID = regexp.MustCompile(`^i-([0-9a-f]{8}|[0-9a-f]{17})$`)
+
+// IsFullInstanceID reports whether s is a complete instance ID rather than
+// a prefix or name fragment.
+func IsFullInstanceID(s string) bool {
+	return fullInstanceID.MatchString(s)
+}
+
+// regionListEndpoint is asked for the enabled regions when no region is
+// configured yet; every commercial account can call it.
+const regionListEndpoint = "us-east-1"
+
+// EnabledRegions lists the regions enabled for the account, sorted. It works
+// with a config that has no region.
+func EnabledRegions(ctx context.Context, cfg aws.Config) ([]string, error) {
+	client := ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+		if o.Region == "" {
+			o.Region = regionListEndpoint
+		}
+	})
+	out, err := client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list regions: %w", err)
+	}
+	names := make([]string, 0, len(out.Regions))
+	for _, r := range out.Regions {
+		if name := aws.ToString(r.RegionName); name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// FindRegion searches the account's enabled regions, other than the
+// client's own, for the instance and returns the region it lives in.
+func (c *Client) FindRegion(ctx context.Context, instanceID string) (string, error) {
+	regions, err := c.ec2.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list regions: %w", err)
+	}
+
+	c.out.Debug("Searching %d regions for %s...", len(regions.Regions), instanceID)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		found string
+	)
+	for _, r := range regions.Regions {
+		name := aws.ToString(r.RegionName)
+		if name == "" || name == c.cfg.Region {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := ec2.NewFromConfig(c.cfg, func(o *ec2.Options) { o.Region = name })
+			out, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+				InstanceIds: []string{instanceID},
+			})
+			if err != nil {
+				var apiErr smithy.APIError
+				if !errors.As(err, &apiErr) || !strings.HasPrefix(apiErr.ErrorCode(), "InvalidInstanceID") {
+					c.out.Debug("Region %s: %v", name, err)
+				}
+				return
+			}
+			if len(out.Reservations) == 0 {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if found == "" {
+				found = name
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if found == "" {
+		return "", fmt.Errorf("instance %s not found in any enabled region", instanceID)
+	}
+	return found, nil
+}
@@ -0,0 +1,208 @@
+package ssm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+)
+
+// signedRegion matches the region in the credential scope of a signed request.
+var signedRegion = regexp.MustCompile(`Credential=[^/]+/[^/]+/([^/]+)/`)
+
+func TestFindRegion(t *testing.T) {
+	regions := []string{"eu-west-1", "us-east-1", "us-west-2"}
+	tests := []struct {
+		name    string
+		home    string // region the instance lives in
+		want    string
+		wantErr string
+	}{
+		{"other region", "us-west-2", "us-west-2", ""},
+		{"nowhere", "", "", "instance i-0abc not found in any enabled region"},
+		// The client's own region was already searched
+		{"own region", "us-east-1", "", "not found in any enabled region"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				searched []string
+			)
+			cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				form, _ := url.ParseQuery(string(body))
+				w.Header().Set("Content-Type", "text/xml")
+				switch form.Get("Action") {
+				case "DescribeRegions":
+					io.WriteString(w, `<DescribeRegionsResponse><regionInfo>`)
+					for _, name := range regions {
+						fmt.Fprintf(w, `<item><regionName>%s</regionName></item>`, name)
+					}
+					io.WriteString(w, `</regionInfo></DescribeRegionsResponse>`)
+				case "DescribeInstances":
+					region := signedRegion.FindStringSubmatch(r.Header.Get("Authorization"))[1]
+					mu.Lock()
+					searched = append(searched, region)
+					mu.Unlock()
+					if region != tt.home {
+						w.WriteHeader(http.StatusBadRequest)
+						io.WriteString(w, `<Response><Errors><Error><Code>InvalidInstanceID.NotFound</Code><Message>not found</Message></Error></Errors></Response>`)
+						return
+					}
+					fmt.Fprintf(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet><item><instanceId>%s</instanceId></item></instancesSet></item></reservationSet></DescribeInstancesResponse>`, form.Get("InstanceId.1"))
+				}
+			}))
+			c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{})
+
+			got, err := c.FindRegion(context.Background(), "i-0abc")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FindRegion() = %q, %v; want error %q", got, err, tt.wantErr)
+				}
+			} else if err != nil || got != tt.want {
+				t.Fatalf("FindRegion() = %q, %v; want %q", got, err, tt.want)
+			}
+			for _, region := range searched {
+				if region == cfg.Region {
+					t.Errorf("searched the client's own region %s again", region)
+				}
+			}
+		})
+	}
+}
+
+func TestIsFullInstanceID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"i-0123abcd", true},
+		{"i-0123456789abcdef0", true},
+		{"i-0123", false},
+		{"i-0123456789abcdef", false},
+		{"i-0123ABCD", false},
+		{"i-0123abcd ", false},
+		{"web-1", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsFullInstanceID(tt.id); got != tt.want {
+			t.Errorf("IsFullInstanceID(%q) = %t, want %t", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestManagedInstance(t *testing.T) {
+	const id = "i-0123456789abcdef0"
+	tests := []struct {
+		name     string
+		ping     string // empty when SSM does not know the instance
+		ec2State string // empty when EC2 cannot describe it
+		noEC2    bool
+		wantName string
+		wantErr  string
+		wantEC2  int
+	}{
+		{"online", "Online", "running", false, "web-1", "", 1},
+		{"no ec2", "Online", "", true, "", "", 0},
+		// Without EC2 details the SSM ones still do
+		{"ec2 denied", "Online", "", false, "", "", 1},
+		{"stopped", "Online", "stopped", false, "", "is stopped, not running", 1},
+		{"agent lost", "ConnectionLost", "running", false, "", "is ConnectionLost, not Online", 0},
+		{"unmanaged", "", "running", false, "", "not managed by SSM", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := map[string]int{}
+			var filters []any
+			cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if target := r.Header.Get("X-Amz-Target"); target != "" {
+					ops[target]++
+					var body struct{ Filters []any }
+					json.NewDecoder(r.Body).Decode(&body)
+					filters = body.Filters
+					w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+					if tt.ping == "" {
+						io.WriteString(w, `{"InstanceInformationList":[]}`)
+						return
+					}
+					fmt.Fprintf(w, `{"InstanceInformationList":[{"InstanceId":%q,"PingStatus":%q,"PlatformType":"Linux"}]}`, id, tt.ping)
+					return
+				}
+				ops["ec2"]++
+				if tt.ec2State == "" {
+					w.WriteHeader(http.StatusForbidden)
+					io.WriteString(w, `<Response><Errors><Error><Code>UnauthorizedOperation</Code><Message>denied</Message></Error></Errors></Response>`)
+					return
+				}
+				w.Header().Set("Content-Type", "text/xml")
+				fmt.Fprintf(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet><item>
+					<instanceId>%s</instanceId><instanceState><name>%s</name></instanceState>
+					<tagSet><item><key>Name</key><value>web-1</value></item></tagSet>
+					</item></instancesSet></item></reservationSet></DescribeInstancesResponse>`, id, tt.ec2State)
+			}))
+			c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{NoEC2: tt.noEC2})
+
+			inst, err := c.ManagedInstance(context.Background(), id)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ManagedInstance() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || inst.ID != id || inst.Name != tt.wantName || inst.SSMStatus != "Online" {
+				t.Fatalf("ManagedInstance() = %+v, %v; want %s named %q", inst, err, id, tt.wantName)
+			}
+			// One filtered SSM call and at most one EC2 call, never discovery
+			if ops["AmazonSSM.DescribeInstanceInformation"] != 1 || len(ops) > 2 || ops["ec2"] != tt.wantEC2 {
+				t.Errorf("calls = %v, want one DescribeInstanceInformation and %d EC2", ops, tt.wantEC2)
+			}
+			if want := fmt.Sprint([]any{map[string]any{"Key": "InstanceIds", "Values": []any{id}}}); fmt.Sprint(filters) != want {
+				t.Errorf("filters = %v, want %v", filters, want)
+			}
+		})
+	}
+}
+
+func TestEnabledRegions(t *testing.T) {
+	tests := []struct {
+		region     string
+		wantSigned string
+	}{
+		{"eu-west-1", "eu-west-1"},
+		// No region configured yet: asked of regionListEndpoint
+		{"", regionListEndpoint},
+	}
+	for _, tt := range tests {
+		var signed string
+		cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			signed = signedRegion.FindStringSubmatch(r.Header.Get("Authorization"))[1]
+			w.Header().Set("Content-Type", "text/xml")
+			io.WriteString(w, `<DescribeRegionsResponse><regionInfo>
+				<item><regionName>us-west-2</regionName></item>
+				<item><regionName>eu-west-1</regionName></item>
+				<item><regionName>ap-south-1</regionName></item>
+				</regionInfo></DescribeRegionsResponse>`)
+		}))
+		cfg.Region = tt.region
+
+		got, err := EnabledRegions(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"ap-south-1", "eu-west-1", "us-west-2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("EnabledRegions() = %q, want %q", got, want)
+		}
+		if signed != tt.wantSigned {
+			t.Errorf("with region %q, asked %s, want %s", tt.region, signed, tt.wantSigned)
+		}
+	}
+}
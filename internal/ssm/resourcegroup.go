@@ -0,0 +1,75 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroups"
+	rgtypes "github.com/aws/aws-sdk-go-v2/service/resourcegroups/types"
+)
+
+// groupMembers returns the IDs of the EC2 instances in Options.ResourceGroup,
+// or nil when no group is set. Membership is resolved once per client.
+func (c *Client) groupMembers(ctx context.Context) (map[string]bool, error) {
+	if c.opts.ResourceGroup == "" {
+		return nil, nil
+	}
+	c.groupMu.Lock()
+	defer c.groupMu.Unlock()
+	if c.members != nil {
+		return c.members, nil
+	}
+
+	c.out.Debug("Resolving resource group %s...", c.opts.ResourceGroup)
+	ids, err := c.listGroupInstances(ctx, c.opts.ResourceGroup)
+	if err != nil {
+		return nil, err
+	}
+	c.members = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		c.members[id] = true
+	}
+	c.out.Debug("Resource group %s has %d instances", c.opts.ResourceGroup, len(ids))
+	return c.members, nil
+}
+
+// listGroupInstances pages through ListGroupResources for the group (a name
+// or ARN), returning the IDs of its EC2 instances.
+func (c *Client) listGroupInstances(ctx context.Context, group string) ([]string, error) {
+	paginator := resourcegroups.NewListGroupResourcesPaginator(c.rg, &resourcegroups.ListGroupResourcesInput{
+		Group: aws.String(group),
+		Filters: []rgtypes.ResourceFilter{
+			{Name: rgtypes.ResourceFilterNameResourceType, Values: []string{"AWS::EC2::Instance"}},
+		},
+	})
+	var ids []string
+	for paginator.HasMorePages() {
+		page, err := retryExpired(c, func() (*resourcegroups.ListGroupResourcesOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list resource group %s: %w", group, err)
+		}
+		for _, r := range page.Resources {
+			if r.Identifier == nil {
+				continue
+			}
+			if id := instanceIDFromARN(aws.ToString(r.Identifier.ResourceArn)); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// instanceIDFromARN returns the instance ID of an EC2 instance ARN, or ""
+// for any other ARN.
+func instanceIDFromARN(arn string) string {
+	_, id, ok := strings.Cut(arn, ":instance/")
+	if !ok {
+		return ""
+	}
+	return id
+}
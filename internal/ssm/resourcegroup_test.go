@@ -0,0 +1,81 @@
+package ssm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+)
+
+func TestGroupMembers(t *testing.T) {
+	pages := map[string]string{
+		"": `{"Resources":[
+			{"Identifier":{"ResourceArn":"arn:aws:ec2:us-east-1:123456789012:instance/i-1","ResourceType":"AWS::EC2::Instance"}},
+			{"Identifier":{"ResourceArn":"arn:aws:ec2:us-east-1:123456789012:volume/vol-1","ResourceType":"AWS::EC2::Volume"}}
+		],"NextToken":"p2"}`,
+		"p2": `{"Resources":[{"Identifier":{"ResourceArn":"arn:aws:ec2:us-east-1:123456789012:instance/i-2"}}]}`,
+	}
+	calls := 0
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/list-group-resources" {
+			t.Errorf("request to %s", r.URL.Path)
+		}
+		if !strings.Contains(r.Header.Get("Authorization"), "/resource-groups/aws4_request") {
+			t.Errorf("request not signed for resource-groups: %q", r.Header.Get("Authorization"))
+		}
+		var input struct {
+			Group     string
+			NextToken string
+			Filters   []struct{ Name string }
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			t.Fatal(err)
+		}
+		if input.Group != "web" || len(input.Filters) != 1 || input.Filters[0].Name != "resource-type" {
+			t.Errorf("unexpected input %+v", input)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(pages[input.NextToken]))
+	}))
+
+	c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{ResourceGroup: "web"})
+	for range 2 {
+		members, err := c.groupMembers(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[string]bool{"i-1": true, "i-2": true}; !reflect.DeepEqual(members, want) {
+			t.Errorf("groupMembers() = %v, want %v", members, want)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("%d calls, want 2 (two pages, resolved once)", calls)
+	}
+}
+
+func TestGroupMembersNoGroup(t *testing.T) {
+	c := NewClient(aws.Config{Region: "us-east-1"}, output.New(false, output.UnicodeGlyphs), Options{})
+	if members, err := c.groupMembers(context.Background()); members != nil || err != nil {
+		t.Errorf("groupMembers() = %v, %v; want nil, nil", members, err)
+	}
+}
+
+func TestInstanceIDFromARN(t *testing.T) {
+	tests := map[string]string{
+		"arn:aws:ec2:us-east-1:123456789012:instance/i-0abc": "i-0abc",
+		"arn:aws:ec2:us-east-1:123456789012:volume/vol-1":    "",
+		"": "",
+	}
+	for arn, want := range tests {
+		if got := instanceIDFromARN(arn); got != want {
+			t.Errorf("instanceIDFromARN(%q) = %q, want %q", arn, got, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package ssm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// envName matches a valid POSIX environment variable name.
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// shellQuote quotes s for safe use as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// tagEnvPrefix starts every variable TagExports names, which also keeps
+// names from starting with a digit.
+const tagEnvPrefix = "TAG_"
+
+// TagEnvName turns a tag key into an environment variable name: TAG_ and
+// the key upper-cased, with anything but letters, digits and _ replaced by _.
+func TagEnvName(key string) string {
+	var b strings.Builder
+	b.WriteString(tagEnvPrefix)
+	for _, r := range strings.ToUpper(key) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// TagExports returns "export NAME='value'" lines for tags, in key order.
+// Keys that sanitize to the same name (e.g. "app-name" and "app.name") get
+// _2, _3, ... suffixes in key order, so the output is deterministic.
+func TagExports(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	used := make(map[string]bool, len(keys))
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		name := TagEnvName(k)
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s_%d", TagEnvName(k), n)
+		}
+		used[name] = true
+		lines = append(lines, "export "+name+"="+shellQuote(tags[k]))
+	}
+	return lines
+}
+
+// CommandOptions adjusts how a command runs on the instance.
+type CommandOptions struct {
+	// Sudo runs the command as root via sudo sh -c.
+	Sudo bool
+	// Workdir is the directory to run the command in.
+	Workdir string
+	// Env holds KEY=VALUE variables exported for the command.
+	Env []string
+}
+
+// BuildCommand wraps command according to opts. Variables are exported
+// first, then the working directory is entered, then the command runs.
+// With Sudo all of that happens inside the root shell, so the variables and
+// directory apply to the command rather than to sudo.
+func BuildCommand(command string, opts CommandOptions) (string, error) {
+	var prefix []string
+	for _, kv := range opts.Env {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !envName.MatchString(key) {
+			return "", fmt.Errorf("invalid environment variable %q (expected KEY=VALUE)", kv)
+		}
+		prefix = append(prefix, "export "+key+"="+shellQuote(value)+";")
+	}
+	if opts.Workdir != "" {
+		prefix = append(prefix, "cd "+shellQuote(opts.Workdir)+" &&")
+	}
+
+	script := strings.Join(append(prefix, command), " ")
+	if opts.Sudo {
+		script = "sudo sh -c " + shellQuote(script)
+	}
+	return script, nil
+}
+
+// shellPath matches a shell given by name (bash) or absolute path (/bin/zsh).
+var shellPath = regexp.MustCompile(`^(/[A-Za-z0-9._+-]+)+$|^[A-Za-z0-9._+-]+$`)
+
+// ValidateShell checks that shell is a plain name or an absolute path.
+func ValidateShell(shell string) error {
+	if !shellPath.MatchString(shell) {
+		return fmt.Errorf("invalid shell %q (expected a name like bash or an absolute path like /bin/zsh)", shell)
+	}
+	return nil
+}
+
+// sessionInput returns what is typed into a new interactive session: an exec
+// of shell when it exists on the instance (otherwise the default shell stays,
+// with a note), followed by command. Either may be empty.
+func sessionInput(shell, command string) string {
+	var lines []string
+	if shell != "" {
+		q := shellQuote(shell)
+		lines = append(lines, fmt.Sprintf(
+			"if command -v %s >/dev/null 2>&1; then exec %s; else echo %s >&2; fi",
+			q, q, shellQuote("aws-ssm-connect: "+shell+" not found, staying in the default shell")))
+	}
+	if command != "" {
+		lines = append(lines, command)
+	}
+	return strings.Join(lines, "\n")
+}
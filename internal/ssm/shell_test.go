@@ -0,0 +1,201 @@
+package ssm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+)
+
+func TestTagExports(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want []string
+	}{
+		{"none", nil, []string{}},
+		{
+			"sorted by key",
+			map[string]string{"Name": "web-1", "Env": "prod"},
+			[]string{"export TAG_ENV='prod'", "export TAG_NAME='web-1'"},
+		},
+		{
+			"sanitized and quoted",
+			map[string]string{"aws:cloudformation:stack-name": "it's"},
+			[]string{`export TAG_AWS_CLOUDFORMATION_STACK_NAME='it'\''s'`},
+		},
+		{
+			"colliding names get suffixes in key order",
+			map[string]string{"app.name": "b", "app-name": "a", "APP_NAME": "c"},
+			[]string{"export TAG_APP_NAME='c'", "export TAG_APP_NAME_2='a'", "export TAG_APP_NAME_3='b'"},
+		},
+	}
+	for _, tt := range tests {
+		if got := TagExports(tt.tags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: TagExports() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		opts    CommandOptions
+		want    string
+		wantErr string
+	}{
+		{"plain", "uptime", CommandOptions{}, "uptime", ""},
+		{"sudo", "cat /etc/shadow | head -1", CommandOptions{Sudo: true}, `sudo sh -c 'cat /etc/shadow | head -1'`, ""},
+		{"sudo with quotes", `echo "it's $HOME"`, CommandOptions{Sudo: true}, `sudo sh -c 'echo "it'\''s $HOME"'`, ""},
+		{"workdir", "ls", CommandOptions{Workdir: "/var/log"}, `cd '/var/log' && ls`, ""},
+		{"env", "env", CommandOptions{Env: []string{"A=1", "B=x y"}}, `export A='1'; export B='x y'; env`, ""},
+		// Variables and directory apply inside the root shell
+		{"all", "make", CommandOptions{Sudo: true, Workdir: "/srv/app", Env: []string{"MODE=prod"}},
+			`sudo sh -c 'export MODE='\''prod'\''; cd '\''/srv/app'\'' && make'`, ""},
+		{"value with equals", "env", CommandOptions{Env: []string{"URL=a=b"}}, `export URL='a=b'; env`, ""},
+		{"missing value", "env", CommandOptions{Env: []string{"A"}}, "", `invalid environment variable "A"`},
+		{"bad name", "env", CommandOptions{Env: []string{"1A=x"}}, "", `invalid environment variable "1A=x"`},
+	}
+	for _, tt := range tests {
+		got, err := BuildCommand(tt.command, tt.opts)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: BuildCommand() = %s, %v\nwant %s", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestBuildCommandSurvivesTheShell(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	dir := t.TempDir()
+	command := `printf '%s|%s|%s' "$GREETING" "$(basename "$PWD")" 'it'"'"'s'`
+	script, err := BuildCommand(command, CommandOptions{Sudo: true, Workdir: dir, Env: []string{"GREETING=hello world"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Run the root shell without sudo itself
+	script = strings.Replace(script, "sudo sh -c", "sh -c", 1)
+	want := "hello world|" + filepath.Base(dir) + "|it's"
+	if got := runShell(t, script); got != want {
+		t.Errorf("ran %s\ngot %q, want %q", script, got, want)
+	}
+}
+
+func TestSudoRefusedOnWindows(t *testing.T) {
+	var sent bool
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.DescribeInstanceInformation":
+			io.WriteString(w, `{"InstanceInformationList":[{"InstanceId":"i-win","PingStatus":"Online","PlatformType":"Windows"}]}`)
+		case "AmazonSSM.SendCommand":
+			sent = true
+			io.WriteString(w, `{"Command":{"CommandId":"cmd-1"}}`)
+		default:
+			io.WriteString(w, `{}`)
+		}
+	}))
+	c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{Command: CommandOptions{Sudo: true}})
+
+	_, err := c.Dispatch(context.Background(), "i-win", "whoami")
+	if err == nil || !strings.Contains(err.Error(), "--sudo is not supported on Windows") {
+		t.Errorf("Dispatch() error = %v, want a Windows refusal", err)
+	}
+	if sent {
+		t.Error("command was sent")
+	}
+}
+
+func TestValidateShell(t *testing.T) {
+	tests := []struct {
+		shell string
+		ok    bool
+	}{
+		{"bash", true},
+		{"zsh", true},
+		{"/bin/zsh", true},
+		{"/usr/local/bin/fish-3.7", true},
+		{"", false},
+		{"bin/bash", false},
+		{"/bin/", false},
+		{"bash -l", false},
+		{"bash;rm -rf /", false},
+		{"$(id)", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateShell(tt.shell); (err == nil) != tt.ok {
+			t.Errorf("ValidateShell(%q) = %v, want ok %t", tt.shell, err, tt.ok)
+		}
+	}
+}
+
+func TestSessionInput(t *testing.T) {
+	switchTo := func(shell string) string {
+		return "if command -v '" + shell + "' >/dev/null 2>&1; then exec '" + shell + "'; " +
+			"else echo 'aws-ssm-connect: " + shell + " not found, staying in the default shell' >&2; fi"
+	}
+	tests := []struct {
+		name, shell, command string
+		want                 string
+	}{
+		{"neither", "", "", ""},
+		{"exec only", "", "sudo su -", "sudo su -"},
+		{"shell only", "zsh", "", switchTo("zsh")},
+		// The command is typed into the new shell
+		{"both", "/bin/zsh", "cd /srv", switchTo("/bin/zsh") + "\ncd /srv"},
+	}
+	for _, tt := range tests {
+		if got := sessionInput(tt.shell, tt.command); got != tt.want {
+			t.Errorf("%s: sessionInput() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSessionInputSurvivesTheShell(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	// The replacement shell only reports that it was started
+	fake := filepath.Join(t.TempDir(), "fake-shell")
+	if err := os.WriteFile(fake, []byte("#!"+sh+"\necho switched\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, shell            string
+		wantStdout, wantStderr string
+	}{
+		{"present", fake, "switched\n", ""},
+		// The session stays in the default shell and the command still runs
+		{"missing", "no-such-shell", "ran\n", "aws-ssm-connect: no-such-shell not found, staying in the default shell\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(sh)
+			cmd.Stdin = strings.NewReader(sessionInput(tt.shell, "echo ran") + "\n")
+			var stdout, stderr strings.Builder
+			cmd.Stdout, cmd.Stderr = &stdout, &stderr
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("%v: %s", err, stderr.String())
+			}
+			if stdout.String() != tt.wantStdout || stderr.String() != tt.wantStderr {
+				t.Errorf("stdout %q, stderr %q; want %q, %q", stdout.String(), stderr.String(), tt.wantStdout, tt.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+const sshSessionDocument = "AWS-StartSSHSession"
+
+// ProxySSH tunnels the process's stdin/stdout to the instance's SSH port.
+// It is meant to be used as an ssh ProxyCommand, so it prints nothing to stdout.
+func (c *Client) ProxySSH(ctx context.Context, instanceID, profile string, port int) error {
+	return c.runPlugin(ctx, &ssm.StartSessionInput{
+		Target:       aws.String(instanceID),
+		DocumentName: aws.String(sshSessionDocument),
+		Parameters: map[string][]string{
+			"portNumber": {strconv.Itoa(port)},
+		},
+	}, profile, streamsStdio)
+}
+
+// StartSOCKSProxy opens a SOCKS5 proxy on localhost:port that routes traffic
+// through the instance, using ssh -D over an SSM SSH session.
+// It blocks until ssh exits.
+func (c *Client) StartSOCKSProxy(ctx context.Context, instanceID, instanceName, profile, sshUser string, port int) error {
+	sshPath, err := exec.LookPath("ssh")
+	if err != nil {
+		return fmt.Errorf("ssh not found (--socks requires an OpenSSH client): %w", err)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable for ssh ProxyCommand: %w", err)
+	}
+
+	// ssh runs ProxyCommand through a shell, substituting %h and %p
+	proxyCommand := shellQuote(self) + " proxy --region " + shellQuote(c.cfg.Region)
+	if profile != "" {
+		proxyCommand += " --profile " + shellQuote(profile)
+	}
+	if c.opts.Reason != "" {
+		proxyCommand += " --reason " + shellQuote(c.opts.Reason)
+	}
+	proxyCommand += " %h %p"
+
+	c.recordHistory(instanceID, instanceName)
+
+	args := []string{
+		"-N",
+		"-D", fmt.Sprintf("127.0.0.1:%d", port),
+		"-o", "ProxyCommand=" + proxyCommand,
+		"-o", "ExitOnForwardFailure=yes",
+		sshUser + "@" + instanceID,
+	}
+	c.out.Debug("Running: %s %v", sshPath, args)
+	c.out.Info("SOCKS5 proxy on 127.0.0.1:%d via %s (Ctrl-C to stop)", port, instanceID)
+
+	cmd := exec.CommandContext(ctx, sshPath, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ssh exited: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package ssm
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	// stagingPrefix is the key prefix of objects staged for -copy --via-s3.
+	stagingPrefix = "aws-ssm-connect/"
+	// stagingURLExpiry bounds how long the instance may fetch a staged object.
+	stagingURLExpiry = 5 * time.Minute
+)
+
+// uploadViaS3 stages data in Options.StagingBucket and has the instance
+// fetch it with a presigned URL, so the content never appears in the
+// command. The object is deleted afterwards, whatever the outcome.
+func (c *Client) uploadViaS3(ctx context.Context, data []byte, instanceID, remotePath string) (*CommandResult, error) {
+	key, err := stagingKey(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	bucket := c.opts.StagingBucket
+	c.out.Debug("Staging %d bytes at s3://%s/%s...", len(data), bucket, key)
+	if err := c.putObject(ctx, bucket, key, data); err != nil {
+		return nil, fmt.Errorf("failed to stage upload in s3://%s: %w", bucket, err)
+	}
+	defer func() {
+		// The upload context may already be canceled; cleanup still runs.
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		if err := c.deleteObject(cleanupCtx, bucket, key); err != nil {
+			c.out.Warning("Could not delete staged object s3://%s/%s: %v", bucket, key, err)
+		}
+	}()
+
+	fetchURL, err := c.presignGet(ctx, bucket, key, stagingURLExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign s3://%s/%s: %w", bucket, key, err)
+	}
+	return c.runScript(ctx, instanceID, stagedScript(fetchURL, remotePath))
+}
+
+// stagedScript builds the remote script that downloads fetchURL into
+// remotePath with curl, or wget when curl is missing.
+func stagedScript(fetchURL, remotePath string) string {
+	u, dst := shellQuote(fetchURL), shellQuote(remotePath)
+	return fmt.Sprintf("if command -v curl >/dev/null 2>&1; then curl -fsS -o %s %s; else wget -q -O %s %s; fi",
+		dst, u, dst, u)
+}
+
+// stagingKey returns a fresh, unguessable object key for an upload to instanceID.
+func stagingKey(instanceID string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return stagingPrefix + instanceID + "/" + hex.EncodeToString(b), nil
+}
+
+// putObject uploads data as an object.
+func (c *Client) putObject(ctx context.Context, bucket, key string, data []byte) error {
+	_, err := retryExpired(c, func() (*s3.PutObjectOutput, error) {
+		return c.s3.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(key),
+			Body:          bytes.NewReader(data),
+			ContentLength: aws.Int64(int64(len(data))),
+		})
+	})
+	return err
+}
+
+// deleteObject removes an object.
+func (c *Client) deleteObject(ctx context.Context, bucket, key string) error {
+	_, err := retryExpired(c, func() (*s3.DeleteObjectOutput, error) {
+		return c.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+	})
+	return err
+}
+
+// presignGet returns a GET URL for an object that is valid for expiry.
+func (c *Client) presignGet(ctx context.Context, bucket, key string, expiry time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(c.s3).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
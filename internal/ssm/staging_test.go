@@ -0,0 +1,142 @@
+package ssm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+)
+
+func TestStagedScript(t *testing.T) {
+	got := stagedScript("https://b.s3.amazonaws.com/k?X-Amz-Signature=a&b=c", "/tmp/it's")
+	want := `if command -v curl >/dev/null 2>&1; then curl -fsS -o '/tmp/it'\''s' 'https://b.s3.amazonaws.com/k?X-Amz-Signature=a&b=c'; ` +
+		`else wget -q -O '/tmp/it'\''s' 'https://b.s3.amazonaws.com/k?X-Amz-Signature=a&b=c'; fi`
+	if got != want {
+		t.Errorf("stagedScript() = %q, want %q", got, want)
+	}
+}
+
+func TestStagingKey(t *testing.T) {
+	a, err := stagingKey("i-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := stagingKey("i-123")
+	if !strings.HasPrefix(a, stagingPrefix+"i-123/") || a == b {
+		t.Errorf("stagingKey() = %q then %q, want fresh keys under %si-123/", a, b, stagingPrefix)
+	}
+}
+
+// fakeStaging serves S3 object requests and the SSM calls of runScript.
+type fakeStaging struct {
+	mu sync.Mutex
+	// objects maps the path of each stored object to its content.
+	objects map[string]string
+	// deleted lists the paths of deleted objects.
+	deleted []string
+	// script is the command sent to the instance.
+	script string
+	// sendStatus fails SendCommand with this status when set.
+	sendStatus int
+}
+
+func (f *fakeStaging) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		var body struct{ Parameters map[string][]string }
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch target {
+		case "AmazonSSM.SendCommand":
+			if f.sendStatus != 0 {
+				w.WriteHeader(f.sendStatus)
+				w.Write([]byte(`{"__type":"InvalidInstanceId","message":"not connected"}`))
+				return
+			}
+			f.script = body.Parameters["commands"][0]
+			w.Write([]byte(`{"Command":{"CommandId":"cmd-1"}}`))
+		case "AmazonSSM.GetCommandInvocation":
+			w.Write([]byte(`{"Status":"Success","ResponseCode":0}`))
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(data)
+	case http.MethodDelete:
+		f.deleted = append(f.deleted, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestUploadViaS3(t *testing.T) {
+	fake := &fakeStaging{objects: map[string]string{}}
+	c := NewClient(testConfig(t, fake), output.New(false, output.UnicodeGlyphs), Options{StagingBucket: "stage"})
+
+	result, err := c.uploadViaS3(context.Background(), []byte("payload"), "i-123", "/tmp/dest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("exit code %d", result.ExitCode)
+	}
+
+	if len(fake.objects) != 1 {
+		t.Fatalf("stored %d objects, want 1", len(fake.objects))
+	}
+	var path string
+	for p, data := range fake.objects {
+		path = p
+		if data != "payload" {
+			t.Errorf("stored %q, want %q", data, "payload")
+		}
+	}
+	if !strings.HasPrefix(path, "/stage/"+stagingPrefix+"i-123/") {
+		t.Errorf("object stored at %s", path)
+	}
+
+	// The command fetches the presigned URL of the staged object
+	if !strings.Contains(fake.script, "-o '/tmp/dest'") {
+		t.Errorf("script does not write /tmp/dest: %s", fake.script)
+	}
+	start := strings.Index(fake.script, "'http")
+	if start < 0 {
+		t.Fatalf("no URL in script: %s", fake.script)
+	}
+	rawURL, _, _ := strings.Cut(fake.script[start+1:], "'")
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != path || q.Get("X-Amz-Signature") == "" || q.Get("X-Amz-Expires") != "300" {
+		t.Errorf("script fetches %s, want a presigned URL for %s valid 300s", rawURL, path)
+	}
+
+	if len(fake.deleted) != 1 || fake.deleted[0] != path {
+		t.Errorf("deleted %v, want [%s]", fake.deleted, path)
+	}
+}
+
+func TestUploadViaS3CleansUpOnFailure(t *testing.T) {
+	fake := &fakeStaging{objects: map[string]string{}, sendStatus: http.StatusBadRequest}
+	c := NewClient(testConfig(t, fake), output.New(false, output.UnicodeGlyphs), Options{StagingBucket: "stage"})
+
+	if _, err := c.uploadViaS3(context.Background(), []byte("payload"), "i-123", "/tmp/dest"); err == nil {
+		t.Fatal("upload succeeded although SendCommand failed")
+	}
+	if len(fake.deleted) != 1 {
+		t.Fatalf("deleted %v, want the staged object", fake.deleted)
+	}
+	if _, ok := fake.objects[fake.deleted[0]]; !ok {
+		t.Errorf("deleted %s, which was never staged", fake.deleted[0])
+	}
+}
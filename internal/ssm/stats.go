@@ -0,0 +1,17 @@
+package ssm
+
+import "time"
+
+// TransferStats describes a finished upload or download.
+type TransferStats struct {
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+// BytesPerSecond returns the average throughput, or 0 when nothing was timed.
+func (s TransferStats) BytesPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / s.Elapsed.Seconds()
+}
@@ -0,0 +1,82 @@
+package ssm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBytesPerSecond(t *testing.T) {
+	tests := []struct {
+		stats TransferStats
+		want  float64
+	}{
+		{TransferStats{Bytes: 1000, Elapsed: time.Second}, 1000},
+		{TransferStats{Bytes: 1000, Elapsed: 500 * time.Millisecond}, 2000},
+		{TransferStats{Bytes: 0, Elapsed: time.Second}, 0},
+		// Untimed transfers have no rate rather than an infinite one
+		{TransferStats{Bytes: 1000}, 0},
+		{TransferStats{Bytes: 1000, Elapsed: -time.Second}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.stats.BytesPerSecond(); got != tt.want {
+			t.Errorf("%+v.BytesPerSecond() = %v, want %v", tt.stats, got, tt.want)
+		}
+	}
+}
+
+func TestTimingPopulated(t *testing.T) {
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.txt")
+	content := "timed content\n"
+	c := shellClient(t, Options{})
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		run       func() (bytes int64, elapsed time.Duration, err error)
+		wantBytes int64
+	}{
+		{"upload", func() (int64, time.Duration, error) {
+			stats, err := c.UploadReader(ctx, strings.NewReader(content), "stdin", "i-1", remote, nil)
+			return stats.Bytes, stats.Elapsed, err
+		}, int64(len(content))},
+		{"download", func() (int64, time.Duration, error) {
+			_, stats, err := c.DownloadFile(ctx, "i-1", remote, filepath.Join(dir, "local.txt"), nil)
+			return stats.Bytes, stats.Elapsed, err
+		}, int64(len(content))},
+		{"run", func() (int64, time.Duration, error) {
+			result, err := c.Run(ctx, "i-1", "true")
+			if err != nil {
+				return 0, 0, err
+			}
+			return 0, result.Elapsed, nil
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				bytes   int64
+				elapsed time.Duration
+				err     error
+			)
+			captureOutput(t, func() { bytes, elapsed, err = tt.run() })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes != tt.wantBytes {
+				t.Errorf("bytes = %d, want %d", bytes, tt.wantBytes)
+			}
+			// Each goes through a real round trip to the fake endpoint
+			if elapsed <= 0 {
+				t.Errorf("elapsed = %v, want a positive duration", elapsed)
+			}
+		})
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "local.txt")); err != nil || string(data) != content {
+		t.Errorf("downloaded %q, %v", data, err)
+	}
+}
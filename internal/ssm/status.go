@@ -0,0 +1,103 @@
+package ssm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// Invocation is the state of a sent command on one instance.
+type Invocation struct {
+	InstanceID string `json:"instance_id"`
+	// Status is Pending, InProgress, Success, Failed, TimedOut, Cancelled, ...
+	Status string `json:"status"`
+	// Done is set once the command has finished, whatever the outcome.
+	Done bool `json:"done"`
+	// ExitCode is the command's exit status; -1 while it is still running.
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+}
+
+// CommandStatus looks up a command sent earlier (e.g. with Dispatch) and
+// returns its state on every targeted instance, with the output so far.
+// A non-empty instanceID limits the lookup to that instance.
+func (c *Client) CommandStatus(ctx context.Context, commandID, instanceID string) ([]Invocation, error) {
+	if instanceID != "" {
+		inv, err := c.invocation(ctx, commandID, instanceID)
+		if err != nil {
+			return nil, err
+		}
+		return []Invocation{inv}, nil
+	}
+
+	var instanceIDs []string
+	paginator := ssm.NewListCommandInvocationsPaginator(c.ssm, &ssm.ListCommandInvocationsInput{
+		CommandId: aws.String(commandID),
+	})
+	for paginator.HasMorePages() {
+		page, err := retryExpired(c, func() (*ssm.ListCommandInvocationsOutput, error) {
+			return paginator.NextPage(ctx)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list invocations of %s: %w", commandID, err)
+		}
+		for _, inv := range page.CommandInvocations {
+			instanceIDs = append(instanceIDs, aws.ToString(inv.InstanceId))
+		}
+	}
+	if len(instanceIDs) == 0 {
+		return nil, fmt.Errorf("command %s not found (commands are kept for 30 days, in the region they were sent to)", commandID)
+	}
+
+	invocations := make([]Invocation, 0, len(instanceIDs))
+	for _, id := range instanceIDs {
+		inv, err := c.invocation(ctx, commandID, id)
+		if err != nil {
+			return nil, err
+		}
+		invocations = append(invocations, inv)
+	}
+	return invocations, nil
+}
+
+// invocation returns the state of the command on one instance.
+func (c *Client) invocation(ctx context.Context, commandID, instanceID string) (Invocation, error) {
+	result, err := retryExpired(c, func() (*ssm.GetCommandInvocationOutput, error) {
+		return c.ssm.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
+			CommandId:  aws.String(commandID),
+			InstanceId: aws.String(instanceID),
+		})
+	})
+	if err != nil {
+		var missing *ssmtypes.InvocationDoesNotExist
+		if errors.As(err, &missing) {
+			return Invocation{}, fmt.Errorf("command %s was not sent to %s (or has expired)", commandID, instanceID)
+		}
+		return Invocation{}, fmt.Errorf("failed to get command result on %s: %w", instanceID, err)
+	}
+	return Invocation{
+		InstanceID: instanceID,
+		Status:     string(result.Status),
+		Done:       invocationDone(result.Status),
+		ExitCode:   int(result.ResponseCode),
+		Stdout:     aws.ToString(result.StandardOutputContent),
+		Stderr:     aws.ToString(result.StandardErrorContent),
+	}, nil
+}
+
+// invocationDone reports whether status is final.
+func invocationDone(status ssmtypes.CommandInvocationStatus) bool {
+	switch status {
+	case ssmtypes.CommandInvocationStatusPending,
+		ssmtypes.CommandInvocationStatusInProgress,
+		ssmtypes.CommandInvocationStatusDelayed,
+		ssmtypes.CommandInvocationStatusCancelling:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package ssm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+)
+
+// commandClient returns a client whose SSM calls are answered by handler
+// with the operation name, e.g. "SendCommand", and the decoded request body.
+// A handler returning a string answers with that error code.
+func commandClient(t *testing.T, handler func(op string, body map[string]any) any) *Client {
+	t.Helper()
+	cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		_, op, _ := strings.Cut(r.Header.Get("X-Amz-Target"), ".")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		resp := handler(op, body)
+		if code, ok := resp.(string); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": code, "Message": code})
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	return NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{Quiet: true})
+}
+
+func TestDispatch(t *testing.T) {
+	var ops []string
+	var sent map[string]any
+	c := commandClient(t, func(op string, body map[string]any) any {
+		ops = append(ops, op)
+		switch op {
+		case "SendCommand":
+			sent = body
+			return map[string]any{"Command": map[string]any{"CommandId": "cmd-1"}}
+		case "DescribeDocument":
+			return map[string]any{"Document": map[string]any{"Name": runShellDocument}}
+		}
+		return map[string]any{}
+	})
+	var id string
+	var err error
+	captureOutput(t, func() { id, err = c.Dispatch(context.Background(), "i-1", "sleep 600") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "cmd-1" {
+		t.Errorf("Dispatch() = %q, want cmd-1", id)
+	}
+	if got := sent["Parameters"].(map[string]any)["commands"]; !reflect.DeepEqual(got, []any{"sleep 600"}) {
+		t.Errorf("sent commands %v, want [sleep 600]", got)
+	}
+	// Fire and forget: nothing is polled
+	for _, op := range ops {
+		if op == "GetCommandInvocation" {
+			t.Errorf("Dispatch() polled the command: %q", ops)
+		}
+	}
+}
+
+func TestCommandStatus(t *testing.T) {
+	invocations := map[string]map[string]any{
+		"i-1": {"Status": "Success", "ResponseCode": 0, "StandardOutputContent": "done\n"},
+		"i-2": {"Status": "InProgress", "ResponseCode": -1, "StandardOutputContent": "half"},
+		"i-3": {"Status": "Failed", "ResponseCode": 2, "StandardErrorContent": "oops\n"},
+	}
+	tests := []struct {
+		name      string
+		commandID string
+		instance  string
+		want      []Invocation
+		wantErr   string
+	}{
+		{
+			name: "all instances", commandID: "cmd-1",
+			want: []Invocation{
+				{InstanceID: "i-1", Status: "Success", Done: true, ExitCode: 0, Stdout: "done\n"},
+				{InstanceID: "i-2", Status: "InProgress", Done: false, ExitCode: -1, Stdout: "half"},
+				{InstanceID: "i-3", Status: "Failed", Done: true, ExitCode: 2, Stderr: "oops\n"},
+			},
+		},
+		{
+			name: "one instance", commandID: "cmd-1", instance: "i-3",
+			want: []Invocation{{InstanceID: "i-3", Status: "Failed", Done: true, ExitCode: 2, Stderr: "oops\n"}},
+		},
+		{name: "unknown command", commandID: "cmd-x", wantErr: "command cmd-x not found"},
+		{name: "not sent to instance", commandID: "cmd-1", instance: "i-9", wantErr: "was not sent to i-9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := commandClient(t, func(op string, body map[string]any) any {
+				if body["CommandId"] != "cmd-1" {
+					if op == "ListCommandInvocations" {
+						return map[string]any{}
+					}
+					return "InvalidCommandId"
+				}
+				switch op {
+				case "ListCommandInvocations":
+					// Two pages, to check they are all listed
+					if body["NextToken"] == nil {
+						return map[string]any{"CommandInvocations": []map[string]any{{"InstanceId": "i-1"}, {"InstanceId": "i-2"}}, "NextToken": "p2"}
+					}
+					return map[string]any{"CommandInvocations": []map[string]any{{"InstanceId": "i-3"}}}
+				case "GetCommandInvocation":
+					inv, ok := invocations[body["InstanceId"].(string)]
+					if !ok {
+						return "InvocationDoesNotExist"
+					}
+					return inv
+				}
+				return map[string]any{}
+			})
+			got, err := c.CommandStatus(context.Background(), tt.commandID, tt.instance)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CommandStatus() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CommandStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package ssm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// stdinDrainDelay bounds how long we wait for the stdin copier after the
+// plugin exits; it is usually blocked on a tty read that will never come.
+const stdinDrainDelay = 100 * time.Millisecond
+
+// prefixedInput returns a reader that yields command (newline-terminated)
+// followed by everything read from r.
+func prefixedInput(command string, r io.Reader) io.Reader {
+	if !strings.HasSuffix(command, "\n") {
+		command += "\n"
+	}
+	return io.MultiReader(strings.NewReader(command), r)
+}
+
+// execPluginWithInput runs the plugin on /dev/tty like execPlugin, but sends
+// command to the remote shell before handing input over to the user.
+func execPluginWithInput(pluginPath string, sess *PluginSession, command string) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open /dev/tty: %w", err)
+	}
+	defer tty.Close()
+
+	// The plugin only puts its stdin into raw mode when it is a terminal,
+	// so do it ourselves now that stdin is a pipe.
+	state, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return fmt.Errorf("failed to set terminal mode: %w", err)
+	}
+	defer func() { _ = term.Restore(int(tty.Fd()), state) }()
+
+	cmd := exec.Command(pluginPath, sess.PluginArgs[1:]...)
+	cmd.Stdin = prefixedInput(command, tty)
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	cmd.WaitDelay = stdinDrainDelay
+	err = cmd.Run()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		return nil
+	}
+	return err
+}
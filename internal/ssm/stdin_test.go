@@ -0,0 +1,38 @@
+package ssm
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestPrefixedInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		typed   string
+		want    string
+	}{
+		{"adds the newline", "sudo su -", "ls\n", "sudo su -\nls\n"},
+		{"keeps an existing newline", "cd /var/log\n", "tail -f syslog\n", "cd /var/log\ntail -f syslog\n"},
+		{"nothing typed", "uptime", "", "uptime\n"},
+	}
+	for _, tt := range tests {
+		got, err := io.ReadAll(prefixedInput(tt.command, strings.NewReader(tt.typed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: read %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPrefixedInputPassesThroughInteractiveReads(t *testing.T) {
+	// User input arriving a byte at a time still follows the command
+	r := prefixedInput("id", iotest.OneByteReader(strings.NewReader("exit\n")))
+	if err := iotest.TestReader(r, []byte("id\nexit\n")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,75 @@
+package ssm
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// tailInterval is the slowest poll rate while streaming command output.
+// SSM refreshes partial output on its own cadence, so streaming is best-effort.
+const tailInterval = time.Second
+
+// outputTail prints only the part of a command's growing output that has
+// not been printed yet.
+type outputTail struct {
+	stdout, stderr io.Writer
+	outN, errN     int
+}
+
+// update writes the new suffix of stdout and stderr since the last call.
+func (t *outputTail) update(stdout, stderr string) {
+	t.outN = writeDelta(t.stdout, stdout, t.outN)
+	t.errN = writeDelta(t.stderr, stderr, t.errN)
+}
+
+// writeDelta writes content[printed:] to w and returns the new offset.
+// Content shorter than what was already printed (e.g. SSM truncation) is ignored.
+func writeDelta(w io.Writer, content string, printed int) int {
+	if len(content) <= printed {
+		return printed
+	}
+	_, _ = io.WriteString(w, content[printed:])
+	return len(content)
+}
+
+// LastLines returns the last n lines of s and how many lines were dropped.
+// A trailing newline does not start another line. n <= 0 keeps everything.
+func LastLines(s string, n int) (string, int) {
+	if n <= 0 {
+		return s, 0
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n")
+	if len(lines) <= n {
+		return s, 0
+	}
+	dropped := len(lines) - n
+	return s[len(strings.Join(lines[:dropped], "")):], dropped
+}
+
+// idleWatch detects command output that has stopped changing.
+type idleWatch struct {
+	interval   time.Duration
+	last       string
+	lastChange time.Time
+	// fired is set once the output has been idle for interval.
+	fired bool
+}
+
+func newIdleWatch(interval time.Duration, now time.Time) *idleWatch {
+	return &idleWatch{interval: interval, lastChange: now}
+}
+
+// stalled records the latest output and reports whether it has been
+// unchanged for at least the interval.
+func (w *idleWatch) stalled(output string, now time.Time) bool {
+	if output != w.last {
+		w.last = output
+		w.lastChange = now
+		return false
+	}
+	if now.Sub(w.lastChange) >= w.interval {
+		w.fired = true
+	}
+	return w.fired
+}
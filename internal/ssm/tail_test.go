@@ -0,0 +1,149 @@
+package ssm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/output"
+)
+
+func TestLastLines(t *testing.T) {
+	tests := []struct {
+		s           string
+		n           int
+		want        string
+		wantDropped int
+	}{
+		{"a\nb\nc\n", 0, "a\nb\nc\n", 0},
+		{"a\nb\nc\n", -1, "a\nb\nc\n", 0},
+		{"a\nb\nc\n", 3, "a\nb\nc\n", 0},
+		{"a\nb\nc\n", 5, "a\nb\nc\n", 0},
+		{"a\nb\nc\n", 2, "b\nc\n", 1},
+		{"a\nb\nc", 1, "c", 2},
+		{"a\n\nc\n", 2, "\nc\n", 1},
+		{"", 2, "", 0},
+	}
+	for _, tt := range tests {
+		got, dropped := LastLines(tt.s, tt.n)
+		if got != tt.want || dropped != tt.wantDropped {
+			t.Errorf("LastLines(%q, %d) = (%q, %d), want (%q, %d)", tt.s, tt.n, got, dropped, tt.want, tt.wantDropped)
+		}
+	}
+}
+
+func TestOutputTailPrintsGrowingOutputOnce(t *testing.T) {
+	var stdout, stderr strings.Builder
+	tail := &outputTail{stdout: &stdout, stderr: &stderr}
+
+	// Successive polls of GetCommandInvocation
+	polls := []struct{ stdout, stderr string }{
+		{"", ""},
+		{"line 1\n", ""},
+		{"line 1\n", ""},
+		{"line 1\nline 2\npart", "warn\n"},
+		// SSM truncated or lagging content never re-prints or rewinds
+		{"line 1\n", "warn\n"},
+		{"line 1\nline 2\npartial done\n", "warn\n"},
+	}
+	for _, p := range polls {
+		tail.update(p.stdout, p.stderr)
+	}
+
+	if got, want := stdout.String(), "line 1\nline 2\npartial done\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+	if got, want := stderr.String(), "warn\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+}
+
+func TestIdleWatch(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	type poll struct {
+		after  time.Duration
+		output string
+		want   bool
+	}
+	tests := []struct {
+		name  string
+		polls []poll
+	}{
+		{"growing output never stalls", []poll{{10 * time.Second, "a", false}, {20 * time.Second, "ab", false}, {30 * time.Second, "abc", false}}},
+		{"stalls after the interval", []poll{{time.Second, "a", false}, {5 * time.Second, "a", false}, {11 * time.Second, "a", true}}},
+		{"no output at all stalls", []poll{{5 * time.Second, "", false}, {10 * time.Second, "", true}}},
+		{"once fired, unchanged output is stalled at once", []poll{{10 * time.Second, "", true}, {11 * time.Second, "late", false}, {12 * time.Second, "late", true}}},
+	}
+	for _, tt := range tests {
+		w := newIdleWatch(10*time.Second, start)
+		for i, p := range tt.polls {
+			if got := w.stalled(p.output, start.Add(p.after)); got != p.want {
+				t.Errorf("%s: poll %d stalled = %t, want %t", tt.name, i, got, p.want)
+			}
+		}
+	}
+
+	// Late output does not undo a cancellation already decided
+	w := newIdleWatch(10*time.Second, start)
+	w.stalled("", start.Add(10*time.Second))
+	if w.stalled("late", start.Add(11*time.Second)); !w.fired {
+		t.Error("fired was reset by late output")
+	}
+}
+
+func TestRunCommandKillOnIdle(t *testing.T) {
+	tests := []struct {
+		name string
+		// outputs answer successive polls; the last one repeats
+		outputs    []string
+		wantErr    string
+		wantCancel bool
+	}{
+		{"stalled output is cancelled", []string{"waiting for input"}, "command cancelled after 1ms without new output", true},
+		{"finishing command is not", []string{"step 1", "done"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polls, cancelled := 0, false
+			cfg := testConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+				switch r.Header.Get("X-Amz-Target") {
+				case "AmazonSSM.SendCommand":
+					io.WriteString(w, `{"Command":{"CommandId":"cmd-1"}}`)
+				case "AmazonSSM.GetCommandInvocation":
+					out := tt.outputs[min(polls, len(tt.outputs)-1)]
+					polls++
+					status := "InProgress"
+					if out == "done" {
+						status = "Success"
+					}
+					json.NewEncoder(w).Encode(map[string]any{"Status": status, "StandardOutputContent": out})
+				case "AmazonSSM.CancelCommand":
+					cancelled = true
+					io.WriteString(w, `{}`)
+				case "AmazonSSM.DescribeDocument":
+					io.WriteString(w, `{"Document":{"Name":"AWS-RunShellScript","PlatformTypes":["Linux"]}}`)
+				default:
+					io.WriteString(w, `{}`)
+				}
+			}))
+			c := NewClient(cfg, output.New(false, output.UnicodeGlyphs), Options{Quiet: true, KillOnIdle: time.Millisecond})
+
+			_, err := c.runCommand(context.Background(), "i-1", "read answer", nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("runCommand() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("runCommand() error = %v", err)
+			}
+			if cancelled != tt.wantCancel {
+				t.Errorf("CancelCommand called = %t, want %t", cancelled, tt.wantCancel)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package ssm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/e/aws-ssm-connect/internal/selector"
+)
+
+// pathField matches a placeholder in a remote path: {id}, {name} or
+// {tag:Key}, each with an optional |default. Other braces, such as a
+// directory literally named "{a,b}", are left alone.
+var pathField = regexp.MustCompile(`\{(id|name|tag:[^{}|]+)(?:\|([^{}]*))?\}`)
+
+// IsPathTemplate reports whether a remote path contains placeholders.
+func IsPathTemplate(p string) bool {
+	return pathField.MatchString(p)
+}
+
+// ExpandPath fills placeholders in a remote path from the target instance:
+// {id}, {name} and {tag:Key}. A default after | is used when the value is
+// empty or the tag is missing, e.g. {tag:Service|app}; without one a missing
+// value is an error. Braces around anything else are kept as they are.
+func ExpandPath(p string, inst selector.Instance) (string, error) {
+	var expandErr error
+	expanded := pathField.ReplaceAllStringFunc(p, func(m string) string {
+		field, def, hasDefault := strings.Cut(m[1:len(m)-1], "|")
+		var value string
+		switch field {
+		case "id":
+			value = inst.ID
+		case "name":
+			value = inst.Name
+		default:
+			value = inst.Tags[strings.TrimPrefix(field, "tag:")]
+		}
+		if value == "" {
+			if !hasDefault {
+				if expandErr == nil {
+					expandErr = fmt.Errorf("%s is not set on %s (add a default, e.g. {%s|value})", field, inst.ID, field)
+				}
+				return m
+			}
+			value = def
+		}
+		return value
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return expanded, nil
+}
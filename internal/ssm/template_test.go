@@ -0,0 +1,48 @@
+package ssm
+
+import (
+	"testing"
+
+	"github.com/e/aws-ssm-connect/internal/selector"
+)
+
+func TestExpandPath(t *testing.T) {
+	inst := selector.Instance{
+		ID:   "i-0abc",
+		Name: "web-1",
+		Tags: map[string]string{"Service": "api", "Empty": ""},
+	}
+	tests := []struct {
+		path     string
+		want     string
+		template bool
+		wantErr  bool
+	}{
+		{"/srv/{tag:Service}/{name}/{id}.log", "/srv/api/web-1/i-0abc.log", true, false},
+		{"/srv/{tag:Team|ops}/x", "/srv/ops/x", true, false},
+		{"/srv/{tag:Empty|none}/x", "/srv/none/x", true, false},
+		{"/srv/{tag:Service|ops}/x", "/srv/api/x", true, false},
+		{"/srv/{tag:Team}/x", "", true, true},
+
+		// Braces that are not placeholders stay literal
+		{"/tmp/{a,b}/file", "/tmp/{a,b}/file", false, false},
+		{"/tmp/{}/file", "/tmp/{}/file", false, false},
+		{"/tmp/{host}/file", "/tmp/{host}/file", false, false},
+		{"/tmp/{tag:}/file", "/tmp/{tag:}/file", false, false},
+		{"/tmp/${HOME}/{name}", "/tmp/${HOME}/web-1", true, false},
+		{"/tmp/plain", "/tmp/plain", false, false},
+	}
+	for _, tt := range tests {
+		if got := IsPathTemplate(tt.path); got != tt.template {
+			t.Errorf("IsPathTemplate(%q) = %t, want %t", tt.path, got, tt.template)
+		}
+		got, err := ExpandPath(tt.path, inst)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExpandPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ExpandPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
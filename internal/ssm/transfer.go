@@ -0,0 +1,39 @@
+package ssm
+
+// TransferCommands override the remote commands used to move file content.
+// Both filter stdin to stdout. Empty fields are detected on the instance.
+type TransferCommands struct {
+	// Decode turns base64 into bytes, e.g. "openssl base64 -d -A".
+	Decode string
+	// Encode turns bytes into base64, e.g. "openssl base64 -A".
+	Encode string
+}
+
+// Fallbacks when base64 is missing from the instance.
+const (
+	fallbackDecode = "openssl base64 -d -A"
+	fallbackEncode = "openssl base64 -A"
+)
+
+// decodeCommand returns the remote base64 decoder. Without an override it
+// probes for base64 at run time and falls back to openssl.
+func (t TransferCommands) decodeCommand() string {
+	if t.Decode != "" {
+		return t.Decode
+	}
+	return probeCommand("base64 -d", fallbackDecode)
+}
+
+// encodeCommand returns the remote base64 encoder, like decodeCommand.
+func (t TransferCommands) encodeCommand() string {
+	if t.Encode != "" {
+		return t.Encode
+	}
+	return probeCommand("base64", fallbackEncode)
+}
+
+// probeCommand builds a shell group that runs preferred when base64 exists
+// on the instance, and fallback otherwise.
+func probeCommand(preferred, fallback string) string {
+	return "{ if command -v base64 >/dev/null 2>&1; then " + preferred + "; else " + fallback + "; fi; }"
+}
@@ -0,0 +1,68 @@
+package ssm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransferCommands(t *testing.T) {
+	tests := []struct {
+		name           string
+		cmds           TransferCommands
+		decode, encode string
+	}{
+		{"probed", TransferCommands{}, probeCommand("base64 -d", fallbackDecode), probeCommand("base64", fallbackEncode)},
+		{"decode override", TransferCommands{Decode: "busybox base64 -d"}, "busybox base64 -d", probeCommand("base64", fallbackEncode)},
+		{"encode override", TransferCommands{Encode: "b64enc"}, probeCommand("base64 -d", fallbackDecode), "b64enc"},
+		{"both", TransferCommands{Decode: "dec", Encode: "enc"}, "dec", "enc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cmds.decodeCommand(); got != tt.decode {
+				t.Errorf("decodeCommand() = %q, want %q", got, tt.decode)
+			}
+			if got := tt.cmds.encodeCommand(); got != tt.encode {
+				t.Errorf("encodeCommand() = %q, want %q", got, tt.encode)
+			}
+		})
+	}
+}
+
+func TestProbeCommandPicksAvailable(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh")
+	}
+	tests := []struct {
+		name  string
+		tools []string
+		want  string
+	}{
+		{"base64 present", []string{"base64", "openssl"}, "base64 -d"},
+		{"base64 missing", []string{"openssl"}, "openssl base64 -d -A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each fake tool prints how it was invoked instead of decoding.
+			dir := t.TempDir()
+			for _, tool := range tt.tools {
+				script := "#!" + sh + "\necho " + tool + ` "$@"` + "\n"
+				if err := os.WriteFile(filepath.Join(dir, tool), []byte(script), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cmd := exec.Command(sh, "-c", TransferCommands{}.decodeCommand())
+			cmd.Env = []string{"PATH=" + dir}
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("%v: %s", err, out)
+			}
+			if got := strings.TrimSpace(string(out)); got != tt.want {
+				t.Errorf("ran %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package ssm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// checksumScript prints the SHA-256 of path, with sha256sum or, where it
+// is missing (macOS, BSDs), shasum.
+func checksumScript(path string) string {
+	q := shellQuote(path)
+	return fmt.Sprintf("if command -v sha256sum >/dev/null 2>&1; then sha256sum -- %s; else shasum -a 256 -- %s; fi", q, q)
+}
+
+// verifyUpload compares the SHA-256 of payload with that of the uploaded
+// file. On a mismatch the remote file is left in place for inspection.
+func (c *Client) verifyUpload(ctx context.Context, instanceID, remotePath string, payload []byte) error {
+	sum := sha256.Sum256(payload)
+	expected := hex.EncodeToString(sum[:])
+	c.out.Debug("Verifying %s:%s against sha256 %s", instanceID, remotePath, expected)
+
+	result, err := c.runScript(ctx, instanceID, checksumScript(remotePath))
+	if err != nil {
+		return fmt.Errorf("failed to verify upload: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to verify upload (exit %d): %s (use --no-verify without sha256sum or shasum)",
+			result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	got, _, _ := strings.Cut(strings.TrimSpace(result.Stdout), " ")
+	if got != expected {
+		return fmt.Errorf("checksum mismatch: expected %s got %s (%s left in place)", expected, got, remotePath)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package ssm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksumScript(t *testing.T) {
+	want := "if command -v sha256sum >/dev/null 2>&1; then sha256sum -- '/tmp/a b'; else shasum -a 256 -- '/tmp/a b'; fi"
+	if got := checksumScript("/tmp/a b"); got != want {
+		t.Errorf("checksumScript() = %q, want %q", got, want)
+	}
+}
+
+func TestChecksumScriptRuns(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	_, errSum := exec.LookPath("sha256sum")
+	_, errShasum := exec.LookPath("shasum")
+	if errSum != nil && errShasum != nil {
+		t.Skip("neither sha256sum nor shasum available")
+	}
+	path := filepath.Join(t.TempDir(), "it's here")
+	content := []byte("payload\n")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	got, _, _ := strings.Cut(runShell(t, checksumScript(path)), " ")
+	if got != hex.EncodeToString(sum[:]) {
+		t.Errorf("checksum = %q, want %x", got, sum)
+	}
+}
@@ -0,0 +1,121 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+const (
+	remoteHostForwardDocument = "AWS-StartPortForwardingSessionToRemoteHost"
+
+	// tunnelReadyTimeout bounds how long we wait for the bastion tunnel to listen.
+	tunnelReadyTimeout = 20 * time.Second
+)
+
+// StartSSHVia opens an interactive SSH session to host through a bastion:
+// an SSM port forward from a free local port to host:22 via the bastion,
+// then ssh to that local port. It blocks until ssh exits.
+func (c *Client) StartSSHVia(ctx context.Context, bastionID, bastionName, host, hostName, profile, sshUser string) error {
+	sshPath, err := exec.LookPath("ssh")
+	if err != nil {
+		return fmt.Errorf("ssh not found (--via requires an OpenSSH client): %w", err)
+	}
+	pluginPath, err := lookPlugin()
+	if err != nil {
+		return err
+	}
+
+	localPort, err := freeLocalPort()
+	if err != nil {
+		return err
+	}
+
+	c.out.Info("Hop 1: SSM session to bastion %s", describeInstance(bastionID, bastionName))
+	sess, err := c.startPluginSession(ctx, &ssm.StartSessionInput{
+		Target:       aws.String(bastionID),
+		DocumentName: aws.String(remoteHostForwardDocument),
+		Parameters: map[string][]string{
+			"host":            {host},
+			"portNumber":      {"22"},
+			"localPortNumber": {strconv.Itoa(localPort)},
+		},
+	}, profile)
+	if err != nil {
+		return fmt.Errorf("hop 1 (bastion %s): %w", bastionID, err)
+	}
+
+	tunnel := exec.Command(pluginPath, sess.PluginArgs[1:]...)
+	if err := tunnel.Start(); err != nil {
+		return fmt.Errorf("hop 1 (bastion %s): failed to start session-manager-plugin: %w", bastionID, err)
+	}
+	defer func() {
+		_ = tunnel.Process.Kill()
+		_ = tunnel.Wait()
+	}()
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(localPort))
+	if err := waitForListener(ctx, addr, tunnelReadyTimeout); err != nil {
+		return fmt.Errorf("hop 1 (bastion %s): tunnel to %s:22 not ready: %w", bastionID, host, err)
+	}
+
+	c.out.Info("Hop 2: ssh %s@%s through %s", sshUser, describeInstance(host, hostName), addr)
+	args := []string{
+		"-p", strconv.Itoa(localPort),
+		"-o", "NoHostAuthenticationForLocalhost=yes",
+		sshUser + "@127.0.0.1",
+	}
+	c.out.Debug("Running: %s %v", sshPath, args)
+
+	cmd := exec.CommandContext(ctx, sshPath, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("hop 2 (ssh to %s): %w", host, err)
+	}
+	return nil
+}
+
+// freeLocalPort asks the OS for an unused TCP port on the loopback interface.
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free local port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// waitForListener polls until addr accepts TCP connections or timeout elapses.
+func waitForListener(ctx context.Context, addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+func describeInstance(id, name string) string {
+	if name == "" {
+		return id
+	}
+	return name + " (" + id + ")"
+}
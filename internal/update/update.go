@@ -0,0 +1,159 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+)
+
+const (
+	// OfflineEnv disables network access for update checks when set.
+	OfflineEnv = "AWS_SSM_CONNECT_OFFLINE"
+
+	releasesURL = "https://api.github.com/repos/eugenetaranov/aws-ssm-connect/releases/latest"
+	cacheFile   = "update-check.json"
+	cacheTTL    = 24 * time.Hour
+	timeout     = 5 * time.Second
+)
+
+// cache is the on-disk record of the last successful check.
+type cache struct {
+	Latest    string    `json:"latest"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Latest returns the newest release tag, using a cached answer younger than
+// a day. It fails without touching the network when offline is set.
+func Latest(ctx context.Context, offline bool) (string, error) {
+	path, _ := paths.File(cacheFile)
+	if c, ok := readCache(path); ok {
+		return c.Latest, nil
+	}
+	if offline || os.Getenv(OfflineEnv) != "" {
+		return "", fmt.Errorf("update check disabled (offline) and no recent cached result")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query latest release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to query latest release: %s", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("failed to parse latest release: %w", err)
+	}
+	if release.TagName == "" {
+		return "", fmt.Errorf("latest release has no tag")
+	}
+
+	if path != "" {
+		writeCache(path, cache{Latest: release.TagName, CheckedAt: time.Now()})
+	}
+	return release.TagName, nil
+}
+
+// CachePath returns the file caching the last update check.
+func CachePath() (string, error) {
+	return paths.File(cacheFile)
+}
+
+// Newer reports whether latest is a higher version than current. Versions
+// are dotted numbers with an optional "v" prefix; pre-release and build
+// suffixes are ignored. A non-numeric current version (e.g. "dev") is
+// never considered outdated.
+func Newer(current, latest string) bool {
+	cur, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	lat, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	for i := 0; i < max(len(cur), len(lat)); i++ {
+		var a, b int
+		if i < len(cur) {
+			a = cur[i]
+		}
+		if i < len(lat) {
+			b = lat[i]
+		}
+		if a != b {
+			return b > a
+		}
+	}
+	return false
+}
+
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return nil, false
+	}
+	parts := strings.Split(v, ".")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		nums[i] = n
+	}
+	return nums, true
+}
+
+func readCache(path string) (cache, bool) {
+	if path == "" {
+		return cache{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache{}, false
+	}
+	var c cache
+	if err := json.Unmarshal(data, &c); err != nil || c.Latest == "" {
+		return cache{}, false
+	}
+	if time.Since(c.CheckedAt) > cacheTTL {
+		return cache{}, false
+	}
+	return c, true
+}
+
+// writeCache saves the check result; failures only cost a future request.
+func writeCache(path string, c cache) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	_ = os.WriteFile(path, data, 0600)
+}
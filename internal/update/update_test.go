@@ -0,0 +1,78 @@
+package update
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/e/aws-ssm-connect/internal/paths"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		current, latest string
+		want            bool
+	}{
+		{"1.2.3", "1.2.4", true},
+		{"v1.2.3", "v1.3.0", true},
+		{"1.2.3", "v2.0.0", true},
+		{"1.2.3", "1.2.3", false},
+		{"v1.2.3", "1.2.3", false},
+		{"1.10.0", "1.9.9", false},
+		{"1.9.9", "1.10.0", true},
+		// Missing components count as zero
+		{"1.2", "1.2.0", false},
+		{"1.2", "1.2.1", true},
+		// Pre-release and build suffixes are ignored
+		{"1.2.3-rc1", "1.2.3", false},
+		{"1.2.3", "1.2.4+build.7", true},
+		// Unparseable versions never report an update
+		{"dev", "v9.9.9", false},
+		{"", "1.0.0", false},
+		{"1.0.0", "latest", false},
+		{"1.0.0", "v1.x", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.current, tt.latest); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %t, want %t", tt.current, tt.latest, got, tt.want)
+		}
+	}
+}
+
+func TestLatestUsesFreshCacheOffline(t *testing.T) {
+	tests := []struct {
+		name    string
+		cache   string
+		want    string
+		wantErr string
+	}{
+		{"fresh cache", `{"latest":"v1.4.0","checked_at":"` + time.Now().Add(-time.Hour).Format(time.RFC3339) + `"}`, "v1.4.0", ""},
+		{"stale cache", `{"latest":"v1.4.0","checked_at":"` + time.Now().Add(-25*time.Hour).Format(time.RFC3339) + `"}`, "", "offline"},
+		{"corrupt cache", `{`, "", "offline"},
+		{"no cache", "", "", "offline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv(paths.HomeEnv, dir)
+			if tt.cache != "" {
+				if err := os.WriteFile(filepath.Join(dir, cacheFile), []byte(tt.cache), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := Latest(context.Background(), true)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Latest() = %q, %v; want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Latest() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}